@@ -0,0 +1,31 @@
+package slogspy
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// AnnotationMessage is the message of the records carrying annotations (see Annotate).
+const AnnotationMessage = "annotation"
+
+// Annotate attaches a context marker (e.g., "deploy v1.2.3 started" or "feature flag X flipped") to the stream.
+// The annotation is rendered as a special record with the attributes nested into the "annotation" group:
+//
+//	{"time":"...","level":"INFO","msg":"annotation","annotation":{"deploy":"v1.2.3"}}
+//
+// Annotations are delivered to all the watchers regardless of filters and sampling (except for
+// request-scoped captures) and appear in the next flushed batch. Nothing is annotated if there are no watchers.
+func (h *SpyHandler) Annotate(attrs ...slog.Attr) {
+	if h.active.Load() == 0 || h.closed.Load() {
+		return
+	}
+
+	r := slog.NewRecord(time.Now(), slog.LevelInfo, AnnotationMessage, 0)
+	r.AddAttrs(slog.Attr{Key: AnnotationMessage, Value: slog.GroupValue(attrs...)})
+
+	entry := newEntry(r, nil, nil)
+	entry.annotation = true
+
+	h.enqueue(context.Background(), entry)
+}
@@ -0,0 +1,50 @@
+package slogspy
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"sync"
+	"testing"
+)
+
+func TestSpy__Annotate(t *testing.T) {
+	var mu sync.Mutex
+
+	buf := &bytes.Buffer{}
+	session := &bytes.Buffer{}
+
+	spy := NewSpy(slog.NewTextHandler(&bytes.Buffer{}, nil), WithFilter(WhereAttr("status", ">=", 500)))
+
+	// Annotations are skipped without watchers
+	spy.Annotate(slog.String("deploy", "v1.2.2"))
+
+	go spy.Run(context.Background(), func(msg []byte) { // nolint: errcheck
+		buf.Write(msg)
+	})
+
+	spy.Subscribe(func(msg []byte) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		session.Write(msg)
+	}, WithSubscriptionFilter(WhereAttr("user", "==", "admin")))
+
+	logger := slog.New(spy)
+
+	logger.Info("filtered", "status", 200)
+	spy.Annotate(slog.String("deploy", "v1.2.3"), slog.Bool("canary", true))
+
+	if err := spy.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	// Annotations bypass filters
+	assertBufferContains(t, buf, `"msg":"annotation","annotation":{"deploy":"v1.2.3","canary":true}`)
+	assertBufferContains(t, session, `"msg":"annotation","annotation":{"deploy":"v1.2.3","canary":true}`)
+	assertBufferContainsNot(t, buf, "filtered")
+	assertBufferContainsNot(t, buf, "v1.2.2")
+}
@@ -0,0 +1,519 @@
+package slogspy
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	defaultArchiveBlockSize = 1024 * 1024
+
+	archiveHeaderSize      = 8
+	archiveBlockHeaderSize = 4 + 8 + 8 + 4 + 4
+	archiveIndexEntrySize  = 8 + 8 + 8 + 4 + 4
+	archiveTrailerSize     = 8 + 8
+)
+
+var (
+	archiveMagic      = []byte("SLOGSPY1")
+	archiveBlockMagic = []byte("SSBK")
+	archiveIndexMagic = []byte("SSINDEX1")
+
+	// ErrArchiveClosed is returned when writing to a closed archive.
+	ErrArchiveClosed = errors.New("archive closed")
+)
+
+// ArchiveWriter writes flushed batches to a file as a sequence of independently zstd-compressed blocks
+// with the time index, so long captures could be queried by time without decompressing the whole session.
+// Use its Write method as a SpyOutput.
+type ArchiveWriter struct {
+	mu     sync.Mutex
+	file   *os.File
+	w      *bufio.Writer
+	offset int64
+	closed bool
+
+	enc       *zstd.Encoder
+	zbuf      []byte
+	blockSize int
+	block     bytes.Buffer
+	current   archiveBlock
+	index     []archiveBlock
+
+	errorHandler func(err error)
+}
+
+// archiveBlock describes a compressed block of batches written within the time range
+type archiveBlock struct {
+	offset int64
+	first  int64
+	last   int64
+	count  uint32
+	size   uint32
+}
+
+type ArchiveOption func(*ArchiveWriter)
+
+// WithArchiveBlockSize sets the uncompressed size of archive blocks (1MB by default);
+// smaller blocks make queries more precise at the cost of the compression ratio.
+func WithArchiveBlockSize(size int) ArchiveOption {
+	return func(a *ArchiveWriter) {
+		a.blockSize = size
+	}
+}
+
+// WithArchiveErrorHandler sets a function to be called with write errors.
+func WithArchiveErrorHandler(fn func(err error)) ArchiveOption {
+	return func(a *ArchiveWriter) {
+		a.errorHandler = fn
+	}
+}
+
+// CreateArchive creates (or truncates) the archive file.
+func CreateArchive(path string, opts ...ArchiveOption) (*ArchiveWriter, error) {
+	a := &ArchiveWriter{blockSize: defaultArchiveBlockSize}
+
+	for _, opt := range opts {
+		opt(a)
+	}
+
+	enc, err := zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1))
+
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.Create(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	a.enc = enc
+	a.file = file
+	a.w = bufio.NewWriter(file)
+
+	if _, err := a.w.Write(archiveMagic); err != nil {
+		file.Close() // nolint: errcheck
+		return nil, err
+	}
+
+	a.offset = archiveHeaderSize
+
+	return a, nil
+}
+
+// Write adds the batch to the archive; the batch is indexed by the current time.
+func (a *ArchiveWriter) Write(msg []byte) {
+	if err := a.WriteAt(time.Now(), msg); err != nil && a.errorHandler != nil {
+		a.errorHandler(err)
+	}
+}
+
+// WriteAt adds the batch to the archive indexing it by the specified time (times must not decrease).
+func (a *ArchiveWriter) WriteAt(ts time.Time, msg []byte) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.closed {
+		return ErrArchiveClosed
+	}
+
+	nanos := ts.UnixNano()
+
+	if a.current.count == 0 {
+		a.current.first = nanos
+	}
+
+	a.current.last = nanos
+	a.current.count++
+
+	a.block.Write(binary.AppendVarint(nil, nanos))
+	a.block.Write(binary.AppendUvarint(nil, uint64(len(msg))))
+	a.block.Write(msg)
+
+	if a.block.Len() >= a.blockSize {
+		return a.writeBlock()
+	}
+
+	return nil
+}
+
+// Flush writes the pending block to the file.
+func (a *ArchiveWriter) Flush() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.closed {
+		return ErrArchiveClosed
+	}
+
+	if err := a.writeBlock(); err != nil {
+		return err
+	}
+
+	return a.w.Flush()
+}
+
+// Close writes the pending block and the index, and closes the file.
+// An archive which hasn't been closed (e.g., due to a crash) is still readable, but opening it requires a full scan.
+func (a *ArchiveWriter) Close() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.closed {
+		return nil
+	}
+
+	a.closed = true
+
+	err := a.writeBlock()
+
+	if err == nil {
+		err = a.writeIndex()
+	}
+
+	if err == nil {
+		err = a.w.Flush()
+	}
+
+	a.enc.Close() // nolint: errcheck
+
+	return errors.Join(err, a.file.Close())
+}
+
+// writeBlock compresses and writes the current block; must be called with the lock held
+func (a *ArchiveWriter) writeBlock() error {
+	if a.current.count == 0 {
+		return nil
+	}
+
+	a.zbuf = a.enc.EncodeAll(a.block.Bytes(), a.zbuf[:0])
+
+	block := a.current
+	block.offset = a.offset
+	block.size = uint32(len(a.zbuf))
+
+	header := append([]byte(nil), archiveBlockMagic...)
+	header = appendArchiveBlock(header, block)
+
+	a.block.Reset()
+	a.current = archiveBlock{}
+
+	if _, err := a.w.Write(header); err != nil {
+		return err
+	}
+
+	if _, err := a.w.Write(a.zbuf); err != nil {
+		return err
+	}
+
+	a.offset += int64(archiveBlockHeaderSize + len(a.zbuf))
+	a.index = append(a.index, block)
+
+	return nil
+}
+
+func (a *ArchiveWriter) writeIndex() error {
+	buf := make([]byte, 0, len(a.index)*archiveIndexEntrySize+archiveTrailerSize)
+
+	for _, block := range a.index {
+		buf = binary.BigEndian.AppendUint64(buf, uint64(block.offset))
+		buf = appendArchiveBlock(buf, block)
+	}
+
+	buf = binary.BigEndian.AppendUint64(buf, uint64(a.offset))
+	buf = append(buf, archiveIndexMagic...)
+
+	_, err := a.w.Write(buf)
+
+	return err
+}
+
+// appendArchiveBlock appends the block time range, batches count and compressed size
+func appendArchiveBlock(buf []byte, block archiveBlock) []byte {
+	buf = binary.BigEndian.AppendUint64(buf, uint64(block.first))
+	buf = binary.BigEndian.AppendUint64(buf, uint64(block.last))
+	buf = binary.BigEndian.AppendUint32(buf, block.count)
+	buf = binary.BigEndian.AppendUint32(buf, block.size)
+
+	return buf
+}
+
+func parseArchiveBlock(data []byte) archiveBlock {
+	return archiveBlock{
+		first: int64(binary.BigEndian.Uint64(data[0:8])),
+		last:  int64(binary.BigEndian.Uint64(data[8:16])),
+		count: binary.BigEndian.Uint32(data[16:20]),
+		size:  binary.BigEndian.Uint32(data[20:24]),
+	}
+}
+
+// Archive is an archive opened for reading.
+type Archive struct {
+	file   *os.File
+	blocks []archiveBlock
+	dec    *zstd.Decoder
+}
+
+// OpenArchive opens the archive and loads its index (or rebuilds it by scanning the block headers
+// if the archive hasn't been closed properly; a truncated trailing block is ignored).
+func OpenArchive(path string) (*Archive, error) {
+	file, err := os.Open(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	blocks, err := readArchiveIndex(file)
+
+	if err != nil {
+		file.Close() // nolint: errcheck
+		return nil, err
+	}
+
+	dec, err := zstd.NewReader(nil, zstd.WithDecoderConcurrency(1))
+
+	if err != nil {
+		file.Close() // nolint: errcheck
+		return nil, err
+	}
+
+	return &Archive{file: file, blocks: blocks, dec: dec}, nil
+}
+
+func readArchiveIndex(file *os.File) ([]archiveBlock, error) {
+	info, err := file.Stat()
+
+	if err != nil {
+		return nil, err
+	}
+
+	size := info.Size()
+	header := make([]byte, archiveHeaderSize)
+
+	if _, err := file.ReadAt(header, 0); err != nil || !bytes.Equal(header, archiveMagic) {
+		return nil, errors.New("not a slog-spy archive")
+	}
+
+	if size >= archiveHeaderSize+archiveTrailerSize {
+		trailer := make([]byte, archiveTrailerSize)
+
+		if _, err := file.ReadAt(trailer, size-archiveTrailerSize); err != nil {
+			return nil, err
+		}
+
+		indexOffset := int64(binary.BigEndian.Uint64(trailer[:8]))
+		indexSize := size - archiveTrailerSize - indexOffset
+
+		if bytes.Equal(trailer[8:], archiveIndexMagic) && indexOffset >= archiveHeaderSize && indexSize >= 0 && indexSize%archiveIndexEntrySize == 0 {
+			data := make([]byte, indexSize)
+
+			if _, err := file.ReadAt(data, indexOffset); err != nil {
+				return nil, err
+			}
+
+			blocks := make([]archiveBlock, 0, indexSize/archiveIndexEntrySize)
+
+			for entry := data; len(entry) > 0; entry = entry[archiveIndexEntrySize:] {
+				block := parseArchiveBlock(entry[8:archiveIndexEntrySize])
+				block.offset = int64(binary.BigEndian.Uint64(entry[:8]))
+				blocks = append(blocks, block)
+			}
+
+			return blocks, nil
+		}
+	}
+
+	return scanArchiveBlocks(file, size)
+}
+
+func scanArchiveBlocks(file *os.File, size int64) ([]archiveBlock, error) {
+	var blocks []archiveBlock
+
+	header := make([]byte, archiveBlockHeaderSize)
+
+	for offset := int64(archiveHeaderSize); offset+archiveBlockHeaderSize <= size; {
+		if _, err := file.ReadAt(header, offset); err != nil {
+			return nil, err
+		}
+
+		if !bytes.Equal(header[:4], archiveBlockMagic) {
+			break
+		}
+
+		block := parseArchiveBlock(header[4:])
+		block.offset = offset
+
+		next := offset + archiveBlockHeaderSize + int64(block.size)
+
+		if next > size {
+			break
+		}
+
+		blocks = append(blocks, block)
+		offset = next
+	}
+
+	return blocks, nil
+}
+
+// Len returns the number of archived batches.
+func (a *Archive) Len() int {
+	n := 0
+
+	for _, block := range a.blocks {
+		n += int(block.count)
+	}
+
+	return n
+}
+
+// TimeRange returns the times of the first and the last archived batches.
+func (a *Archive) TimeRange() (time.Time, time.Time) {
+	if len(a.blocks) == 0 {
+		return time.Time{}, time.Time{}
+	}
+
+	return time.Unix(0, a.blocks[0].first), time.Unix(0, a.blocks[len(a.blocks)-1].last)
+}
+
+// Query calls the function for every batch written within the time range (inclusive; zero times mean no bound)
+// until it returns false. Only the blocks overlapping the range are decompressed.
+// The message must not be retained after the function returns.
+func (a *Archive) Query(from, to time.Time, fn func(ts time.Time, msg []byte) bool) error {
+	minTs, maxTs := archiveBounds(from, to)
+
+	// Blocks are ordered by time, so we can skip the ones ending before the range
+	start := sort.Search(len(a.blocks), func(i int) bool { return a.blocks[i].last >= minTs })
+
+	var compressed, data []byte
+
+	for _, block := range a.blocks[start:] {
+		if block.first > maxTs {
+			return nil
+		}
+
+		if cap(compressed) < int(block.size) {
+			compressed = make([]byte, block.size)
+		}
+
+		compressed = compressed[:block.size]
+
+		if _, err := a.file.ReadAt(compressed, block.offset+archiveBlockHeaderSize); err != nil {
+			return err
+		}
+
+		var err error
+
+		data, err = a.dec.DecodeAll(compressed, data[:0])
+
+		if err != nil {
+			return fmt.Errorf("block at %d: %w", block.offset, err)
+		}
+
+		for rest := data; len(rest) > 0; {
+			nanos, n := binary.Varint(rest)
+
+			if n <= 0 {
+				return fmt.Errorf("block at %d: invalid batch time", block.offset)
+			}
+
+			size, m := binary.Uvarint(rest[n:])
+
+			if m <= 0 || uint64(len(rest)-n-m) < size {
+				return fmt.Errorf("block at %d: invalid batch size", block.offset)
+			}
+
+			msg := rest[n+m : n+m+int(size)]
+			rest = rest[n+m+int(size):]
+
+			if nanos < minTs || nanos > maxTs {
+				continue
+			}
+
+			if !fn(time.Unix(0, nanos), msg) {
+				return nil
+			}
+		}
+	}
+
+	return nil
+}
+
+// Recording loads the records from the batches written within the time range (see LoadRecording),
+// so they could be replayed. Compressed batches are decompressed automatically.
+func (a *Archive) Recording(from, to time.Time) (*Recording, error) {
+	var (
+		buf bytes.Buffer
+		err error
+	)
+
+	qerr := a.Query(from, to, func(_ time.Time, msg []byte) bool {
+		if IsCompressed(msg) {
+			if msg, err = a.decompress(msg); err != nil {
+				return false
+			}
+		}
+
+		buf.Write(msg)
+
+		if len(msg) > 0 && msg[len(msg)-1] != '\n' {
+			buf.WriteByte('\n')
+		}
+
+		return true
+	})
+
+	if err = errors.Join(qerr, err); err != nil {
+		return nil, err
+	}
+
+	return LoadRecording(&buf)
+}
+
+// Close closes the archive file.
+func (a *Archive) Close() error {
+	a.dec.Close()
+
+	return a.file.Close()
+}
+
+func (a *Archive) decompress(msg []byte) ([]byte, error) {
+	if bytes.HasPrefix(msg, zstdMagic) {
+		return a.dec.DecodeAll(msg, nil)
+	}
+
+	r, err := gzip.NewReader(bytes.NewReader(msg))
+
+	if err != nil {
+		return nil, err
+	}
+
+	return io.ReadAll(r)
+}
+
+func archiveBounds(from, to time.Time) (int64, int64) {
+	minTs, maxTs := int64(-1<<63), int64(1<<63-1)
+
+	if !from.IsZero() {
+		minTs = from.UnixNano()
+	}
+
+	if !to.IsZero() {
+		maxTs = to.UnixNano()
+	}
+
+	return minTs, maxTs
+}
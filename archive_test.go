@@ -0,0 +1,172 @@
+package slogspy
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestArchive__Query(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "session.slogspy")
+
+	aw, err := CreateArchive(path, WithArchiveBlockSize(256))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	for i := 0; i < 100; i++ {
+		if err := aw.WriteAt(start.Add(time.Duration(i)*time.Second), []byte(fmt.Sprintf(`{"msg":"batch %d"}`+"\n", i))); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := aw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := aw.WriteAt(time.Now(), []byte("late")); err != ErrArchiveClosed {
+		t.Errorf("expected writes after close to fail, got: %v", err)
+	}
+
+	archive, err := OpenArchive(path)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer archive.Close() // nolint: errcheck
+
+	if len(archive.blocks) < 10 {
+		t.Errorf("expected multiple blocks, got %d", len(archive.blocks))
+	}
+
+	if archive.Len() != 100 {
+		t.Errorf("expected 100 batches, got %d", archive.Len())
+	}
+
+	if first, last := archive.TimeRange(); !first.Equal(start) || !last.Equal(start.Add(99*time.Second)) {
+		t.Errorf("unexpected time range: %v - %v", first, last)
+	}
+
+	var batches []string
+
+	err = archive.Query(start.Add(40*time.Second), start.Add(42*time.Second), func(ts time.Time, msg []byte) bool {
+		batches = append(batches, string(msg))
+		return true
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(batches) != 3 || batches[0] != `{"msg":"batch 40"}`+"\n" || batches[2] != `{"msg":"batch 42"}`+"\n" {
+		t.Errorf("unexpected batches: %v", batches)
+	}
+
+	rec, err := archive.Recording(start.Add(90*time.Second), time.Time{})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if rec.Len() != 10 || rec.Records()[0].Message != "batch 90" {
+		t.Errorf("expected to load 10 records starting from batch 90, got %d", rec.Len())
+	}
+}
+
+func TestArchive__Recovery(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "session.slogspy")
+
+	aw, err := CreateArchive(path, WithArchiveBlockSize(64))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Now()
+
+	for i := 0; i < 10; i++ {
+		aw.WriteAt(start.Add(time.Duration(i)*time.Millisecond), []byte(fmt.Sprintf(`{"msg":"batch %d","padding":"................................"}`, i))) // nolint: errcheck
+	}
+
+	if err := aw.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Simulate a crash in the middle of writing the last block
+	info, _ := os.Stat(path)
+
+	if err := os.Truncate(path, info.Size()-5); err != nil {
+		t.Fatal(err)
+	}
+
+	archive, err := OpenArchive(path)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer archive.Close() // nolint: errcheck
+
+	if archive.Len() != 9 {
+		t.Errorf("expected 9 batches to be recovered, got %d", archive.Len())
+	}
+
+	aw.file.Close() // nolint: errcheck
+}
+
+func TestArchive__Spy(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "session.slogspy")
+
+	aw, err := CreateArchive(path)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	spy := NewSpy(slog.NewTextHandler(io.Discard, nil), WithCompression(Zstd, 3))
+
+	go spy.Run(context.Background(), aw.Write) // nolint: errcheck
+
+	spy.Watch()
+
+	logger := slog.New(spy)
+
+	for i := 0; i < 5; i++ {
+		logger.Info("archived", "i", i)
+	}
+
+	if err := spy.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := aw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	archive, err := OpenArchive(path)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer archive.Close() // nolint: errcheck
+
+	rec, err := archive.Recording(time.Time{}, time.Time{})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if rec.Len() != 5 {
+		t.Errorf("expected 5 records, got %d", rec.Len())
+	}
+}
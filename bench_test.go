@@ -0,0 +1,122 @@
+package slogspy
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"testing"
+	"time"
+	_ "unsafe"
+)
+
+//go:linkname IgnorePC log/slog/internal.IgnorePC
+var IgnorePC = true
+
+func BenchmarkSpy(b *testing.B) {
+	handler := slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelError})
+	configs := []struct {
+		spy          bool
+		active       bool
+		ignorePC     bool
+		handlerDebug bool
+	}{
+		{true, true, false, false},
+		{true, true, true, false},
+		{true, false, false, false},
+		{true, false, true, false},
+		{false, false, false, false},
+		{false, false, false, true},
+		{false, false, true, false},
+		{false, false, true, true},
+	}
+
+	for _, config := range configs {
+		spyDesc := "no spy"
+
+		if config.spy {
+			spyDesc = "active spy"
+			if !config.active {
+				spyDesc = "inactive spy"
+			}
+		}
+
+		desc := fmt.Sprintf("%s ignorePC=%t", spyDesc, config.ignorePC)
+
+		if config.handlerDebug {
+			desc += " mainLevel=debug"
+		}
+
+		b.Run(desc, func(b *testing.B) {
+			if config.handlerDebug {
+				handlerBuf := &bytes.Buffer{}
+				handler = slog.NewTextHandler(handlerBuf, &slog.HandlerOptions{Level: slog.LevelDebug})
+			}
+
+			var h slog.Handler = handler
+
+			IgnorePC = config.ignorePC
+
+			if config.spy {
+				spy := NewSpy(handler)
+				go spy.Run(context.Background(), func(msg []byte) {
+					// immitate some work
+					time.Sleep(10 * time.Millisecond)
+				})
+				defer spy.Shutdown(context.Background())
+
+				if config.active {
+					spy.Watch()
+					defer spy.Unwatch()
+				}
+
+				h = spy
+			}
+
+			logger := slog.New(h)
+
+			b.ReportAllocs()
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				logger.Debug("test", "key", 1, "key2", "value2", "key3", 3.14)
+			}
+		})
+	}
+}
+
+func BenchmarkSpyHandler_Handle(b *testing.B) {
+	spy := NewSpyHandler(WithBacklogSize(1024 * 1024))
+	spy.Watch()
+
+	r := slog.NewRecord(time.Now(), slog.LevelDebug, "test", 0)
+	r.AddAttrs(slog.Int("key", 1), slog.String("key2", "value2"))
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		spy.Handle(context.Background(), r) // nolint: errcheck
+
+		// Emulate the Run loop processing entries
+		if i%1024 == 0 {
+			for len(spy.queue()) > 0 {
+				releaseEntry(<-spy.queue())
+			}
+		}
+	}
+}
+
+func BenchmarkWebsocketConn_enqueue(b *testing.B) {
+	c := &wsConn{send: make(chan wsMessage, 1), opcode: wsOpText, sub: &Subscription{}}
+	msg := bytes.Repeat([]byte("x"), 4096)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		c.enqueue(msg)
+		releasePayload((<-c.send).payload)
+	}
+}
@@ -0,0 +1,194 @@
+package slogspy
+
+import (
+	"bytes"
+	"errors"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// ErrSpyAttached is returned by Bus.Attach when a spy with the same name is already attached.
+var ErrSpyAttached = errors.New("spy with this name is already attached")
+
+// Bus is an in-process hub for multiple spies (e.g., different subsystems with different parent handlers):
+// attached spies publish batches tagged by their names, and bus subscriptions (or transports) receive them all.
+// Attached spies are active only while the bus has subscribers.
+type Bus struct {
+	// hub holds the bus subscriptions (it never runs its own loop)
+	hub *SpyHandler
+	tag func(name string, msg []byte) []byte
+
+	mu       sync.Mutex
+	members  map[string]*busMember
+	relaying bool
+
+	// deliverMu serializes deliveries from different spies
+	deliverMu sync.Mutex
+}
+
+type busMember struct {
+	name  string
+	spy   *Spy
+	relay *Subscription
+}
+
+var _ subscriber = (*Bus)(nil)
+
+type BusOption func(*Bus)
+
+// WithBusTagger sets a function to tag batches with the spy name (see TagBatch for the default behavior).
+func WithBusTagger(fn func(name string, msg []byte) []byte) BusOption {
+	return func(b *Bus) {
+		b.tag = fn
+	}
+}
+
+// NewBus creates a new bus.
+func NewBus(opts ...BusOption) *Bus {
+	b := &Bus{
+		hub:     NewSpyHandler(),
+		tag:     TagBatch,
+		members: make(map[string]*busMember),
+	}
+
+	b.hub.onWatchers = b.sync
+
+	for _, opt := range opts {
+		opt(b)
+	}
+
+	return b
+}
+
+// Attach adds the spy to the bus under the specified name.
+func (b *Bus) Attach(name string, spy *Spy) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.members[name]; ok {
+		return ErrSpyAttached
+	}
+
+	m := &busMember{name: name, spy: spy}
+	b.members[name] = m
+
+	if b.relaying {
+		b.startRelay(m)
+	}
+
+	return nil
+}
+
+// Detach removes the spy from the bus.
+func (b *Bus) Detach(name string) {
+	b.mu.Lock()
+	m, ok := b.members[name]
+	delete(b.members, name)
+	b.mu.Unlock()
+
+	if ok && m.relay != nil {
+		m.relay.Close()
+	}
+}
+
+// Spies returns the names of the attached spies.
+func (b *Bus) Spies() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	names := make([]string, 0, len(b.members))
+	for name := range b.members {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// Subscribe registers a new bus subscription receiving tagged batches from all the attached spies.
+// All the subscription options are supported.
+func (b *Bus) Subscribe(out SpyOutput, opts ...SubscriptionOption) *Subscription {
+	sub := b.newSubscription(out, opts...)
+	b.register(sub)
+
+	return sub
+}
+
+// WebsocketHandler creates a new WebsocketHandler streaming batches from all the attached spies.
+func (b *Bus) WebsocketHandler(opts ...WebsocketOption) *WebsocketHandler {
+	return newWebsocketHandler(b, opts...)
+}
+
+func (b *Bus) newSubscription(out SpyOutput, opts ...SubscriptionOption) *Subscription {
+	return b.hub.newSubscription(out, opts...)
+}
+
+func (b *Bus) register(sub *Subscription) {
+	b.hub.register(sub)
+}
+
+// sync starts or stops relaying from the attached spies depending on the number of bus subscribers
+func (b *Bus) sync() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	active := b.hub.active.Load() > 0
+
+	if active == b.relaying {
+		return
+	}
+
+	b.relaying = active
+
+	for _, m := range b.members {
+		if active {
+			b.startRelay(m)
+		} else if m.relay != nil {
+			m.relay.Close()
+			m.relay = nil
+		}
+	}
+}
+
+func (b *Bus) startRelay(m *busMember) {
+	name := m.name
+
+	m.relay = m.spy.Subscribe(func(msg []byte) {
+		b.deliverMu.Lock()
+		defer b.deliverMu.Unlock()
+
+		b.hub.subs.deliver(b.tag(name, msg), bytes.Count(msg, []byte{'\n'}))
+	})
+}
+
+// TagBatch tags every line of the batch with the spy name: JSON objects get the "spy" field,
+// other lines are prefixed with "[name] ".
+func TagBatch(name string, msg []byte) []byte {
+	field := append(append([]byte(`{"spy":`), strconv.Quote(name)...), ',')
+	prefix := []byte("[" + name + "] ")
+
+	tagged := make([]byte, 0, len(msg)+len(field)*4)
+
+	for len(msg) > 0 {
+		line := msg
+		rest := []byte(nil)
+
+		if i := bytes.IndexByte(msg, '\n'); i >= 0 {
+			line, rest = msg[:i+1], msg[i+1:]
+		}
+
+		if len(line) > 1 && line[0] == '{' && line[1] != '}' {
+			tagged = append(tagged, field...)
+			tagged = append(tagged, line[1:]...)
+		} else {
+			tagged = append(tagged, prefix...)
+			tagged = append(tagged, line...)
+		}
+
+		msg = rest
+	}
+
+	return tagged
+}
@@ -0,0 +1,77 @@
+package slogspy
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestBus(t *testing.T) {
+	api := NewSpy(slog.NewTextHandler(io.Discard, nil), WithFlushInterval(10*time.Millisecond))
+	worker := NewSpy(slog.NewTextHandler(io.Discard, nil), WithFlushInterval(10*time.Millisecond), WithPrinter(func(w io.Writer) slog.Handler {
+		return slog.NewTextHandler(w, &slog.HandlerOptions{Level: slog.LevelDebug})
+	}))
+
+	for _, spy := range []*Spy{api, worker} {
+		go spy.Run(context.Background(), nil)    // nolint: errcheck
+		defer spy.Shutdown(context.Background()) // nolint: errcheck
+	}
+
+	bus := NewBus()
+
+	if err := bus.Attach("api", api); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := bus.Attach("api", worker); err != ErrSpyAttached {
+		t.Errorf("expected duplicate name error, got %v", err)
+	}
+
+	bus.Attach("worker", worker) // nolint: errcheck
+
+	if api.handler.active.Load() != 0 || worker.handler.active.Load() != 0 {
+		t.Fatal("expected spies to be inactive without bus subscribers")
+	}
+
+	var mu sync.Mutex
+	output := &bytes.Buffer{}
+
+	sub := bus.Subscribe(func(msg []byte) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		output.Write(msg)
+	})
+
+	waitFor(t, func() bool { return api.handler.active.Load() == 1 && worker.handler.active.Load() == 1 })
+
+	slog.New(api).Info("api-log")
+	slog.New(worker).Info("worker-log")
+
+	waitFor(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+
+		return bytes.Contains(output.Bytes(), []byte(`{"spy":"api","time"`)) &&
+			bytes.Contains(output.Bytes(), []byte("[worker] time="))
+	})
+
+	sub.Close()
+
+	if api.handler.active.Load() != 0 || worker.handler.active.Load() != 0 {
+		t.Error("expected spies to be deactivated when the bus has no subscribers")
+	}
+}
+
+func TestTagBatch(t *testing.T) {
+	tagged := TagBatch("api", []byte("{\"msg\":\"a\"}\nlevel=INFO msg=b\n{}\n"))
+	expected := "{\"spy\":\"api\",\"msg\":\"a\"}\n[api] level=INFO msg=b\n[api] {}\n"
+
+	if string(tagged) != expected {
+		t.Errorf("expected %q, got %q", expected, tagged)
+	}
+}
@@ -0,0 +1,123 @@
+package slogspy
+
+import (
+	"context"
+	"time"
+)
+
+// captureFlushTimeout limits the time we wait for the final capture flush
+const captureFlushTimeout = time.Second
+
+// CaptureStats contains the number of records and bytes delivered during a capture.
+type CaptureStats struct {
+	Records int64 `json:"records"`
+	Bytes   int64 `json:"bytes"`
+}
+
+// CaptureFor streams log records to the output for the specified duration (or until the context is canceled).
+// See CaptureUntil.
+func (h *SpyHandler) CaptureFor(ctx context.Context, d time.Duration, out SpyOutput) CaptureStats {
+	ctx, cancel := context.WithTimeout(ctx, d)
+	defer cancel()
+
+	return h.CaptureUntil(ctx, out)
+}
+
+// CaptureUntil activates watching and streams log records to the output until the context is canceled
+// (or the spy is shut down). Then, it performs the final flush, deactivates watching and returns the number
+// of captured records and bytes. The Run loop must be running for the records to be delivered.
+func (h *SpyHandler) CaptureUntil(ctx context.Context, out SpyOutput) CaptureStats {
+	sub := h.Subscribe(out)
+
+	select {
+	case <-ctx.Done():
+		h.flushSync()
+	case <-sub.Context().Done():
+	}
+
+	sub.Close()
+
+	return CaptureStats{Records: sub.records.Load(), Bytes: sub.bytes.Load()}
+}
+
+// flushSync requests a flush and waits for it to be processed by the Run loop
+func (h *SpyHandler) flushSync() {
+	if h.closed.Load() {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), captureFlushTimeout)
+	defer cancel()
+
+	h.requestFlush(ctx, nil) // nolint: errcheck
+}
+
+// requestFlush sends the flush command (targeted at the subscription if provided) and waits for it to be processed
+func (h *SpyHandler) requestFlush(ctx context.Context, target *Subscription) error {
+	entry := &Entry{cmd: SpyCommandFlush, target: target, flushed: make(chan struct{})}
+
+	select {
+	case h.ctrl <- entry:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	select {
+	case <-entry.flushed:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+type captureContextKey struct{}
+
+// CaptureContext returns a context to log records with (via the *Context logger methods) and the request-scoped
+// subscription receiving only the records logged with this context (e.g., within an HTTP request).
+// Call sub.Flush(ctx) to make sure all the records logged so far are delivered (read-your-writes).
+// The subscription is closed when the parent context is done; like keyed watchers, it doesn't activate the spy's output.
+func (h *SpyHandler) CaptureContext(parent context.Context, out SpyOutput, opts ...SubscriptionOption) (context.Context, *Subscription) {
+	sub := h.newSubscription(out, append(opts, withRequestScope())...)
+	h.register(sub)
+
+	stop := context.AfterFunc(parent, func() { sub.closeWithCause(context.Cause(parent)) })
+
+	// Don't keep the subscription referenced by long-lived parent contexts once it's closed
+	sub.timerMu.Lock()
+	sub.stopParent = stop
+	closed := sub.Closed()
+	sub.timerMu.Unlock()
+
+	if closed {
+		stop()
+	}
+
+	return context.WithValue(parent, captureContextKey{}, sub), sub
+}
+
+func withRequestScope() SubscriptionOption {
+	return func(s *Subscription) {
+		s.requestScoped = true
+	}
+}
+
+// captureFromContext returns the request-scoped subscription of this spy for the context (if any)
+func (h *SpyHandler) captureFromContext(ctx context.Context) *Subscription {
+	if ctx == nil {
+		return nil
+	}
+
+	sub, ok := ctx.Value(captureContextKey{}).(*Subscription)
+
+	if !ok || sub.handler.subs != h.subs {
+		return nil
+	}
+
+	return sub
+}
+
+func (e *Entry) ack() {
+	if e.flushed != nil {
+		close(e.flushed)
+	}
+}
@@ -0,0 +1,158 @@
+package slogspy
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSpy__CaptureFor(t *testing.T) {
+	spy := NewSpy(slog.NewTextHandler(&bytes.Buffer{}, nil), WithFlushInterval(time.Hour))
+
+	go spy.Run(context.Background(), nil)    // nolint: errcheck
+	defer spy.Shutdown(context.Background()) // nolint: errcheck
+
+	waitForRunning(t, spy)
+
+	var mu sync.Mutex
+	captured := &bytes.Buffer{}
+
+	logger := slog.New(spy)
+
+	go func() {
+		waitFor(t, func() bool { return spy.handler.active.Load() == 1 })
+
+		logger.Info("first")
+		logger.Info("second")
+	}()
+
+	stats := spy.CaptureFor(context.Background(), 100*time.Millisecond, func(msg []byte) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		captured.Write(msg)
+	})
+
+	if stats.Records != 2 {
+		t.Errorf("expected 2 records, got %d", stats.Records)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if stats.Bytes != int64(captured.Len()) {
+		t.Errorf("expected %d bytes, got %d", captured.Len(), stats.Bytes)
+	}
+
+	assertBufferContains(t, captured, "first")
+	assertBufferContains(t, captured, "second")
+
+	if spy.handler.active.Load() != 0 {
+		t.Errorf("expected watching to be deactivated")
+	}
+}
+
+func TestSpy__CaptureUntil_Shutdown(t *testing.T) {
+	spy := NewSpy(slog.NewTextHandler(&bytes.Buffer{}, nil))
+
+	go spy.Run(context.Background(), nil) // nolint: errcheck
+
+	waitForRunning(t, spy)
+
+	go func() {
+		waitFor(t, func() bool { return spy.handler.active.Load() == 1 })
+		spy.Shutdown(context.Background()) // nolint: errcheck
+	}()
+
+	stats := spy.CaptureUntil(context.Background(), func(msg []byte) {})
+
+	if stats.Records != 0 {
+		t.Errorf("expected no records, got %d", stats.Records)
+	}
+}
+
+func TestSpy__CaptureContext(t *testing.T) {
+	spy := NewSpy(slog.NewTextHandler(&bytes.Buffer{}, nil), WithFlushInterval(time.Hour))
+
+	go spy.Run(context.Background(), nil)    // nolint: errcheck
+	defer spy.Shutdown(context.Background()) // nolint: errcheck
+
+	waitForRunning(t, spy)
+
+	// Captured records are written synchronously during the flush
+	captured := &bytes.Buffer{}
+	global := &bytes.Buffer{}
+
+	spy.Subscribe(func(msg []byte) { global.Write(msg) })
+
+	reqCtx, cancel := context.WithCancel(context.Background())
+
+	ctx, sub := spy.CaptureContext(reqCtx, func(msg []byte) { captured.Write(msg) })
+
+	logger := slog.New(spy)
+
+	logger.InfoContext(ctx, "in-request")
+	logger.Info("outside")
+
+	flushCtx, flushCancel := context.WithTimeout(context.Background(), time.Second)
+	defer flushCancel()
+
+	if err := sub.Flush(flushCtx); err != nil {
+		t.Fatalf("flush failed: %v", err)
+	}
+
+	assertBufferContains(t, captured, "in-request")
+	assertBufferContainsNot(t, captured, "outside")
+
+	// The targeted flush doesn't flush the spy's batch
+	if global.Len() != 0 {
+		t.Errorf("expected global batch to not be flushed, got %q", global.String())
+	}
+
+	cancel()
+
+	waitFor(t, sub.Closed)
+
+	if err := sub.Flush(flushCtx); err != ErrSubscriptionClosed {
+		t.Errorf("expected ErrSubscriptionClosed, got %v", err)
+	}
+}
+
+func TestSpy__CaptureContext_Close(t *testing.T) {
+	spy := NewSpy(slog.NewTextHandler(&bytes.Buffer{}, nil))
+
+	parent, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	_, sub := spy.CaptureContext(parent, func([]byte) {})
+
+	sub.Close()
+
+	// The parent context callback must be unregistered on close
+	if sub.stopParent() {
+		t.Error("expected the parent context callback to be stopped")
+	}
+
+	cancel()
+
+	if cause := context.Cause(sub.Context()); cause != ErrSubscriptionClosed {
+		t.Errorf("expected the subscription to be closed explicitly, got: %v", cause)
+	}
+}
+
+func TestSubscription__Flush_deadline(t *testing.T) {
+	spy := NewSpy(slog.NewTextHandler(&bytes.Buffer{}, nil))
+
+	_, sub := spy.CaptureContext(context.Background(), func([]byte) {})
+
+	// The Run loop is not running, so the flush can't complete
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	if err := sub.Flush(ctx); err != context.DeadlineExceeded {
+		t.Errorf("expected deadline exceeded, got %v", err)
+	}
+}
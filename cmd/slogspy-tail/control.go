@@ -0,0 +1,145 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	slogspy "github.com/palkan/slog-spy"
+)
+
+const controlRestoreTimeout = 5 * time.Second
+
+// remoteControl is a client of the spy's ControlHandler
+type remoteControl struct {
+	base   string
+	client *http.Client
+}
+
+// apply changes the spy level and filter and starts watching for the duration via the control API;
+// the returned function restores the previous state (the changes are applied to the whole spy, not only to our stream)
+func (c *remoteControl) apply(ctx context.Context, opts *options) (func(context.Context) error, error) {
+	prev, err := c.do(ctx, http.MethodGet, "/status", nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var undo []func(context.Context) error
+
+	restore := func(ctx context.Context) error {
+		var errs []error
+
+		for i := len(undo) - 1; i >= 0; i-- {
+			errs = append(errs, undo[i](ctx))
+		}
+
+		return errors.Join(errs...)
+	}
+
+	// Roll back the changes applied so far if any of the requests fails
+	fail := func(err error) (func(context.Context) error, error) {
+		rctx, cancel := context.WithTimeout(context.Background(), controlRestoreTimeout)
+		defer cancel()
+
+		restore(rctx) // nolint: errcheck
+
+		return nil, err
+	}
+
+	if opts.level != "" {
+		if _, err := c.do(ctx, http.MethodPost, "/level", url.Values{"level": {opts.level}}); err != nil {
+			return fail(err)
+		}
+
+		undo = append(undo, c.restoreFunc("/level", "level", prev.Level))
+	}
+
+	if opts.filter != "" {
+		if _, err := c.do(ctx, http.MethodPost, "/filter", url.Values{"expr": {opts.filter}}); err != nil {
+			return fail(err)
+		}
+
+		undo = append(undo, c.restoreFunc("/filter", "expr", prev.Filter))
+	}
+
+	// Re-watching would replace the existing watcher, so we only watch if no one does
+	if opts.duration > 0 && !prev.Watching {
+		if _, err := c.do(ctx, http.MethodPost, "/watch", url.Values{"ttl": {opts.duration.String()}}); err != nil {
+			return fail(err)
+		}
+
+		undo = append(undo, func(ctx context.Context) error {
+			_, err := c.do(ctx, http.MethodPost, "/unwatch", nil)
+			return err
+		})
+	}
+
+	return restore, nil
+}
+
+// restoreFunc returns a function setting the previous value back (or removing the value if there was none)
+func (c *remoteControl) restoreFunc(path string, param string, prev string) func(context.Context) error {
+	return func(ctx context.Context) error {
+		var err error
+
+		if prev == "" {
+			_, err = c.do(ctx, http.MethodDelete, path, nil)
+		} else {
+			_, err = c.do(ctx, http.MethodPost, path, url.Values{param: {prev}})
+		}
+
+		return err
+	}
+}
+
+func (c *remoteControl) do(ctx context.Context, method string, path string, params url.Values) (*slogspy.ControlStatus, error) {
+	var body io.Reader
+
+	if params != nil {
+		body = strings.NewReader(params.Encode())
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimSuffix(c.base, "/")+path, body)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if params != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+
+	resp, err := c.client.Do(req)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("control %s %s failed: %s %s", method, path, resp.Status, bytes.TrimSpace(data))
+	}
+
+	var status slogspy.ControlStatus
+
+	if err := json.Unmarshal(data, &status); err != nil {
+		return nil, fmt.Errorf("control %s %s: invalid response: %w", method, path, err)
+	}
+
+	return &status, nil
+}
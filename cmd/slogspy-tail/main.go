@@ -0,0 +1,216 @@
+// Command slogspy-tail streams the spied logs from the server-sent events, WebSocket or gRPC endpoint exposed
+// by the spy and pretty-prints them:
+//
+//	slogspy-tail --level warn --filter 'attrs.tenant=="acme"' --duration 5m wss://app.example.com/logs
+//	slogspy-tail --level debug grpcs://node:50051
+//	slogspy-tail --control https://app.example.com/spy --level debug https://app.example.com/logs/sse
+//
+// HTTP(S) URLs are tailed as server-sent event streams; gRPC requires TLS (the grpcs scheme).
+// With --control, the level, filter and duration are applied via the spy's control API instead of the stream request.
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/palkan/slog-spy/grpcspy"
+)
+
+type options struct {
+	level    string
+	filter   string
+	duration time.Duration
+	control  string
+	headers  http.Header
+	insecure bool
+	color    bool
+	raw      bool
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	os.Exit(run(ctx, os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(ctx context.Context, args []string, stdout io.Writer, stderr io.Writer) int {
+	opts, target, err := parseFlags(args, stderr)
+
+	if errors.Is(err, flag.ErrHelp) {
+		return 0
+	}
+
+	if err != nil {
+		return 2
+	}
+
+	if opts.duration > 0 {
+		var cancel context.CancelFunc
+
+		ctx, cancel = context.WithTimeout(ctx, opts.duration)
+		defer cancel()
+	}
+
+	p := &printer{w: stdout, color: opts.color, raw: opts.raw}
+
+	streamOpts := opts
+
+	if opts.control != "" {
+		restore, err := applyControl(ctx, opts)
+
+		if err != nil {
+			fmt.Fprintf(stderr, "slogspy-tail: %v\n", err)
+			return 1
+		}
+
+		defer func() {
+			rctx, cancel := context.WithTimeout(context.Background(), controlRestoreTimeout)
+			defer cancel()
+
+			if err := restore(rctx); err != nil {
+				fmt.Fprintf(stderr, "slogspy-tail: failed to restore the spy state: %v\n", err)
+			}
+		}()
+
+		// The spy applies the level and filter to all the records, so the stream doesn't need them
+		streamOpts = &options{}
+		*streamOpts = *opts
+		streamOpts.level, streamOpts.filter = "", ""
+	}
+
+	err = tail(ctx, target, streamOpts, p.printBatch)
+
+	// Interrupting the tool or reaching the duration is the expected way to stop it
+	if err != nil && ctx.Err() == nil {
+		fmt.Fprintf(stderr, "slogspy-tail: %v\n", err)
+		return 1
+	}
+
+	return 0
+}
+
+func parseFlags(args []string, stderr io.Writer) (*options, string, error) {
+	opts := &options{headers: http.Header{}}
+
+	fs := flag.NewFlagSet("slogspy-tail", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: slogspy-tail [flags] <http(s)://... | ws(s)://... | grpcs://...>")
+		fs.PrintDefaults()
+	}
+
+	noColor := false
+
+	fs.StringVar(&opts.level, "level", "", "minimum level of the records (e.g., debug, warn)")
+	fs.StringVar(&opts.filter, "filter", "", "filter expression (e.g., 'attrs.user_id==\"42\"')")
+	fs.DurationVar(&opts.duration, "duration", 0, "stop tailing after the duration (e.g., 5m)")
+	fs.StringVar(&opts.control, "control", "", "control API URL to apply the level, filter and duration to the spy (e.g., https://app/spy)")
+	fs.BoolVar(&opts.insecure, "insecure", false, "skip TLS certificate verification")
+	fs.BoolVar(&noColor, "no-color", false, "disable colors")
+	fs.BoolVar(&opts.raw, "raw", false, "print the batches as is")
+	fs.Func("header", "request header (e.g., 'Authorization: Bearer <token>'); could be repeated", func(value string) error {
+		name, val, ok := strings.Cut(value, ":")
+
+		if !ok {
+			return fmt.Errorf("invalid header: %q", value)
+		}
+
+		opts.headers.Add(strings.TrimSpace(name), strings.TrimSpace(val))
+
+		return nil
+	})
+
+	if err := fs.Parse(args); err != nil {
+		return nil, "", err
+	}
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return nil, "", errors.New("endpoint URL is required")
+	}
+
+	opts.color = !noColor && os.Getenv("NO_COLOR") == "" && isTerminal(os.Stdout)
+
+	return opts, fs.Arg(0), nil
+}
+
+// tail streams the batches from the endpoint until the context is done or the server ends the stream
+func tail(ctx context.Context, target string, opts *options, fn func(batch []byte)) error {
+	u, err := url.Parse(target)
+
+	if err != nil {
+		return err
+	}
+
+	tlsConfig := &tls.Config{InsecureSkipVerify: opts.insecure} // nolint: gosec
+
+	switch u.Scheme {
+	case "http", "https":
+		return tailEventStream(ctx, u, opts, tlsConfig, fn)
+	case "ws", "wss":
+		return tailWebsocket(ctx, u, opts, tlsConfig, fn)
+	case "grpcs":
+		return tailGRPC(ctx, u, opts, tlsConfig, fn)
+	case "grpc":
+		return errors.New("plaintext gRPC is not supported, use grpcs://")
+	default:
+		return fmt.Errorf("unsupported scheme: %q", u.Scheme)
+	}
+}
+
+// applyControl applies the level, filter and duration via the control API and returns the function restoring
+// the previous state
+func applyControl(ctx context.Context, opts *options) (func(context.Context) error, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: opts.insecure} // nolint: gosec
+
+	c := &remoteControl{
+		base:   opts.control,
+		client: &http.Client{Transport: &headerTransport{headers: opts.headers, next: &http.Transport{TLSClientConfig: tlsConfig}}},
+	}
+
+	return c.apply(ctx, opts)
+}
+
+func tailGRPC(ctx context.Context, u *url.URL, opts *options, tlsConfig *tls.Config, fn func(batch []byte)) error {
+	transport := &http.Transport{TLSClientConfig: tlsConfig, ForceAttemptHTTP2: true}
+	defer transport.CloseIdleConnections()
+
+	client := &http.Client{Transport: &headerTransport{headers: opts.headers, next: transport}}
+
+	req := grpcspy.TailRequest{Level: opts.level, Filter: opts.filter, TTL: opts.duration}
+
+	return grpcspy.Tail(ctx, client, "https://"+u.Host, req, fn)
+}
+
+// headerTransport adds the custom headers to the requests
+type headerTransport struct {
+	headers http.Header
+	next    http.RoundTripper
+}
+
+func (t *headerTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	r = r.Clone(r.Context())
+
+	for name, values := range t.headers {
+		r.Header[name] = values
+	}
+
+	return t.next.RoundTrip(r)
+}
+
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/palkan/slog-spy/grpcspy"
+)
+
+func TestRun__GRPC(t *testing.T) {
+	spy := newTestSpy(t)
+
+	server := httptest.NewUnstartedServer(grpcspy.NewServer(spy))
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	defer server.Close()
+
+	var stdout, stderr bytes.Buffer
+
+	done := make(chan int, 1)
+
+	go func() {
+		args := []string{"--level", "error", "--duration", "300ms", "--insecure", "--no-color", "grpcs://" + server.Listener.Addr().String()}
+		done <- run(context.Background(), args, &stdout, &stderr)
+	}()
+
+	waitFor(t, func() bool { return spy.Stats().Watchers == 1 })
+
+	logger := slog.New(spy)
+	logger.Warn("retrying")
+	logger.Error("payment failed", "user", "jack")
+
+	if code := <-done; code != 0 {
+		t.Fatalf("expected the tool to exit with 0 once the duration passes, got %d: %s", code, stderr.String())
+	}
+
+	if out := stdout.String(); !strings.Contains(out, "ERROR payment failed user=jack") || strings.Contains(out, "retrying") {
+		t.Errorf("expected the error record to be printed, got: %s", out)
+	}
+}
+
+func TestRun__Usage(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+
+	if code := run(context.Background(), nil, &stdout, &stderr); code != 2 {
+		t.Errorf("expected the usage error, got %d", code)
+	}
+
+	if code := run(context.Background(), []string{"ftp://example.com"}, &stdout, &stderr); code != 1 {
+		t.Errorf("expected the unsupported scheme error, got %d", code)
+	}
+
+	if !strings.Contains(stderr.String(), `unsupported scheme: "ftp"`) {
+		t.Errorf("expected the error to be reported, got: %s", stderr.String())
+	}
+}
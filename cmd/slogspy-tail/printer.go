@@ -0,0 +1,219 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"strconv"
+	"strings"
+
+	"github.com/klauspost/compress/zstd"
+	slogspy "github.com/palkan/slog-spy"
+)
+
+const (
+	colorReset  = "\033[0m"
+	colorBold   = "\033[1m"
+	colorDim    = "\033[2m"
+	colorRed    = "\033[31m"
+	colorGreen  = "\033[32m"
+	colorYellow = "\033[33m"
+	colorBlue   = "\033[34m"
+	colorCyan   = "\033[36m"
+)
+
+// printer pretty-prints the received batches (NDJSON records, batch envelopes, protobuf batches and control messages);
+// lines which are not JSON records (e.g., produced by the text printer) are printed as is
+type printer struct {
+	w     io.Writer
+	color bool
+	raw   bool
+
+	buf bytes.Buffer
+}
+
+func (p *printer) printBatch(batch []byte) {
+	if slogspy.IsCompressed(batch) {
+		data, err := decompress(batch)
+
+		if err != nil {
+			fmt.Fprintf(p.w, "slogspy-tail: failed to decompress batch: %v\n", err)
+			return
+		}
+
+		batch = data
+	}
+
+	if p.raw {
+		p.w.Write(batch) // nolint: errcheck
+		return
+	}
+
+	p.buf.Reset()
+
+	if slogspy.IsProtobuf(batch) {
+		if records, err := slogspy.DecodeProtobufBatch(batch); err == nil {
+			for _, r := range records {
+				p.printRecord(r)
+			}
+
+			p.w.Write(p.buf.Bytes()) // nolint: errcheck
+			return
+		}
+	}
+
+	for _, line := range bytes.Split(batch, []byte("\n")) {
+		if line = bytes.TrimSpace(line); len(line) > 0 {
+			p.printLine(line)
+		}
+	}
+
+	p.w.Write(p.buf.Bytes()) // nolint: errcheck
+}
+
+func (p *printer) printLine(line []byte) {
+	if line[0] != '{' {
+		p.buf.Write(line)
+		p.buf.WriteByte('\n')
+		return
+	}
+
+	var control struct {
+		Type  string `json:"type"`
+		Event string `json:"event"`
+	}
+
+	if json.Unmarshal(line, &control) == nil && control.Type == "control" {
+		p.paint(colorDim, "--- "+control.Event+" "+string(line))
+		p.buf.WriteByte('\n')
+		return
+	}
+
+	rec, err := slogspy.LoadRecording(bytes.NewReader(line))
+
+	if err != nil {
+		p.buf.Write(line)
+		p.buf.WriteByte('\n')
+		return
+	}
+
+	for _, r := range rec.Records() {
+		p.printRecord(r)
+	}
+}
+
+func (p *printer) printRecord(r slog.Record) {
+	if !r.Time.IsZero() {
+		p.paint(colorDim, r.Time.Format("15:04:05.000"))
+		p.buf.WriteByte(' ')
+	}
+
+	level := fmt.Sprintf("%-5s", r.Level.String())
+
+	p.paint(levelColor(r.Level), level)
+	p.buf.WriteByte(' ')
+
+	if r.Level >= slog.LevelError {
+		p.paint(colorBold, r.Message)
+	} else {
+		p.buf.WriteString(r.Message)
+	}
+
+	r.Attrs(func(a slog.Attr) bool {
+		p.printAttr("", a)
+		return true
+	})
+
+	p.buf.WriteByte('\n')
+}
+
+func (p *printer) printAttr(prefix string, a slog.Attr) {
+	key := a.Key
+
+	if prefix != "" {
+		key = prefix + "." + key
+	}
+
+	if a.Value.Kind() == slog.KindGroup {
+		for _, nested := range a.Value.Group() {
+			p.printAttr(key, nested)
+		}
+		return
+	}
+
+	p.buf.WriteByte(' ')
+	p.paint(colorCyan, key+"=")
+	p.buf.WriteString(formatValue(a.Value))
+}
+
+func (p *printer) paint(color string, s string) {
+	if !p.color {
+		p.buf.WriteString(s)
+		return
+	}
+
+	p.buf.WriteString(color)
+	p.buf.WriteString(s)
+	p.buf.WriteString(colorReset)
+}
+
+func levelColor(l slog.Level) string {
+	switch {
+	case l >= slog.LevelError:
+		return colorBold + colorRed
+	case l >= slog.LevelWarn:
+		return colorYellow
+	case l >= slog.LevelInfo:
+		return colorGreen
+	default:
+		return colorBlue
+	}
+}
+
+func formatValue(v slog.Value) string {
+	switch v.Kind() {
+	case slog.KindString:
+		s := v.String()
+
+		if s == "" || strings.ContainsAny(s, " \t\n\"=") {
+			return strconv.Quote(s)
+		}
+
+		return s
+	case slog.KindAny:
+		data, err := json.Marshal(v.Any())
+
+		if err != nil {
+			return fmt.Sprint(v.Any())
+		}
+
+		return string(data)
+	default:
+		return v.String()
+	}
+}
+
+func decompress(batch []byte) ([]byte, error) {
+	if bytes.HasPrefix(batch, []byte{0x1f, 0x8b}) {
+		r, err := gzip.NewReader(bytes.NewReader(batch))
+
+		if err != nil {
+			return nil, err
+		}
+
+		return io.ReadAll(r)
+	}
+
+	d, err := zstd.NewReader(nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer d.Close()
+
+	return d.DecodeAll(batch, nil)
+}
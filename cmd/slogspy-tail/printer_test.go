@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	slogspy "github.com/palkan/slog-spy"
+)
+
+func TestPrinter__Batch(t *testing.T) {
+	var out bytes.Buffer
+
+	p := &printer{w: &out}
+
+	p.printBatch([]byte(`{"time":"2024-05-01T10:20:30.123Z","level":"WARN","msg":"slow query","db":{"table":"users","ms":120},"query":"select 1"}
+{"type":"control","event":"start"}
+{"count":2,"records":[{"level":"DEBUG","msg":"first"},{"level":"ERROR","msg":"second","tags":["a","b"]}]}
+time=2024-05-01T10:20:30Z level=INFO msg=text
+`))
+
+	expected := `10:20:30.123 WARN  slow query db.table=users db.ms=120 query="select 1"
+--- start {"type":"control","event":"start"}
+DEBUG first
+ERROR second tags=["a","b"]
+time=2024-05-01T10:20:30Z level=INFO msg=text
+`
+
+	if out.String() != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, out.String())
+	}
+}
+
+func TestPrinter__Colors(t *testing.T) {
+	var out bytes.Buffer
+
+	p := &printer{w: &out, color: true}
+
+	p.printBatch([]byte(`{"level":"ERROR","msg":"failed"}`))
+
+	if !strings.Contains(out.String(), colorBold+colorRed+"ERROR"+colorReset) {
+		t.Errorf("expected the level to be highlighted, got: %q", out.String())
+	}
+}
+
+func TestPrinter__Compressed(t *testing.T) {
+	var batch bytes.Buffer
+
+	w := gzip.NewWriter(&batch)
+	w.Write([]byte(`{"level":"INFO","msg":"compressed"}` + "\n")) // nolint: errcheck
+	w.Close()                                                     // nolint: errcheck
+
+	var out bytes.Buffer
+
+	p := &printer{w: &out}
+
+	p.printBatch(batch.Bytes())
+
+	if out.String() != "INFO  compressed\n" {
+		t.Errorf("expected the batch to be decompressed, got: %q", out.String())
+	}
+}
+
+func TestPrinter__Protobuf(t *testing.T) {
+	var batch bytes.Buffer
+
+	encoder := slogspy.NewProtobufPrinter(&batch)
+
+	r := slog.NewRecord(time.Time{}, slog.LevelWarn, "slow query", 0)
+	r.AddAttrs(slog.Int("ms", 120), slog.Group("db", slog.String("table", "users")))
+
+	encoder.Handle(context.Background(), r) // nolint: errcheck
+
+	var out bytes.Buffer
+
+	p := &printer{w: &out}
+
+	p.printBatch(batch.Bytes())
+
+	if out.String() != "WARN  slow query ms=120 db.table=users\n" {
+		t.Errorf("expected the batch to be decoded, got: %q", out.String())
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// tailEventStream connects to the spy's EventStreamHandler and passes the received batches to the function
+// until the context is done or the server ends the stream
+func tailEventStream(ctx context.Context, u *url.URL, opts *options, tlsConfig *tls.Config, fn func(batch []byte)) error {
+	transport := &http.Transport{TLSClientConfig: tlsConfig}
+	defer transport.CloseIdleConnections()
+
+	client := &http.Client{Transport: &headerTransport{headers: opts.headers, next: transport}}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, streamURL(u, opts).String(), nil)
+
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Accept", "text/event-stream")
+
+	resp, err := client.Do(req)
+
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("unexpected response: %s %s", resp.Status, bytes.TrimSpace(body))
+	}
+
+	if ct, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type")); ct != "text/event-stream" {
+		return fmt.Errorf("unexpected content type: %q", resp.Header.Get("Content-Type"))
+	}
+
+	err = readEvents(resp.Body, fn)
+
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+
+	return err
+}
+
+// readEvents reads the server-sent events and passes their data to the function;
+// the data of the "binary" events is base64-decoded
+func readEvents(r io.Reader, fn func(batch []byte)) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), websocketMaxMessage)
+
+	var (
+		event string
+		data  []byte
+		ready bool
+	)
+
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		if line == "" {
+			if ready {
+				if err := dispatchEvent(event, data, fn); err != nil {
+					return err
+				}
+			}
+
+			event, data, ready = "", data[:0], false
+			continue
+		}
+
+		// Comments (e.g., keepalives)
+		if line[0] == ':' {
+			continue
+		}
+
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+
+		switch field {
+		case "event":
+			event = value
+		case "data":
+			data = append(data, value...)
+			data = append(data, '\n')
+			ready = true
+		}
+
+		if len(data) > websocketMaxMessage {
+			return errors.New("event is too large")
+		}
+	}
+
+	return scanner.Err()
+}
+
+func dispatchEvent(event string, data []byte, fn func(batch []byte)) error {
+	if event != "binary" {
+		fn(data)
+		return nil
+	}
+
+	batch, err := base64.StdEncoding.AppendDecode(nil, bytes.TrimSuffix(data, []byte("\n")))
+
+	if err != nil {
+		return fmt.Errorf("invalid binary event: %w", err)
+	}
+
+	fn(batch)
+
+	return nil
+}
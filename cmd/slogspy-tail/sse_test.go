@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	slogspy "github.com/palkan/slog-spy"
+)
+
+func TestRun__EventStream(t *testing.T) {
+	spy := newTestSpy(t)
+
+	server := httptest.NewServer(spy.EventStreamHandler())
+	defer server.Close()
+
+	var stdout, stderr bytes.Buffer
+
+	done := make(chan int, 1)
+
+	go func() {
+		args := []string{"--level", "warn", "--filter", `msg=~"payment"`, "--duration", "300ms", "--no-color", server.URL}
+		done <- run(context.Background(), args, &stdout, &stderr)
+	}()
+
+	waitFor(t, func() bool { return spy.Stats().Watchers == 1 })
+
+	logger := slog.New(spy)
+	logger.Info("payment accepted")
+	logger.Warn("payment delayed", "id", 42)
+	logger.Error("database is down")
+
+	if code := <-done; code != 0 {
+		t.Fatalf("expected the tool to exit with 0 once the duration passes, got %d: %s", code, stderr.String())
+	}
+
+	out := stdout.String()
+
+	if !strings.Contains(out, "WARN  payment delayed id=42") {
+		t.Errorf("expected the record to be printed, got: %s", out)
+	}
+
+	if strings.Contains(out, "payment accepted") || strings.Contains(out, "database is down") {
+		t.Errorf("expected the level and filter to be applied, got: %s", out)
+	}
+
+	waitFor(t, func() bool { return spy.Stats().Watchers == 0 })
+}
+
+func TestRun__Control(t *testing.T) {
+	spy := newTestSpy(t)
+	spy.SetLevel(slog.LevelError)
+
+	control := spy.ControlHandler()
+	defer control.Close()
+
+	mux := http.NewServeMux()
+	mux.Handle("/logs", spy.EventStreamHandler())
+	mux.Handle("/spy/", http.StripPrefix("/spy", control))
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	var stdout, stderr bytes.Buffer
+
+	done := make(chan int, 1)
+
+	go func() {
+		args := []string{"--control", server.URL + "/spy", "--level", "warn", "--filter", `msg=~"payment"`, "--duration", "300ms", "--no-color", server.URL + "/logs"}
+		done <- run(context.Background(), args, &stdout, &stderr)
+	}()
+
+	// The control API watcher and the stream subscription
+	waitFor(t, func() bool { return spy.Stats().Watchers == 2 })
+
+	if status := controlStatus(t, control); status.Level != "WARN" || status.Filter != `msg=~"payment"` || !status.Watching {
+		t.Errorf("expected the level, filter and watcher to be set via the control API, got: %+v", status)
+	}
+
+	logger := slog.New(spy)
+	logger.Info("payment accepted")
+	logger.Warn("payment delayed", "id", 42)
+	logger.Error("database is down")
+
+	if code := <-done; code != 0 {
+		t.Fatalf("expected the tool to exit with 0 once the duration passes, got %d: %s", code, stderr.String())
+	}
+
+	out := stdout.String()
+
+	if !strings.Contains(out, "WARN  payment delayed id=42") {
+		t.Errorf("expected the record to be printed, got: %s", out)
+	}
+
+	if strings.Contains(out, "payment accepted") || strings.Contains(out, "database is down") {
+		t.Errorf("expected the level and filter to be applied, got: %s", out)
+	}
+
+	waitFor(t, func() bool { return spy.Stats().Watchers == 0 })
+
+	if status := controlStatus(t, control); status.Level != "ERROR" || status.Filter != "" || status.Watching {
+		t.Errorf("expected the previous state to be restored, got: %+v", status)
+	}
+}
+
+func controlStatus(t *testing.T, control http.Handler) slogspy.ControlStatus {
+	t.Helper()
+
+	rec := httptest.NewRecorder()
+	control.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/status", nil))
+
+	var status slogspy.ControlStatus
+
+	if err := json.Unmarshal(rec.Body.Bytes(), &status); err != nil {
+		t.Fatal(err)
+	}
+
+	return status
+}
+
+func TestReadEvents(t *testing.T) {
+	stream := ": ping\n\ndata: {\"a\":1}\ndata: {\"b\":2}\n\nevent: binary\ndata: aGVsbG8=\n\n"
+
+	var batches []string
+
+	if err := readEvents(strings.NewReader(stream), func(batch []byte) { batches = append(batches, string(batch)) }); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(batches) != 2 || batches[0] != "{\"a\":1}\n{\"b\":2}\n" || batches[1] != "hello" {
+		t.Errorf("unexpected batches: %q", batches)
+	}
+}
@@ -0,0 +1,294 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/sha1" // nolint: gosec
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+)
+
+const (
+	websocketGUID       = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+	websocketMaxMessage = 64 * 1024 * 1024
+
+	wsOpContinuation byte = 0x0
+	wsOpText         byte = 0x1
+	wsOpBinary       byte = 0x2
+	wsOpClose        byte = 0x8
+	wsOpPing         byte = 0x9
+	wsOpPong         byte = 0xA
+)
+
+// tailWebsocket connects to the spy's WebsocketHandler and passes the received messages to the function
+// until the context is done or the server closes the connection
+func tailWebsocket(ctx context.Context, u *url.URL, opts *options, tlsConfig *tls.Config, fn func(batch []byte)) error {
+	u = websocketURL(u, opts)
+
+	conn, r, err := dialWebsocket(ctx, u, opts.headers, tlsConfig)
+
+	if err != nil {
+		return err
+	}
+
+	defer conn.Close()
+
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	var message []byte
+
+	for {
+		fin, opcode, payload, err := readFrame(r)
+
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+
+			return err
+		}
+
+		switch opcode {
+		case wsOpText, wsOpBinary, wsOpContinuation:
+			message = append(message, payload...)
+
+			if len(message) > websocketMaxMessage {
+				return errors.New("websocket message is too large")
+			}
+
+			if fin {
+				fn(message)
+				message = message[:0]
+			}
+		case wsOpPing:
+			if err := writeFrame(conn, wsOpPong, payload); err != nil {
+				return err
+			}
+		case wsOpClose:
+			writeFrame(conn, wsOpClose, payload) // nolint: errcheck
+
+			if len(payload) >= 2 {
+				if code := binary.BigEndian.Uint16(payload); code != 1000 {
+					return fmt.Errorf("connection closed by server: %d %s", code, payload[2:])
+				}
+			}
+
+			return nil
+		}
+	}
+}
+
+// websocketURL converts the URL into the WebSocket one with the level and filter parameters
+func websocketURL(u *url.URL, opts *options) *url.URL {
+	u = streamURL(u, opts)
+
+	switch u.Scheme {
+	case "http":
+		u.Scheme = "ws"
+	case "https":
+		u.Scheme = "wss"
+	}
+
+	return u
+}
+
+// streamURL returns a copy of the URL with the level and filter parameters
+func streamURL(u *url.URL, opts *options) *url.URL {
+	u = &url.URL{Scheme: u.Scheme, Host: u.Host, Path: u.Path, RawQuery: u.RawQuery}
+
+	query := u.Query()
+
+	if opts.level != "" {
+		query.Set("level", opts.level)
+	}
+
+	if opts.filter != "" {
+		query.Set("filter", opts.filter)
+	}
+
+	u.RawQuery = query.Encode()
+
+	return u
+}
+
+func dialWebsocket(ctx context.Context, u *url.URL, headers http.Header, tlsConfig *tls.Config) (net.Conn, *bufio.Reader, error) {
+	host := u.Host
+
+	if u.Port() == "" {
+		if u.Scheme == "wss" {
+			host = net.JoinHostPort(u.Hostname(), "443")
+		} else {
+			host = net.JoinHostPort(u.Hostname(), "80")
+		}
+	}
+
+	var d net.Dialer
+
+	conn, err := d.DialContext(ctx, "tcp", host)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if u.Scheme == "wss" {
+		cfg := tlsConfig.Clone()
+		cfg.ServerName = u.Hostname()
+
+		tlsConn := tls.Client(conn, cfg)
+
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, nil, err
+		}
+
+		conn = tlsConn
+	}
+
+	var nonce [16]byte
+	rand.Read(nonce[:]) // nolint: errcheck
+
+	key := base64.StdEncoding.EncodeToString(nonce[:])
+
+	req := &http.Request{
+		Method: http.MethodGet,
+		URL:    &url.URL{Scheme: "http", Host: u.Host, Path: u.Path, RawQuery: u.RawQuery},
+		Host:   u.Host,
+		Header: headers.Clone(),
+	}
+
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", key)
+
+	if err := req.Write(conn); err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+
+	r := bufio.NewReader(conn)
+
+	resp, err := http.ReadResponse(r, req)
+
+	if err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		conn.Close()
+		return nil, nil, fmt.Errorf("websocket handshake failed: %s", resp.Status)
+	}
+
+	if resp.Header.Get("Sec-WebSocket-Accept") != websocketAccept(key) {
+		conn.Close()
+		return nil, nil, errors.New("websocket handshake failed: invalid accept key")
+	}
+
+	return conn, r, nil
+}
+
+func websocketAccept(key string) string {
+	h := sha1.New() // nolint: gosec
+	h.Write([]byte(key + websocketGUID))
+
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+func readFrame(r io.Reader) (bool, byte, []byte, error) {
+	var header [2]byte
+
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return false, 0, nil, err
+	}
+
+	fin := header[0]&0x80 != 0
+	opcode := header[0] & 0x0F
+	masked := header[1]&0x80 != 0
+	size := uint64(header[1] & 0x7F)
+
+	switch size {
+	case 126:
+		var ext [2]byte
+
+		if _, err := io.ReadFull(r, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+
+		size = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+
+		if _, err := io.ReadFull(r, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+
+		size = binary.BigEndian.Uint64(ext[:])
+	}
+
+	if size > websocketMaxMessage {
+		return false, 0, nil, errors.New("websocket frame is too large")
+	}
+
+	var mask [4]byte
+
+	if masked {
+		if _, err := io.ReadFull(r, mask[:]); err != nil {
+			return false, 0, nil, err
+		}
+	}
+
+	payload := make([]byte, size)
+
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return false, 0, nil, err
+	}
+
+	if masked {
+		for i := range payload {
+			payload[i] ^= mask[i%4]
+		}
+	}
+
+	return fin, opcode, payload, nil
+}
+
+// writeFrame writes a masked frame (client frames must be masked)
+func writeFrame(w io.Writer, opcode byte, payload []byte) error {
+	frame := []byte{0x80 | opcode}
+
+	switch {
+	case len(payload) < 126:
+		frame = append(frame, 0x80|byte(len(payload)))
+	case len(payload) <= 0xFFFF:
+		frame = append(frame, 0x80|126)
+		frame = binary.BigEndian.AppendUint16(frame, uint16(len(payload)))
+	default:
+		frame = append(frame, 0x80|127)
+		frame = binary.BigEndian.AppendUint64(frame, uint64(len(payload)))
+	}
+
+	var mask [4]byte
+	rand.Read(mask[:]) // nolint: errcheck
+
+	frame = append(frame, mask[:]...)
+
+	for i, b := range payload {
+		frame = append(frame, b^mask[i%4])
+	}
+
+	_, err := w.Write(frame)
+
+	return err
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	slogspy "github.com/palkan/slog-spy"
+)
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(2 * time.Second)
+
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for condition")
+		}
+
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func newTestSpy(t *testing.T) *slogspy.Spy {
+	t.Helper()
+
+	spy := slogspy.NewSpy(
+		slog.NewTextHandler(io.Discard, nil),
+		slogspy.WithFlushInterval(10*time.Millisecond),
+		slogspy.WithFraming(slogspy.NDJSON),
+	)
+
+	go spy.Run(context.Background(), nil)                    // nolint: errcheck
+	t.Cleanup(func() { spy.Shutdown(context.Background()) }) // nolint: errcheck
+
+	return spy
+}
+
+func TestRun__Websocket(t *testing.T) {
+	spy := newTestSpy(t)
+
+	server := httptest.NewServer(spy.WebsocketHandler())
+	defer server.Close()
+
+	var stdout, stderr bytes.Buffer
+
+	done := make(chan int, 1)
+
+	go func() {
+		args := []string{"--level", "warn", "--filter", `msg=~"payment"`, "--duration", "300ms", "--no-color", "ws" + strings.TrimPrefix(server.URL, "http")}
+		done <- run(context.Background(), args, &stdout, &stderr)
+	}()
+
+	waitFor(t, func() bool { return spy.Stats().Watchers == 1 })
+
+	logger := slog.New(spy)
+	logger.Info("payment accepted")
+	logger.Warn("payment delayed", "id", 42)
+	logger.Error("database is down")
+
+	if code := <-done; code != 0 {
+		t.Fatalf("expected the tool to exit with 0 once the duration passes, got %d: %s", code, stderr.String())
+	}
+
+	out := stdout.String()
+
+	if !strings.Contains(out, "WARN  payment delayed id=42") {
+		t.Errorf("expected the record to be printed, got: %s", out)
+	}
+
+	if strings.Contains(out, "payment accepted") || strings.Contains(out, "database is down") {
+		t.Errorf("expected the level and filter to be applied, got: %s", out)
+	}
+
+	waitFor(t, func() bool { return spy.Stats().Watchers == 0 })
+}
+
+func TestWebsocketURL(t *testing.T) {
+	u, _ := url.Parse("https://example.com/logs?token=x")
+
+	got := websocketURL(u, &options{level: "debug", filter: `attrs.id=="1"`}).String()
+
+	if got != "wss://example.com/logs?filter=attrs.id%3D%3D%221%22&level=debug&token=x" {
+		t.Errorf("unexpected URL: %s", got)
+	}
+}
@@ -0,0 +1,105 @@
+package slogspy
+
+import (
+	"bytes"
+	"compress/gzip"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+// Compression is an algorithm to compress flushed batches with.
+type Compression int
+
+const (
+	CompressionNone Compression = iota
+	// Gzip (RFC 1952); levels are the compress/gzip ones
+	Gzip
+	// Zstd (RFC 8878); levels are the zstd ones (1-22), they're mapped to the closest encoder speed
+	Zstd
+)
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zstdMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+)
+
+// WithCompression makes the spy compress the flushed batches (delivered to the output and subscriptions).
+// Debug-level JSON is extremely compressible, so it dramatically cuts egress for network transports.
+// Compressed batches could be recognized by the gzip or zstd magic bytes (see IsCompressed).
+func WithCompression(c Compression, level int) SpyHandlerOption {
+	return func(h *SpyHandler) {
+		h.compressor = &compressor{algo: c, level: level}
+	}
+}
+
+// WithCompressionThreshold makes the spy only compress the batches larger than the specified number of bytes.
+func WithCompressionThreshold(n int) SpyHandlerOption {
+	return func(h *SpyHandler) {
+		h.compressionThreshold = n
+	}
+}
+
+// IsCompressed returns true if the batch is compressed with gzip or zstd.
+func IsCompressed(msg []byte) bool {
+	return bytes.HasPrefix(msg, gzipMagic) || bytes.HasPrefix(msg, zstdMagic)
+}
+
+// compressor is only used by the Run loop, so the buffers are reused
+type compressor struct {
+	algo  Compression
+	level int
+
+	buf  bytes.Buffer
+	zbuf []byte
+	gzip *gzip.Writer
+	zstd *zstd.Encoder
+}
+
+func (c *compressor) compress(msg []byte) []byte {
+	switch c.algo {
+	case Gzip:
+		c.buf.Reset()
+
+		if c.gzip == nil {
+			w, err := gzip.NewWriterLevel(&c.buf, c.level)
+
+			if err != nil {
+				w = gzip.NewWriter(&c.buf)
+			}
+
+			c.gzip = w
+		} else {
+			c.gzip.Reset(&c.buf)
+		}
+
+		c.gzip.Write(msg) // nolint: errcheck
+		c.gzip.Close()    // nolint: errcheck
+
+		return c.buf.Bytes()
+	case Zstd:
+		if c.zstd == nil {
+			enc, err := zstd.NewWriter(nil, zstd.WithEncoderLevel(zstd.EncoderLevelFromZstd(c.level)), zstd.WithEncoderConcurrency(1))
+
+			if err != nil {
+				return msg
+			}
+
+			c.zstd = enc
+		}
+
+		c.zbuf = c.zstd.EncodeAll(msg, c.zbuf[:0])
+
+		return c.zbuf
+	}
+
+	return msg
+}
+
+// compressBatch compresses the framed batch if compression is enabled and the batch is large enough
+func (h *SpyHandler) compressBatch(msg []byte) []byte {
+	if h.compressor == nil || len(msg) <= h.compressionThreshold {
+		return msg
+	}
+
+	return h.compressor.compress(msg)
+}
@@ -0,0 +1,88 @@
+package slogspy
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+func TestSpy__WithCompression(t *testing.T) {
+	decoders := map[Compression]func(msg []byte) ([]byte, error){
+		Gzip: func(msg []byte) ([]byte, error) {
+			r, err := gzip.NewReader(bytes.NewReader(msg))
+
+			if err != nil {
+				return nil, err
+			}
+
+			return io.ReadAll(r)
+		},
+		Zstd: func(msg []byte) ([]byte, error) {
+			dec, err := zstd.NewReader(nil)
+
+			if err != nil {
+				return nil, err
+			}
+
+			defer dec.Close()
+
+			return dec.DecodeAll(msg, nil)
+		},
+	}
+
+	for algo, decode := range decoders {
+		var msgs [][]byte
+
+		spy := NewSpy(slog.NewTextHandler(&bytes.Buffer{}, nil), WithCompression(algo, 3), WithCompressionThreshold(200))
+
+		go spy.Run(context.Background(), func(msg []byte) { // nolint: errcheck
+			msgs = append(msgs, bytes.Clone(msg))
+		})
+
+		spy.Watch()
+
+		logger := slog.New(spy)
+
+		logger.Info("small")
+		spy.handler.flushSync()
+
+		for i := 0; i < 10; i++ {
+			logger.Debug("compressible", "payload", "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa")
+		}
+
+		if err := spy.Shutdown(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+
+		if len(msgs) != 2 {
+			t.Fatalf("expected 2 batches, got %d", len(msgs))
+		}
+
+		if IsCompressed(msgs[0]) || !bytes.Contains(msgs[0], []byte("small")) {
+			t.Errorf("expected batch below the threshold to be delivered as is, got %q", msgs[0])
+		}
+
+		if !IsCompressed(msgs[1]) {
+			t.Fatalf("expected batch to be compressed, got %q", msgs[1])
+		}
+
+		data, err := decode(msgs[1])
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if n := bytes.Count(data, []byte("compressible")); n != 10 {
+			t.Errorf("expected decompressed batch to contain 10 records, got %d", n)
+		}
+
+		if len(msgs[1]) >= len(data) {
+			t.Errorf("expected batch to be compressed, got %d bytes for %d bytes", len(msgs[1]), len(data))
+		}
+	}
+}
@@ -0,0 +1,338 @@
+package slogspy
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ControlHandler is an HTTP handler to control the spy of a running process:
+//
+//	GET  /stats            returns the spy stats
+//	GET  /status           returns the control status (watching, level, filter)
+//	POST /watch?ttl=5m     starts watching (until /unwatch or the TTL expires)
+//	POST /unwatch          stops watching started via /watch
+//	POST /level?level=warn changes the spy level; DELETE /level removes it
+//	POST /filter?expr=...  sets the filter expression; DELETE /filter removes the filter
+//	POST /config?...       changes the flush_interval, max_buf_size and/or backlog size
+//	POST /stop?label=k=v   stops the subscriptions tagged with the label
+//
+// Mount it under a prefix via http.StripPrefix.
+type ControlHandler struct {
+	spy *Spy
+	mux *http.ServeMux
+
+	token       string
+	parseFilter func(expr string) (Filter, error)
+
+	mu    sync.Mutex
+	watch *WatchToken
+	expr  string
+}
+
+var _ http.Handler = (*ControlHandler)(nil)
+
+type ControlOption func(*ControlHandler)
+
+// WithControlToken requires requests to provide the token via the "Authorization: Bearer <token>" header.
+func WithControlToken(token string) ControlOption {
+	return func(h *ControlHandler) {
+		h.token = token
+	}
+}
+
+// WithControlFilterParser sets a function to compile filter expressions passed to the /filter endpoint.
+// By default, expressions are compiled via CompileFilter.
+func WithControlFilterParser(fn func(expr string) (Filter, error)) ControlOption {
+	return func(h *ControlHandler) {
+		h.parseFilter = fn
+	}
+}
+
+// ControlStatus describes the spy state changed via the control API.
+type ControlStatus struct {
+	// Watching is true if the spy is watched via the control API
+	Watching bool `json:"watching"`
+	// Level is the minimum level of spied records (empty if all levels are spied)
+	Level string `json:"level,omitempty"`
+	// Filter is the filter expression set via the control API
+	Filter string `json:"filter,omitempty"`
+}
+
+// ControlHandler creates a new ControlHandler for the spy:
+//
+//	http.Handle("/spy/", http.StripPrefix("/spy", spy.ControlHandler(slogspy.WithControlToken(token))))
+func (s *Spy) ControlHandler(opts ...ControlOption) *ControlHandler {
+	h := &ControlHandler{spy: s, mux: http.NewServeMux(), parseFilter: CompileFilter}
+
+	for _, opt := range opts {
+		opt(h)
+	}
+
+	h.mux.HandleFunc("GET /stats", h.handleStats)
+	h.mux.HandleFunc("GET /status", h.handleStatus)
+	h.mux.HandleFunc("POST /watch", h.handleWatch)
+	h.mux.HandleFunc("POST /unwatch", h.handleUnwatch)
+	h.mux.HandleFunc("POST /level", h.handleLevel)
+	h.mux.HandleFunc("DELETE /level", h.handleLevel)
+	h.mux.HandleFunc("POST /filter", h.handleFilter)
+	h.mux.HandleFunc("DELETE /filter", h.handleFilter)
+	h.mux.HandleFunc("POST /config", h.handleConfig)
+	h.mux.HandleFunc("POST /stop", h.handleStop)
+
+	return h
+}
+
+func (h *ControlHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h.token != "" && !h.authorized(r) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	h.mux.ServeHTTP(w, r)
+}
+
+// Close releases the watcher registered via the control API.
+func (h *ControlHandler) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.releaseWatch()
+}
+
+func (h *ControlHandler) authorized(r *http.Request) bool {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+
+	return ok && subtle.ConstantTimeCompare([]byte(token), []byte(h.token)) == 1
+}
+
+func (h *ControlHandler) handleStats(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, h.spy.Stats())
+}
+
+func (h *ControlHandler) handleStatus(w http.ResponseWriter, r *http.Request) {
+	h.respond(w)
+}
+
+func (h *ControlHandler) handleWatch(w http.ResponseWriter, r *http.Request) {
+	var ttl time.Duration
+
+	if val := r.FormValue("ttl"); val != "" {
+		d, err := time.ParseDuration(val)
+
+		if err != nil || d <= 0 {
+			http.Error(w, fmt.Sprintf("invalid ttl: %q", val), http.StatusBadRequest)
+			return
+		}
+
+		ttl = d
+	}
+
+	h.mu.Lock()
+
+	// Re-watching replaces the previous watcher (e.g., to extend the TTL)
+	h.releaseWatch()
+
+	if ttl > 0 {
+		h.watch = h.spy.WatchWithTTL(ttl)
+	} else {
+		h.watch = &WatchToken{handler: h.spy.handler}
+		h.spy.Watch()
+	}
+
+	h.mu.Unlock()
+
+	h.respond(w)
+}
+
+func (h *ControlHandler) handleUnwatch(w http.ResponseWriter, r *http.Request) {
+	h.mu.Lock()
+	h.releaseWatch()
+	h.mu.Unlock()
+
+	h.respond(w)
+}
+
+func (h *ControlHandler) handleLevel(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodDelete {
+		h.spy.ResetLevel()
+		h.respond(w)
+		return
+	}
+
+	var level slog.Level
+
+	if err := level.UnmarshalText([]byte(r.FormValue("level"))); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	h.spy.SetLevel(level)
+
+	h.respond(w)
+}
+
+func (h *ControlHandler) handleFilter(w http.ResponseWriter, r *http.Request) {
+	expr := strings.TrimSpace(r.FormValue("expr"))
+
+	if r.Method == http.MethodDelete {
+		expr = ""
+	}
+
+	var filter Filter
+
+	if expr != "" {
+		f, err := h.parseFilter(expr)
+
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		filter = f
+	}
+
+	h.mu.Lock()
+	h.expr = expr
+	h.spy.SetFilter(filter)
+	h.mu.Unlock()
+
+	h.respond(w)
+}
+
+// handleConfig validates all the parameters first and applies the changes at once (as a single Run loop command),
+// so invalid requests don't change anything
+func (h *ControlHandler) handleConfig(w http.ResponseWriter, r *http.Request) {
+	var changes []func(*SpyHandler)
+
+	if raw := r.FormValue("flush_interval"); raw != "" {
+		interval, err := time.ParseDuration(raw)
+
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		change, err := flushIntervalChange(interval)
+
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		changes = append(changes, change)
+	}
+
+	// The parameters are processed in a fixed order
+	for _, param := range []struct {
+		name   string
+		change func(int) (func(*SpyHandler), error)
+	}{{"max_buf_size", maxBufSizeChange}, {"backlog", resizeChange}} {
+		raw := r.FormValue(param.name)
+
+		if raw == "" {
+			continue
+		}
+
+		size, err := strconv.Atoi(raw)
+
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid %s: %q", param.name, raw), http.StatusBadRequest)
+			return
+		}
+
+		change, err := param.change(size)
+
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		changes = append(changes, change)
+	}
+
+	if len(changes) > 0 {
+		h.spy.handler.configure(func(sh *SpyHandler) {
+			for _, change := range changes {
+				change(sh)
+			}
+		})
+	}
+
+	h.respond(w)
+}
+
+func (h *ControlHandler) handleStop(w http.ResponseWriter, r *http.Request) {
+	label := r.FormValue("label")
+
+	if label == "" {
+		http.Error(w, "label is required", http.StatusBadRequest)
+		return
+	}
+
+	writeJSON(w, map[string]int{"stopped": h.spy.StopAll(label)})
+}
+
+// releaseWatch unregisters the control watcher; must be called with the lock held
+func (h *ControlHandler) releaseWatch() {
+	if h.watch == nil {
+		return
+	}
+
+	h.watch.Release()
+	h.watch = nil
+}
+
+func (h *ControlHandler) respond(w http.ResponseWriter) {
+	h.mu.Lock()
+	status := ControlStatus{Watching: h.watch != nil && !h.watch.released.Load(), Filter: h.expr}
+	h.mu.Unlock()
+
+	if level, ok := h.spy.handler.Level(); ok {
+		status.Level = level.String()
+	}
+
+	writeJSON(w, status)
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v) // nolint: errcheck
+}
+
+// ParseAttrFilter compiles a single attribute comparison in the form of "<path> <op> <value>"
+// (e.g., `user_id == 42` or `tenant != "acme"`) into a filter (see WhereAttr).
+// Values are parsed as JSON literals when possible and used as raw strings otherwise.
+func ParseAttrFilter(expr string) (Filter, error) {
+	fields := strings.Fields(expr)
+
+	if len(fields) < 3 {
+		return nil, fmt.Errorf("invalid filter expression: %q", expr)
+	}
+
+	path, op := fields[0], fields[1]
+
+	switch op {
+	case "==", "!=", ">", ">=", "<", "<=":
+	default:
+		return nil, fmt.Errorf("unknown operator: %q", op)
+	}
+
+	raw := strings.Join(fields[2:], " ")
+
+	var value any
+
+	if n, err := strconv.ParseInt(raw, 10, 64); err == nil {
+		value = n
+	} else if err := json.Unmarshal([]byte(raw), &value); err != nil {
+		value = raw
+	}
+
+	return WhereAttr(path, op, value), nil
+}
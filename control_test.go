@@ -0,0 +1,267 @@
+package slogspy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestControlHandler(t *testing.T) {
+	var (
+		mu  sync.Mutex
+		buf bytes.Buffer
+	)
+
+	spy := NewSpy(slog.NewTextHandler(io.Discard, nil), WithFlushInterval(10*time.Millisecond))
+
+	go spy.Run(context.Background(), func(msg []byte) { // nolint: errcheck
+		mu.Lock()
+		defer mu.Unlock()
+
+		buf.Write(msg)
+	})
+
+	control := spy.ControlHandler()
+
+	server := httptest.NewServer(control)
+	defer server.Close()
+
+	logger := slog.New(spy)
+
+	status := controlRequest(t, http.MethodPost, server.URL+"/watch", nil)
+
+	if !status.Watching {
+		t.Fatal("expected to start watching")
+	}
+
+	status = controlRequest(t, http.MethodPost, server.URL+"/level", url.Values{"level": {"warn"}})
+
+	if status.Level != "WARN" {
+		t.Errorf("expected level to be WARN, got: %q", status.Level)
+	}
+
+	status = controlRequest(t, http.MethodPost, server.URL+"/filter", url.Values{"expr": {`attrs.tenant == "acme"`}})
+
+	if status.Filter != `attrs.tenant == "acme"` {
+		t.Errorf("expected filter to be set, got: %q", status.Filter)
+	}
+
+	controlRequest(t, http.MethodPost, server.URL+"/config", url.Values{"flush_interval": {"5ms"}, "backlog": {"64"}})
+
+	logger.Info("info acme", "tenant", "acme")
+	logger.Warn("warn acme", "tenant", "acme")
+	logger.Warn("warn other", "tenant", "other")
+
+	waitFor(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+
+		return strings.Contains(buf.String(), "warn acme")
+	})
+
+	status = controlRequest(t, http.MethodDelete, server.URL+"/filter", nil)
+
+	if status.Filter != "" {
+		t.Errorf("expected filter to be removed, got: %q", status.Filter)
+	}
+
+	status = controlRequest(t, http.MethodDelete, server.URL+"/level", nil)
+
+	if status.Level != "" {
+		t.Errorf("expected level to be removed, got: %q", status.Level)
+	}
+
+	if _, ok := spy.handler.Level(); ok {
+		t.Error("expected all levels to be spied")
+	}
+
+	status = controlRequest(t, http.MethodPost, server.URL+"/unwatch", nil)
+
+	if status.Watching {
+		t.Error("expected to stop watching")
+	}
+
+	if stats := spy.Stats(); stats.Watchers != 0 {
+		t.Errorf("expected no watchers, got %d", stats.Watchers)
+	}
+
+	if err := spy.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	assertBufferContainsNot(t, &buf, "info acme")
+	assertBufferContainsNot(t, &buf, "warn other")
+}
+
+func TestControlHandler__WatchTTL(t *testing.T) {
+	spy := NewSpy(slog.NewTextHandler(io.Discard, nil))
+
+	server := httptest.NewServer(spy.ControlHandler())
+	defer server.Close()
+
+	controlRequest(t, http.MethodPost, server.URL+"/watch", url.Values{"ttl": {"20ms"}})
+
+	// Re-watching replaces the previous watcher
+	controlRequest(t, http.MethodPost, server.URL+"/watch", url.Values{"ttl": {"20ms"}})
+
+	if stats := spy.Stats(); stats.Watchers != 1 {
+		t.Errorf("expected 1 watcher, got %d", stats.Watchers)
+	}
+
+	waitFor(t, func() bool { return spy.Stats().Watchers == 0 })
+
+	if status := controlRequest(t, http.MethodGet, server.URL+"/status", nil); status.Watching {
+		t.Error("expected watching to stop after the TTL")
+	}
+}
+
+func TestControlHandler__Errors(t *testing.T) {
+	spy := NewSpy(slog.NewTextHandler(io.Discard, nil))
+
+	server := httptest.NewServer(spy.ControlHandler(WithControlToken("secret")))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/stats")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp.Body.Close() // nolint: errcheck
+
+	if resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("expected 401 without a token, got %d", resp.StatusCode)
+	}
+
+	for path, params := range map[string]url.Values{
+		"/level":  {"level": {"loud"}},
+		"/filter": {"expr": {"tenant ~ acme"}},
+		"/watch":  {"ttl": {"forever"}},
+		"/config": {"backlog": {"-1"}},
+		"/stop":   {},
+	} {
+		req, _ := http.NewRequest(http.MethodPost, server.URL+path, strings.NewReader(params.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.Header.Set("Authorization", "Bearer secret")
+
+		resp, err := http.DefaultClient.Do(req)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		resp.Body.Close() // nolint: errcheck
+
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Errorf("expected 400 for %s, got %d", path, resp.StatusCode)
+		}
+	}
+}
+
+func TestControlHandler__ConfigAtomic(t *testing.T) {
+	spy := NewSpy(slog.NewTextHandler(io.Discard, nil))
+
+	server := httptest.NewServer(spy.ControlHandler())
+	defer server.Close()
+
+	params := url.Values{"flush_interval": {"5ms"}, "max_buf_size": {"1024"}, "backlog": {"-1"}}
+
+	resp, err := http.PostForm(server.URL+"/config", params)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp.Body.Close() // nolint: errcheck
+
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected 400, got %d", resp.StatusCode)
+	}
+
+	spy.handler.state.mu.Lock()
+	pending := len(spy.handler.state.pending)
+	spy.handler.state.mu.Unlock()
+
+	if pending != 0 {
+		t.Errorf("expected no changes to be applied, got %d", pending)
+	}
+
+	controlRequest(t, http.MethodPost, server.URL+"/config", url.Values{"flush_interval": {"5ms"}, "max_buf_size": {"1024"}})
+
+	spy.handler.state.mu.Lock()
+	pending = len(spy.handler.state.pending)
+	spy.handler.state.mu.Unlock()
+
+	if pending != 1 {
+		t.Errorf("expected the changes to be applied at once, got %d", pending)
+	}
+}
+
+func TestParseAttrFilter(t *testing.T) {
+	r := slog.NewRecord(time.Now(), slog.LevelInfo, "test", 0)
+	r.AddAttrs(slog.Int("status", 503), slog.String("tenant", "acme corp"), slog.Any("tags", []any{"a", "b"}))
+
+	view := &RecordView{Record: r}
+
+	for expr, expected := range map[string]bool{
+		"status >= 500":         true,
+		"status < 500":          false,
+		`tenant == "acme corp"`: true,
+		"tenant == acme corp":   true,
+		"tenant != acme":        true,
+		"missing == 1":          false,
+		`tags == ["a","b"]`:     true,
+		`tags != ["a"]`:         true,
+	} {
+		f, err := ParseAttrFilter(expr)
+
+		if err != nil {
+			t.Fatalf("failed to parse %q: %v", expr, err)
+		}
+
+		if f(view) != expected {
+			t.Errorf("expected %q to match: %v", expr, expected)
+		}
+	}
+}
+
+func controlRequest(t *testing.T, method string, target string, params url.Values) ControlStatus {
+	t.Helper()
+
+	req, err := http.NewRequest(method, target, strings.NewReader(params.Encode()))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := http.DefaultClient.Do(req)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer resp.Body.Close() // nolint: errcheck
+
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("unexpected status for %s %s: %d", method, target, resp.StatusCode)
+	}
+
+	var status ControlStatus
+
+	if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+		t.Fatal(err)
+	}
+
+	return status
+}
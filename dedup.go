@@ -0,0 +1,121 @@
+package slogspy
+
+import (
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RepeatCountKey is the key of the synthetic attribute added to a record collapsed with its identical
+// consecutive duplicates (see WithDedup).
+const RepeatCountKey = "repeat_count"
+
+// WithDedup makes the spy collapse identical consecutive records (the same level, message and attributes)
+// received within the window into a single record with the repeat_count attribute.
+// A record is held until a different one arrives or the window ends, so it's delivered with a delay.
+// Annotations and request-scoped records are never collapsed.
+func WithDedup(window time.Duration) SpyHandlerOption {
+	return func(h *SpyHandler) {
+		h.dedup = &dedupState{window: window}
+	}
+}
+
+// dedupState contains the held record and the number of its duplicates; it's only accessed by the Run loop
+type dedupState struct {
+	window time.Duration
+
+	held  *Entry
+	key   string
+	since time.Time
+	count int
+}
+
+// collapse holds the entry or counts it as a duplicate of the held one; it returns false if the entry
+// must be processed right away
+func (h *SpyHandler) collapse(entry *Entry) bool {
+	d := h.dedup
+
+	if entry.annotation || entry.capture != nil {
+		h.releaseDuplicates(true)
+		return false
+	}
+
+	key := dedupKey(entry)
+	now := time.Now()
+
+	if d.held != nil {
+		if key == d.key && now.Sub(d.since) < d.window {
+			d.count++
+			h.stats.collapsed.Add(1)
+			releaseEntry(entry)
+			return true
+		}
+
+		h.releaseDuplicates(true)
+	}
+
+	d.held, d.key, d.since, d.count = entry, key, now, 1
+
+	return true
+}
+
+// releaseDuplicates prints the held record (unless it's not forced and the window hasn't ended yet);
+// it returns true if the record is still held
+func (h *SpyHandler) releaseDuplicates(force bool) bool {
+	d := h.dedup
+
+	if d == nil || d.held == nil {
+		return false
+	}
+
+	if !force && time.Since(d.since) < d.window {
+		return true
+	}
+
+	entry := d.held
+	entry.repeats = d.count
+	d.held, d.key = nil, ""
+
+	h.print(entry)
+	releaseEntry(entry)
+
+	return false
+}
+
+// dedupKey returns the string identifying the record along with the handler's attributes and groups
+func dedupKey(entry *Entry) string {
+	var b strings.Builder
+
+	b.WriteString(entry.record.Level.String())
+	b.WriteByte(0)
+	b.WriteString(entry.record.Message)
+
+	for _, group := range entry.groups {
+		b.WriteByte(0)
+		b.WriteString(group)
+	}
+
+	for _, frame := range entry.frames {
+		b.WriteByte(0)
+		b.WriteString(strconv.Itoa(frame.depth))
+
+		for _, a := range frame.attrs {
+			writeDedupAttr(&b, a)
+		}
+	}
+
+	entry.record.Attrs(func(a slog.Attr) bool {
+		writeDedupAttr(&b, a)
+		return true
+	})
+
+	return b.String()
+}
+
+func writeDedupAttr(b *strings.Builder, a slog.Attr) {
+	b.WriteByte(0)
+	b.WriteString(a.Key)
+	b.WriteByte('=')
+	b.WriteString(a.Value.Resolve().String())
+}
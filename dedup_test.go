@@ -0,0 +1,95 @@
+package slogspy
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSpy__WithDedup(t *testing.T) {
+	buf := &bytes.Buffer{}
+
+	spy := NewSpy(slog.NewTextHandler(&bytes.Buffer{}, nil), WithDedup(time.Minute))
+
+	go spy.Run(context.Background(), func(msg []byte) { // nolint: errcheck
+		buf.Write(msg)
+	})
+
+	spy.Watch()
+
+	logger := slog.New(spy)
+	retries := logger.With("component", "db")
+
+	for i := 0; i < 5; i++ {
+		retries.Debug("retrying", "attempt", 1)
+	}
+
+	// Attributes are compared, too
+	retries.Debug("retrying", "attempt", 2)
+	logger.Debug("retrying", "attempt", 2)
+	logger.Info("connected")
+
+	if err := spy.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	out := buf.String()
+
+	if n := strings.Count(out, `"msg":"retrying"`); n != 3 {
+		t.Errorf("expected 3 retrying records, got %d: %s", n, out)
+	}
+
+	assertBufferContains(t, buf, `"attempt":1,"repeat_count":5`)
+	assertBufferContains(t, buf, "connected")
+
+	if n := strings.Count(out, "repeat_count"); n != 1 {
+		t.Errorf("expected only collapsed records to have the repeat count, got %d: %s", n, out)
+	}
+
+	if strings.Index(out, `"attempt":1`) > strings.Index(out, "connected") {
+		t.Errorf("expected the records order to be preserved: %s", out)
+	}
+
+	if n := spy.Stats().Collapsed; n != 4 {
+		t.Errorf("expected 4 collapsed records, got %d", n)
+	}
+}
+
+func TestSpy__WithDedup_Window(t *testing.T) {
+	buf := &bytes.Buffer{}
+
+	spy := NewSpy(slog.NewTextHandler(&bytes.Buffer{}, nil), WithDedup(50*time.Millisecond), WithFlushInterval(10*time.Millisecond))
+
+	go spy.Run(context.Background(), func(msg []byte) { // nolint: errcheck
+		buf.Write(msg)
+	})
+
+	spy.Watch()
+
+	logger := slog.New(spy)
+
+	logger.Debug("retrying")
+	logger.Debug("retrying")
+
+	// The held record is flushed by the timer once the window ends
+	waitFor(t, func() bool { return spy.Stats().Flushes > 0 })
+
+	if err := spy.handler.requestFlush(context.Background(), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	assertBufferContains(t, buf, `"repeat_count":2`)
+
+	logger.Debug("retrying")
+
+	if err := spy.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := strings.Count(buf.String(), `"msg":"retrying"`); n != 2 {
+		t.Errorf("expected a new record after the window ends, got %d: %s", n, buf.String())
+	}
+}
@@ -0,0 +1,13 @@
+// Package slogspy provides a slog.Handler wrapper which streams verbose logs to consumers on demand.
+//
+// The spy is activated only while there are watchers (e.g., subscriptions or WebSocket clients),
+// so it adds almost no overhead when nobody's watching:
+//
+//	spy := slogspy.NewSpy(handler)
+//	logger := slog.New(spy)
+//
+//	go spy.Run(ctx, nil)
+//	defer spy.Shutdown(ctx)
+//
+//	http.Handle("/logs", spy.WebsocketHandler())
+package slogspy
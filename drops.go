@@ -0,0 +1,150 @@
+package slogspy
+
+import (
+	"log/slog"
+	"strconv"
+)
+
+// DropReason is the cause of a dropped record (or batch).
+type DropReason string
+
+const (
+	// DropQueueFull records are dropped due to the backlog overflow
+	DropQueueFull DropReason = "queue_full"
+	// DropEvicted records have been in the backlog for too long (see WithMaxBacklogAge)
+	DropEvicted DropReason = "evicted"
+	// DropSampled records are skipped by sampling (see WithSampling)
+	DropSampled DropReason = "sampled"
+	// DropRateLimited records exceed the rate limit (see WithRateLimit)
+	DropRateLimited DropReason = "rate_limited"
+	// DropOversized records exceed the max record size (see WithMaxRecordSize)
+	DropOversized DropReason = "oversized"
+	// DropQuota batches exceed subscriptions quotas (counted per subscription)
+	DropQuota DropReason = "quota"
+	// DropSinkFailure batches couldn't be handed to the output (e.g., spool write errors or full output queues)
+	DropSinkFailure DropReason = "sink_failure"
+	// DropCanceled records are logged with canceled contexts (or their contexts are canceled while waiting for the backlog)
+	DropCanceled DropReason = "canceled"
+	// DropSlowOutput batches couldn't be handed to stalled outputs (see WithOutputTimeout)
+	DropSlowOutput DropReason = "slow_output"
+)
+
+var dropReasons = [...]DropReason{DropQueueFull, DropEvicted, DropSampled, DropRateLimited, DropOversized, DropQuota, DropSinkFailure, DropCanceled, DropSlowOutput}
+
+func (r DropReason) index() int {
+	for i, reason := range dropReasons {
+		if reason == r {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// WithOnDropReason sets a function to be called for every dropped record along with the drop reason
+// (batches dropped due to quotas or sink failures are not reported; batches dropped by stalled outputs are reported
+// as DroppedBatchMessage records, see WithOutputTimeout). The function is called synchronously
+// from the logging goroutine for backlog overflows and from the Run loop otherwise, so it must be fast.
+func WithOnDropReason(fn func(r slog.Record, reason DropReason)) SpyHandlerOption {
+	return func(h *SpyHandler) {
+		h.onDrop = fn
+	}
+}
+
+// WithMaxRecordSize makes the spy drop records which formatted size exceeds the limit (in bytes).
+func WithMaxRecordSize(n int) SpyHandlerOption {
+	return func(h *SpyHandler) {
+		h.maxRecordSize = n
+	}
+}
+
+func (s *spyStats) trackDrop(reason DropReason) {
+	s.trackDrops(reason, 1)
+}
+
+func (s *spyStats) trackDrops(reason DropReason, n int64) {
+	s.drops[reason.index()].Add(n)
+}
+
+func (s *spyStats) dropsByReason() map[DropReason]int64 {
+	var drops map[DropReason]int64
+
+	for i, reason := range dropReasons {
+		if n := s.drops[i].Load(); n > 0 {
+			if drops == nil {
+				drops = make(map[DropReason]int64, len(dropReasons))
+			}
+
+			drops[reason] = n
+		}
+	}
+
+	return drops
+}
+
+// dropRecord reports the record dropped by the Run loop
+func (h *SpyHandler) dropRecord(r slog.Record, reason DropReason) {
+	h.stats.trackDrop(reason)
+
+	if h.onDrop != nil {
+		h.onDrop(r, reason)
+	}
+}
+
+// batchDrops returns the number of drops by reason since the previous batch; it's only called by the Run loop
+func (h *SpyHandler) batchDrops() [len(dropReasons)]int64 {
+	var deltas [len(dropReasons)]int64
+
+	for i := range dropReasons {
+		n := h.stats.drops[i].Load()
+		deltas[i] = n - h.reportedDrops[i]
+		h.reportedDrops[i] = n
+	}
+
+	return deltas
+}
+
+// appendBatchDrops appends the number of drops by reason as a JSON object
+// (or returns false if there were no drops)
+func appendBatchDrops(buf []byte, deltas [len(dropReasons)]int64) ([]byte, bool) {
+	found := false
+
+	for i, reason := range dropReasons {
+		delta := deltas[i]
+
+		if delta == 0 {
+			continue
+		}
+
+		if found {
+			buf = append(buf, ',')
+		} else {
+			buf = append(buf, '{')
+			found = true
+		}
+
+		buf = strconv.AppendQuote(buf, string(reason))
+		buf = append(buf, ':')
+		buf = strconv.AppendInt(buf, delta, 10)
+	}
+
+	if found {
+		buf = append(buf, '}')
+	}
+
+	return buf, found
+}
+
+// missingRecords returns the number of dropped records (batches dropped due to quotas or sink failures
+// are not included, consumers detect them via sequence gaps)
+func missingRecords(deltas [len(dropReasons)]int64) int64 {
+	var n int64
+
+	for i, reason := range dropReasons {
+		if reason != DropQuota && reason != DropSinkFailure {
+			n += deltas[i]
+		}
+	}
+
+	return n
+}
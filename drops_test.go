@@ -0,0 +1,91 @@
+package slogspy
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSpy__DropReasons(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		reasons []DropReason
+		msgs    []string
+	)
+
+	spy := NewSpy(
+		slog.NewTextHandler(&bytes.Buffer{}, nil),
+		WithRateLimit(1, time.Hour),
+		WithMaxRecordSize(100),
+		WithFraming(NDJSONEnvelope),
+		WithOnDropReason(func(r slog.Record, reason DropReason) {
+			mu.Lock()
+			defer mu.Unlock()
+
+			reasons = append(reasons, reason)
+		}),
+	)
+
+	go spy.Run(context.Background(), func(msg []byte) { // nolint: errcheck
+		msgs = append(msgs, string(msg))
+	})
+
+	spy.Watch()
+
+	logger := slog.New(spy)
+
+	logger.Info("limited")
+	logger.Info("limited")
+	logger.Info("large", "payload", strings.Repeat("x", 100))
+	logger.Info("ok")
+
+	if err := spy.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if len(reasons) != 2 || reasons[0] != DropRateLimited || reasons[1] != DropOversized {
+		t.Errorf("expected rate limited and oversized drops, got %v", reasons)
+	}
+
+	drops := spy.Stats().Drops
+
+	if drops[DropRateLimited] != 1 || drops[DropOversized] != 1 || len(drops) != 2 {
+		t.Errorf("expected drops to be counted by reason, got %v", drops)
+	}
+
+	if len(msgs) != 1 || !strings.Contains(msgs[0], `"dropped":{"rate_limited":1,"oversized":1}`) {
+		t.Errorf("expected batch envelope to contain drops, got %v", msgs)
+	}
+}
+
+func TestSpy__DropReasons_queueFull(t *testing.T) {
+	var dropped []string
+
+	spy := NewSpy(
+		slog.NewTextHandler(&bytes.Buffer{}, nil),
+		WithOnDrop(func(r slog.Record) { dropped = append(dropped, r.Message) }),
+	)
+
+	spy.handler.ch = newBacklog(1)
+	spy.Watch()
+
+	logger := slog.New(spy)
+
+	logger.Info("queued")
+	logger.Info("overflow")
+
+	if len(dropped) != 1 || dropped[0] != "overflow" {
+		t.Errorf("expected the new record to be dropped, got %v", dropped)
+	}
+
+	if n := spy.Stats().Drops[DropQueueFull]; n != 1 {
+		t.Errorf("expected 1 queue_full drop, got %d", n)
+	}
+}
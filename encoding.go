@@ -0,0 +1,193 @@
+package slogspy
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/json"
+	"sync"
+	"time"
+)
+
+const defaultAdaptiveInterval = time.Second
+
+// Encoding transforms flushed batches before delivering them to a subscription.
+type Encoding interface {
+	// Name is used to announce the encoding to consumers
+	Name() string
+	// Encode returns the encoded batch; the input must not be retained
+	Encode(msg []byte) []byte
+}
+
+type deflateEncoding struct {
+	level int
+
+	mu     sync.Mutex
+	buf    bytes.Buffer
+	writer *flate.Writer
+}
+
+// NewDeflateEncoding creates a raw DEFLATE (RFC 1951) encoding with the specified compression level.
+func NewDeflateEncoding(level int) Encoding {
+	return &deflateEncoding{level: level}
+}
+
+func (e *deflateEncoding) Name() string {
+	return "deflate"
+}
+
+func (e *deflateEncoding) Encode(msg []byte) []byte {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.buf.Reset()
+
+	if e.writer == nil {
+		w, err := flate.NewWriter(&e.buf, e.level)
+
+		if err != nil {
+			w, _ = flate.NewWriter(&e.buf, flate.DefaultCompression)
+		}
+
+		e.writer = w
+	} else {
+		e.writer.Reset(&e.buf)
+	}
+
+	e.writer.Write(msg) // nolint: errcheck
+	e.writer.Close()    // nolint: errcheck
+
+	return bytes.Clone(e.buf.Bytes())
+}
+
+// AdaptiveEncoding configures switching a subscription to a compact encoding under load.
+// The encoding is turned on when the throughput exceeds the threshold and turned off
+// when it goes below the half of the threshold.
+type AdaptiveEncoding struct {
+	// Encoding to use under load
+	Encoding Encoding
+	// Threshold is the throughput (in bytes per second) to switch the encoding on
+	Threshold int64
+	// Interval to measure the throughput (1s by default)
+	Interval time.Duration
+}
+
+type adaptiveState struct {
+	config AdaptiveEncoding
+
+	mu          sync.Mutex
+	active      bool
+	bytes       int64
+	windowStart time.Time
+}
+
+// WithAdaptiveEncoding makes the subscription switch to a compact encoding under load.
+// Every switch is announced via a control message ({"type":"control","event":"encoding","encoding":"<name>"}),
+// the "identity" encoding name means no encoding. The option is ignored if the encoding is nil.
+func WithAdaptiveEncoding(config AdaptiveEncoding) SubscriptionOption {
+	return func(s *Subscription) {
+		if config.Encoding == nil {
+			return
+		}
+
+		if config.Interval == 0 {
+			config.Interval = defaultAdaptiveInterval
+		}
+
+		s.adaptive = &adaptiveState{config: config, windowStart: time.Now()}
+	}
+}
+
+// WithSubscriptionControl sets a separate output for control messages (the subscription output is used by default).
+func WithSubscriptionControl(out SpyOutput) SubscriptionOption {
+	return func(s *Subscription) {
+		s.control = out
+	}
+}
+
+// Encoding returns the name of the encoding currently used by the subscription or an empty string if none.
+func (s *Subscription) Encoding() string {
+	if s.adaptive == nil {
+		return ""
+	}
+
+	s.adaptive.mu.Lock()
+	defer s.adaptive.mu.Unlock()
+
+	if s.adaptive.active {
+		return s.adaptive.config.Encoding.Name()
+	}
+
+	return ""
+}
+
+func (s *Subscription) encode(msg []byte) []byte {
+	a := s.adaptive
+
+	a.mu.Lock()
+
+	a.bytes += int64(len(msg))
+
+	switched := false
+
+	if elapsed := time.Since(a.windowStart); elapsed >= a.config.Interval {
+		rate := float64(a.bytes) / elapsed.Seconds()
+
+		if !a.active && rate > float64(a.config.Threshold) {
+			a.active = true
+			switched = true
+		} else if a.active && rate < float64(a.config.Threshold)/2 {
+			a.active = false
+			switched = true
+		}
+
+		a.bytes = 0
+		a.windowStart = time.Now()
+	}
+
+	active := a.active
+
+	a.mu.Unlock()
+
+	if switched {
+		name := "identity"
+
+		if active {
+			name = a.config.Encoding.Name()
+		}
+
+		s.sendControl(EventEncoding, map[string]any{"encoding": name})
+	}
+
+	if active {
+		return a.config.Encoding.Encode(msg)
+	}
+
+	return msg
+}
+
+func (s *Subscription) sendControl(event string, data map[string]any) {
+	out := s.control
+
+	if out == nil {
+		out = s.output
+	}
+
+	// Bare watchers have no output
+	if out == nil {
+		return
+	}
+
+	s.write(out, encodeControl(event, data))
+}
+
+func encodeControl(event string, data map[string]any) []byte {
+	payload := map[string]any{"type": "control", "event": event}
+
+	for k, v := range data {
+		payload[k] = v
+	}
+
+	msg, _ := json.Marshal(payload)
+
+	return append(msg, '\n')
+}
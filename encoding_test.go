@@ -0,0 +1,85 @@
+package slogspy
+
+import (
+	"bytes"
+	"compress/flate"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSubscription__AdaptiveEncoding(t *testing.T) {
+	spy := NewSpy(slog.NewTextHandler(&bytes.Buffer{}, nil))
+
+	var (
+		batches  [][]byte
+		controls []string
+	)
+
+	sub := spy.Subscribe(
+		func(msg []byte) { batches = append(batches, bytes.Clone(msg)) },
+		WithSubscriptionControl(func(msg []byte) { controls = append(controls, string(msg)) }),
+		WithAdaptiveEncoding(AdaptiveEncoding{
+			Encoding:  NewDeflateEncoding(flate.BestSpeed),
+			Threshold: 1000,
+			Interval:  10 * time.Millisecond,
+		}),
+	)
+
+	heavy := []byte(strings.Repeat(`{"msg":"load"}`+"\n", 100))
+
+	spy.handler.subs.deliver(heavy, 1)
+	time.Sleep(15 * time.Millisecond)
+	spy.handler.subs.deliver(heavy, 1)
+
+	if sub.Encoding() != "deflate" {
+		t.Fatalf("expected deflate encoding under load, got %q", sub.Encoding())
+	}
+
+	if len(controls) != 1 || !strings.Contains(controls[0], `"encoding":"deflate"`) {
+		t.Fatalf("expected encoding switch to be announced, got %v", controls)
+	}
+
+	decoded, err := io.ReadAll(flate.NewReader(bytes.NewReader(batches[1])))
+
+	if err != nil || !bytes.Equal(decoded, heavy) {
+		t.Errorf("expected encoded batch to be decodable, got %s (%v)", decoded, err)
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	spy.handler.subs.deliver([]byte("light\n"), 1)
+
+	if sub.Encoding() != "" {
+		t.Errorf("expected encoding to be turned off, got %q", sub.Encoding())
+	}
+
+	if len(controls) != 2 || !strings.Contains(controls[1], `"encoding":"identity"`) {
+		t.Errorf("expected encoding switch back to be announced, got %v", controls)
+	}
+
+	if string(batches[2]) != "light\n" {
+		t.Errorf("expected raw batch, got %q", batches[2])
+	}
+}
+
+func TestSubscription__AdaptiveEncoding_NilEncoding(t *testing.T) {
+	spy := NewSpy(slog.NewTextHandler(&bytes.Buffer{}, nil))
+
+	var delivered [][]byte
+
+	sub := spy.Subscribe(func(msg []byte) {
+		delivered = append(delivered, bytes.Clone(msg))
+	}, WithAdaptiveEncoding(AdaptiveEncoding{Threshold: 1}))
+
+	if sub.adaptive != nil {
+		t.Fatal("expected the adaptive encoding without an encoding to be ignored")
+	}
+
+	spy.handler.subs.deliver([]byte("plain"), 1)
+
+	if len(delivered) != 1 || string(delivered[0]) != "plain" {
+		t.Errorf("expected the batch to be delivered as is, got: %q", delivered)
+	}
+}
@@ -0,0 +1,74 @@
+package slogspy
+
+import (
+	"time"
+)
+
+// Lifecycle events emitted into the subscription stream as control messages
+const (
+	EventStart  = "start"
+	EventEnd    = "end"
+	EventFilter = "filter"
+	EventLevel  = "level"
+	EventPause  = "pause"
+	EventResume = "resume"
+	EventDrop   = "drop"
+	// EventThrottle notices are sent regardless of WithSubscriptionEvents (see WithSubscriptionThrottle)
+	EventThrottle = "throttle"
+	// EventPushdown announces the client filter split (see WithWebsocketFilterPushdown)
+	EventPushdown = "pushdown"
+	// EventSchema is the dictionary frame sent before any records (see WithSubscriptionSchema)
+	EventSchema = "schema"
+	// EventEncoding announces the encoding switches (see WithAdaptiveEncoding)
+	EventEncoding = "encoding"
+)
+
+// WithSubscriptionEvents makes the subscription receive lifecycle events (start, end, filter and level changes, pauses
+// and drops due to quotas) as control messages, so a recorded session is self-describing:
+//
+//	{"type":"control","event":"drop","reason":"quota","dropped":3,"time":"..."}
+func WithSubscriptionEvents() SubscriptionOption {
+	return func(s *Subscription) {
+		s.events = true
+	}
+}
+
+// Pause stops delivering batches to the subscription until Resume is called.
+// The subscription remains registered as a watcher.
+func (s *Subscription) Pause() {
+	if s.paused.CompareAndSwap(false, true) {
+		s.emit(EventPause, nil)
+	}
+}
+
+// Resume resumes delivering batches to the paused subscription.
+func (s *Subscription) Resume() {
+	if s.paused.CompareAndSwap(true, false) {
+		s.emit(EventResume, nil)
+	}
+}
+
+// Paused returns true if the subscription is paused.
+func (s *Subscription) Paused() bool {
+	return s.paused.Load()
+}
+
+func (s *Subscription) emit(event string, data map[string]any) {
+	if !s.events {
+		return
+	}
+
+	payload := map[string]any{"time": time.Now().UTC().Format(time.RFC3339Nano)}
+
+	for k, v := range data {
+		payload[k] = v
+	}
+
+	s.sendControl(event, payload)
+}
+
+func (ss *subscriptions) emit(event string, data map[string]any) {
+	for _, sub := range ss.list() {
+		sub.emit(event, data)
+	}
+}
@@ -0,0 +1,79 @@
+package slogspy
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSubscription__Events(t *testing.T) {
+	spy := NewSpy(slog.NewTextHandler(&bytes.Buffer{}, nil))
+
+	var messages []string
+
+	sub := spy.Subscribe(
+		func(msg []byte) { messages = append(messages, string(msg)) },
+		WithSubscriptionEvents(),
+		WithSubscriptionQuota(Quota{Bytes: 4, Window: time.Hour}),
+	)
+
+	spy.SetFilter(WhereAttr("user", "==", "42"))
+
+	sub.Pause()
+	spy.handler.subs.deliver([]byte("skip"), 1)
+	sub.Resume()
+
+	spy.handler.subs.deliver([]byte("1234"), 1)
+	spy.handler.subs.deliver([]byte("drop"), 1)
+
+	sub.Close()
+
+	var events []string
+
+	for _, msg := range messages {
+		if !strings.HasPrefix(msg, "{") {
+			events = append(events, msg)
+			continue
+		}
+
+		var event map[string]any
+
+		if err := json.Unmarshal([]byte(msg), &event); err != nil {
+			t.Fatalf("invalid control message: %s", msg)
+		}
+
+		if event["type"] != "control" || event["time"] == nil {
+			t.Errorf("unexpected control message: %s", msg)
+		}
+
+		events = append(events, event["event"].(string))
+	}
+
+	expected := []string{EventStart, EventFilter, EventPause, EventResume, "1234", EventDrop, EventEnd}
+
+	if strings.Join(events, ",") != strings.Join(expected, ",") {
+		t.Errorf("expected events %v, got %v", expected, events)
+	}
+
+	if last := messages[len(messages)-1]; !strings.Contains(last, `"reason":"subscription closed"`) {
+		t.Errorf("expected end event to contain the reason, got %s", last)
+	}
+}
+
+func TestSubscription__NoEvents(t *testing.T) {
+	spy := NewSpy(slog.NewTextHandler(&bytes.Buffer{}, nil))
+
+	buf := &bytes.Buffer{}
+	sub := spy.Subscribe(func(msg []byte) { buf.Write(msg) })
+
+	spy.SetFilter(nil)
+	sub.Pause()
+	sub.Close()
+
+	if buf.Len() != 0 {
+		t.Errorf("expected no events, got %s", buf.String())
+	}
+}
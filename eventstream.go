@@ -0,0 +1,137 @@
+package slogspy
+
+import (
+	"bytes"
+	"encoding/base64"
+	"net/http"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	defaultEventStreamSendBuffer   = 64
+	defaultEventStreamWriteTimeout = 10 * time.Second
+)
+
+// EventStreamHandler streams spied logs to HTTP clients as server-sent events (see EventStreamHandler).
+type EventStreamHandler struct {
+	source subscriber
+}
+
+var _ http.Handler = (*EventStreamHandler)(nil)
+
+// EventStreamHandler creates a handler streaming spied logs as server-sent events. Every connected client is
+// registered as a subscription; the level and filter query parameters are applied by the server (see WebsocketHandler).
+// Text batches are sent as "message" events (a data line per batch line), binary ones (e.g., compressed
+// or protobuf batches) are sent base64-encoded as "binary" events. Clients which can't keep up are disconnected.
+func (s *Spy) EventStreamHandler() *EventStreamHandler {
+	return &EventStreamHandler{source: s.handler}
+}
+
+func (h *EventStreamHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	server, client, err := splitQueryFilter(r.URL.Query(), FullPushdown)
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var opts []SubscriptionOption
+
+	if expr := andExpr(server, client); expr != "" {
+		f, _ := CompileFilter(expr)
+		opts = append(opts, WithSubscriptionFilter(f))
+	}
+
+	send := make(chan []byte, defaultEventStreamSendBuffer)
+	overflow := make(chan struct{})
+
+	var overflowOnce sync.Once
+
+	output := func(msg []byte) {
+		select {
+		case send <- encodeEvent(msg):
+		default:
+			// Closing the subscription emits the end event via this output, so we let the handler do that
+			overflowOnce.Do(func() { close(overflow) })
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+
+	rc := http.NewResponseController(w)
+
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	sub := h.source.newSubscription(output, opts...)
+	h.source.register(sub)
+	defer sub.Close()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-overflow:
+			return
+		case <-sub.Context().Done():
+			// Deliver the pending batches (e.g., the final one)
+			for {
+				select {
+				case event := <-send:
+					if writeEvent(w, rc, event) != nil {
+						return
+					}
+				default:
+					return
+				}
+			}
+		case event := <-send:
+			if writeEvent(w, rc, event) != nil {
+				return
+			}
+		}
+	}
+}
+
+func writeEvent(w http.ResponseWriter, rc *http.ResponseController, event []byte) error {
+	rc.SetWriteDeadline(time.Now().Add(defaultEventStreamWriteTimeout)) // nolint: errcheck
+
+	if _, err := w.Write(event); err != nil {
+		return err
+	}
+
+	return rc.Flush()
+}
+
+// encodeEvent converts the batch into a server-sent event (the batch buffer is reused by the spy after the output returns)
+func encodeEvent(msg []byte) []byte {
+	if IsCompressed(msg) || IsProtobuf(msg) || !utf8.Valid(msg) {
+		buf := make([]byte, 0, len("event: binary\ndata: \n\n")+base64.StdEncoding.EncodedLen(len(msg)))
+		buf = append(buf, "event: binary\ndata: "...)
+		buf = base64.StdEncoding.AppendEncode(buf, msg)
+
+		return append(buf, "\n\n"...)
+	}
+
+	lines := bytes.Split(bytes.TrimSuffix(msg, []byte("\n")), []byte("\n"))
+
+	buf := make([]byte, 0, len(msg)+len(lines)*len("data: \n")+1)
+
+	for _, line := range lines {
+		buf = append(buf, "data: "...)
+		buf = append(buf, bytes.TrimSuffix(line, []byte("\r"))...)
+		buf = append(buf, '\n')
+	}
+
+	return append(buf, '\n')
+}
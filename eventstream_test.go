@@ -0,0 +1,90 @@
+package slogspy
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEventStreamHandler(t *testing.T) {
+	spy := NewSpy(slog.NewTextHandler(&bytes.Buffer{}, nil), WithFlushInterval(10*time.Millisecond), WithFraming(NDJSON))
+
+	go spy.Run(context.Background(), nil)
+	defer spy.Shutdown(context.Background())
+
+	server := httptest.NewServer(spy.EventStreamHandler())
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "?level=warn&filter=" + `msg%3D~%22payment%22`)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer resp.Body.Close()
+
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("unexpected content type: %s", ct)
+	}
+
+	waitFor(t, func() bool { return spy.handler.active.Load() == 1 })
+
+	logger := slog.New(spy)
+	logger.Info("payment accepted")
+	logger.Warn("payment delayed")
+	logger.Error("database is down")
+
+	scanner := bufio.NewScanner(resp.Body)
+
+	if !scanner.Scan() {
+		t.Fatalf("failed to read event: %v", scanner.Err())
+	}
+
+	line := scanner.Text()
+
+	if !strings.HasPrefix(line, "data: ") || !strings.Contains(line, "payment delayed") {
+		t.Errorf("expected the record data line, got: %s", line)
+	}
+
+	if strings.Contains(line, "payment accepted") || strings.Contains(line, "database is down") {
+		t.Errorf("expected the level and filter to be applied, got: %s", line)
+	}
+
+	if scanner.Scan(); scanner.Text() != "" {
+		t.Errorf("expected the event to end, got: %s", scanner.Text())
+	}
+
+	resp.Body.Close()
+
+	waitFor(t, func() bool { return spy.handler.active.Load() == 0 })
+}
+
+func TestEventStreamHandler__InvalidFilter(t *testing.T) {
+	spy := NewSpy(slog.NewTextHandler(&bytes.Buffer{}, nil))
+
+	w := httptest.NewRecorder()
+	spy.EventStreamHandler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/?level=loud", nil))
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400, got %d", w.Code)
+	}
+}
+
+func TestEncodeEvent(t *testing.T) {
+	if got := string(encodeEvent([]byte("{\"a\":1}\n{\"b\":2}\n"))); got != "data: {\"a\":1}\ndata: {\"b\":2}\n\n" {
+		t.Errorf("unexpected text event: %q", got)
+	}
+
+	msg := []byte{0x28, 0xb5, 0x2f, 0xfd, 0x00}
+
+	if got := string(encodeEvent(msg)); got != "event: binary\ndata: "+base64.StdEncoding.EncodeToString(msg)+"\n\n" {
+		t.Errorf("unexpected binary event: %q", got)
+	}
+}
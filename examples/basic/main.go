@@ -0,0 +1,68 @@
+// This example streams debug logs to WebSocket clients connected to /logs
+// while the main handler prints only info logs to stderr.
+package main
+
+import (
+	"context"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"os"
+	"os/signal"
+	"time"
+
+	slogspy "github.com/palkan/slog-spy"
+)
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	handler := slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelInfo})
+	spy := slogspy.NewSpy(handler, slogspy.WithFraming(slogspy.NDJSON))
+
+	logger := slog.New(spy)
+
+	go spy.Run(ctx, nil) // nolint: errcheck
+
+	mux := http.NewServeMux()
+	mux.Handle("/logs", spy.WebsocketHandler())
+	mux.HandleFunc("/stats", func(w http.ResponseWriter, r *http.Request) {
+		logger.Info("stats requested", "stats", spy.Stats())
+	})
+
+	server := &http.Server{Addr: "localhost:8080", Handler: mux, ReadHeaderTimeout: time.Second}
+
+	go func() {
+		logger.Info("server started", "addr", server.Addr)
+
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			logger.Error("server failed", "error", err)
+			stop()
+		}
+	}()
+
+	go work(ctx, logger.With("component", "worker"))
+
+	<-ctx.Done()
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	server.Shutdown(shutdownCtx) // nolint: errcheck
+	spy.Shutdown(shutdownCtx)    // nolint: errcheck
+}
+
+func work(ctx context.Context, logger *slog.Logger) {
+	ticker := time.NewTicker(500 * time.Millisecond)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			logger.Debug("job processed", "duration", time.Duration(rand.IntN(100))*time.Millisecond)
+		}
+	}
+}
@@ -0,0 +1,402 @@
+package slogspy
+
+import (
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// CompileFilter compiles a filter expression into a filter, for example:
+//
+//	level>=warn && attrs.user_id=="42" && msg=~"payment"
+//
+// Fields are level, msg and attrs.<path> (see RecordView.Lookup); comparison operators are
+// ==, !=, >, >=, <, <= and =~, !~ (regular expressions); conditions are combined with &&, || and !,
+// and grouped with parentheses. Values are quoted strings, numbers, durations (e.g., 100ms), true/false,
+// and level names (for level comparisons). A field without a comparison checks that the attribute is present.
+func CompileFilter(expr string) (Filter, error) {
+	tokens, err := lexExpr(expr)
+
+	if err != nil {
+		return nil, err
+	}
+
+	p := &exprParser{tokens: tokens}
+
+	f, err := p.parseOr()
+
+	if err != nil {
+		return nil, err
+	}
+
+	if tok := p.peek(); tok.kind != tokEOF {
+		return nil, fmt.Errorf("unexpected %q at position %d", tok.text, tok.pos)
+	}
+
+	return f, nil
+}
+
+// SetFilterExpr compiles the filter expression (see CompileFilter) and replaces the spy's filter with it;
+// an empty expression removes the filter. The current filter is kept if the expression is invalid.
+func (h *SpyHandler) SetFilterExpr(expr string) error {
+	if strings.TrimSpace(expr) == "" {
+		h.SetFilter(nil)
+		return nil
+	}
+
+	f, err := CompileFilter(expr)
+
+	if err != nil {
+		return err
+	}
+
+	h.SetFilter(f)
+
+	return nil
+}
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokString
+	tokNumber
+	tokOp
+	tokAnd
+	tokOr
+	tokNot
+	tokLParen
+	tokRParen
+)
+
+type exprToken struct {
+	kind tokenKind
+	text string
+	pos  int
+	// end is the position right after the token in the source expression
+	end int
+}
+
+func lexExpr(expr string) ([]exprToken, error) {
+	var tokens []exprToken
+
+	for i := 0; i < len(expr); {
+		c := expr[i]
+		n := len(tokens)
+
+		switch {
+		case c == ' ' || c == '\t' || c == '\n':
+			i++
+		case c == '(':
+			tokens = append(tokens, exprToken{kind: tokLParen, text: "(", pos: i})
+			i++
+		case c == ')':
+			tokens = append(tokens, exprToken{kind: tokRParen, text: ")", pos: i})
+			i++
+		case strings.HasPrefix(expr[i:], "&&"):
+			tokens = append(tokens, exprToken{kind: tokAnd, text: "&&", pos: i})
+			i += 2
+		case strings.HasPrefix(expr[i:], "||"):
+			tokens = append(tokens, exprToken{kind: tokOr, text: "||", pos: i})
+			i += 2
+		case c == '"' || c == '`':
+			end := i + 1
+
+			for end < len(expr) && expr[end] != c {
+				if expr[end] == '\\' && c == '"' {
+					end++
+				}
+				end++
+			}
+
+			if end >= len(expr) {
+				return nil, fmt.Errorf("unterminated string at position %d", i)
+			}
+
+			s, err := strconv.Unquote(expr[i : end+1])
+
+			if err != nil {
+				return nil, fmt.Errorf("invalid string at position %d: %w", i, err)
+			}
+
+			tokens = append(tokens, exprToken{kind: tokString, text: s, pos: i})
+			i = end + 1
+		case strings.ContainsRune("=!<>", rune(c)):
+			op := string(c)
+
+			if i+1 < len(expr) && (expr[i+1] == '=' || expr[i+1] == '~') {
+				op += string(expr[i+1])
+			}
+
+			switch op {
+			case "!":
+				tokens = append(tokens, exprToken{kind: tokNot, text: op, pos: i})
+			case "==", "!=", ">", ">=", "<", "<=", "=~", "!~":
+				tokens = append(tokens, exprToken{kind: tokOp, text: op, pos: i})
+			default:
+				return nil, fmt.Errorf("unknown operator %q at position %d", op, i)
+			}
+
+			i += len(op)
+		case c == '-' || (c >= '0' && c <= '9'):
+			end := i + 1
+
+			for end < len(expr) && isIdentChar(expr[end]) {
+				end++
+			}
+
+			tokens = append(tokens, exprToken{kind: tokNumber, text: expr[i:end], pos: i})
+			i = end
+		case isIdentChar(c):
+			end := i + 1
+
+			for end < len(expr) && isIdentChar(expr[end]) {
+				end++
+			}
+
+			tokens = append(tokens, exprToken{kind: tokIdent, text: expr[i:end], pos: i})
+			i = end
+		default:
+			return nil, fmt.Errorf("unexpected character %q at position %d", c, i)
+		}
+
+		if len(tokens) > n {
+			tokens[n].end = i
+		}
+	}
+
+	return append(tokens, exprToken{kind: tokEOF, pos: len(expr), end: len(expr)}), nil
+}
+
+func isIdentChar(c byte) bool {
+	return c == '_' || c == '.' || c == '+' || unicode.IsLetter(rune(c)) || unicode.IsDigit(rune(c))
+}
+
+type exprParser struct {
+	tokens []exprToken
+	pos    int
+}
+
+func (p *exprParser) peek() exprToken {
+	return p.tokens[p.pos]
+}
+
+func (p *exprParser) next() exprToken {
+	tok := p.tokens[p.pos]
+
+	if tok.kind != tokEOF {
+		p.pos++
+	}
+
+	return tok
+}
+
+func (p *exprParser) parseOr() (Filter, error) {
+	left, err := p.parseAnd()
+
+	if err != nil {
+		return nil, err
+	}
+
+	for p.peek().kind == tokOr {
+		p.next()
+
+		right, err := p.parseAnd()
+
+		if err != nil {
+			return nil, err
+		}
+
+		l := left
+		left = func(r *RecordView) bool { return l(r) || right(r) }
+	}
+
+	return left, nil
+}
+
+func (p *exprParser) parseAnd() (Filter, error) {
+	left, err := p.parseUnary()
+
+	if err != nil {
+		return nil, err
+	}
+
+	for p.peek().kind == tokAnd {
+		p.next()
+
+		right, err := p.parseUnary()
+
+		if err != nil {
+			return nil, err
+		}
+
+		l := left
+		left = func(r *RecordView) bool { return l(r) && right(r) }
+	}
+
+	return left, nil
+}
+
+func (p *exprParser) parseUnary() (Filter, error) {
+	switch tok := p.next(); tok.kind {
+	case tokNot:
+		f, err := p.parseUnary()
+
+		if err != nil {
+			return nil, err
+		}
+
+		return func(r *RecordView) bool { return !f(r) }, nil
+	case tokLParen:
+		f, err := p.parseOr()
+
+		if err != nil {
+			return nil, err
+		}
+
+		if closing := p.next(); closing.kind != tokRParen {
+			return nil, fmt.Errorf("expected ) at position %d", closing.pos)
+		}
+
+		return f, nil
+	case tokIdent:
+		return p.parseCondition(tok)
+	default:
+		return nil, fmt.Errorf("expected condition at position %d", tok.pos)
+	}
+}
+
+func (p *exprParser) parseCondition(field exprToken) (Filter, error) {
+	var path string
+
+	switch {
+	case field.text == "level" || field.text == "msg":
+	case strings.HasPrefix(field.text, "attrs.") && len(field.text) > len("attrs."):
+		path = strings.TrimPrefix(field.text, "attrs.")
+	default:
+		return nil, fmt.Errorf("unknown field %q at position %d (expected level, msg or attrs.<path>)", field.text, field.pos)
+	}
+
+	if p.peek().kind != tokOp {
+		if path == "" {
+			return nil, fmt.Errorf("expected operator after %q at position %d", field.text, p.peek().pos)
+		}
+
+		return func(r *RecordView) bool {
+			_, ok := r.Lookup(path)
+			return ok
+		}, nil
+	}
+
+	op := p.next().text
+	operand := p.next()
+
+	if operand.kind != tokString && operand.kind != tokNumber && operand.kind != tokIdent {
+		return nil, fmt.Errorf("expected value at position %d", operand.pos)
+	}
+
+	if op == "=~" || op == "!~" {
+		re, err := regexp.Compile(operand.text)
+
+		if err != nil {
+			return nil, fmt.Errorf("invalid regular expression at position %d: %w", operand.pos, err)
+		}
+
+		match := func(s string) bool { return re.MatchString(s) == (op == "=~") }
+
+		switch field.text {
+		case "level":
+			return func(r *RecordView) bool { return match(r.Record.Level.String()) }, nil
+		case "msg":
+			return func(r *RecordView) bool { return match(r.Record.Message) }, nil
+		}
+
+		return func(r *RecordView) bool {
+			val, ok := r.Lookup(path)
+			return ok && match(val.Resolve().String())
+		}, nil
+	}
+
+	if field.text == "level" {
+		var level slog.Level
+
+		if err := level.UnmarshalText([]byte(operand.text)); err != nil {
+			n, nerr := strconv.Atoi(operand.text)
+
+			if nerr != nil {
+				return nil, fmt.Errorf("invalid level %q at position %d", operand.text, operand.pos)
+			}
+
+			level = slog.Level(n)
+		}
+
+		expected := slog.IntValue(int(level))
+
+		return func(r *RecordView) bool {
+			return compareValues(slog.IntValue(int(r.Record.Level)), op, expected)
+		}, nil
+	}
+
+	expected, err := operandValue(operand)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if field.text == "msg" {
+		return func(r *RecordView) bool { return compareExpr(slog.StringValue(r.Record.Message), op, expected) }, nil
+	}
+
+	return func(r *RecordView) bool {
+		val, ok := r.Lookup(path)
+		return ok && compareExpr(val.Resolve(), op, expected)
+	}, nil
+}
+
+func operandValue(tok exprToken) (slog.Value, error) {
+	switch tok.kind {
+	case tokString:
+		return slog.StringValue(tok.text), nil
+	case tokNumber:
+		if n, err := strconv.ParseInt(tok.text, 10, 64); err == nil {
+			return slog.Int64Value(n), nil
+		}
+
+		if f, err := strconv.ParseFloat(tok.text, 64); err == nil {
+			return slog.Float64Value(f), nil
+		}
+
+		if d, err := time.ParseDuration(tok.text); err == nil {
+			return slog.DurationValue(d), nil
+		}
+
+		return slog.Value{}, fmt.Errorf("invalid number %q at position %d", tok.text, tok.pos)
+	}
+
+	switch tok.text {
+	case "true":
+		return slog.BoolValue(true), nil
+	case "false":
+		return slog.BoolValue(false), nil
+	}
+
+	// Bare words are treated as strings (e.g., attrs.tenant==acme)
+	return slog.StringValue(tok.text), nil
+}
+
+// compareExpr is like compareValues but compares quoted values with the string representation
+// of non-string attributes (so attrs.user_id=="42" matches user_id=42)
+func compareExpr(actual slog.Value, op string, expected slog.Value) bool {
+	if expected.Kind() == slog.KindString && actual.Kind() != slog.KindString {
+		if _, ok := compareOrder(actual, expected); !ok {
+			actual = slog.StringValue(actual.String())
+		}
+	}
+
+	return compareValues(actual, op, expected)
+}
@@ -0,0 +1,115 @@
+package slogspy
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCompileFilter(t *testing.T) {
+	r := slog.NewRecord(time.Now(), slog.LevelWarn, "payment failed", 0)
+	r.AddAttrs(
+		slog.Int("user_id", 42),
+		slog.String("tenant", "acme"),
+		slog.Duration("took", 150*time.Millisecond),
+		slog.Bool("retry", true),
+		slog.Group("http", slog.Int("status", 503)),
+	)
+
+	view := &RecordView{Record: r}
+
+	for expr, expected := range map[string]bool{
+		`level>=warn && attrs.user_id=="42" && msg=~"payment"`: true,
+		`level>=error`:                            false,
+		`level==WARN`:                             true,
+		`level<8`:                                 true,
+		`msg=="payment failed"`:                   true,
+		`msg!~"^payment"`:                         false,
+		`attrs.user_id>40 && attrs.user_id<=42`:   true,
+		`attrs.user_id==43 || attrs.tenant==acme`: true,
+		`!(attrs.tenant=="acme")`:                 false,
+		`attrs.took>100ms`:                        true,
+		`attrs.took<1s`:                           true,
+		`attrs.retry==true`:                       true,
+		`attrs.http.status>=500`:                  true,
+		`attrs.http.status=~"^5"`:                 true,
+		`attrs.tenant`:                            true,
+		`attrs.missing`:                           false,
+		`!attrs.missing && (level<info || attrs.tenant!="globex")`: true,
+	} {
+		f, err := CompileFilter(expr)
+
+		if err != nil {
+			t.Fatalf("failed to compile %q: %v", expr, err)
+		}
+
+		if actual := f(view); actual != expected {
+			t.Errorf("expected %s to be %v, got %v", expr, expected, actual)
+		}
+	}
+}
+
+func TestCompileFilter__Errors(t *testing.T) {
+	for expr, message := range map[string]string{
+		`tenant=="acme"`:           "unknown field",
+		`level>=loud`:              "invalid level",
+		`msg=~"("`:                 "invalid regular expression",
+		`attrs.tenant=="acme`:      "unterminated string",
+		`(level>=warn`:             "expected )",
+		`level>=warn &&`:           "expected condition",
+		`level>=warn attrs.tenant`: "unexpected",
+		`msg`:                      "expected operator",
+		`attrs.tenant = "acme"`:    "unknown operator",
+	} {
+		_, err := CompileFilter(expr)
+
+		if err == nil || !strings.Contains(err.Error(), message) {
+			t.Errorf("expected %s to fail with %q, got: %v", expr, message, err)
+		}
+	}
+}
+
+func TestSpy__SetFilterExpr(t *testing.T) {
+	buf := &bytes.Buffer{}
+
+	spy := NewSpy(slog.NewTextHandler(&bytes.Buffer{}, nil))
+
+	go spy.Run(context.Background(), func(msg []byte) { // nolint: errcheck
+		buf.Write(msg)
+	})
+
+	spy.Watch()
+
+	if err := spy.SetFilterExpr(`attrs.tenant=="acme"`); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := spy.SetFilterExpr(`attrs.tenant=`); err == nil {
+		t.Error("expected invalid expression to fail")
+	}
+
+	logger := slog.New(spy)
+	logger.Info("acme request", "tenant", "acme")
+	logger.Info("globex request", "tenant", "globex")
+
+	if err := spy.handler.requestFlush(context.Background(), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := spy.SetFilterExpr(""); err != nil {
+		t.Fatal(err)
+	}
+
+	logger.Info("initech request", "tenant", "initech")
+
+	if err := spy.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	assertBufferContains(t, buf, "acme request")
+	assertBufferContainsNot(t, buf, "globex request")
+	assertBufferContains(t, buf, "initech request")
+}
@@ -0,0 +1,284 @@
+package slogspy
+
+import (
+	"log/slog"
+	"reflect"
+	"strings"
+)
+
+// Filter decides whether a record should be spied.
+type Filter func(r *RecordView) bool
+
+// RecordView provides filters access to a record along with the attributes and groups
+// added to the logger via WithAttrs and WithGroup.
+type RecordView struct {
+	Record slog.Record
+
+	groups []string
+	frames []attrFrame
+}
+
+// attrFrame contains attributes added via WithAttrs when the specified number of groups was open
+type attrFrame struct {
+	depth int
+	attrs []slog.Attr
+}
+
+// Groups returns the names of the groups opened via WithGroup.
+func (v *RecordView) Groups() []string {
+	return v.groups
+}
+
+// Lookup returns the value of the attribute by its dot-separated path (e.g., "http.request.status").
+// Paths include the groups opened via WithGroup; record attributes take precedence over the logger ones.
+func (v *RecordView) Lookup(path string) (slog.Value, bool) {
+	keys := strings.Split(path, ".")
+
+	if val, ok := v.lookupAt(len(v.groups), keys, v.recordAttrs()); ok {
+		return val, true
+	}
+
+	for i := len(v.frames) - 1; i >= 0; i-- {
+		frame := v.frames[i]
+
+		if val, ok := v.lookupAt(frame.depth, keys, frame.attrs); ok {
+			return val, true
+		}
+	}
+
+	return slog.Value{}, false
+}
+
+func (v *RecordView) recordAttrs() []slog.Attr {
+	attrs := make([]slog.Attr, 0, v.Record.NumAttrs())
+
+	v.Record.Attrs(func(a slog.Attr) bool {
+		attrs = append(attrs, a)
+		return true
+	})
+
+	return attrs
+}
+
+// lookupAt looks up the keys in the attributes nested into the specified number of groups
+func (v *RecordView) lookupAt(depth int, keys []string, attrs []slog.Attr) (slog.Value, bool) {
+	if len(keys) <= depth {
+		return slog.Value{}, false
+	}
+
+	for i := 0; i < depth; i++ {
+		if keys[i] != v.groups[i] {
+			return slog.Value{}, false
+		}
+	}
+
+	return lookupAttrs(keys[depth:], attrs)
+}
+
+func lookupAttrs(keys []string, attrs []slog.Attr) (slog.Value, bool) {
+	var (
+		found slog.Value
+		ok    bool
+	)
+
+	// The last attribute with the same key wins
+	for _, a := range attrs {
+		val := a.Value.Resolve()
+
+		if a.Key == "" && val.Kind() == slog.KindGroup {
+			if nested, nestedOk := lookupAttrs(keys, val.Group()); nestedOk {
+				found, ok = nested, true
+			}
+			continue
+		}
+
+		if a.Key != keys[0] {
+			continue
+		}
+
+		if len(keys) == 1 {
+			found, ok = val, true
+			continue
+		}
+
+		if val.Kind() == slog.KindGroup {
+			if nested, nestedOk := lookupAttrs(keys[1:], val.Group()); nestedOk {
+				found, ok = nested, true
+			}
+		}
+	}
+
+	return found, ok
+}
+
+// WithFilter sets the filter for spied records. Records not matching the filter are skipped.
+func WithFilter(f Filter) SpyHandlerOption {
+	return func(h *SpyHandler) {
+		h.filter.Store(&f)
+	}
+}
+
+// SetFilter replaces the filter for spied records; nil removes the filter.
+func (h *SpyHandler) SetFilter(f Filter) {
+	if f == nil {
+		h.filter.Store(nil)
+	} else {
+		h.filter.Store(&f)
+	}
+
+	h.subs.emit(EventFilter, map[string]any{"active": f != nil})
+}
+
+// WithLevel sets the minimum level of spied records (all levels are spied by default).
+func WithLevel(level slog.Level) SpyHandlerOption {
+	return func(h *SpyHandler) {
+		h.level.Store(&level)
+	}
+}
+
+// SetLevel changes the minimum level of spied records.
+func (h *SpyHandler) SetLevel(level slog.Level) {
+	h.level.Store(&level)
+
+	h.subs.emit(EventLevel, map[string]any{"level": level.String()})
+}
+
+// ResetLevel removes the minimum level, so records of all levels are spied.
+func (h *SpyHandler) ResetLevel() {
+	h.level.Store(nil)
+
+	h.subs.emit(EventLevel, map[string]any{"level": ""})
+}
+
+// Level returns the minimum level of spied records; false means all levels are spied.
+func (h *SpyHandler) Level() (slog.Level, bool) {
+	if level := h.level.Load(); level != nil {
+		return *level, true
+	}
+
+	return 0, false
+}
+
+func (h *SpyHandler) matches(entry *Entry) bool {
+	f := h.filter.Load()
+
+	if f == nil {
+		return true
+	}
+
+	return (*f)(&RecordView{Record: entry.record, groups: entry.groups, frames: entry.frames})
+}
+
+// WhereAttr returns a filter comparing the attribute value at the path with the provided value.
+// Supported operators are ==, !=, >, >=, <, <=. Numbers (including durations) are compared numerically,
+// strings are compared lexicographically; records without the attribute don't match.
+func WhereAttr(path string, op string, value any) Filter {
+	expected := slog.AnyValue(value)
+
+	return func(r *RecordView) bool {
+		actual, ok := r.Lookup(path)
+
+		if !ok {
+			return false
+		}
+
+		return compareValues(actual, op, expected)
+	}
+}
+
+func compareValues(actual slog.Value, op string, expected slog.Value) bool {
+	cmp, ok := compareOrder(actual, expected)
+
+	if !ok {
+		switch op {
+		case "==":
+			return valuesEqual(actual, expected)
+		case "!=":
+			return !valuesEqual(actual, expected)
+		}
+
+		return false
+	}
+
+	switch op {
+	case "==":
+		return cmp == 0
+	case "!=":
+		return cmp != 0
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	}
+
+	return false
+}
+
+// valuesEqual is like slog.Value.Equal but doesn't panic on uncomparable values (e.g., slices)
+func valuesEqual(a slog.Value, b slog.Value) bool {
+	if a.Kind() != b.Kind() {
+		return false
+	}
+
+	switch a.Kind() {
+	case slog.KindAny:
+		return reflect.DeepEqual(a.Any(), b.Any())
+	case slog.KindGroup:
+		ga, gb := a.Group(), b.Group()
+
+		if len(ga) != len(gb) {
+			return false
+		}
+
+		for i := range ga {
+			if ga[i].Key != gb[i].Key || !valuesEqual(ga[i].Value, gb[i].Value) {
+				return false
+			}
+		}
+
+		return true
+	default:
+		return a.Equal(b)
+	}
+}
+
+// compareOrder returns -1, 0 or 1 if the values are comparable (both numeric or both strings)
+func compareOrder(a slog.Value, b slog.Value) (int, bool) {
+	if an, ok := numericValue(a); ok {
+		if bn, ok := numericValue(b); ok {
+			switch {
+			case an < bn:
+				return -1, true
+			case an > bn:
+				return 1, true
+			default:
+				return 0, true
+			}
+		}
+	}
+
+	if a.Kind() == slog.KindString && b.Kind() == slog.KindString {
+		return strings.Compare(a.String(), b.String()), true
+	}
+
+	return 0, false
+}
+
+func numericValue(v slog.Value) (float64, bool) {
+	switch v.Kind() {
+	case slog.KindInt64:
+		return float64(v.Int64()), true
+	case slog.KindUint64:
+		return float64(v.Uint64()), true
+	case slog.KindFloat64:
+		return v.Float64(), true
+	case slog.KindDuration:
+		return float64(v.Duration()), true
+	}
+
+	return 0, false
+}
@@ -0,0 +1,117 @@
+package slogspy
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+func TestSpy__WithFilter(t *testing.T) {
+	buf := &bytes.Buffer{}
+
+	spy := NewSpy(slog.NewTextHandler(&bytes.Buffer{}, nil), WithFilter(WhereAttr("http.request.status", ">=", 500)))
+
+	go spy.Run(context.Background(), func(msg []byte) { // nolint: errcheck
+		buf.Write(msg)
+	})
+
+	spy.Watch()
+
+	logger := slog.New(spy).WithGroup("http").WithGroup("request")
+	logger.Info("failed", "status", 503)
+	logger.Info("succeeded", "status", 200)
+	logger.Info("unknown")
+
+	if err := spy.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	assertBufferContains(t, buf, "failed")
+	assertBufferContainsNot(t, buf, "succeeded")
+	assertBufferContainsNot(t, buf, "unknown")
+}
+
+func TestSpy__SetLevel(t *testing.T) {
+	buf := &bytes.Buffer{}
+
+	spy := NewSpy(slog.NewTextHandler(&bytes.Buffer{}, nil), WithLevel(slog.LevelInfo))
+
+	go spy.Run(context.Background(), func(msg []byte) { // nolint: errcheck
+		buf.Write(msg)
+	})
+
+	spy.Watch()
+
+	logger := slog.New(spy)
+	logger.Debug("debug details")
+	logger.Info("info message")
+
+	spy.SetLevel(slog.LevelError)
+
+	logger.Warn("warn message")
+	logger.Error("error message")
+
+	if err := spy.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	assertBufferContainsNot(t, buf, "debug details")
+	assertBufferContains(t, buf, "info message")
+	assertBufferContainsNot(t, buf, "warn message")
+	assertBufferContains(t, buf, "error message")
+}
+
+func TestRecordView__Lookup(t *testing.T) {
+	r := slog.NewRecord(time.Now(), slog.LevelInfo, "test", 0)
+	r.AddAttrs(
+		slog.Int("status", 201),
+		slog.Group("response", slog.Duration("duration", time.Second)),
+		slog.Group("", slog.String("inline", "yes")),
+	)
+
+	view := &RecordView{
+		Record: r,
+		groups: []string{"http", "request"},
+		frames: []attrFrame{
+			{depth: 0, attrs: []slog.Attr{slog.String("service", "api")}},
+			{depth: 1, attrs: []slog.Attr{slog.String("method", "GET")}},
+			{depth: 2, attrs: []slog.Attr{slog.Int("status", 100)}},
+		},
+	}
+
+	cases := map[string]any{
+		"service":                        "api",
+		"http.method":                    "GET",
+		"http.request.status":            int64(201),
+		"http.request.response.duration": time.Second,
+		"http.request.inline":            "yes",
+	}
+
+	for path, expected := range cases {
+		val, ok := view.Lookup(path)
+
+		if !ok || !val.Equal(slog.AnyValue(expected)) {
+			t.Errorf("expected %s to be %v, got %v (found: %t)", path, expected, val, ok)
+		}
+	}
+
+	if val, ok := view.Lookup("http.request.response"); !ok || val.Kind() != slog.KindGroup {
+		t.Errorf("expected http.request.response to be a group, got %v", val)
+	}
+
+	for _, path := range []string{"method", "http.status", "http.request.missing"} {
+		if val, ok := view.Lookup(path); ok {
+			t.Errorf("expected %s to be missing, got %v", path, val)
+		}
+	}
+
+	if !WhereAttr("http.request.response.duration", "<", 2*time.Second)(view) {
+		t.Error("expected duration to be compared numerically")
+	}
+
+	if !WhereAttr("http.method", "!=", "POST")(view) {
+		t.Error("expected strings to be compared")
+	}
+}
@@ -0,0 +1,113 @@
+package slogspy
+
+import (
+	"log/slog"
+)
+
+type valueFormatter func(v any) (slog.Value, bool)
+
+// WithRedactor sets a function to mask or remove sensitive attributes before they reach the spy output.
+// It has the same semantics as slog.HandlerOptions.ReplaceAttr: groups contains the names of the groups
+// the attribute belongs to, and returning a zero Attr discards the attribute.
+// The redactor is only applied to the spied records and is called before the value formatters.
+func WithRedactor(fn func(groups []string, a slog.Attr) slog.Attr) SpyHandlerOption {
+	return func(h *SpyHandler) {
+		h.redactor = fn
+	}
+}
+
+// WithFormatter registers a formatter for attribute values of the type T (which could be an interface).
+// Formatters are only applied to the spied records; the parent handler receives the original values.
+//
+//	slogspy.WithFormatter(func(d time.Duration) slog.Value {
+//		return slog.StringValue(d.String())
+//	})
+func WithFormatter[T any](fn func(v T) slog.Value) SpyHandlerOption {
+	return func(h *SpyHandler) {
+		h.formatters = append(h.formatters, func(v any) (slog.Value, bool) {
+			if tv, ok := v.(T); ok {
+				return fn(tv), true
+			}
+
+			return slog.Value{}, false
+		})
+	}
+}
+
+func (h *SpyHandler) formatRecord(groups []string, r slog.Record) slog.Record {
+	if !h.hasFormatting() {
+		return r
+	}
+
+	fr := slog.NewRecord(r.Time, r.Level, r.Message, r.PC)
+
+	r.Attrs(func(a slog.Attr) bool {
+		if fa, ok := h.formatAttr(groups, a); ok {
+			fr.AddAttrs(fa)
+		}
+		return true
+	})
+
+	return fr
+}
+
+func (h *SpyHandler) formatAttrs(groups []string, attrs []slog.Attr) []slog.Attr {
+	if !h.hasFormatting() {
+		return attrs
+	}
+
+	formatted := make([]slog.Attr, 0, len(attrs))
+
+	for _, a := range attrs {
+		if fa, ok := h.formatAttr(groups, a); ok {
+			formatted = append(formatted, fa)
+		}
+	}
+
+	return formatted
+}
+
+// formatAttr applies the redactor, formatters and offloading to the attribute;
+// it returns false if the attribute must be discarded
+func (h *SpyHandler) formatAttr(groups []string, a slog.Attr) (slog.Attr, bool) {
+	a.Value = a.Value.Resolve()
+
+	if a.Value.Kind() == slog.KindGroup {
+		nested := groups
+
+		// Inline groups (with empty keys) don't change the path
+		if a.Key != "" {
+			nested = append(groups[:len(groups):len(groups)], a.Key)
+		}
+
+		a.Value = slog.GroupValue(h.formatAttrs(nested, a.Value.Group())...)
+		return a, true
+	}
+
+	if h.redactor != nil {
+		a = h.redactor(groups, a)
+
+		if a.Equal(slog.Attr{}) {
+			return a, false
+		}
+
+		a.Value = a.Value.Resolve()
+	}
+
+	for _, fn := range h.formatters {
+		if fv, ok := fn(a.Value.Any()); ok {
+			a.Value = fv
+			break
+		}
+	}
+
+	if h.offloadStore != nil {
+		a.Value = h.offloadValue(a.Value)
+	}
+
+	return a, true
+}
+
+func (h *SpyHandler) hasFormatting() bool {
+	return len(h.formatters) > 0 || h.offloadStore != nil || h.redactor != nil
+}
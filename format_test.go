@@ -0,0 +1,105 @@
+package slogspy
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+type formatterTestUser struct {
+	ID   int
+	Name string
+}
+
+func (u formatterTestUser) String() string {
+	return fmt.Sprintf("user#%d", u.ID)
+}
+
+func TestSpy__WithFormatter(t *testing.T) {
+	mainBuf := &bytes.Buffer{}
+	buf := &bytes.Buffer{}
+
+	spy := NewSpy(
+		slog.NewTextHandler(mainBuf, nil),
+		WithFormatter(func(d time.Duration) slog.Value {
+			return slog.StringValue(fmt.Sprintf("%.1fms", float64(d)/float64(time.Millisecond)))
+		}),
+		WithFormatter(func(s fmt.Stringer) slog.Value {
+			return slog.StringValue(s.String())
+		}),
+	)
+
+	go spy.Run(context.Background(), func(msg []byte) {
+		buf.Write(msg)
+	})
+
+	spy.Watch()
+
+	logger := slog.New(spy).With("user", formatterTestUser{ID: 42, Name: "john"})
+	logger.Info("request", "duration", 12300*time.Microsecond, slog.Group("db", "duration", 2*time.Millisecond))
+
+	if err := spy.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	assertBufferContains(t, buf, `"duration":"12.3ms"`)
+	assertBufferContains(t, buf, `"db":{"duration":"2.0ms"}`)
+	assertBufferContains(t, buf, `"user":"user#42"`)
+
+	assertBufferContains(t, mainBuf, "duration=12.3ms")
+	assertBufferContains(t, mainBuf, "user=user#42")
+	assertBufferContainsNot(t, mainBuf, "2.0ms")
+}
+
+func TestSpy__WithRedactor(t *testing.T) {
+	mainBuf := &bytes.Buffer{}
+	buf := &bytes.Buffer{}
+
+	var paths []string
+
+	spy := NewSpy(
+		slog.NewTextHandler(mainBuf, nil),
+		WithRedactor(func(groups []string, a slog.Attr) slog.Attr {
+			paths = append(paths, strings.Join(append(groups, a.Key), "."))
+
+			switch a.Key {
+			case "password", "token":
+				return slog.String(a.Key, "[FILTERED]")
+			case "email":
+				return slog.Attr{}
+			}
+
+			return a
+		}),
+	)
+
+	go spy.Run(context.Background(), func(msg []byte) { // nolint: errcheck
+		buf.Write(msg)
+	})
+
+	spy.Watch()
+
+	logger := slog.New(spy).WithGroup("req").With("token", "secret-token")
+	logger.Info("login", "password", "secret-password", "email", "john@example.com", slog.Group("user", "id", 42))
+
+	if err := spy.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	assertBufferContains(t, buf, `"req":{"token":"[FILTERED]","password":"[FILTERED]","user":{"id":42}}`)
+	assertBufferContainsNot(t, buf, "secret")
+	assertBufferContainsNot(t, buf, "email")
+
+	assertBufferContains(t, mainBuf, "req.password=secret-password")
+	assertBufferContains(t, mainBuf, "req.email=john@example.com")
+
+	expectedPaths := []string{"req.token", "req.password", "req.email", "req.user.id"}
+
+	if strings.Join(paths, ",") != strings.Join(expectedPaths, ",") {
+		t.Errorf("expected redactor to be called with %v, got %v", expectedPaths, paths)
+	}
+}
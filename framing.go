@@ -0,0 +1,174 @@
+package slogspy
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"strconv"
+	"time"
+)
+
+// Framing defines how records are framed within flushed batches.
+type Framing int
+
+const (
+	// FramingRaw delivers whatever the printer writes (the default).
+	FramingRaw Framing = iota
+	// NDJSON guarantees that each record is a complete JSON object terminated by a newline.
+	// Records which are not valid JSON objects (e.g., produced by a text printer) are wrapped into {"raw":"<record>"}.
+	NDJSON
+	// NDJSONEnvelope wraps each batch of NDJSON records into {"count":N,"records":[...],"meta":[...]}
+	// (the envelope itself is terminated by a newline). The meta array contains an object per record
+	// with the numeric level and the severity class: {"level":8,"severity":"error"}.
+	NDJSONEnvelope
+)
+
+// Severity classes used in the frame metadata
+const (
+	SeverityDebug = "debug"
+	SeverityInfo  = "info"
+	SeverityWarn  = "warn"
+	SeverityError = "error"
+)
+
+// DefaultSeverityClass maps levels to severity classes by the closest standard level below.
+func DefaultSeverityClass(level slog.Level) string {
+	switch {
+	case level < slog.LevelInfo:
+		return SeverityDebug
+	case level < slog.LevelWarn:
+		return SeverityInfo
+	case level < slog.LevelError:
+		return SeverityWarn
+	default:
+		return SeverityError
+	}
+}
+
+// WithSeverityClass sets a function to map (custom) levels to severity classes used in the frame metadata.
+func WithSeverityClass(fn func(level slog.Level) string) SpyHandlerOption {
+	return func(h *SpyHandler) {
+		h.severityClass = fn
+	}
+}
+
+// WithFraming sets the framing of flushed batches.
+func WithFraming(f Framing) SpyHandlerOption {
+	return func(h *SpyHandler) {
+		h.framing = f
+	}
+}
+
+// WithBatchMetadata makes the spy wrap batches into envelopes (see NDJSONEnvelope) with the metadata to detect gaps
+// on lossy transports: the sequence number (incremented with every batch, starting from 1), the batch timestamp
+// and the number of records dropped since the previous batch:
+//
+//	{"seq":42,"time":"...","count":N,"records":[...],"meta":[...],"missing":3,"dropped":{"queue_full":3}}
+//
+// Subscriptions with their own batches (see WithSubscriptionFilter) have their own sequences (without the drops).
+func WithBatchMetadata() SpyHandlerOption {
+	return func(h *SpyHandler) {
+		h.framing = NDJSONEnvelope
+		h.batchMetadata = true
+	}
+}
+
+// frameRecord makes sure that the last record written to the buffer (starting at the offset) is a complete JSON line
+func (h *SpyHandler) frameRecord(start int, level slog.Level) {
+	if h.frameBuffer(h.buf, start) {
+		h.batchLevels = append(h.batchLevels, level)
+	}
+}
+
+// frameBuffer frames the record written to the buffer starting at the offset;
+// it returns false if there is nothing to frame (or the framing is raw)
+func (h *SpyHandler) frameBuffer(buf *bytes.Buffer, start int) bool {
+	if h.framing == FramingRaw || buf.Len() == start {
+		return false
+	}
+
+	// Protobuf records (e.g., written by subscription printers) are not framed
+	if IsProtobuf(buf.Bytes()[start:]) {
+		return false
+	}
+
+	record := bytes.TrimSpace(buf.Bytes()[start:])
+
+	if len(record) > 0 && record[0] == '{' && json.Valid(record) {
+		// The record could be a multiline (pretty-printed) JSON
+		if bytes.IndexByte(record, '\n') == -1 {
+			buf.Truncate(start + len(record))
+		} else {
+			var compacted bytes.Buffer
+			json.Compact(&compacted, record) // nolint: errcheck
+			buf.Truncate(start)
+			buf.Write(compacted.Bytes())
+		}
+	} else {
+		raw, _ := json.Marshal(map[string]string{"raw": string(record)})
+		buf.Truncate(start)
+		buf.Write(raw)
+	}
+
+	buf.WriteByte('\n')
+
+	return true
+}
+
+// frameBatch returns the batch to deliver according to the framing; the sequence number is incremented
+// if the batch metadata is enabled. The envelope of the spy's batch also includes the drops since the previous batch
+func (h *SpyHandler) frameBatch(msg []byte, levels []slog.Level, seq *int64, reportDrops bool) []byte {
+	if h.framing != NDJSONEnvelope {
+		return msg
+	}
+
+	envelope := make([]byte, 0, len(msg)+64)
+	envelope = append(envelope, '{')
+
+	if h.batchMetadata {
+		*seq++
+
+		envelope = append(envelope, `"seq":`...)
+		envelope = strconv.AppendInt(envelope, *seq, 10)
+		envelope = append(envelope, `,"time":"`...)
+		envelope = time.Now().UTC().AppendFormat(envelope, time.RFC3339Nano)
+		envelope = append(envelope, `",`...)
+	}
+
+	envelope = append(envelope, `"count":`...)
+	envelope = strconv.AppendInt(envelope, int64(len(levels)), 10)
+	envelope = append(envelope, `,"records":[`...)
+	envelope = append(envelope, bytes.ReplaceAll(bytes.TrimSuffix(msg, []byte{'\n'}), []byte{'\n'}, []byte{','})...)
+	envelope = append(envelope, `],"meta":[`...)
+
+	for i, level := range levels {
+		if i > 0 {
+			envelope = append(envelope, ',')
+		}
+
+		envelope = append(envelope, `{"level":`...)
+		envelope = strconv.AppendInt(envelope, int64(level), 10)
+		envelope = append(envelope, `,"severity":`...)
+		envelope = strconv.AppendQuote(envelope, h.severityClass(level))
+		envelope = append(envelope, '}')
+	}
+
+	envelope = append(envelope, ']')
+
+	if reportDrops {
+		deltas := h.batchDrops()
+
+		if h.batchMetadata {
+			envelope = append(envelope, `,"missing":`...)
+			envelope = strconv.AppendInt(envelope, missingRecords(deltas), 10)
+		}
+
+		if withDrops, ok := appendBatchDrops(append(envelope, `,"dropped":`...), deltas); ok {
+			envelope = withDrops
+		}
+	}
+
+	envelope = append(envelope, "}\n"...)
+
+	return envelope
+}
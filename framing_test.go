@@ -0,0 +1,172 @@
+package slogspy
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"testing"
+)
+
+func TestSpy__WithFraming_NDJSON(t *testing.T) {
+	buf := &bytes.Buffer{}
+
+	spy := NewSpy(
+		slog.NewTextHandler(&bytes.Buffer{}, nil),
+		WithFraming(NDJSON),
+		WithPrinter(func(w io.Writer) slog.Handler {
+			return slog.NewTextHandler(w, nil)
+		}),
+	)
+
+	go spy.Run(context.Background(), func(msg []byte) { buf.Write(msg) }) // nolint: errcheck
+
+	spy.Watch()
+
+	logger := slog.New(spy).WithGroup("req").With("id", 1)
+	logger.Info("first")
+	logger.Info("second")
+
+	if err := spy.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	scanner := bufio.NewScanner(buf)
+	lines := 0
+
+	for scanner.Scan() {
+		var record map[string]string
+
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			t.Fatalf("expected a JSON line, got %s", scanner.Bytes())
+		}
+
+		if record["raw"] == "" {
+			t.Errorf("expected text record to be wrapped, got %v", record)
+		}
+
+		lines++
+	}
+
+	if lines != 2 {
+		t.Errorf("expected 2 lines, got %d", lines)
+	}
+}
+
+func TestSpy__WithFraming_Envelope(t *testing.T) {
+	var batches [][]byte
+
+	spy := NewSpy(
+		slog.NewTextHandler(&bytes.Buffer{}, nil),
+		WithFraming(NDJSONEnvelope),
+		WithSeverityClass(func(level slog.Level) string {
+			if level >= slog.LevelError+4 {
+				return "fatal"
+			}
+			return DefaultSeverityClass(level)
+		}),
+	)
+
+	go spy.Run(context.Background(), func(msg []byte) { batches = append(batches, bytes.Clone(msg)) }) // nolint: errcheck
+
+	spy.Watch()
+
+	logger := slog.New(spy)
+	logger.Info("first", "n", 1)
+	logger.Warn("second", "n", 2)
+	logger.Log(context.Background(), slog.LevelError+4, "third")
+
+	if err := spy.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(batches) != 1 {
+		t.Fatalf("expected 1 batch, got %d", len(batches))
+	}
+
+	var envelope struct {
+		Count   int `json:"count"`
+		Records []struct {
+			Msg string `json:"msg"`
+			N   int    `json:"n"`
+		} `json:"records"`
+		Meta []struct {
+			Level    int    `json:"level"`
+			Severity string `json:"severity"`
+		} `json:"meta"`
+	}
+
+	if err := json.Unmarshal(batches[0], &envelope); err != nil {
+		t.Fatalf("failed to parse envelope: %v: %s", err, batches[0])
+	}
+
+	if envelope.Count != 3 || len(envelope.Records) != 3 || envelope.Records[1].Msg != "second" || envelope.Records[1].N != 2 {
+		t.Errorf("unexpected envelope: %s", batches[0])
+	}
+
+	if len(envelope.Meta) != 3 ||
+		envelope.Meta[0].Severity != "info" ||
+		envelope.Meta[1].Severity != "warn" || envelope.Meta[1].Level != 4 ||
+		envelope.Meta[2].Severity != "fatal" {
+		t.Errorf("unexpected meta: %s", batches[0])
+	}
+}
+
+func TestSpy__WithBatchMetadata(t *testing.T) {
+	var batches [][]byte
+
+	spy := NewSpy(slog.NewTextHandler(io.Discard, nil), WithBatchMetadata(), WithMaxRecordSize(100))
+
+	go spy.Run(context.Background(), func(msg []byte) { // nolint: errcheck
+		batches = append(batches, bytes.Clone(msg))
+	})
+
+	spy.Watch()
+
+	logger := slog.New(spy)
+
+	logger.Info("first")
+
+	if err := spy.handler.requestFlush(context.Background(), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	logger.Info("oversized", "payload", string(bytes.Repeat([]byte("x"), 200)))
+	logger.Info("second")
+
+	if err := spy.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(batches) != 2 {
+		t.Fatalf("expected 2 batches, got %d", len(batches))
+	}
+
+	for i, batch := range batches {
+		var envelope struct {
+			Seq     int64            `json:"seq"`
+			Time    string           `json:"time"`
+			Count   int              `json:"count"`
+			Missing int64            `json:"missing"`
+			Dropped map[string]int64 `json:"dropped"`
+		}
+
+		if err := json.Unmarshal(batch, &envelope); err != nil {
+			t.Fatalf("invalid envelope: %s", batch)
+		}
+
+		if envelope.Seq != int64(i+1) {
+			t.Errorf("expected sequence number %d, got %d", i+1, envelope.Seq)
+		}
+
+		if envelope.Time == "" || envelope.Count != 1 {
+			t.Errorf("expected timestamp and count, got: %s", batch)
+		}
+
+		if expected := int64(i); envelope.Missing != expected || envelope.Dropped["oversized"] != expected {
+			t.Errorf("expected %d missing records in batch %d, got: %s", expected, i+1, batch)
+		}
+	}
+}
@@ -1,3 +1,5 @@
 module github.com/palkan/slog-spy
 
 go 1.22.2
+
+require github.com/klauspost/compress v1.18.0
@@ -0,0 +1,111 @@
+package slogspy
+
+import (
+	"bytes"
+	"context"
+	"runtime"
+	"strconv"
+	"sync"
+	"sync/atomic"
+)
+
+// CaptureGoroutine is like CaptureContext but also captures the records logged by the current goroutine without the
+// returned context (e.g., via slog.Info) and by the child goroutines started via Go. It's useful to debug one worker
+// in a pool without seeing its siblings. Looking up the goroutine has a cost, so it's only done while there are
+// active goroutine captures.
+func (h *SpyHandler) CaptureGoroutine(parent context.Context, out SpyOutput, opts ...SubscriptionOption) (context.Context, *Subscription) {
+	ctx, sub := h.CaptureContext(parent, out, opts...)
+
+	h.subs.goroutines.bind(goroutineID(), sub)
+
+	return ctx, sub
+}
+
+// Go runs the function in a new goroutine, which inherits the goroutine capture from the context (see CaptureGoroutine).
+func Go(ctx context.Context, fn func(ctx context.Context)) {
+	sub, _ := ctx.Value(captureContextKey{}).(*Subscription)
+
+	go func() {
+		if sub != nil && !sub.Closed() {
+			sub.handler.subs.goroutines.bind(goroutineID(), sub)
+		}
+
+		fn(ctx)
+	}()
+}
+
+// captureFromGoroutine returns the goroutine capture of the current goroutine (if any)
+func (h *SpyHandler) captureFromGoroutine() *Subscription {
+	if h.subs.goroutines.active.Load() == 0 {
+		return nil
+	}
+
+	return h.subs.goroutines.lookup(goroutineID())
+}
+
+// goroutineCaptures maps goroutines to their captures; goroutines are unbound when the capture ends
+type goroutineCaptures struct {
+	mu     sync.RWMutex
+	byID   map[uint64]*Subscription
+	bySub  map[*Subscription][]uint64
+	active atomic.Int64
+}
+
+func (gc *goroutineCaptures) bind(id uint64, sub *Subscription) {
+	gc.mu.Lock()
+
+	if gc.byID == nil {
+		gc.byID = make(map[uint64]*Subscription)
+		gc.bySub = make(map[*Subscription][]uint64)
+	}
+
+	first := len(gc.bySub[sub]) == 0
+
+	gc.byID[id] = sub
+	gc.bySub[sub] = append(gc.bySub[sub], id)
+	gc.active.Store(int64(len(gc.byID)))
+
+	gc.mu.Unlock()
+
+	if first {
+		context.AfterFunc(sub.Context(), func() { gc.unbind(sub) })
+	}
+}
+
+func (gc *goroutineCaptures) unbind(sub *Subscription) {
+	gc.mu.Lock()
+	defer gc.mu.Unlock()
+
+	for _, id := range gc.bySub[sub] {
+		if gc.byID[id] == sub {
+			delete(gc.byID, id)
+		}
+	}
+
+	delete(gc.bySub, sub)
+	gc.active.Store(int64(len(gc.byID)))
+}
+
+func (gc *goroutineCaptures) lookup(id uint64) *Subscription {
+	gc.mu.RLock()
+	defer gc.mu.RUnlock()
+
+	return gc.byID[id]
+}
+
+var goroutinePrefix = []byte("goroutine ")
+
+// goroutineID returns the current goroutine ID parsed from the stack trace header ("goroutine 42 [running]:")
+func goroutineID() uint64 {
+	var buf [64]byte
+
+	header := bytes.TrimPrefix(buf[:runtime.Stack(buf[:], false)], goroutinePrefix)
+
+	if i := bytes.IndexByte(header, ' '); i > 0 {
+		header = header[:i]
+	}
+
+	id, _ := strconv.ParseUint(string(header), 10, 64)
+
+	return id
+}
@@ -0,0 +1,124 @@
+package slogspy
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"testing"
+)
+
+func TestSpy__CaptureGoroutine(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		main     bytes.Buffer
+		captured bytes.Buffer
+	)
+
+	spy := NewSpy(slog.NewTextHandler(&bytes.Buffer{}, nil))
+
+	go spy.Run(context.Background(), func(msg []byte) { // nolint: errcheck
+		mu.Lock()
+		defer mu.Unlock()
+
+		main.Write(msg)
+	})
+
+	waitForRunning(t, spy)
+
+	logger := slog.New(spy)
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var (
+		wg  sync.WaitGroup
+		sub *Subscription
+	)
+
+	started := make(chan struct{})
+
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+
+		go func(i int) {
+			defer wg.Done()
+
+			if i == 1 {
+				var workerCtx context.Context
+
+				workerCtx, sub = spy.CaptureGoroutine(ctx, func(msg []byte) {
+					mu.Lock()
+					defer mu.Unlock()
+
+					captured.Write(msg)
+				})
+
+				close(started)
+
+				var child sync.WaitGroup
+				child.Add(1)
+
+				Go(workerCtx, func(context.Context) {
+					defer child.Done()
+					logger.Info("child of worker 1")
+				})
+
+				child.Wait()
+			} else {
+				<-started
+			}
+
+			logger.Info(fmt.Sprintf("worker %d", i))
+		}(i)
+	}
+
+	wg.Wait()
+
+	logger.Info("outside of workers")
+
+	if err := sub.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	cancel()
+
+	waitFor(t, func() bool { return sub.Closed() })
+
+	if spy.handler.subs.goroutines.active.Load() != 0 {
+		t.Error("expected goroutines to be unbound when the capture ends")
+	}
+
+	if err := spy.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	assertBufferContains(t, &captured, "worker 1")
+	assertBufferContains(t, &captured, "child of worker 1")
+	assertBufferContainsNot(t, &captured, "worker 0")
+	assertBufferContainsNot(t, &captured, "worker 2")
+	assertBufferContainsNot(t, &captured, "outside of workers")
+
+	if main.Len() != 0 {
+		t.Errorf("expected goroutine captures not to activate the spy's output, got: %s", main.String())
+	}
+}
+
+func TestGoroutineID(t *testing.T) {
+	id := goroutineID()
+
+	if id == 0 {
+		t.Fatal("expected to parse the goroutine ID")
+	}
+
+	child := make(chan uint64)
+
+	go func() { child <- goroutineID() }()
+
+	if childID := <-child; childID == id || childID == 0 {
+		t.Errorf("expected a different goroutine ID, got %d", childID)
+	}
+}
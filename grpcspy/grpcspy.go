@@ -0,0 +1,688 @@
+// Package grpcspy provides a gRPC streaming service (SpyService.Tail, see spy.proto) to tail the spied logs
+// over the existing RPC infrastructure. It implements the subset of the gRPC protocol required by the service
+// on top of net/http (HTTP/2 is required), so no gRPC library is required.
+package grpcspy
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	slogspy "github.com/palkan/slog-spy"
+)
+
+const (
+	// TailMethod is the path of the SpyService.Tail method.
+	TailMethod = "/slogspy.v1.SpyService/Tail"
+
+	defaultSendBuffer = 64
+	maxMessageSize    = 4 * 1024 * 1024
+)
+
+// Status codes used by the service (see https://grpc.github.io/grpc/core/md_doc_statuscodes.html).
+const (
+	CodeOK                = 0
+	CodeCanceled          = 1
+	CodeInvalidArgument   = 3
+	CodeResourceExhausted = 8
+	CodeUnimplemented     = 12
+	CodeInternal          = 13
+	CodeUnavailable       = 14
+)
+
+var errSlowClient = errors.New("client is too slow")
+
+// Action is the TailRequest action.
+type Action int32
+
+const (
+	ActionStart Action = 0
+	ActionStop  Action = 1
+)
+
+// TailRequest is the SpyService.Tail request message.
+type TailRequest struct {
+	Action Action
+	// Level is the minimum level of the records (e.g., "warn")
+	Level string
+	// Filter is the filter expression (see slogspy.CompileFilter)
+	Filter string
+	// TTL is the max duration of the subscription (zero means no limit)
+	TTL time.Duration
+}
+
+// StatusError is the non-OK status the call has been completed with.
+type StatusError struct {
+	Code    int
+	Message string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("grpc: code %d: %s", e.Code, e.Message)
+}
+
+// Subscriber is a spy (or spy handler) to subscribe to.
+type Subscriber interface {
+	Subscribe(out slogspy.SpyOutput, opts ...slogspy.SubscriptionOption) *slogspy.Subscription
+}
+
+// Server is an http.Handler implementing the SpyService; every started tail is registered as a subscription.
+type Server struct {
+	source     Subscriber
+	sendBuffer int
+	subOpts    []slogspy.SubscriptionOption
+}
+
+var _ http.Handler = (*Server)(nil)
+
+type Option func(*Server)
+
+// WithSendBuffer sets the number of batches queued per call. Clients which fall behind are disconnected.
+func WithSendBuffer(size int) Option {
+	return func(s *Server) {
+		s.sendBuffer = size
+	}
+}
+
+// WithSubscriptionOptions sets the options to apply to every subscription (e.g., labels or quotas).
+func WithSubscriptionOptions(opts ...slogspy.SubscriptionOption) Option {
+	return func(s *Server) {
+		s.subOpts = append(s.subOpts, opts...)
+	}
+}
+
+// NewServer returns the SpyService server for the spy. It must be served via HTTP/2
+// (e.g., by an http.Server with TLS or with unencrypted HTTP/2 enabled) at the TailMethod path.
+func NewServer(source Subscriber, opts ...Option) *Server {
+	s := &Server{source: source, sendBuffer: defaultSendBuffer}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	return s
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost || !strings.HasPrefix(r.Header.Get("Content-Type"), "application/grpc") {
+		http.Error(w, "Unsupported Media Type", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	if r.ProtoMajor != 2 {
+		http.Error(w, "HTTP Version Not Supported", http.StatusHTTPVersionNotSupported)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/grpc")
+
+	// Calls failed right away get trailers-only responses
+	if r.URL.Path != TailMethod {
+		writeStatus(w, CodeUnimplemented, "unknown method "+r.URL.Path)
+		return
+	}
+
+	if enc := r.Header.Get("Grpc-Encoding"); enc != "" && enc != "identity" {
+		writeStatus(w, CodeUnimplemented, "unsupported encoding "+enc)
+		return
+	}
+
+	w.Header().Set("Trailer", "Grpc-Status, Grpc-Message")
+	w.WriteHeader(http.StatusOK)
+
+	rc := http.NewResponseController(w)
+	rc.Flush() // nolint: errcheck
+
+	code, msg := s.tail(r, w, rc)
+
+	writeStatus(w, code, msg)
+}
+
+// tail serves the call until the client is gone, the subscription ends or the client half-closes the stream
+// while not tailing; it returns the call status
+func (s *Server) tail(r *http.Request, w io.Writer, rc *http.ResponseController) (int, string) {
+	ctx := r.Context()
+
+	requests := make(chan *TailRequest)
+	readErr := make(chan error, 1)
+
+	go func() {
+		readErr <- readRequests(ctx, r.Body, requests)
+	}()
+
+	out := make(chan []byte, s.sendBuffer)
+	overflow := make(chan struct{})
+
+	var overflowOnce sync.Once
+
+	output := func(msg []byte) {
+		// The message buffer is reused by the spy after the output returns
+		select {
+		case out <- bytes.Clone(msg):
+		default:
+			overflowOnce.Do(func() { close(overflow) })
+		}
+	}
+
+	var sub *slogspy.Subscription
+
+	stop := func() {
+		if sub != nil {
+			sub.Close()
+			sub = nil
+		}
+	}
+
+	defer stop()
+
+	for {
+		var subDone <-chan struct{}
+
+		if sub != nil {
+			subDone = sub.Context().Done()
+		}
+
+		select {
+		case <-ctx.Done():
+			return CodeCanceled, ctx.Err().Error()
+		case <-overflow:
+			return CodeResourceExhausted, errSlowClient.Error()
+		case err := <-readErr:
+			readErr = nil
+
+			var status *StatusError
+
+			if errors.As(err, &status) {
+				return status.Code, status.Message
+			}
+
+			if !errors.Is(err, io.EOF) {
+				return CodeCanceled, err.Error()
+			}
+
+			// The client has half-closed the stream, keep tailing until the subscription ends
+			if sub == nil {
+				return CodeOK, ""
+			}
+		case req := <-requests:
+			stop()
+
+			if req.Action == ActionStop {
+				continue
+			}
+
+			opts, err := s.subscriptionOptions(req)
+
+			if err != nil {
+				return CodeInvalidArgument, err.Error()
+			}
+
+			sub = s.source.Subscribe(output, opts...)
+		case msg := <-out:
+			if err := writeResponse(w, rc, msg); err != nil {
+				return CodeCanceled, err.Error()
+			}
+		case <-subDone:
+			cause := context.Cause(sub.Context())
+			sub = nil
+
+			// Deliver the messages sent before the subscription ended (e.g., the end event)
+			for n := len(out); n > 0; n-- {
+				if err := writeResponse(w, rc, <-out); err != nil {
+					return CodeCanceled, err.Error()
+				}
+			}
+
+			switch {
+			case errors.Is(cause, slogspy.ErrSubscriptionExpired):
+				return CodeOK, ""
+			case errors.Is(cause, slogspy.ErrQuotaExhausted):
+				return CodeResourceExhausted, cause.Error()
+			default:
+				return CodeUnavailable, cause.Error()
+			}
+		}
+	}
+}
+
+func (s *Server) subscriptionOptions(req *TailRequest) ([]slogspy.SubscriptionOption, error) {
+	opts := append([]slogspy.SubscriptionOption{}, s.subOpts...)
+
+	var conds []string
+
+	if req.Level != "" {
+		var l slog.Level
+
+		if err := l.UnmarshalText([]byte(req.Level)); err != nil {
+			return nil, err
+		}
+
+		// Numeric levels are used since the names of custom levels contain minus signs (e.g., DEBUG-4)
+		conds = append(conds, "level>="+strconv.Itoa(int(l)))
+	}
+
+	if expr := strings.TrimSpace(req.Filter); expr != "" {
+		conds = append(conds, "("+expr+")")
+	}
+
+	if len(conds) > 0 {
+		f, err := slogspy.CompileFilter(strings.Join(conds, " && "))
+
+		if err != nil {
+			return nil, err
+		}
+
+		opts = append(opts, slogspy.WithSubscriptionFilter(f))
+	}
+
+	if req.TTL > 0 {
+		opts = append(opts, slogspy.WithSubscriptionTTL(req.TTL))
+	}
+
+	return opts, nil
+}
+
+func readRequests(ctx context.Context, body io.Reader, requests chan<- *TailRequest) error {
+	r := bufio.NewReader(body)
+
+	for {
+		msg, err := readMessage(r)
+
+		if err != nil {
+			return err
+		}
+
+		req, err := decodeRequest(msg)
+
+		if err != nil {
+			return &StatusError{Code: CodeInvalidArgument, Message: err.Error()}
+		}
+
+		select {
+		case requests <- req:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+func writeResponse(w io.Writer, rc *http.ResponseController, data []byte) error {
+	if _, err := w.Write(encodeMessage(encodeResponse(data))); err != nil {
+		return err
+	}
+
+	return rc.Flush()
+}
+
+func writeStatus(w http.ResponseWriter, code int, msg string) {
+	w.Header().Set("Grpc-Status", strconv.Itoa(code))
+
+	if msg != "" {
+		w.Header().Set("Grpc-Message", encodeStatusMessage(msg))
+	}
+}
+
+// Stream is a client-side SpyService.Tail call.
+type Stream struct {
+	body *io.PipeWriter
+	resp *http.Response
+	r    *bufio.Reader
+	stop func() bool
+
+	mu sync.Mutex
+}
+
+// Open starts the SpyService.Tail call at the target (e.g., "https://node:50051"); the client must speak HTTP/2.
+func Open(ctx context.Context, client *http.Client, target string) (*Stream, error) {
+	pr, pw := io.Pipe()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(target, "/")+TailMethod, pr)
+
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Content-Type", "application/grpc")
+	req.Header.Set("Te", "trailers")
+
+	resp, err := client.Do(req)
+
+	if err != nil {
+		pw.Close()
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		pw.Close()
+		return nil, fmt.Errorf("grpc: unexpected HTTP status %d", resp.StatusCode)
+	}
+
+	s := &Stream{body: pw, resp: resp, r: bufio.NewReader(resp.Body)}
+
+	// The transport doesn't abort the call until the request body is closed
+	s.stop = context.AfterFunc(ctx, func() { pw.CloseWithError(ctx.Err()) })
+
+	// The call might have been completed right away (a trailers-only response)
+	if err := statusError(resp.Header); err != nil {
+		s.Close()
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// Send sends the request to the server.
+func (s *Stream) Send(req TailRequest) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, err := s.body.Write(encodeMessage(encodeRequest(&req)))
+
+	return err
+}
+
+// CloseSend half-closes the stream; the server keeps streaming until the subscription ends.
+func (s *Stream) CloseSend() error {
+	return s.body.Close()
+}
+
+// Recv returns the next batch; io.EOF is returned when the call is completed successfully
+// (a *StatusError is returned otherwise).
+func (s *Stream) Recv() ([]byte, error) {
+	msg, err := readMessage(s.r)
+
+	if errors.Is(err, io.EOF) {
+		// Trailers are available once the body has been read
+		if err := statusError(s.resp.Trailer); err != nil {
+			return nil, err
+		}
+
+		if s.resp.Trailer.Get("Grpc-Status") == "" && s.resp.Header.Get("Grpc-Status") == "" {
+			return nil, io.ErrUnexpectedEOF
+		}
+
+		return nil, io.EOF
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return decodeResponse(msg)
+}
+
+// Close terminates the call.
+func (s *Stream) Close() error {
+	s.stop()
+	s.body.Close()
+
+	return s.resp.Body.Close()
+}
+
+// Tail starts tailing at the target and passes the received batches to the function
+// until the call is completed or the context is done.
+func Tail(ctx context.Context, client *http.Client, target string, req TailRequest, fn func(data []byte)) error {
+	s, err := Open(ctx, client, target)
+
+	if err != nil {
+		return err
+	}
+
+	defer s.Close()
+
+	if err := s.Send(req); err != nil {
+		return err
+	}
+
+	for {
+		data, err := s.Recv()
+
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+
+			return err
+		}
+
+		fn(data)
+	}
+}
+
+func statusError(h http.Header) error {
+	status := h.Get("Grpc-Status")
+
+	if status == "" || status == "0" {
+		return nil
+	}
+
+	code, err := strconv.Atoi(status)
+
+	if err != nil {
+		return fmt.Errorf("grpc: malformed status %q", status)
+	}
+
+	return &StatusError{Code: code, Message: decodeStatusMessage(h.Get("Grpc-Message"))}
+}
+
+// readMessage reads a length-prefixed message (compression is not supported)
+func readMessage(r io.Reader) ([]byte, error) {
+	var prefix [5]byte
+
+	if _, err := io.ReadFull(r, prefix[:]); err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, &StatusError{Code: CodeInternal, Message: "truncated message"}
+		}
+
+		return nil, err
+	}
+
+	if prefix[0] != 0 {
+		return nil, &StatusError{Code: CodeUnimplemented, Message: "compressed messages are not supported"}
+	}
+
+	size := binary.BigEndian.Uint32(prefix[1:])
+
+	if size > maxMessageSize {
+		return nil, &StatusError{Code: CodeResourceExhausted, Message: "message is too large"}
+	}
+
+	msg := make([]byte, size)
+
+	if _, err := io.ReadFull(r, msg); err != nil {
+		return nil, &StatusError{Code: CodeInternal, Message: "truncated message"}
+	}
+
+	return msg, nil
+}
+
+func encodeMessage(msg []byte) []byte {
+	buf := make([]byte, 5, 5+len(msg))
+	binary.BigEndian.PutUint32(buf[1:], uint32(len(msg)))
+
+	return append(buf, msg...)
+}
+
+// Protobuf encoding of the messages (see spy.proto)
+
+const (
+	wireVarint = 0
+	wireI64    = 1
+	wireBytes  = 2
+	wireI32    = 5
+)
+
+func encodeRequest(req *TailRequest) []byte {
+	var buf []byte
+
+	if req.Action != 0 {
+		buf = appendTag(buf, 1, wireVarint)
+		buf = binary.AppendUvarint(buf, uint64(req.Action))
+	}
+
+	if req.Level != "" {
+		buf = appendTag(buf, 2, wireBytes)
+		buf = appendBytes(buf, []byte(req.Level))
+	}
+
+	if req.Filter != "" {
+		buf = appendTag(buf, 3, wireBytes)
+		buf = appendBytes(buf, []byte(req.Filter))
+	}
+
+	if req.TTL > 0 {
+		buf = appendTag(buf, 4, wireVarint)
+		buf = binary.AppendUvarint(buf, uint64(req.TTL.Milliseconds()))
+	}
+
+	return buf
+}
+
+func decodeRequest(msg []byte) (*TailRequest, error) {
+	req := &TailRequest{}
+
+	err := decodeFields(msg, func(num int, value uint64, data []byte) {
+		switch num {
+		case 1:
+			req.Action = Action(int32(value))
+		case 2:
+			req.Level = string(data)
+		case 3:
+			req.Filter = string(data)
+		case 4:
+			req.TTL = time.Duration(int64(value)) * time.Millisecond
+		}
+	})
+
+	return req, err
+}
+
+func encodeResponse(data []byte) []byte {
+	buf := make([]byte, 0, len(data)+binary.MaxVarintLen64+1)
+	buf = appendTag(buf, 1, wireBytes)
+
+	return appendBytes(buf, data)
+}
+
+func decodeResponse(msg []byte) ([]byte, error) {
+	var data []byte
+
+	err := decodeFields(msg, func(num int, _ uint64, value []byte) {
+		if num == 1 {
+			data = value
+		}
+	})
+
+	return data, err
+}
+
+func appendTag(buf []byte, num int, wireType int) []byte {
+	return binary.AppendUvarint(buf, uint64(num)<<3|uint64(wireType))
+}
+
+func appendBytes(buf []byte, data []byte) []byte {
+	buf = binary.AppendUvarint(buf, uint64(len(data)))
+
+	return append(buf, data...)
+}
+
+// decodeFields calls the function for every varint and length-delimited field (other fields are skipped)
+func decodeFields(msg []byte, fn func(num int, value uint64, data []byte)) error {
+	for len(msg) > 0 {
+		tag, n := binary.Uvarint(msg)
+
+		if n <= 0 {
+			return errors.New("malformed field tag")
+		}
+
+		msg = msg[n:]
+		num := int(tag >> 3)
+
+		switch tag & 7 {
+		case wireVarint:
+			value, n := binary.Uvarint(msg)
+
+			if n <= 0 {
+				return errors.New("malformed varint")
+			}
+
+			msg = msg[n:]
+			fn(num, value, nil)
+		case wireBytes:
+			size, n := binary.Uvarint(msg)
+
+			if n <= 0 || size > uint64(len(msg)-n) {
+				return errors.New("malformed length-delimited field")
+			}
+
+			fn(num, 0, msg[n:n+int(size)])
+			msg = msg[n+int(size):]
+		case wireI64:
+			if len(msg) < 8 {
+				return errors.New("malformed fixed64 field")
+			}
+
+			msg = msg[8:]
+		case wireI32:
+			if len(msg) < 4 {
+				return errors.New("malformed fixed32 field")
+			}
+
+			msg = msg[4:]
+		default:
+			return fmt.Errorf("unsupported wire type %d", tag&7)
+		}
+	}
+
+	return nil
+}
+
+// encodeStatusMessage percent-encodes the status message as required by the protocol
+func encodeStatusMessage(msg string) string {
+	var b strings.Builder
+
+	for i := 0; i < len(msg); i++ {
+		c := msg[i]
+
+		if c < 0x20 || c > 0x7e || c == '%' {
+			fmt.Fprintf(&b, "%%%02X", c)
+		} else {
+			b.WriteByte(c)
+		}
+	}
+
+	return b.String()
+}
+
+func decodeStatusMessage(msg string) string {
+	var b strings.Builder
+
+	for i := 0; i < len(msg); i++ {
+		if msg[i] == '%' && i+2 < len(msg) {
+			if c, err := strconv.ParseUint(msg[i+1:i+3], 16, 8); err == nil {
+				b.WriteByte(byte(c))
+				i += 2
+				continue
+			}
+		}
+
+		b.WriteByte(msg[i])
+	}
+
+	return b.String()
+}
@@ -0,0 +1,233 @@
+package grpcspy
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	slogspy "github.com/palkan/slog-spy"
+)
+
+func newTestServer(t *testing.T, opts ...Option) (*slogspy.Spy, *httptest.Server) {
+	t.Helper()
+
+	spy := slogspy.NewSpy(slog.NewTextHandler(io.Discard, nil), slogspy.WithFlushInterval(10*time.Millisecond))
+
+	go spy.Run(context.Background(), nil)                    // nolint: errcheck
+	t.Cleanup(func() { spy.Shutdown(context.Background()) }) // nolint: errcheck
+
+	server := httptest.NewUnstartedServer(NewServer(spy, opts...))
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	t.Cleanup(server.Close)
+
+	return spy, server
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(2 * time.Second)
+
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for condition")
+		}
+
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+type received struct {
+	mu   sync.Mutex
+	data strings.Builder
+}
+
+func (r *received) add(data []byte) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.data.Write(data)
+}
+
+func (r *received) String() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.data.String()
+}
+
+func TestTail(t *testing.T) {
+	spy, server := newTestServer(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var got received
+
+	errCh := make(chan error, 1)
+
+	go func() {
+		errCh <- Tail(ctx, server.Client(), server.URL, TailRequest{Level: "warn", Filter: `msg=~"payment"`}, got.add)
+	}()
+
+	waitFor(t, func() bool { return spy.Stats().Watchers == 1 })
+
+	logger := slog.New(spy)
+	logger.Info("payment accepted")
+	logger.Warn("payment delayed")
+	logger.Error("database is down")
+
+	waitFor(t, func() bool { return strings.Contains(got.String(), "payment delayed") })
+
+	if out := got.String(); strings.Contains(out, "payment accepted") || strings.Contains(out, "database is down") {
+		t.Errorf("expected the level and filter to be applied, got: %s", out)
+	}
+
+	cancel()
+
+	if err := <-errCh; !errors.Is(err, context.Canceled) {
+		t.Errorf("expected the call to be canceled, got: %v", err)
+	}
+
+	waitFor(t, func() bool { return spy.Stats().Watchers == 0 })
+}
+
+func TestStream__StartStop(t *testing.T) {
+	spy, server := newTestServer(t)
+
+	s, err := Open(context.Background(), server.Client(), server.URL)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer s.Close()
+
+	if err := s.Send(TailRequest{}); err != nil {
+		t.Fatal(err)
+	}
+
+	waitFor(t, func() bool { return spy.Stats().Watchers == 1 })
+
+	slog.New(spy).Info("hello")
+
+	data, err := s.Recv()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(string(data), `"msg":"hello"`) {
+		t.Errorf("expected the batch to contain the record, got: %s", data)
+	}
+
+	if err := s.Send(TailRequest{Action: ActionStop}); err != nil {
+		t.Fatal(err)
+	}
+
+	waitFor(t, func() bool { return spy.Stats().Watchers == 0 })
+
+	// Half-closing the stream while not tailing completes the call
+	if err := s.CloseSend(); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.Recv(); !errors.Is(err, io.EOF) {
+		t.Errorf("expected the call to be completed, got: %v", err)
+	}
+}
+
+func TestStream__TTL(t *testing.T) {
+	spy, server := newTestServer(t)
+
+	err := Tail(context.Background(), server.Client(), server.URL, TailRequest{TTL: 50 * time.Millisecond}, func([]byte) {})
+
+	if err != nil {
+		t.Errorf("expected the call to be completed once the subscription expires, got: %v", err)
+	}
+
+	waitFor(t, func() bool { return spy.Stats().Watchers == 0 })
+}
+
+func TestTail__InvalidFilter(t *testing.T) {
+	_, server := newTestServer(t)
+
+	err := Tail(context.Background(), server.Client(), server.URL, TailRequest{Filter: "level>="}, func([]byte) {})
+
+	var status *StatusError
+
+	if !errors.As(err, &status) || status.Code != CodeInvalidArgument {
+		t.Errorf("expected the invalid argument status, got: %v", err)
+	}
+}
+
+func TestTail__StopAll(t *testing.T) {
+	spy, server := newTestServer(t, WithSubscriptionOptions(slogspy.WithSubscriptionLabel("incident", "INC-1")))
+
+	errCh := make(chan error, 1)
+
+	go func() {
+		errCh <- Tail(context.Background(), server.Client(), server.URL, TailRequest{}, func([]byte) {})
+	}()
+
+	waitFor(t, func() bool { return spy.Stats().Watchers == 1 })
+
+	if n := spy.StopAll("incident=INC-1"); n != 1 {
+		t.Fatalf("expected 1 subscription to be stopped, got %d", n)
+	}
+
+	var status *StatusError
+
+	err := <-errCh
+
+	if !errors.As(err, &status) || status.Code != CodeUnavailable || status.Message != slogspy.ErrSubscriptionStopped.Error() {
+		t.Errorf("expected the unavailable status, got: %v", err)
+	}
+}
+
+func TestServer__UnknownMethod(t *testing.T) {
+	_, server := newTestServer(t)
+
+	req, _ := http.NewRequest("POST", server.URL+"/slogspy.v1.SpyService/Unknown", strings.NewReader(""))
+	req.Header.Set("Content-Type", "application/grpc")
+
+	resp, err := server.Client().Do(req)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer resp.Body.Close()
+
+	io.ReadAll(resp.Body) // nolint: errcheck
+
+	if code := resp.Header.Get("Grpc-Status") + resp.Trailer.Get("Grpc-Status"); code != "12" {
+		t.Errorf("expected the unimplemented status, got: %q", code)
+	}
+}
+
+func TestRequestEncoding(t *testing.T) {
+	req := TailRequest{Action: ActionStop, Level: "debug", Filter: `attrs.user_id=="42"`, TTL: 90 * time.Second}
+
+	decoded, err := decodeRequest(encodeRequest(&req))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if *decoded != req {
+		t.Errorf("expected %+v, got %+v", req, *decoded)
+	}
+
+	if msg := decodeStatusMessage(encodeStatusMessage("100% done\n")); msg != "100% done\n" {
+		t.Errorf("expected status message to be decoded, got: %q", msg)
+	}
+}
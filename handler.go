@@ -0,0 +1,938 @@
+package slogspy
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultMaxbufSize    = 256 * 1024 // 256KB
+	defaultFlushInterval = 250 * time.Millisecond
+	// defaultControlBacklog is the size of the control commands channel
+	defaultControlBacklog = 16
+)
+
+// ErrAlreadyRunning is returned by Run when the Run loop is already active.
+var ErrAlreadyRunning = errors.New("spy is already running")
+
+// SpyOutput receives batches of formatted records; the message buffer is reused after the function returns.
+type SpyOutput func(msg []byte)
+
+// SpyCommand is a command processed by the Run loop.
+type SpyCommand int
+
+const (
+	SpyCommandRecord SpyCommand = iota
+	SpyCommandFlush
+	SpyCommandStop
+	SpyCommandConfigure
+)
+
+// Entry is an item of the Run loop queue: a record to process or a command.
+type Entry struct {
+	record slog.Record
+	// groups and frames contain the groups and attributes added to the handler;
+	// they're applied to the record at format time
+	groups []string
+	frames []attrFrame
+	cmd    SpyCommand
+	// capture is the request-scoped subscription the record belongs to (see CaptureContext)
+	capture *Subscription
+	// annotation is set for the records created via Annotate
+	annotation bool
+	// target is the subscription to flush (all outputs are flushed if nil)
+	target *Subscription
+	// enqueuedAt is only tracked when the max backlog age is set
+	enqueuedAt time.Time
+	// flushed is closed once the flush command has been processed
+	flushed chan struct{}
+	// apply is the configuration change performed by the Run loop (see SetFlushInterval)
+	apply func(h *SpyHandler)
+	// probe entries measure the delivery latency (see WithLatencyProbes)
+	probe bool
+	// repeats is the number of the collapsed identical records (see WithDedup)
+	repeats int
+}
+
+var entryPool = sync.Pool{New: func() any { return &Entry{} }}
+
+func newEntry(r slog.Record, groups []string, frames []attrFrame) *Entry {
+	entry := entryPool.Get().(*Entry)
+	entry.record = r
+	entry.groups = groups
+	entry.frames = frames
+	entry.cmd = SpyCommandRecord
+
+	return entry
+}
+
+// releaseEntry returns the processed (or dropped) record entry to the pool
+func releaseEntry(entry *Entry) {
+	*entry = Entry{}
+	entryPool.Put(entry)
+}
+
+// SpyHandler is a slog.Handler formatting records and delivering them in batches to the outputs.
+// Records are processed asynchronously by the Run loop; the handler is enabled only while there are watchers.
+type SpyHandler struct {
+	output SpyOutput
+
+	// recordsOutput is set when running in the structured delivery mode
+	recordsOutput   SpyOutputRecords
+	records         []slog.Record
+	maxBatchRecords int
+	// syncRecords is set in the synchronous capture mode (see WithSyncRecords)
+	syncRecords func(r slog.Record)
+
+	active *atomic.Int64
+	// ch is the backlog of records; commands are sent via the separate control channel,
+	// so they're never blocked or dropped by records backpressure; the backlog can be replaced via Resize
+	ch   *atomic.Pointer[chan *Entry]
+	ctrl chan *Entry
+	buf  *bytes.Buffer
+	// retired are the previous backlogs drained by the Run loop after Resize
+	retired []retiredBacklog
+	// retiredC is the ticker channel to sweep the retired backlogs (nil if there are none)
+	retiredC      <-chan time.Time
+	retiredTicker *time.Ticker
+	// timer is used by the Run loop to flush records within the flush interval
+	timer  *time.Timer
+	timerC <-chan time.Time
+
+	// closed is set on Shutdown; records are no longer accepted after that
+	closed *atomic.Bool
+	state  *runState
+	subs   *subscriptions
+	stats  *spyStats
+	// outputs are the extra outputs added via AddOutput
+	outputs *outputs
+
+	errorHandler func(err error)
+	metrics      MetricsCollector
+	// onWatchers is called when the number of watchers changes
+	onWatchers func()
+
+	overflowPolicy OverflowPolicy
+	blockTimeout   time.Duration
+	onDrop         func(r slog.Record, reason DropReason)
+	maxRecordSize  int
+	// reportedDrops are the drops counters included into the previous batch envelope
+	reportedDrops [len(dropReasons)]int64
+	// routes deliver records of the specific levels to their own outputs (see WithLevelRoute)
+	routes []*levelRoute
+	dedup  *dedupState
+	// trigger keeps the recent records regardless of watchers (see WithErrorTrigger)
+	trigger *errorTrigger
+	// slowOutput contains the output timeout settings (see WithOutputTimeout)
+	slowOutput *slowOutputConfig
+	// probes are the enqueue times of the probes included into the current batch
+	probes        []time.Time
+	probeInterval time.Duration
+	// batchSeq is the sequence number of the previous batch (see WithBatchMetadata)
+	batchSeq      int64
+	batchMetadata bool
+	maxBacklogAge time.Duration
+	// captureCanceled is set if records logged with canceled contexts are captured (see WithCanceledContexts)
+	captureCanceled bool
+
+	// A log handler we use to format records; logger attributes and groups are resolved
+	// before passing records to it, so the printer is shared by all the clones
+	printer        slog.Handler
+	printerBuilder func(w io.Writer) slog.Handler
+	// encoding is the records encoding (see WithEncoding)
+	encoding RecordEncoding
+	// printerErr is the printer construction error reported via the diagnostic record by the Run loop
+	printerErr error
+	// addSource is set if the printer must include source locations (see WithSource)
+	addSource     bool
+	maxBufSize    int
+	flushInterval time.Duration
+	framing       Framing
+	// spoolConfig enables the asynchronous output delivery with the disk spill-over (see WithSpool)
+	spoolConfig *spoolConfig
+	// compressor is used to compress flushed batches (see WithCompression)
+	compressor           *compressor
+	compressionThreshold int
+	severityClass        func(level slog.Level) string
+	// schema contains the declared and discovered record keys (see WithSchema)
+	schema *schemaState
+	// batchLevels contains the levels of the records in the buffer (only tracked with NDJSON framing)
+	batchLevels []slog.Level
+	// batchRecords is the number of records in the buffer
+	batchRecords int
+
+	staticSessions  []SessionConfig
+	matchedSessions []*Subscription
+
+	// groups opened via WithGroup and attributes added via WithAttrs
+	groups []string
+	frames []attrFrame
+
+	filter *atomic.Pointer[Filter]
+	// level is the min level of spied records (nil means all levels)
+	level *atomic.Pointer[slog.Level]
+	// source toggles the source locations capture (see WithSourceToggle)
+	source *sourceToggle
+	// sampling is used to deliver only a subset of the spied records (see WithSampling and WithRateLimit)
+	sampling *recordSampler
+
+	// Redactor and value formatters applied to records on the spy path only
+	redactor   func(groups []string, a slog.Attr) slog.Attr
+	formatters []valueFormatter
+	// Large values are replaced with references and stored in the offload store
+	offloadStore     OffloadStore
+	offloadThreshold int
+}
+
+var _ slog.Handler = (*SpyHandler)(nil)
+
+// runState tracks the Run loop lifecycle; it's shared between the handler and its clones
+type runState struct {
+	mu       sync.Mutex
+	running  bool
+	started  bool
+	stopping bool
+	// pendingStop is set when Shutdown is called before the Run loop has ever started
+	pendingStop bool
+	// done is closed when the current (or the next) Run loop exits
+	done chan struct{}
+	// sessions contains the running static sessions by name
+	sessions map[string]*Subscription
+	// pending contains the configuration changes to apply when the Run loop starts
+	pending []func(h *SpyHandler)
+}
+
+// SpyHandlerOption configures a SpyHandler.
+type SpyHandlerOption func(*SpyHandler)
+
+// WithMaxBufSize sets the maximum output buffer size for the SpyHandler.
+func WithMaxBufSize(size int) SpyHandlerOption {
+	return func(h *SpyHandler) {
+		h.maxBufSize = size
+	}
+}
+
+// WithFlushInterval sets the max flush interval for the SpyHandler.
+func WithFlushInterval(interval time.Duration) SpyHandlerOption {
+	return func(h *SpyHandler) {
+		h.flushInterval = interval
+	}
+}
+
+// WithPrinter allows to configure a custom slog.Handler used to format log records.
+// The printer is built once all the options are applied. If the builder panics or returns nil,
+// the default JSON printer is used, and the diagnostic record is added to the first batch (see PrinterFallbackMessage).
+func WithPrinter(printerBuilder func(io io.Writer) slog.Handler) SpyHandlerOption {
+	return func(h *SpyHandler) {
+		h.printerBuilder = printerBuilder
+	}
+}
+
+// WithErrorHandler sets a function to be called with errors occurred while formatting records.
+func WithErrorHandler(fn func(err error)) SpyHandlerOption {
+	return func(h *SpyHandler) {
+		h.errorHandler = fn
+	}
+}
+
+// WithBacklogSize sets the size of the backlog channel used as a queue for log records.
+func WithBacklogSize(size int) SpyHandlerOption {
+	return func(h *SpyHandler) {
+		h.ch = newBacklog(size)
+	}
+}
+
+func newBacklog(size int) *atomic.Pointer[chan *Entry] {
+	ch := make(chan *Entry, size)
+	backlog := &atomic.Pointer[chan *Entry]{}
+	backlog.Store(&ch)
+
+	return backlog
+}
+
+// queue returns the current backlog channel
+func (h *SpyHandler) queue() chan *Entry {
+	return *h.ch.Load()
+}
+
+// NewSpyHandler creates a new SpyHandler with the provided options.
+func NewSpyHandler(opts ...SpyHandlerOption) *SpyHandler {
+	buf := &bytes.Buffer{}
+	h := &SpyHandler{
+		ch:            newBacklog(2048),
+		ctrl:          make(chan *Entry, defaultControlBacklog),
+		buf:           buf,
+		active:        &atomic.Int64{},
+		closed:        &atomic.Bool{},
+		state:         &runState{done: make(chan struct{})},
+		subs:          newSubscriptions(),
+		stats:         &spyStats{},
+		outputs:       &outputs{},
+		filter:        &atomic.Pointer[Filter]{},
+		level:         &atomic.Pointer[slog.Level]{},
+		maxBufSize:    defaultMaxbufSize,
+		flushInterval: defaultFlushInterval,
+		blockTimeout:  defaultBlockTimeout,
+		severityClass: DefaultSeverityClass,
+
+		maxBatchRecords: defaultMaxBatchRecords,
+	}
+
+	for _, opt := range opts {
+		opt(h)
+	}
+
+	h.applyEncoding()
+
+	if h.printerBuilder != nil {
+		h.printer, h.printerErr = buildPrinter(h.printerBuilder, h.buf)
+
+		if h.printerErr != nil {
+			h.trackPrinterFallback(h.printerErr)
+		}
+	}
+
+	if h.printer == nil {
+		h.printer = defaultPrinter(h.buf)
+	}
+
+	if h.source != nil {
+		// The capture is always enabled for the spy's own printer
+		if h.addSource {
+			h.source.n = 1
+		}
+
+		h.source.fn(h.addSource)
+	}
+
+	return h
+}
+
+func (h *SpyHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	if h.closed.Load() || (h.active.Load() <= 0 && h.trigger == nil) {
+		return false
+	}
+
+	min := h.level.Load()
+
+	return min == nil || level >= *min
+}
+
+func (h *SpyHandler) Handle(ctx context.Context, r slog.Record) error {
+	if h.syncRecords != nil {
+		h.handleSync(r)
+		return nil
+	}
+
+	capture := h.captureFromContext(ctx)
+
+	if capture == nil {
+		capture = h.captureFromGoroutine()
+	}
+
+	h.enqueueRecord(ctx, r, capture)
+
+	return nil
+}
+
+func (h *SpyHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+
+	newHandler := h.Clone()
+	newHandler.frames = append(h.frames[:len(h.frames):len(h.frames)], attrFrame{depth: len(h.groups), attrs: attrs})
+	return newHandler
+}
+
+func (h *SpyHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+
+	newHandler := h.Clone()
+	newHandler.groups = append(h.groups[:len(h.groups):len(h.groups)], name)
+	return newHandler
+}
+
+// Run processes log records in the background until the context is canceled or Shutdown is called.
+// The output could be nil if logs are only consumed via subscriptions.
+// It returns the context's error in the former case and nil in the latter one.
+// Run could be called again after it has returned.
+func (h *SpyHandler) Run(ctx context.Context, out SpyOutput) error {
+	return h.run(ctx, out, nil)
+}
+
+func (h *SpyHandler) run(ctx context.Context, out SpyOutput, recordsOut SpyOutputRecords) error {
+	done, stopNow, err := h.start()
+
+	if err != nil {
+		return err
+	}
+
+	defer h.finish(done)
+	// The extra outputs must catch up before the loop is reported as stopped
+	defer h.outputs.drain(outputDrainTimeout)
+	defer h.startProbes()()
+
+	out, stopTimed := h.withOutputTimeout(out)
+	defer stopTimed()
+
+	for _, route := range h.routes {
+		var stop func()
+
+		route.sink, stop = h.withOutputTimeout(route.output)
+		defer stop()
+	}
+
+	if h.trigger != nil {
+		var stop func()
+
+		h.trigger.sink, stop = h.withOutputTimeout(h.trigger.output)
+		defer stop()
+	}
+
+	h.output = out
+	h.recordsOutput = recordsOut
+
+	if h.spoolConfig != nil && out != nil {
+		if sp, err := newSpool(h.spoolConfig, out, h); err == nil {
+			h.output = sp.push
+			defer sp.close()
+		} else if h.errorHandler != nil {
+			h.errorHandler(err)
+		}
+	}
+
+	h.applyPending()
+
+	if stopNow {
+		h.drain()
+		return nil
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			h.releaseDuplicates(true)
+			h.flush()
+			return ctx.Err()
+		case cmd := <-h.ctrl:
+			if cmd.cmd == SpyCommandStop {
+				// Ignore stale stop commands left from the previous runs
+				if !h.closed.Load() {
+					continue
+				}
+
+				h.drain()
+				return nil
+			}
+
+			if cmd.cmd == SpyCommandConfigure {
+				cmd.apply(h)
+				continue
+			}
+
+			// Records queued before the flush command must be included into the flushed batch
+			h.processQueued()
+
+			if cmd.target != nil && cmd.target.batch != nil {
+				h.flushSession(cmd.target)
+			} else {
+				h.releaseDuplicates(true)
+				h.flush()
+			}
+
+			cmd.ack()
+		case <-h.timerC:
+			h.timerC = nil
+
+			held := h.releaseDuplicates(false)
+			h.flush()
+
+			// Check the held record again when the window ends
+			if held {
+				h.armTimer()
+			}
+		case entry := <-h.queue():
+			h.processAndFlush(entry)
+		case <-h.retiredC:
+			h.sweepRetired()
+		}
+	}
+}
+
+// processAndFlush processes the entry and flushes the buffer if it's full
+func (h *SpyHandler) processAndFlush(entry *Entry) {
+	h.process(entry)
+
+	if h.buf.Len() > h.maxBufSize || len(h.records) >= h.maxBatchRecords {
+		h.flush()
+	} else {
+		h.armTimer()
+	}
+}
+
+// Shutdown stops accepting new records and waits for the Run loop to process the queued ones,
+// perform the final flush and exit. It returns the context's error if the context is done first.
+// If the loop has been stopped via the context, Shutdown returns immediately.
+// It's safe to call Shutdown multiple times.
+func (h *SpyHandler) Shutdown(ctx context.Context) error {
+	h.state.mu.Lock()
+
+	h.closed.Store(true)
+
+	if !h.state.running {
+		// The loop has been stopped via the context, nothing to wait for
+		if h.state.started {
+			h.state.mu.Unlock()
+			h.subs.closeAll(ErrSpyShutdown)
+			return nil
+		}
+
+		// The first Run call processes the queued records and exits right after that
+		h.state.pendingStop = true
+	}
+
+	done := h.state.done
+	sendStop := h.state.running && !h.state.stopping
+	h.state.stopping = true
+
+	h.state.mu.Unlock()
+
+	// Subscriptions end when the spy is shut down
+	defer h.subs.closeAll(ErrSpyShutdown)
+
+	if sendStop {
+		select {
+		case h.ctrl <- &Entry{cmd: SpyCommandStop}:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Watch registers a watcher activating the spy.
+func (h *SpyHandler) Watch() {
+	h.trackWatchers(h.active.Add(1))
+}
+
+// Unwatch unregisters a watcher; extra calls are ignored (the watchers counter never goes negative).
+func (h *SpyHandler) Unwatch() {
+	for {
+		n := h.active.Load()
+
+		if n <= 0 {
+			return
+		}
+
+		if h.active.CompareAndSwap(n, n-1) {
+			h.trackWatchers(n - 1)
+			return
+		}
+	}
+}
+
+// WatchToken represents a watcher registered via WatchWithTTL.
+type WatchToken struct {
+	handler  *SpyHandler
+	timer    *time.Timer
+	once     sync.Once
+	released atomic.Bool
+}
+
+// WatchWithTTL registers a watcher which is automatically unregistered after the TTL
+// (unless released earlier via the returned token).
+func (h *SpyHandler) WatchWithTTL(ttl time.Duration) *WatchToken {
+	token := &WatchToken{handler: h}
+
+	h.Watch()
+	token.timer = time.AfterFunc(ttl, token.unwatch)
+
+	return token
+}
+
+// Release unregisters the watcher. It's safe to call Release multiple times.
+func (t *WatchToken) Release() {
+	if t.timer != nil {
+		t.timer.Stop()
+	}
+
+	t.unwatch()
+}
+
+func (t *WatchToken) unwatch() {
+	t.once.Do(func() {
+		t.released.Store(true)
+		t.handler.Unwatch()
+	})
+}
+
+// Clone returns a new SpyHandler with the same parent handler and buffers
+func (t *SpyHandler) Clone() *SpyHandler {
+	return &SpyHandler{
+		active:        t.active,
+		closed:        t.closed,
+		state:         t.state,
+		subs:          t.subs,
+		stats:         t.stats,
+		outputs:       t.outputs,
+		ch:            t.ch,
+		ctrl:          t.ctrl,
+		buf:           t.buf,
+		printer:       t.printer,
+		maxBufSize:    t.maxBufSize,
+		flushInterval: t.flushInterval,
+
+		maxBatchRecords: t.maxBatchRecords,
+		syncRecords:     t.syncRecords,
+		addSource:       t.addSource,
+		framing:         t.framing,
+		batchMetadata:   t.batchMetadata,
+		severityClass:   t.severityClass,
+		schema:          t.schema,
+		formatters:      t.formatters,
+		redactor:        t.redactor,
+		groups:          t.groups,
+		frames:          t.frames,
+		filter:          t.filter,
+		level:           t.level,
+		sampling:        t.sampling,
+		source:          t.source,
+		errorHandler:    t.errorHandler,
+		metrics:         t.metrics,
+		onWatchers:      t.onWatchers,
+
+		overflowPolicy: t.overflowPolicy,
+		blockTimeout:   t.blockTimeout,
+		onDrop:         t.onDrop,
+		maxRecordSize:  t.maxRecordSize,
+		maxBacklogAge:  t.maxBacklogAge,
+		probeInterval:  t.probeInterval,
+		routes:         t.routes,
+		dedup:          t.dedup,
+		trigger:        t.trigger,
+		slowOutput:     t.slowOutput,
+
+		captureCanceled: t.captureCanceled,
+
+		offloadStore:     t.offloadStore,
+		offloadThreshold: t.offloadThreshold,
+
+		staticSessions: t.staticSessions,
+	}
+}
+
+func (h *SpyHandler) enqueueRecord(ctx context.Context, r slog.Record, capture *Subscription) {
+	if h.closed.Load() {
+		return
+	}
+
+	if ctx == nil || h.captureCanceled {
+		ctx = context.Background()
+	} else if ctx.Err() != nil {
+		h.dropRecord(r, DropCanceled)
+		return
+	}
+
+	entry := newEntry(r, h.groups, h.frames)
+	entry.capture = capture
+
+	h.enqueue(ctx, entry)
+}
+
+// enqueue puts the entry into the backlog; the context bounds the wait when using OverflowBlock
+func (h *SpyHandler) enqueue(ctx context.Context, entry *Entry) {
+	if h.maxBacklogAge > 0 {
+		entry.enqueuedAt = time.Now()
+	}
+
+	// Make sure we don't block the main thread; the overflow policy decides what to do if the channel is full
+	select {
+	case h.queue() <- entry:
+	default:
+		h.handleOverflow(ctx, entry)
+	}
+}
+
+func (h *SpyHandler) start() (chan struct{}, bool, error) {
+	h.state.mu.Lock()
+	defer h.state.mu.Unlock()
+
+	if h.state.running {
+		return nil, false, ErrAlreadyRunning
+	}
+
+	h.state.running = true
+	h.state.started = true
+	h.state.stopping = false
+
+	stopNow := h.state.pendingStop
+	h.state.pendingStop = false
+
+	if !stopNow {
+		h.closed.Store(false)
+		h.startSessions()
+	}
+
+	return h.state.done, stopNow, nil
+}
+
+func (h *SpyHandler) finish(done chan struct{}) {
+	h.state.mu.Lock()
+	defer h.state.mu.Unlock()
+
+	h.state.running = false
+	h.state.done = make(chan struct{})
+
+	close(done)
+}
+
+// process prints the record entry (unless it's stale) and releases it
+func (h *SpyHandler) process(entry *Entry) {
+	if entry.probe {
+		h.probes = append(h.probes, entry.enqueuedAt)
+		return
+	}
+
+	if h.evict(entry) {
+		return
+	}
+
+	if h.dedup != nil && h.collapse(entry) {
+		return
+	}
+
+	h.print(entry)
+	releaseEntry(entry)
+}
+
+func (h *SpyHandler) print(entry *Entry) {
+	spied := entry.annotation || h.spied(entry)
+	sampledOut := 0
+
+	// With the error trigger, records are queued regardless of watchers
+	if h.trigger != nil && h.active.Load() <= 0 {
+		spied = false
+	}
+
+	if spied && h.sampling != nil && !entry.annotation {
+		var reason DropReason
+
+		spied, sampledOut, reason = h.sampling.allow(entry.record)
+
+		if !spied {
+			h.stats.sampledOut.Add(1)
+			h.dropRecord(entry.record, reason)
+		}
+	}
+
+	sessions := h.matchSessions(entry, spied)
+
+	if !spied && len(sessions) == 0 && h.trigger == nil {
+		return
+	}
+
+	record := h.resolveRecord(entry)
+
+	if h.schema != nil {
+		h.schema.observe(record)
+	}
+
+	if sampledOut > 0 {
+		record = record.Clone()
+		record.AddAttrs(slog.Int(SampledOutKey, sampledOut))
+	}
+
+	if entry.repeats > 1 {
+		record = record.Clone()
+		record.AddAttrs(slog.Int(RepeatCountKey, entry.repeats))
+	}
+
+	if h.recordsOutput != nil {
+		if spied {
+			h.records = append(h.records, record)
+		}
+		return
+	}
+
+	if spied && h.printerErr != nil {
+		h.printDiagnostic()
+	}
+
+	start := h.buf.Len()
+	levels := len(h.batchLevels)
+
+	if spied || sharesPrinter(sessions) || h.trigger != nil {
+		printed := record
+
+		// Subscription printers get the record as is (they might add the source themselves)
+		if h.addSource {
+			printed = withSource(record)
+		}
+
+		err := h.printer.Handle(context.Background(), printed)
+
+		if err != nil && h.errorHandler != nil {
+			h.errorHandler(err)
+		}
+
+		if h.maxRecordSize > 0 && h.buf.Len()-start > h.maxRecordSize {
+			h.buf.Truncate(start)
+			h.dropRecord(entry.record, DropOversized)
+			return
+		}
+
+		if h.trigger != nil {
+			h.trigger.remember(h.buf.Bytes()[start:])
+
+			if record.Level >= h.trigger.level {
+				h.triggerDump()
+			}
+		}
+
+		h.frameRecord(start, record.Level)
+	}
+
+	if len(sessions) > 0 {
+		h.printSessions(sessions, record, h.buf.Bytes()[start:])
+	}
+
+	if !spied {
+		h.buf.Truncate(start)
+		h.batchLevels = h.batchLevels[:levels]
+		return
+	}
+
+	if route := h.routeFor(record.Level); route != nil {
+		h.routeRecord(route, start, levels, record.Level)
+		return
+	}
+
+	h.batchRecords++
+}
+
+// drain processes the records queued before the stop command and performs the final flush
+func (h *SpyHandler) drain() {
+	for {
+		select {
+		case entry := <-h.queue():
+			h.process(entry)
+		default:
+			if h.processRetired() {
+				continue
+			}
+
+			h.releaseDuplicates(true)
+			h.flush()
+			h.ackPending()
+			return
+		}
+	}
+}
+
+// processQueued processes the records queued so far
+func (h *SpyHandler) processQueued() {
+	h.processRetired()
+	h.processBacklog(h.queue())
+}
+
+func (h *SpyHandler) processBacklog(ch chan *Entry) {
+	for n := len(ch); n > 0; n-- {
+		h.process(<-ch)
+	}
+}
+
+// ackPending acknowledges the pending flush commands (the final flush has been already performed)
+// and applies the pending configuration changes
+func (h *SpyHandler) ackPending() {
+	for {
+		select {
+		case cmd := <-h.ctrl:
+			if cmd.cmd == SpyCommandConfigure {
+				cmd.apply(h)
+			}
+
+			cmd.ack()
+		default:
+			return
+		}
+	}
+}
+
+// armTimer makes sure the buffered records are flushed within the flush interval
+func (h *SpyHandler) armTimer() {
+	if h.timerC != nil {
+		return
+	}
+
+	if h.timer == nil {
+		h.timer = time.NewTimer(h.flushInterval)
+	} else {
+		h.timer.Reset(h.flushInterval)
+	}
+
+	h.timerC = h.timer.C
+}
+
+func (h *SpyHandler) stopTimer() {
+	if h.timerC == nil {
+		return
+	}
+
+	if !h.timer.Stop() {
+		select {
+		case <-h.timer.C:
+		default:
+		}
+	}
+
+	h.timerC = nil
+}
+
+func (h *SpyHandler) flush() {
+	h.stopTimer()
+
+	// Probes are measured once the batch has been handed to the outputs
+	defer h.observeProbes()
+
+	if h.recordsOutput != nil {
+		h.flushRecords()
+		return
+	}
+
+	h.flushSessions()
+	h.flushRoutes()
+
+	if h.buf.Len() == 0 {
+		return
+	}
+
+	msg := h.compressBatch(h.frameBatch(h.buf.Bytes(), h.batchLevels, &h.batchSeq, true))
+
+	if h.output != nil {
+		h.output(msg)
+	}
+
+	h.outputs.deliver(msg)
+	h.subs.deliver(msg, h.batchRecords)
+
+	h.trackFlushed(len(msg))
+
+	h.buf.Reset()
+	h.batchLevels = h.batchLevels[:0]
+	h.batchRecords = 0
+}
@@ -0,0 +1,101 @@
+package slogspy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+	"testing/slogtest"
+)
+
+func TestSpyHandler__slogtest(t *testing.T) {
+	var (
+		handler *SpyHandler
+		output  *bytes.Buffer
+	)
+
+	slogtest.Run(t, func(t *testing.T) slog.Handler {
+		output = &bytes.Buffer{}
+		handler = NewSpyHandler()
+
+		go handler.Run(context.Background(), func(msg []byte) { output.Write(msg) }) // nolint: errcheck
+
+		handler.Watch()
+
+		return handler
+	}, func(t *testing.T) map[string]any {
+		if err := handler.Shutdown(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+
+		var result map[string]any
+
+		if err := json.Unmarshal(output.Bytes(), &result); err != nil {
+			t.Fatalf("invalid JSON output %q: %v", output.String(), err)
+		}
+
+		return result
+	})
+}
+
+func TestSpy__slogtest(t *testing.T) {
+	var (
+		spy    *Spy
+		output *bytes.Buffer
+	)
+
+	slogtest.Run(t, func(t *testing.T) slog.Handler {
+		output = &bytes.Buffer{}
+		spy = NewSpy(slog.NewTextHandler(io.Discard, nil), WithPrinter(func(w io.Writer) slog.Handler {
+			return slog.NewTextHandler(w, &slog.HandlerOptions{Level: slog.LevelDebug})
+		}))
+
+		go spy.Run(context.Background(), func(msg []byte) { output.Write(msg) }) // nolint: errcheck
+
+		spy.Watch()
+
+		return spy
+	}, func(t *testing.T) map[string]any {
+		if err := spy.Shutdown(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+
+		return parseTextRecord(t, strings.TrimSpace(output.String()))
+	})
+}
+
+// parseTextRecord parses a text handler output line (without quoted values support) into a nested map
+func parseTextRecord(t *testing.T, line string) map[string]any {
+	t.Helper()
+
+	result := map[string]any{}
+
+	for _, field := range strings.Fields(line) {
+		key, value, ok := strings.Cut(field, "=")
+
+		if !ok {
+			t.Fatalf("invalid text output: %q", line)
+		}
+
+		keys := strings.Split(key, ".")
+		m := result
+
+		for _, k := range keys[:len(keys)-1] {
+			nested, ok := m[k].(map[string]any)
+
+			if !ok {
+				nested = map[string]any{}
+				m[k] = nested
+			}
+
+			m = nested
+		}
+
+		m[keys[len(keys)-1]] = value
+	}
+
+	return result
+}
@@ -0,0 +1,276 @@
+// Package httpmw provides a net/http middleware to capture the logs of individual requests
+// carrying a signed spy token (e.g., to debug a failing request in production).
+package httpmw
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	slogspy "github.com/palkan/slog-spy"
+)
+
+const (
+	DefaultHeader     = "X-Slog-Spy"
+	DefaultQueryParam = "slog_spy"
+	// DefaultTrailer is the trailer containing the captured logs (base64-encoded) if no sink is configured
+	DefaultTrailer = "X-Slog-Spy-Logs"
+	// RequestIDHeader is the response header with the ID of the captured request
+	RequestIDHeader = "X-Slog-Spy-Request-Id"
+
+	defaultRequestIDKey = "request_id"
+	defaultFlushTimeout = 100 * time.Millisecond
+)
+
+// Sink receives the captured logs of the request as they're flushed.
+type Sink func(requestID string, msg []byte)
+
+type config struct {
+	header       string
+	queryParam   string
+	verify       func(token string) bool
+	requestID    func(r *http.Request) string
+	requestIDKey string
+	sink         Sink
+	trailer      string
+	flushTimeout time.Duration
+	printer      func(w io.Writer) slog.Handler
+}
+
+type Option func(*config)
+
+// WithHeader sets the request header carrying the spy token (X-Slog-Spy by default).
+func WithHeader(name string) Option {
+	return func(c *config) {
+		c.header = name
+	}
+}
+
+// WithQueryParam sets the query parameter carrying the spy token (slog_spy by default); empty name disables it.
+func WithQueryParam(name string) Option {
+	return func(c *config) {
+		c.queryParam = name
+	}
+}
+
+// WithSecret makes the middleware accept tokens signed with the secret (see Sign).
+func WithSecret(secret []byte) Option {
+	return func(c *config) {
+		c.verify = func(token string) bool { return Verify(secret, token) }
+	}
+}
+
+// WithVerifier sets a custom token verification function.
+func WithVerifier(fn func(token string) bool) Option {
+	return func(c *config) {
+		c.verify = fn
+	}
+}
+
+// WithRequestID sets a function to obtain the request ID; the X-Request-Id header is used by default
+// (a random ID is generated if it's missing).
+func WithRequestID(fn func(r *http.Request) string) Option {
+	return func(c *config) {
+		c.requestID = fn
+	}
+}
+
+// WithRequestIDKey sets the attribute key to tag the captured records with (request_id by default).
+func WithRequestIDKey(key string) Option {
+	return func(c *config) {
+		c.requestIDKey = key
+	}
+}
+
+// WithSink streams the captured logs to the sink instead of returning them in the response trailer.
+func WithSink(sink Sink) Option {
+	return func(c *config) {
+		c.sink = sink
+	}
+}
+
+// WithTrailer sets the name of the response trailer to return the captured logs in.
+func WithTrailer(name string) Option {
+	return func(c *config) {
+		c.trailer = name
+	}
+}
+
+// WithFlushTimeout limits the time to wait for the captured logs to be delivered before completing the response.
+func WithFlushTimeout(d time.Duration) Option {
+	return func(c *config) {
+		c.flushTimeout = d
+	}
+}
+
+// WithPrinter sets a handler builder to format the captured records (JSON by default).
+func WithPrinter(printerBuilder func(w io.Writer) slog.Handler) Option {
+	return func(c *config) {
+		c.printer = printerBuilder
+	}
+}
+
+// New returns a middleware capturing the logs of the requests carrying a valid spy token.
+// Records must be logged with the request context (via the *Context logger methods) to be captured.
+// Requests are served as usual if the token is missing or invalid; a verifier (or a secret) must be configured.
+func New(spy *slogspy.Spy, opts ...Option) func(http.Handler) http.Handler {
+	c := &config{
+		header:       DefaultHeader,
+		queryParam:   DefaultQueryParam,
+		requestID:    defaultRequestID,
+		requestIDKey: defaultRequestIDKey,
+		trailer:      DefaultTrailer,
+		flushTimeout: defaultFlushTimeout,
+		printer: func(w io.Writer) slog.Handler {
+			return slog.NewJSONHandler(w, &slog.HandlerOptions{Level: slog.LevelDebug})
+		},
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token := c.token(r)
+
+			if token == "" || c.verify == nil || !c.verify(token) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			c.capture(spy, next, w, r)
+		})
+	}
+}
+
+func (c *config) token(r *http.Request) string {
+	if token := r.Header.Get(c.header); token != "" {
+		return token
+	}
+
+	if c.queryParam != "" {
+		return r.URL.Query().Get(c.queryParam)
+	}
+
+	return ""
+}
+
+func (c *config) capture(spy *slogspy.Spy, next http.Handler, w http.ResponseWriter, r *http.Request) {
+	id := c.requestID(r)
+
+	out := &captureOutput{id: id, sink: c.sink}
+
+	printer := func(w io.Writer) slog.Handler {
+		return c.printer(w).WithAttrs([]slog.Attr{slog.String(c.requestIDKey, id)})
+	}
+
+	ctx, sub := spy.CaptureContext(r.Context(), out.write, slogspy.WithSubscriptionPrinter(printer))
+	defer sub.Close()
+
+	w.Header().Set(RequestIDHeader, id)
+
+	if c.sink == nil {
+		w.Header().Add("Trailer", c.trailer)
+	}
+
+	next.ServeHTTP(w, r.WithContext(ctx))
+
+	flushCtx, cancel := context.WithTimeout(context.Background(), c.flushTimeout)
+	defer cancel()
+
+	sub.Flush(flushCtx) // nolint: errcheck
+
+	if c.sink == nil {
+		w.Header().Set(c.trailer, base64.StdEncoding.EncodeToString(out.close()))
+	}
+}
+
+// captureOutput collects (or streams) the captured logs; late deliveries are ignored
+type captureOutput struct {
+	id   string
+	sink Sink
+
+	mu     sync.Mutex
+	buf    bytes.Buffer
+	closed bool
+}
+
+func (o *captureOutput) write(msg []byte) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.closed {
+		return
+	}
+
+	if o.sink != nil {
+		o.sink(o.id, msg)
+		return
+	}
+
+	o.buf.Write(msg)
+}
+
+func (o *captureOutput) close() []byte {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.closed = true
+
+	return o.buf.Bytes()
+}
+
+// Sign returns a spy token signed with the secret and valid until the specified time.
+func Sign(secret []byte, expiresAt time.Time) string {
+	exp := strconv.FormatInt(expiresAt.Unix(), 10)
+
+	return exp + "." + signature(secret, exp)
+}
+
+// Verify checks the token signature and expiration.
+func Verify(secret []byte, token string) bool {
+	exp, sig, ok := strings.Cut(token, ".")
+
+	if !ok {
+		return false
+	}
+
+	ts, err := strconv.ParseInt(exp, 10, 64)
+
+	if err != nil || time.Now().Unix() > ts {
+		return false
+	}
+
+	return hmac.Equal([]byte(sig), []byte(signature(secret, exp)))
+}
+
+func signature(secret []byte, payload string) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(payload))
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func defaultRequestID(r *http.Request) string {
+	if id := r.Header.Get("X-Request-Id"); id != "" {
+		return id
+	}
+
+	b := make([]byte, 8)
+	rand.Read(b) // nolint: errcheck
+
+	return fmt.Sprintf("%x", b)
+}
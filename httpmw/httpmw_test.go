@@ -0,0 +1,139 @@
+package httpmw
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	slogspy "github.com/palkan/slog-spy"
+)
+
+var secret = []byte("s3cr3t")
+
+func newTestServer(t *testing.T, opts ...Option) *httptest.Server {
+	t.Helper()
+
+	spy := slogspy.NewSpy(slog.NewTextHandler(io.Discard, nil), slogspy.WithFlushInterval(time.Hour))
+
+	go spy.Run(context.Background(), nil)                    // nolint: errcheck
+	t.Cleanup(func() { spy.Shutdown(context.Background()) }) // nolint: errcheck
+
+	logger := slog.New(spy)
+
+	app := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		logger.InfoContext(r.Context(), "handling request", "path", r.URL.Path)
+		logger.Info("unrelated")
+
+		w.Write([]byte("ok")) // nolint: errcheck
+	})
+
+	server := httptest.NewServer(New(spy, append([]Option{WithSecret(secret)}, opts...)...)(app))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func TestMiddleware__Trailer(t *testing.T) {
+	server := newTestServer(t)
+
+	req, _ := http.NewRequest("GET", server.URL+"/users", nil)
+	req.Header.Set(DefaultHeader, Sign(secret, time.Now().Add(time.Minute)))
+	req.Header.Set("X-Request-Id", "req-42")
+
+	resp, err := http.DefaultClient.Do(req)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer resp.Body.Close()
+
+	io.ReadAll(resp.Body) // nolint: errcheck
+
+	if resp.Header.Get(RequestIDHeader) != "req-42" {
+		t.Errorf("expected request ID header, got %q", resp.Header.Get(RequestIDHeader))
+	}
+
+	logs, err := base64.StdEncoding.DecodeString(resp.Trailer.Get(DefaultTrailer))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(string(logs), `"path":"/users"`) {
+		t.Errorf("expected captured logs to contain the request record, got %s", logs)
+	}
+
+	if !strings.Contains(string(logs), `"request_id":"req-42"`) {
+		t.Errorf("expected captured records to be tagged with the request ID, got %s", logs)
+	}
+
+	if strings.Contains(string(logs), "unrelated") {
+		t.Errorf("expected records logged without the request context to be skipped, got %s", logs)
+	}
+}
+
+func TestMiddleware__Sink(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		captured bytes.Buffer
+		ids      []string
+	)
+
+	server := newTestServer(t, WithSink(func(id string, msg []byte) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		ids = append(ids, id)
+		captured.Write(msg)
+	}))
+
+	resp, err := http.Get(server.URL + "/?slog_spy=" + Sign(secret, time.Now().Add(time.Minute)))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp.Body.Close()
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if len(ids) != 1 || ids[0] != resp.Header.Get(RequestIDHeader) {
+		t.Errorf("expected logs to be delivered to the sink with the generated request ID, got %v", ids)
+	}
+
+	if !strings.Contains(captured.String(), "handling request") {
+		t.Errorf("expected the sink to receive the request logs, got %s", captured.String())
+	}
+}
+
+func TestMiddleware__InvalidToken(t *testing.T) {
+	server := newTestServer(t)
+
+	for _, token := range []string{"", "garbage", Sign(secret, time.Now().Add(-time.Minute)), Sign([]byte("other"), time.Now().Add(time.Minute))} {
+		req, _ := http.NewRequest("GET", server.URL, nil)
+		req.Header.Set(DefaultHeader, token)
+
+		resp, err := http.DefaultClient.Do(req)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		io.ReadAll(resp.Body) // nolint: errcheck
+		resp.Body.Close()
+
+		if resp.Header.Get(RequestIDHeader) != "" || resp.Trailer.Get(DefaultTrailer) != "" {
+			t.Errorf("expected request with token %q not to be captured", token)
+		}
+	}
+}
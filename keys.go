@@ -0,0 +1,55 @@
+package slogspy
+
+// WatchKey activates the spy only for the records carrying the attribute with the specified value
+// (e.g., tenant=acme); the path could include groups (see RecordView.Lookup) and the attributes added via WithAttrs
+// are taken into account. Matching records are delivered to the spy's output regardless of the spy's filter.
+// Multiple keys could be watched simultaneously; close the returned subscription to stop watching.
+func (h *SpyHandler) WatchKey(path string, value any) *Subscription {
+	sub := h.newSubscription(nil, withKey(WhereAttr(path, "==", value)))
+	h.register(sub)
+
+	return sub
+}
+
+// SubscribeKey is like WatchKey but delivers the matching records to the provided output
+// (the spy's output doesn't receive them unless there are other watchers).
+func (h *SpyHandler) SubscribeKey(path string, value any, out SpyOutput, opts ...SubscriptionOption) *Subscription {
+	f := WhereAttr(path, "==", value)
+
+	sub := h.newSubscription(out, append(opts, withKey(f), WithSubscriptionFilter(f))...)
+	h.register(sub)
+
+	return sub
+}
+
+func withKey(f Filter) SubscriptionOption {
+	return func(s *Subscription) {
+		s.key = f
+	}
+}
+
+// spied returns true if the record must be delivered to the spy's output;
+// scoped watchers (keyed and request-scoped ones, static sessions) only activate the spy for the matching records
+func (h *SpyHandler) spied(entry *Entry) bool {
+	scoped := h.subs.scoped.Load()
+
+	if scoped == 0 || h.active.Load() > scoped {
+		return h.matches(entry)
+	}
+
+	watchers := h.subs.keyWatchersList()
+
+	if len(watchers) == 0 {
+		return false
+	}
+
+	view := &RecordView{Record: entry.record, groups: entry.groups, frames: entry.frames}
+
+	for _, sub := range watchers {
+		if sub.key(view) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,88 @@
+package slogspy
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"sync"
+	"testing"
+)
+
+func TestSpy__WatchKey(t *testing.T) {
+	buf := &bytes.Buffer{}
+
+	spy := NewSpy(slog.NewTextHandler(&bytes.Buffer{}, nil))
+
+	go spy.Run(context.Background(), func(msg []byte) { // nolint: errcheck
+		buf.Write(msg)
+	})
+
+	acme := spy.WatchKey("tenant", "acme")
+	spy.WatchKey("tenant", "globex")
+
+	logger := slog.New(spy)
+
+	logger.With("tenant", "acme").Info("acme-record")
+	logger.Info("globex-record", "tenant", "globex")
+	logger.Info("initech-record", "tenant", "initech")
+	logger.Info("bare-record")
+
+	// Keys are matched by the Run loop, so make sure the records are processed before closing the watcher
+	if err := acme.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	acme.Close()
+
+	logger.With("tenant", "acme").Info("acme-after-close")
+
+	if err := spy.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	assertBufferContains(t, buf, "acme-record")
+	assertBufferContains(t, buf, "globex-record")
+	assertBufferContainsNot(t, buf, "initech-record")
+	assertBufferContainsNot(t, buf, "bare-record")
+	assertBufferContainsNot(t, buf, "acme-after-close")
+}
+
+func TestSpy__SubscribeKey(t *testing.T) {
+	var mu sync.Mutex
+
+	buf := &bytes.Buffer{}
+	acme := &bytes.Buffer{}
+
+	spy := NewSpy(slog.NewTextHandler(&bytes.Buffer{}, nil))
+
+	go spy.Run(context.Background(), func(msg []byte) { // nolint: errcheck
+		buf.Write(msg)
+	})
+
+	spy.SubscribeKey("tenant", "acme", func(msg []byte) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		acme.Write(msg)
+	})
+
+	logger := slog.New(spy)
+
+	logger.Info("acme-record", "tenant", "acme")
+	logger.Info("globex-record", "tenant", "globex")
+
+	if err := spy.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	assertBufferContains(t, acme, "acme-record")
+	assertBufferContainsNot(t, acme, "globex-record")
+
+	// Keyed subscriptions don't activate the spy's output
+	if buf.Len() != 0 {
+		t.Errorf("expected spy's output to be empty, got %q", buf.String())
+	}
+}
@@ -0,0 +1,99 @@
+package slogspy
+
+import (
+	"errors"
+	"maps"
+	"strings"
+	"sync"
+)
+
+// ErrSubscriptionStopped is the cause of subscriptions closed via StopAll
+var ErrSubscriptionStopped = errors.New("subscription stopped")
+
+// labeledSubscriptions tracks the running subscriptions with labels of all the spies in the process,
+// so they could be stopped at once regardless of the spy or transport they belong to
+var labeledSubscriptions = &subscriptionRegistry{subs: make(map[*Subscription]struct{})}
+
+type subscriptionRegistry struct {
+	mu   sync.Mutex
+	subs map[*Subscription]struct{}
+}
+
+func (r *subscriptionRegistry) add(sub *Subscription) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.subs[sub] = struct{}{}
+}
+
+func (r *subscriptionRegistry) remove(sub *Subscription) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.subs, sub)
+}
+
+// match returns the subscriptions with the label (optionally limited to the spy)
+func (r *subscriptionRegistry) match(label string, h *SpyHandler) []*Subscription {
+	key, value, anyValue := parseLabel(label)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var matched []*Subscription
+
+	for sub := range r.subs {
+		if h != nil && sub.handler.subs != h.subs {
+			continue
+		}
+
+		if v, ok := sub.labels[key]; ok && (anyValue || v == value) {
+			matched = append(matched, sub)
+		}
+	}
+
+	return matched
+}
+
+// parseLabel splits the "key=value" label; a bare key matches any value
+func parseLabel(label string) (string, string, bool) {
+	key, value, found := strings.Cut(label, "=")
+
+	return strings.TrimSpace(key), strings.TrimSpace(value), !found
+}
+
+// WithSubscriptionLabel tags the subscription with the label (e.g., "incident", "INC-1234"),
+// so all the related subscriptions could be stopped at once (see StopAll).
+func WithSubscriptionLabel(key string, value string) SubscriptionOption {
+	return func(s *Subscription) {
+		if s.labels == nil {
+			s.labels = make(map[string]string)
+		}
+
+		s.labels[key] = value
+	}
+}
+
+// Labels returns the subscription labels.
+func (s *Subscription) Labels() map[string]string {
+	return maps.Clone(s.labels)
+}
+
+// StopAll closes the subscriptions of all the spies (and transports, e.g., WebSocket connections) tagged with the label
+// in the "key=value" form ("key" alone matches any value). It returns the number of stopped subscriptions.
+func StopAll(label string) int {
+	return stopSubscriptions(labeledSubscriptions.match(label, nil))
+}
+
+// StopAll closes the spy's subscriptions tagged with the label (see the package-level StopAll).
+func (h *SpyHandler) StopAll(label string) int {
+	return stopSubscriptions(labeledSubscriptions.match(label, h))
+}
+
+func stopSubscriptions(subs []*Subscription) int {
+	for _, sub := range subs {
+		sub.closeWithCause(ErrSubscriptionStopped)
+	}
+
+	return len(subs)
+}
@@ -0,0 +1,74 @@
+package slogspy
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+)
+
+func TestStopAll(t *testing.T) {
+	api := NewSpy(slog.NewTextHandler(io.Discard, nil))
+	worker := NewSpy(slog.NewTextHandler(io.Discard, nil))
+
+	incident := api.Subscribe(nil, WithSubscriptionLabel("incident", "INC-1234"), WithSubscriptionLabel("team", "payments"))
+	other := api.Subscribe(nil, WithSubscriptionLabel("incident", "INC-9"))
+	unlabeled := api.Subscribe(nil)
+	workerIncident := worker.Subscribe(nil, WithSubscriptionLabel("incident", "INC-1234"))
+
+	if labels := incident.Labels(); labels["incident"] != "INC-1234" || labels["team"] != "payments" {
+		t.Errorf("unexpected labels: %v", labels)
+	}
+
+	if stopped := StopAll("incident=INC-1234"); stopped != 2 {
+		t.Errorf("expected 2 subscriptions to be stopped, got %d", stopped)
+	}
+
+	if !incident.Closed() || !workerIncident.Closed() {
+		t.Error("expected subscriptions of both spies to be stopped")
+	}
+
+	if cause := context.Cause(incident.Context()); !errors.Is(cause, ErrSubscriptionStopped) {
+		t.Errorf("expected stopped cause, got: %v", cause)
+	}
+
+	if other.Closed() || unlabeled.Closed() {
+		t.Error("expected other subscriptions to keep running")
+	}
+
+	// Stopped subscriptions are untracked
+	if stopped := StopAll("incident=INC-1234"); stopped != 0 {
+		t.Errorf("expected no subscriptions to be stopped again, got %d", stopped)
+	}
+
+	if stats := api.Stats(); stats.Watchers != 2 {
+		t.Errorf("expected 2 watchers left, got %d", stats.Watchers)
+	}
+
+	other.Close()
+	unlabeled.Close()
+}
+
+func TestSpy__StopAll(t *testing.T) {
+	api := NewSpy(slog.NewTextHandler(io.Discard, nil))
+	worker := NewSpy(slog.NewTextHandler(io.Discard, nil))
+
+	first := api.Subscribe(nil, WithSubscriptionLabel("incident", "INC-1"))
+	second := slog.New(api).With("service", "api").Handler().(*Spy).Subscribe(nil, WithSubscriptionLabel("incident", "INC-2"))
+	workerSub := worker.Subscribe(nil, WithSubscriptionLabel("incident", "INC-1"))
+	defer workerSub.Close()
+
+	// A bare key matches any value; subscriptions of the derived spies are included
+	if stopped := api.StopAll("incident"); stopped != 2 {
+		t.Errorf("expected 2 subscriptions to be stopped, got %d", stopped)
+	}
+
+	if !first.Closed() || !second.Closed() {
+		t.Error("expected the spy's subscriptions to be stopped")
+	}
+
+	if workerSub.Closed() {
+		t.Error("expected subscriptions of other spies to keep running")
+	}
+}
@@ -0,0 +1,142 @@
+package slogspy
+
+import (
+	"slices"
+	"sync"
+	"time"
+)
+
+const latencySamples = 128
+
+// LatencyStats contains the percentiles of the enqueue-to-output latency measured by probes (see WithLatencyProbes).
+type LatencyStats struct {
+	// Samples is the number of measurements the percentiles are calculated from (the last 128 probes)
+	Samples int           `json:"samples"`
+	P50     time.Duration `json:"p50"`
+	P90     time.Duration `json:"p90"`
+	P99     time.Duration `json:"p99"`
+	Max     time.Duration `json:"max"`
+}
+
+// LatencyObserver could be implemented by a MetricsCollector to receive the probes measurements.
+type LatencyObserver interface {
+	ObserveLatency(d time.Duration)
+}
+
+// WithLatencyProbes makes the spy periodically send probe entries through the backlog while there are watchers.
+// Probes are not delivered to consumers; the time between enqueuing a probe and handing the batch it belongs to
+// to the output is reported via Stats().Latency (and LatencyObserver).
+func WithLatencyProbes(interval time.Duration) SpyHandlerOption {
+	return func(h *SpyHandler) {
+		h.probeInterval = interval
+	}
+}
+
+type latencyStats struct {
+	mu      sync.Mutex
+	samples [latencySamples]time.Duration
+	n       int
+	pos     int
+}
+
+func (s *latencyStats) observe(d time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.samples[s.pos] = d
+	s.pos = (s.pos + 1) % latencySamples
+
+	if s.n < latencySamples {
+		s.n++
+	}
+}
+
+func (s *latencyStats) snapshot() LatencyStats {
+	s.mu.Lock()
+	samples := slices.Clone(s.samples[:s.n])
+	s.mu.Unlock()
+
+	if len(samples) == 0 {
+		return LatencyStats{}
+	}
+
+	slices.Sort(samples)
+
+	percentile := func(p int) time.Duration {
+		return samples[(len(samples)-1)*p/100]
+	}
+
+	return LatencyStats{
+		Samples: len(samples),
+		P50:     percentile(50),
+		P90:     percentile(90),
+		P99:     percentile(99),
+		Max:     samples[len(samples)-1],
+	}
+}
+
+// startProbes starts sending probes to the backlog; the returned function stops it
+func (h *SpyHandler) startProbes() func() {
+	if h.probeInterval <= 0 {
+		return func() {}
+	}
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		ticker := time.NewTicker(h.probeInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				h.sendProbe()
+			}
+		}
+	}()
+
+	return func() {
+		close(stop)
+		<-done
+	}
+}
+
+func (h *SpyHandler) sendProbe() {
+	if h.active.Load() <= 0 || h.closed.Load() {
+		return
+	}
+
+	entry := &Entry{probe: true, enqueuedAt: time.Now()}
+
+	// Probes are skipped when the backlog is full (the records are dropped anyway)
+	select {
+	case h.queue() <- entry:
+	default:
+	}
+}
+
+// observeProbes reports the latency of the probes included into the flushed batch; it's only called by the Run loop
+func (h *SpyHandler) observeProbes() {
+	if len(h.probes) == 0 {
+		return
+	}
+
+	now := time.Now()
+
+	for _, enqueuedAt := range h.probes {
+		latency := now.Sub(enqueuedAt)
+
+		h.stats.latency.observe(latency)
+
+		if observer, ok := h.metrics.(LatencyObserver); ok {
+			observer.ObserveLatency(latency)
+		}
+	}
+
+	h.probes = h.probes[:0]
+}
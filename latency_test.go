@@ -0,0 +1,101 @@
+package slogspy
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+)
+
+type latencyMetrics struct {
+	testMetrics
+
+	mu        sync.Mutex
+	latencies []time.Duration
+}
+
+func (m *latencyMetrics) ObserveLatency(d time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.latencies = append(m.latencies, d)
+}
+
+func TestSpy__LatencyProbes(t *testing.T) {
+	metrics := &latencyMetrics{}
+
+	var (
+		mu      sync.Mutex
+		batches int
+	)
+
+	spy := NewSpy(
+		slog.NewTextHandler(io.Discard, nil),
+		WithLatencyProbes(5*time.Millisecond),
+		WithFlushInterval(10*time.Millisecond),
+		WithMetricsCollector(metrics),
+	)
+
+	go spy.Run(context.Background(), func([]byte) { // nolint: errcheck
+		mu.Lock()
+		defer mu.Unlock()
+
+		batches++
+	})
+
+	waitForRunning(t, spy)
+
+	if stats := spy.Stats(); stats.Latency.Samples != 0 {
+		t.Errorf("expected no probes without watchers, got %d samples", stats.Latency.Samples)
+	}
+
+	spy.Watch()
+
+	waitFor(t, func() bool { return spy.Stats().Latency.Samples >= 3 })
+
+	if err := spy.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	latency := spy.Stats().Latency
+
+	if latency.P50 <= 0 || latency.P50 > latency.P99 || latency.P99 > latency.Max || latency.Max > time.Second {
+		t.Errorf("unexpected latency percentiles: %+v", latency)
+	}
+
+	metrics.mu.Lock()
+	observed := len(metrics.latencies)
+	metrics.mu.Unlock()
+
+	if observed < latency.Samples {
+		t.Errorf("expected the metrics collector to observe %d probes, got %d", latency.Samples, observed)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if batches != 0 {
+		t.Errorf("expected probes not to be delivered, got %d batches", batches)
+	}
+}
+
+func TestLatencyStats(t *testing.T) {
+	stats := &latencyStats{}
+
+	for i := 1; i <= latencySamples+100; i++ {
+		stats.observe(time.Duration(i) * time.Millisecond)
+	}
+
+	snapshot := stats.snapshot()
+
+	// Only the last samples are kept
+	if snapshot.Samples != latencySamples || snapshot.Max != 228*time.Millisecond {
+		t.Errorf("unexpected stats: %+v", snapshot)
+	}
+
+	if snapshot.P50 != 164*time.Millisecond || snapshot.P99 != 226*time.Millisecond {
+		t.Errorf("unexpected percentiles: %+v", snapshot)
+	}
+}
@@ -0,0 +1,444 @@
+// Package natsout provides a spy output publishing flushed batches to a NATS subject.
+// It speaks a minimal subset of the NATS client protocol (CONNECT, PUB and SUB), so no client library is required.
+package natsout
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultSubject = "slogspy"
+	// PresenceSuffix is appended to the subject to get the one viewers send heartbeats to (see WithWatcher).
+	PresenceSuffix = ".presence"
+
+	defaultTimeout           = time.Second
+	defaultReconnectInterval = time.Second
+	defaultPresenceTTL       = 10 * time.Second
+)
+
+// ErrDisconnected is reported when a batch is dropped while waiting to reconnect.
+var ErrDisconnected = errors.New("nats: disconnected")
+
+// Watcher is a spy (or spy handler) to keep watching while the subject has viewers.
+type Watcher interface {
+	Watch()
+	Unwatch()
+}
+
+// Output publishes batches to the NATS subject; use its Write method as a SpyOutput.
+type Output struct {
+	addr              string
+	subject           string
+	token             string
+	user              string
+	password          string
+	timeout           time.Duration
+	reconnectInterval time.Duration
+	errorHandler      func(err error)
+
+	watcher     Watcher
+	presenceTTL time.Duration
+
+	watchMu  sync.Mutex
+	watching bool
+	lastSeen time.Time
+
+	mu          sync.Mutex
+	conn        *connection
+	lastAttempt time.Time
+
+	closeOnce sync.Once
+	done      chan struct{}
+	wg        sync.WaitGroup
+	readers   sync.WaitGroup
+}
+
+type connection struct {
+	net.Conn
+	w *bufio.Writer
+}
+
+type Option func(*Output)
+
+// WithSubject sets the subject to publish batches to ("slogspy" by default), e.g., "slogspy." + hostname
+// to tell app instances apart (viewers can subscribe to "slogspy.>" to get them all).
+func WithSubject(name string) Option {
+	return func(o *Output) {
+		o.subject = name
+	}
+}
+
+// WithToken sets the token to authenticate with.
+func WithToken(token string) Option {
+	return func(o *Output) {
+		o.token = token
+	}
+}
+
+// WithUserInfo sets the user and password to authenticate with.
+func WithUserInfo(user, password string) Option {
+	return func(o *Output) {
+		o.user = user
+		o.password = password
+	}
+}
+
+// WithTimeout sets the dial, handshake and write timeout (1s by default).
+func WithTimeout(d time.Duration) Option {
+	return func(o *Output) {
+		o.timeout = d
+	}
+}
+
+// WithReconnectInterval sets the min interval between reconnection attempts (1s by default);
+// batches are dropped while disconnected.
+func WithReconnectInterval(d time.Duration) Option {
+	return func(o *Output) {
+		o.reconnectInterval = d
+	}
+}
+
+// WithErrorHandler sets a function to be called on connection and publishing errors.
+func WithErrorHandler(fn func(err error)) Option {
+	return func(o *Output) {
+		o.errorHandler = fn
+	}
+}
+
+// WithWatcher makes the output keep a watcher registered only while there are viewers. NATS doesn't track
+// subscribers, so viewers must publish heartbeats to the subject + PresenceSuffix at least every ttl (10s by default).
+func WithWatcher(w Watcher, ttl time.Duration) Option {
+	return func(o *Output) {
+		o.watcher = w
+
+		if ttl > 0 {
+			o.presenceTTL = ttl
+		}
+	}
+}
+
+// NewOutput creates an output publishing to the NATS server at addr (host:port).
+// The connection is established lazily (or right away if a watcher is set) and re-established on failures.
+func NewOutput(addr string, opts ...Option) *Output {
+	o := &Output{
+		addr:              addr,
+		subject:           DefaultSubject,
+		timeout:           defaultTimeout,
+		reconnectInterval: defaultReconnectInterval,
+		presenceTTL:       defaultPresenceTTL,
+		done:              make(chan struct{}),
+	}
+
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	if o.watcher != nil {
+		o.wg.Add(1)
+		go o.trackPresence()
+	}
+
+	return o
+}
+
+// Write publishes the batch to the subject.
+func (o *Output) Write(msg []byte) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if err := o.connect(); err != nil {
+		o.handleError(err)
+		return
+	}
+
+	c := o.conn
+
+	c.SetWriteDeadline(time.Now().Add(o.timeout))                               // nolint: errcheck
+	c.w.WriteString("PUB " + o.subject + " " + strconv.Itoa(len(msg)) + "\r\n") // nolint: errcheck
+	c.w.Write(msg)                                                              // nolint: errcheck
+	c.w.WriteString("\r\n")                                                     // nolint: errcheck
+
+	if err := c.w.Flush(); err != nil {
+		c.Close() // nolint: errcheck
+		o.conn = nil
+
+		o.handleError(err)
+	}
+}
+
+// Close stops tracking viewers, unregisters the watcher (if any) and closes the connection.
+func (o *Output) Close() error {
+	o.closeOnce.Do(func() { close(o.done) })
+	o.wg.Wait()
+
+	o.setWatching(false)
+
+	o.mu.Lock()
+
+	var err error
+
+	if o.conn != nil {
+		err = o.conn.Close()
+		o.conn = nil
+	}
+
+	o.mu.Unlock()
+
+	o.readers.Wait()
+
+	return err
+}
+
+func (o *Output) trackPresence() {
+	defer o.wg.Done()
+
+	ticker := time.NewTicker(o.presenceTTL / 2)
+	defer ticker.Stop()
+
+	for {
+		// Keep the connection (and, thus, the presence subscription) alive even if nothing is published
+		o.mu.Lock()
+		err := o.connect()
+		o.mu.Unlock()
+
+		if err != nil && !errors.Is(err, ErrDisconnected) {
+			o.handleError(err)
+		}
+
+		o.watchMu.Lock()
+		expired := o.watching && time.Since(o.lastSeen) > o.presenceTTL
+		o.watchMu.Unlock()
+
+		if expired {
+			o.setWatching(false)
+		}
+
+		select {
+		case <-o.done:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (o *Output) seen() {
+	o.watchMu.Lock()
+	o.lastSeen = time.Now()
+	o.watchMu.Unlock()
+
+	o.setWatching(true)
+}
+
+func (o *Output) setWatching(active bool) {
+	if o.watcher == nil {
+		return
+	}
+
+	o.watchMu.Lock()
+	defer o.watchMu.Unlock()
+
+	if o.watching == active {
+		return
+	}
+
+	o.watching = active
+
+	if active {
+		o.watcher.Watch()
+	} else {
+		o.watcher.Unwatch()
+	}
+}
+
+// connect establishes the connection if needed; must be called with the lock held
+func (o *Output) connect() error {
+	if o.conn != nil {
+		return nil
+	}
+
+	select {
+	case <-o.done:
+		return ErrDisconnected
+	default:
+	}
+
+	if time.Since(o.lastAttempt) < o.reconnectInterval {
+		return ErrDisconnected
+	}
+
+	o.lastAttempt = time.Now()
+
+	conn, err := net.DialTimeout("tcp", o.addr, o.timeout)
+
+	if err != nil {
+		return err
+	}
+
+	c := &connection{Conn: conn, w: bufio.NewWriter(conn)}
+	r := bufio.NewReader(conn)
+
+	if err := o.handshake(c, r); err != nil {
+		conn.Close() // nolint: errcheck
+		return err
+	}
+
+	o.conn = c
+
+	o.readers.Add(1)
+	go o.readLoop(c, r)
+
+	return nil
+}
+
+func (o *Output) handshake(c *connection, r *bufio.Reader) error {
+	c.SetDeadline(time.Now().Add(o.timeout)) // nolint: errcheck
+	defer c.SetDeadline(time.Time{})         // nolint: errcheck
+
+	line, err := readLine(r)
+
+	if err != nil {
+		return err
+	}
+
+	if !strings.HasPrefix(line, "INFO ") {
+		return fmt.Errorf("nats: unexpected greeting %q", line)
+	}
+
+	opts := connectOptions{
+		Name:     "slog-spy",
+		Lang:     "go",
+		Protocol: 1,
+		Token:    o.token,
+		User:     o.user,
+		Pass:     o.password,
+	}
+
+	payload, err := json.Marshal(opts)
+
+	if err != nil {
+		return err
+	}
+
+	c.w.WriteString("CONNECT ") // nolint: errcheck
+	c.w.Write(payload)          // nolint: errcheck
+	c.w.WriteString("\r\n")     // nolint: errcheck
+
+	if o.watcher != nil {
+		c.w.WriteString("SUB " + o.subject + PresenceSuffix + " 1\r\n") // nolint: errcheck
+	}
+
+	// PING/PONG makes sure the server has accepted the connection
+	c.w.WriteString("PING\r\n") // nolint: errcheck
+
+	if err := c.w.Flush(); err != nil {
+		return err
+	}
+
+	for {
+		line, err := readLine(r)
+
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case line == "PONG":
+			return nil
+		case strings.HasPrefix(line, "-ERR"):
+			return fmt.Errorf("nats: %s", strings.TrimSpace(strings.TrimPrefix(line, "-ERR")))
+		}
+	}
+}
+
+// readLoop handles the server messages until the connection is closed
+func (o *Output) readLoop(c *connection, r *bufio.Reader) {
+	defer o.readers.Done()
+
+	for {
+		line, err := readLine(r)
+
+		if err != nil {
+			o.disconnect(c)
+
+			if !errors.Is(err, net.ErrClosed) {
+				o.handleError(err)
+			}
+
+			return
+		}
+
+		switch {
+		case line == "PING":
+			o.mu.Lock()
+			c.w.WriteString("PONG\r\n") // nolint: errcheck
+			c.w.Flush()                 // nolint: errcheck
+			o.mu.Unlock()
+		case strings.HasPrefix(line, "MSG "):
+			fields := strings.Fields(line)
+			n, err := strconv.Atoi(fields[len(fields)-1])
+
+			if err == nil {
+				_, err = io.CopyN(io.Discard, r, int64(n)+2)
+			}
+
+			if err != nil {
+				o.disconnect(c)
+				o.handleError(err)
+
+				return
+			}
+
+			o.seen()
+		case strings.HasPrefix(line, "-ERR"):
+			o.handleError(fmt.Errorf("nats: %s", strings.TrimSpace(strings.TrimPrefix(line, "-ERR"))))
+		}
+	}
+}
+
+// disconnect closes the connection and resets it if it's still the current one
+func (o *Output) disconnect(c *connection) {
+	c.Close() // nolint: errcheck
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.conn == c {
+		o.conn = nil
+	}
+}
+
+func (o *Output) handleError(err error) {
+	if o.errorHandler != nil {
+		o.errorHandler(err)
+	}
+}
+
+type connectOptions struct {
+	Verbose  bool   `json:"verbose"`
+	Pedantic bool   `json:"pedantic"`
+	Name     string `json:"name"`
+	Lang     string `json:"lang"`
+	Protocol int    `json:"protocol"`
+	Token    string `json:"auth_token,omitempty"`
+	User     string `json:"user,omitempty"`
+	Pass     string `json:"pass,omitempty"`
+}
+
+func readLine(r *bufio.Reader) (string, error) {
+	line, err := r.ReadString('\n')
+
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimRight(line, "\r\n"), nil
+}
@@ -0,0 +1,265 @@
+package natsout
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"log/slog"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	slogspy "github.com/palkan/slog-spy"
+)
+
+// fakeServer is a minimal NATS server recording the published messages
+type fakeServer struct {
+	ln net.Listener
+
+	mu        sync.Mutex
+	published []string
+	connects  []string
+	subs      map[string]string
+	conns     []net.Conn
+}
+
+func newFakeServer(t *testing.T) *fakeServer {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := &fakeServer{ln: ln, subs: make(map[string]string)}
+
+	go s.serve()
+
+	t.Cleanup(func() { ln.Close() }) // nolint: errcheck
+
+	return s
+}
+
+func (s *fakeServer) serve() {
+	for {
+		conn, err := s.ln.Accept()
+
+		if err != nil {
+			return
+		}
+
+		s.mu.Lock()
+		s.conns = append(s.conns, conn)
+		s.mu.Unlock()
+
+		go s.handle(conn)
+	}
+}
+
+func (s *fakeServer) handle(conn net.Conn) {
+	defer conn.Close() // nolint: errcheck
+
+	conn.Write([]byte("INFO {\"server_id\":\"fake\"}\r\n")) // nolint: errcheck
+
+	r := bufio.NewReader(conn)
+
+	for {
+		line, err := readLine(r)
+
+		if err != nil {
+			return
+		}
+
+		s.mu.Lock()
+
+		switch {
+		case strings.HasPrefix(line, "CONNECT "):
+			s.connects = append(s.connects, strings.TrimPrefix(line, "CONNECT "))
+		case strings.HasPrefix(line, "SUB "):
+			fields := strings.Fields(line)
+			s.subs[fields[1]] = fields[2]
+		case line == "PING":
+			conn.Write([]byte("PONG\r\n")) // nolint: errcheck
+		case strings.HasPrefix(line, "PUB "):
+			fields := strings.Fields(line)
+			n, _ := strconv.Atoi(fields[2])
+			payload := make([]byte, n+2)
+
+			if _, err := io.ReadFull(r, payload); err != nil {
+				s.mu.Unlock()
+				return
+			}
+
+			s.published = append(s.published, fields[1]+" "+string(payload[:n]))
+		}
+
+		s.mu.Unlock()
+	}
+}
+
+// send delivers a message to the subscribers of the subject on the latest connection
+func (s *fakeServer) send(subject, payload string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sid, ok := s.subs[subject]
+
+	if !ok || len(s.conns) == 0 {
+		return
+	}
+
+	s.conns[len(s.conns)-1].Write([]byte("MSG " + subject + " " + sid + " " + strconv.Itoa(len(payload)) + "\r\n" + payload + "\r\n")) // nolint: errcheck
+}
+
+// dropConnections closes all the client connections
+func (s *fakeServer) dropConnections() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, conn := range s.conns {
+		conn.Close() // nolint: errcheck
+	}
+}
+
+func (s *fakeServer) messages() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]string(nil), s.published...)
+}
+
+type fakeWatcher struct {
+	watchers atomic.Int64
+}
+
+func (w *fakeWatcher) Watch()   { w.watchers.Add(1) }
+func (w *fakeWatcher) Unwatch() { w.watchers.Add(-1) }
+
+func TestOutput__Write(t *testing.T) {
+	server := newFakeServer(t)
+
+	out := NewOutput(server.ln.Addr().String(), WithSubject("slogspy.web-1"), WithToken("secret"))
+	defer out.Close() // nolint: errcheck
+
+	spy := slogspy.NewSpy(slog.NewTextHandler(io.Discard, nil))
+
+	go spy.Run(context.Background(), out.Write) // nolint: errcheck
+
+	spy.Watch()
+
+	slog.New(spy).Info("hello", "id", 42)
+
+	if err := spy.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	waitFor(t, func() bool { return len(server.messages()) == 1 })
+
+	if msg := server.messages()[0]; !strings.HasPrefix(msg, "slogspy.web-1 ") || !strings.Contains(msg, `"msg":"hello","id":42`) {
+		t.Errorf("unexpected message: %s", msg)
+	}
+
+	server.mu.Lock()
+	defer server.mu.Unlock()
+
+	if !strings.Contains(server.connects[0], `"auth_token":"secret"`) {
+		t.Errorf("expected to authenticate with the token, got: %s", server.connects[0])
+	}
+}
+
+func TestOutput__Reconnect(t *testing.T) {
+	server := newFakeServer(t)
+
+	out := NewOutput(server.ln.Addr().String(), WithReconnectInterval(10*time.Millisecond))
+	defer out.Close() // nolint: errcheck
+
+	out.Write([]byte("first"))
+
+	waitFor(t, func() bool { return len(server.messages()) == 1 })
+
+	server.dropConnections()
+
+	// The reader detects the closed connection, so the next write reconnects
+	waitFor(t, func() bool {
+		out.mu.Lock()
+		defer out.mu.Unlock()
+
+		return out.conn == nil
+	})
+
+	time.Sleep(20 * time.Millisecond)
+
+	out.Write([]byte("second"))
+
+	waitFor(t, func() bool { return len(server.messages()) == 2 })
+
+	if messages := server.messages(); messages[1] != "slogspy second" {
+		t.Errorf("unexpected messages: %v", messages)
+	}
+}
+
+func TestOutput__WithWatcher(t *testing.T) {
+	server := newFakeServer(t)
+
+	watcher := &fakeWatcher{}
+
+	out := NewOutput(server.ln.Addr().String(), WithWatcher(watcher, 50*time.Millisecond))
+
+	waitFor(t, func() bool {
+		server.mu.Lock()
+		defer server.mu.Unlock()
+
+		_, ok := server.subs["slogspy"+PresenceSuffix]
+
+		return ok
+	})
+
+	if watcher.watchers.Load() != 0 {
+		t.Fatal("expected not to watch without viewers")
+	}
+
+	server.send("slogspy"+PresenceSuffix, "viewer-1")
+
+	waitFor(t, func() bool { return watcher.watchers.Load() == 1 })
+
+	server.send("slogspy"+PresenceSuffix, "viewer-2")
+
+	time.Sleep(10 * time.Millisecond)
+
+	if watcher.watchers.Load() != 1 {
+		t.Errorf("expected to watch once, got %d", watcher.watchers.Load())
+	}
+
+	// No heartbeats within the TTL
+	waitFor(t, func() bool { return watcher.watchers.Load() == 0 })
+
+	server.send("slogspy"+PresenceSuffix, "viewer-1")
+
+	waitFor(t, func() bool { return watcher.watchers.Load() == 1 })
+
+	if err := out.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if watcher.watchers.Load() != 0 {
+		t.Error("expected to unwatch on close")
+	}
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(time.Second)
+
+	for time.Now().Before(deadline) {
+		if cond() {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	t.Fatal("timed out waiting for condition")
+}
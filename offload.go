@@ -0,0 +1,152 @@
+package slogspy
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+)
+
+const defaultOffloadStoreSize = 16 * 1024 * 1024 // 16MB
+
+// OffloadStore keeps the full contents of the values offloaded from the spied records.
+type OffloadStore interface {
+	Put(id string, value []byte)
+	Get(id string) ([]byte, bool)
+}
+
+// WithOffload makes the spy replace attribute values larger than the threshold (in bytes)
+// with references ({"ref":"<id>","size":<size>}) and put the full values into the store.
+// Use Fetch to retrieve them. If the store is nil, an in-memory store is used.
+func WithOffload(threshold int, store OffloadStore) SpyHandlerOption {
+	return func(h *SpyHandler) {
+		if store == nil {
+			store = NewMemoryStore(defaultOffloadStoreSize)
+		}
+
+		h.offloadStore = store
+		h.offloadThreshold = threshold
+	}
+}
+
+// Fetch returns the offloaded value by its reference ID.
+func (h *SpyHandler) Fetch(id string) ([]byte, bool) {
+	if h.offloadStore == nil {
+		return nil, false
+	}
+
+	return h.offloadStore.Get(id)
+}
+
+// FetchHandler returns an http.Handler serving offloaded values by their IDs (passed via the "id" query parameter).
+func (h *SpyHandler) FetchHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		val, ok := h.Fetch(r.URL.Query().Get("id"))
+
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Write(val) // nolint: errcheck
+	})
+}
+
+func (h *SpyHandler) offloadValue(v slog.Value) slog.Value {
+	var data []byte
+
+	switch v.Kind() {
+	case slog.KindString:
+		if len(v.String()) <= h.offloadThreshold {
+			return v
+		}
+		data = []byte(v.String())
+	case slog.KindAny:
+		data = encodeOffloadValue(v.Any())
+	default:
+		return v
+	}
+
+	if len(data) <= h.offloadThreshold {
+		return v
+	}
+
+	id := newOffloadID()
+	h.offloadStore.Put(id, data)
+
+	return slog.GroupValue(slog.String("ref", id), slog.Int("size", len(data)))
+}
+
+func encodeOffloadValue(v any) []byte {
+	switch vv := v.(type) {
+	case []byte:
+		return vv
+	case error:
+		return []byte(vv.Error())
+	case fmt.Stringer:
+		return []byte(vv.String())
+	}
+
+	if data, err := json.Marshal(v); err == nil {
+		return data
+	}
+
+	return []byte(fmt.Sprintf("%+v", v))
+}
+
+func newOffloadID() string {
+	var id [12]byte
+	rand.Read(id[:]) // nolint: errcheck
+	return hex.EncodeToString(id[:])
+}
+
+// MemoryStore is an in-memory OffloadStore evicting the oldest values when the size limit is reached.
+type MemoryStore struct {
+	mu      sync.Mutex
+	values  map[string][]byte
+	order   []string
+	size    int
+	maxSize int
+}
+
+var _ OffloadStore = (*MemoryStore)(nil)
+
+// NewMemoryStore creates a new MemoryStore holding up to maxSize bytes.
+func NewMemoryStore(maxSize int) *MemoryStore {
+	return &MemoryStore{
+		values:  make(map[string][]byte),
+		maxSize: maxSize,
+	}
+}
+
+func (s *MemoryStore) Put(id string, value []byte) {
+	if len(value) > s.maxSize {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for s.size+len(value) > s.maxSize && len(s.order) > 0 {
+		oldest := s.order[0]
+		s.order = s.order[1:]
+		s.size -= len(s.values[oldest])
+		delete(s.values, oldest)
+	}
+
+	s.values[id] = value
+	s.order = append(s.order, id)
+	s.size += len(value)
+}
+
+func (s *MemoryStore) Get(id string) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	val, ok := s.values[id]
+	return val, ok
+}
@@ -0,0 +1,96 @@
+package slogspy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSpy__WithOffload(t *testing.T) {
+	mainBuf := &bytes.Buffer{}
+	buf := &bytes.Buffer{}
+
+	spy := NewSpy(slog.NewTextHandler(mainBuf, nil), WithOffload(16, nil))
+
+	go spy.Run(context.Background(), func(msg []byte) {
+		buf.Write(msg)
+	})
+
+	spy.Watch()
+
+	payload := strings.Repeat("a", 100)
+	slog.New(spy).Info("dump", "payload", payload, "small", "value")
+
+	if err := spy.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	var record struct {
+		Payload struct {
+			Ref  string `json:"ref"`
+			Size int    `json:"size"`
+		} `json:"payload"`
+		Small string `json:"small"`
+	}
+
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("failed to parse output: %v", err)
+	}
+
+	if record.Payload.Ref == "" || record.Payload.Size != 100 {
+		t.Fatalf("expected payload to be offloaded, got %s", buf.String())
+	}
+
+	if record.Small != "value" {
+		t.Errorf("expected small value to be kept, got %s", buf.String())
+	}
+
+	fetched, ok := spy.Fetch(record.Payload.Ref)
+
+	if !ok || string(fetched) != payload {
+		t.Errorf("expected to fetch the offloaded value, got %s", fetched)
+	}
+
+	assertBufferContains(t, mainBuf, payload)
+
+	w := httptest.NewRecorder()
+	spy.FetchHandler().ServeHTTP(w, httptest.NewRequest("GET", "/?id="+record.Payload.Ref, nil))
+
+	body, _ := io.ReadAll(w.Result().Body)
+
+	if string(body) != payload {
+		t.Errorf("expected fetch endpoint to return the offloaded value, got %s", body)
+	}
+
+	w = httptest.NewRecorder()
+	spy.FetchHandler().ServeHTTP(w, httptest.NewRequest("GET", "/?id=unknown", nil))
+
+	if w.Code != 404 {
+		t.Errorf("expected 404 for unknown id, got %d", w.Code)
+	}
+}
+
+func TestMemoryStore__Eviction(t *testing.T) {
+	store := NewMemoryStore(10)
+
+	store.Put("a", []byte("12345"))
+	store.Put("b", []byte("12345"))
+	store.Put("c", []byte("123"))
+
+	if _, ok := store.Get("a"); ok {
+		t.Error("expected the oldest value to be evicted")
+	}
+
+	if val, ok := store.Get("b"); !ok || string(val) != "12345" {
+		t.Errorf("expected b to be kept, got %s", val)
+	}
+
+	if _, ok := store.Get("c"); !ok {
+		t.Error("expected c to be stored")
+	}
+}
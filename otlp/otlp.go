@@ -0,0 +1,324 @@
+// Package otlp provides an output adapter exporting spied records to an OpenTelemetry collector
+// via OTLP/HTTP (JSON encoding).
+package otlp
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultTimeout   = 5 * time.Second
+	defaultScopeName = "github.com/palkan/slog-spy"
+)
+
+// Output converts spied records into OpenTelemetry log records and exports them to the collector.
+// Use it in the structured delivery mode: spy.RunRecords(ctx, out.Export).
+type Output struct {
+	endpoint     string
+	client       *http.Client
+	headers      map[string]string
+	resource     []slog.Attr
+	scope        string
+	timeout      time.Duration
+	errorHandler func(err error)
+}
+
+type Option func(*Output)
+
+// WithHTTPClient sets the HTTP client to use (http.DefaultClient by default).
+func WithHTTPClient(c *http.Client) Option {
+	return func(o *Output) {
+		o.client = c
+	}
+}
+
+// WithHeaders sets additional request headers (e.g., for authentication).
+func WithHeaders(headers map[string]string) Option {
+	return func(o *Output) {
+		o.headers = headers
+	}
+}
+
+// WithServiceName sets the service.name resource attribute.
+func WithServiceName(name string) Option {
+	return WithResource(slog.String("service.name", name))
+}
+
+// WithResource adds resource attributes.
+func WithResource(attrs ...slog.Attr) Option {
+	return func(o *Output) {
+		o.resource = append(o.resource, attrs...)
+	}
+}
+
+// WithScopeName sets the instrumentation scope name.
+func WithScopeName(name string) Option {
+	return func(o *Output) {
+		o.scope = name
+	}
+}
+
+// WithTimeout sets the export request timeout (5s by default).
+func WithTimeout(d time.Duration) Option {
+	return func(o *Output) {
+		o.timeout = d
+	}
+}
+
+// WithErrorHandler sets a function to be called when the export fails.
+func WithErrorHandler(fn func(err error)) Option {
+	return func(o *Output) {
+		o.errorHandler = fn
+	}
+}
+
+// NewOutput creates an output exporting records to the OTLP/HTTP logs endpoint (e.g., http://localhost:4318/v1/logs).
+func NewOutput(endpoint string, opts ...Option) *Output {
+	o := &Output{
+		endpoint: endpoint,
+		client:   http.DefaultClient,
+		scope:    defaultScopeName,
+		timeout:  defaultTimeout,
+	}
+
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	return o
+}
+
+// Export sends the batch of records to the collector; it's a SpyOutputRecords function.
+// Errors are reported to the error handler (if any).
+func (o *Output) Export(records []slog.Record) {
+	if len(records) == 0 {
+		return
+	}
+
+	if err := o.export(records); err != nil && o.errorHandler != nil {
+		o.errorHandler(err)
+	}
+}
+
+func (o *Output) export(records []slog.Record) error {
+	body, err := json.Marshal(o.encode(records))
+
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), o.timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, o.endpoint, bytes.NewReader(body))
+
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	for k, v := range o.headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := o.client.Do(req)
+
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	io.Copy(io.Discard, resp.Body) // nolint: errcheck
+
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("otlp export failed: %s", resp.Status)
+	}
+
+	return nil
+}
+
+// OTLP JSON payload (see opentelemetry-proto/opentelemetry/proto/logs/v1/logs.proto)
+type exportRequest struct {
+	ResourceLogs []resourceLogs `json:"resourceLogs"`
+}
+
+type resourceLogs struct {
+	Resource  resource    `json:"resource"`
+	ScopeLogs []scopeLogs `json:"scopeLogs"`
+}
+
+type resource struct {
+	Attributes []keyValue `json:"attributes,omitempty"`
+}
+
+type scopeLogs struct {
+	Scope      scope       `json:"scope"`
+	LogRecords []logRecord `json:"logRecords"`
+}
+
+type scope struct {
+	Name string `json:"name"`
+}
+
+type logRecord struct {
+	TimeUnixNano         string     `json:"timeUnixNano,omitempty"`
+	ObservedTimeUnixNano string     `json:"observedTimeUnixNano"`
+	SeverityNumber       int        `json:"severityNumber"`
+	SeverityText         string     `json:"severityText"`
+	Body                 anyValue   `json:"body"`
+	Attributes           []keyValue `json:"attributes,omitempty"`
+}
+
+type keyValue struct {
+	Key   string   `json:"key"`
+	Value anyValue `json:"value"`
+}
+
+type anyValue struct {
+	StringValue *string     `json:"stringValue,omitempty"`
+	BoolValue   *bool       `json:"boolValue,omitempty"`
+	IntValue    *string     `json:"intValue,omitempty"`
+	DoubleValue *float64    `json:"doubleValue,omitempty"`
+	BytesValue  *string     `json:"bytesValue,omitempty"`
+	KvlistValue *kvlist     `json:"kvlistValue,omitempty"`
+	ArrayValue  *arrayValue `json:"arrayValue,omitempty"`
+}
+
+type kvlist struct {
+	Values []keyValue `json:"values"`
+}
+
+type arrayValue struct {
+	Values []anyValue `json:"values"`
+}
+
+func (o *Output) encode(records []slog.Record) *exportRequest {
+	observed := strconv.FormatInt(time.Now().UnixNano(), 10)
+
+	logs := make([]logRecord, len(records))
+
+	for i, r := range records {
+		rec := logRecord{
+			ObservedTimeUnixNano: observed,
+			SeverityNumber:       Severity(r.Level),
+			SeverityText:         r.Level.String(),
+			Body:                 stringValue(r.Message),
+		}
+
+		if !r.Time.IsZero() {
+			rec.TimeUnixNano = strconv.FormatInt(r.Time.UnixNano(), 10)
+		}
+
+		r.Attrs(func(a slog.Attr) bool {
+			rec.Attributes = appendAttr(rec.Attributes, a)
+			return true
+		})
+
+		logs[i] = rec
+	}
+
+	attrs := make([]keyValue, 0, len(o.resource))
+
+	for _, a := range o.resource {
+		attrs = appendAttr(attrs, a)
+	}
+
+	return &exportRequest{
+		ResourceLogs: []resourceLogs{{
+			Resource:  resource{Attributes: attrs},
+			ScopeLogs: []scopeLogs{{Scope: scope{Name: o.scope}, LogRecords: logs}},
+		}},
+	}
+}
+
+// Severity maps the slog level to the OpenTelemetry severity number (DEBUG is 5, INFO is 9, WARN is 13, ERROR is 17).
+func Severity(level slog.Level) int {
+	return min(max(int(level)+9, 1), 24)
+}
+
+func appendAttr(kvs []keyValue, a slog.Attr) []keyValue {
+	if a.Equal(slog.Attr{}) {
+		return kvs
+	}
+
+	val := a.Value.Resolve()
+
+	// Inline groups with empty keys
+	if a.Key == "" && val.Kind() == slog.KindGroup {
+		for _, ga := range val.Group() {
+			kvs = appendAttr(kvs, ga)
+		}
+		return kvs
+	}
+
+	return append(kvs, keyValue{Key: a.Key, Value: convertValue(val)})
+}
+
+func convertValue(val slog.Value) anyValue {
+	switch val.Kind() {
+	case slog.KindString:
+		return stringValue(val.String())
+	case slog.KindBool:
+		b := val.Bool()
+		return anyValue{BoolValue: &b}
+	case slog.KindInt64:
+		return intValue(val.Int64())
+	case slog.KindUint64:
+		return intValue(int64(val.Uint64()))
+	case slog.KindFloat64:
+		f := val.Float64()
+		return anyValue{DoubleValue: &f}
+	case slog.KindDuration:
+		return intValue(int64(val.Duration()))
+	case slog.KindTime:
+		return stringValue(val.Time().Format(time.RFC3339Nano))
+	case slog.KindGroup:
+		list := &kvlist{Values: []keyValue{}}
+
+		for _, a := range val.Group() {
+			list.Values = appendAttr(list.Values, a)
+		}
+
+		return anyValue{KvlistValue: list}
+	}
+
+	switch v := val.Any().(type) {
+	case []byte:
+		s := base64.StdEncoding.EncodeToString(v)
+		return anyValue{BytesValue: &s}
+	case []string:
+		arr := &arrayValue{Values: make([]anyValue, len(v))}
+
+		for i, s := range v {
+			arr.Values[i] = stringValue(s)
+		}
+
+		return anyValue{ArrayValue: arr}
+	case error:
+		return stringValue(v.Error())
+	case fmt.Stringer:
+		return stringValue(v.String())
+	}
+
+	return stringValue(fmt.Sprint(val.Any()))
+}
+
+func stringValue(s string) anyValue {
+	return anyValue{StringValue: &s}
+}
+
+func intValue(n int64) anyValue {
+	s := strconv.FormatInt(n, 10)
+	return anyValue{IntValue: &s}
+}
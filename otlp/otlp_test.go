@@ -0,0 +1,99 @@
+package otlp
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	slogspy "github.com/palkan/slog-spy"
+)
+
+func TestOutput__Export(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		requests []*exportRequest
+		headers  []http.Header
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req exportRequest
+
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("failed to decode request: %v", err)
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		requests = append(requests, &req)
+		headers = append(headers, r.Header)
+	}))
+	defer server.Close()
+
+	out := NewOutput(server.URL+"/v1/logs", WithServiceName("api"), WithHeaders(map[string]string{"Authorization": "Bearer secret"}))
+
+	spy := slogspy.NewSpy(slog.NewTextHandler(io.Discard, nil))
+
+	go spy.RunRecords(context.Background(), out.Export) // nolint: errcheck
+
+	spy.Watch()
+
+	slog.New(spy).With("tenant", "acme").WithGroup("req").Debug("processing", "id", 42, "took", time.Millisecond, "ok", true)
+
+	if err := spy.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if len(requests) != 1 {
+		t.Fatalf("expected 1 export request, got %d", len(requests))
+	}
+
+	if headers[0].Get("Authorization") != "Bearer secret" || headers[0].Get("Content-Type") != "application/json" {
+		t.Errorf("unexpected headers: %v", headers[0])
+	}
+
+	logs := requests[0].ResourceLogs[0]
+
+	if attr := logs.Resource.Attributes[0]; attr.Key != "service.name" || *attr.Value.StringValue != "api" {
+		t.Errorf("expected service.name resource attribute, got %+v", attr)
+	}
+
+	rec := logs.ScopeLogs[0].LogRecords[0]
+
+	if rec.SeverityNumber != 5 || rec.SeverityText != "DEBUG" || *rec.Body.StringValue != "processing" {
+		t.Errorf("unexpected record: %+v", rec)
+	}
+
+	if rec.Attributes[0].Key != "tenant" || *rec.Attributes[0].Value.StringValue != "acme" {
+		t.Errorf("expected logger attributes to be exported, got %+v", rec.Attributes[0])
+	}
+
+	group := rec.Attributes[1]
+
+	if group.Key != "req" || group.Value.KvlistValue == nil || len(group.Value.KvlistValue.Values) != 3 {
+		t.Fatalf("expected group to be exported as kvlist, got %+v", group)
+	}
+
+	values := group.Value.KvlistValue.Values
+
+	if *values[0].Value.IntValue != "42" || *values[1].Value.IntValue != "1000000" || !*values[2].Value.BoolValue {
+		t.Errorf("unexpected group values: %+v", values)
+	}
+}
+
+func TestSeverity(t *testing.T) {
+	for level, expected := range map[slog.Level]int{slog.LevelDebug: 5, slog.LevelInfo: 9, slog.LevelWarn: 13, slog.LevelError: 17, slog.Level(-20): 1, slog.Level(100): 24} {
+		if actual := Severity(level); actual != expected {
+			t.Errorf("expected %v to be mapped to %d, got %d", level, expected, actual)
+		}
+	}
+}
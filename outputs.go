@@ -0,0 +1,257 @@
+package slogspy
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultOutputBuffer = 16
+	// outputDrainTimeout limits the time the Run loop waits for the added outputs to catch up on exit
+	outputDrainTimeout = time.Second
+)
+
+// OutputOption configures an output added via AddOutput.
+type OutputOption func(*asyncOutput)
+
+// WithOutputBuffer sets the number of batches queued for the output (16 by default);
+// batches are dropped when the queue is full.
+func WithOutputBuffer(size int) OutputOption {
+	return func(o *asyncOutput) {
+		o.ch = make(chan outputBatch, size)
+	}
+}
+
+// AddOutput registers an extra output receiving the same batches as the Run loop's one (e.g., a file sink along
+// with a WebSocket broadcaster). Each output has its own queue and goroutine, so a slow or failing output doesn't
+// block the others: when the queue is full, batches are dropped (and counted as sink failures); panics are recovered
+// and reported to the error handler. Outputs don't activate the spy. The returned function removes the output
+// after delivering the queued batches (it waits for at most a second, the rest of the queue is dropped);
+// it's safe to call it multiple times.
+func (h *SpyHandler) AddOutput(out SpyOutput, opts ...OutputOption) (remove func()) {
+	o := &asyncOutput{
+		out:          out,
+		ch:           make(chan outputBatch, defaultOutputBuffer),
+		done:         make(chan struct{}),
+		stop:         make(chan struct{}),
+		errorHandler: h.errorHandler,
+		stats:        h.stats,
+	}
+
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	go o.run()
+
+	h.outputs.add(o)
+
+	var once sync.Once
+
+	return func() {
+		once.Do(func() {
+			h.outputs.remove(o)
+			o.close(outputDrainTimeout)
+		})
+	}
+}
+
+type outputBatch struct {
+	msg []byte
+	// ack is closed once the batches queued before are delivered
+	ack chan struct{}
+}
+
+type asyncOutput struct {
+	out  SpyOutput
+	ch   chan outputBatch
+	done chan struct{}
+	// stop is closed when the output is removed (the queue channel is never closed, so senders don't need the lock)
+	stop chan struct{}
+	// abandoned is set when the output doesn't deliver the queue in time on removal; the rest of the queue is dropped
+	abandoned atomic.Bool
+
+	mu     sync.Mutex
+	closed bool
+
+	errorHandler func(err error)
+	stats        *spyStats
+}
+
+func (o *asyncOutput) run() {
+	defer close(o.done)
+
+	for {
+		select {
+		case batch := <-o.ch:
+			o.handle(batch)
+		case <-o.stop:
+			// Deliver the batches queued before the removal
+			for {
+				select {
+				case batch := <-o.ch:
+					o.handle(batch)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+func (o *asyncOutput) handle(batch outputBatch) {
+	switch {
+	case batch.ack != nil:
+		close(batch.ack)
+	case o.abandoned.Load():
+		o.stats.trackDrop(DropSinkFailure)
+	default:
+		o.write(batch.msg)
+	}
+}
+
+func (o *asyncOutput) write(msg []byte) {
+	defer func() {
+		if err := recover(); err != nil {
+			o.stats.trackDrop(DropSinkFailure)
+
+			if o.errorHandler != nil {
+				o.errorHandler(fmt.Errorf("output panicked: %v", err))
+			}
+		}
+	}()
+
+	o.out(msg)
+}
+
+// enqueue queues the batch without blocking (the batch is dropped if the queue is full)
+func (o *asyncOutput) enqueue(msg []byte) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.closed {
+		return
+	}
+
+	select {
+	case o.ch <- outputBatch{msg: msg}:
+	default:
+		o.stats.trackDrop(DropSinkFailure)
+	}
+}
+
+// sync returns a channel which is closed once the currently queued batches are delivered
+// (or the output is stopped); it waits for room in the queue at most for the timeout
+func (o *asyncOutput) sync(timeout time.Duration) <-chan struct{} {
+	ack := make(chan struct{})
+
+	o.mu.Lock()
+	closed := o.closed
+	o.mu.Unlock()
+
+	if closed {
+		close(ack)
+		return ack
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case o.ch <- outputBatch{ack: ack}:
+	case <-o.stop:
+		close(ack)
+	case <-timer.C:
+		close(ack)
+	}
+
+	return ack
+}
+
+// close stops accepting batches and waits for the queued ones to be delivered within the timeout
+func (o *asyncOutput) close(timeout time.Duration) {
+	o.mu.Lock()
+
+	if !o.closed {
+		o.closed = true
+		close(o.stop)
+	}
+
+	o.mu.Unlock()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case <-o.done:
+	case <-timer.C:
+		o.abandoned.Store(true)
+	}
+}
+
+// outputs is the copy-on-write list of the outputs added via AddOutput
+type outputs struct {
+	mu   sync.RWMutex
+	list []*asyncOutput
+}
+
+func (oo *outputs) add(o *asyncOutput) {
+	oo.mu.Lock()
+	defer oo.mu.Unlock()
+
+	oo.list = append(oo.list[:len(oo.list):len(oo.list)], o)
+}
+
+func (oo *outputs) remove(o *asyncOutput) {
+	oo.mu.Lock()
+	defer oo.mu.Unlock()
+
+	list := make([]*asyncOutput, 0, len(oo.list))
+
+	for _, item := range oo.list {
+		if item != o {
+			list = append(list, item)
+		}
+	}
+
+	oo.list = list
+}
+
+func (oo *outputs) snapshot() []*asyncOutput {
+	oo.mu.RLock()
+	defer oo.mu.RUnlock()
+
+	return oo.list
+}
+
+// deliver queues the batch to all the outputs; the batch is copied once and shared by the outputs
+func (oo *outputs) deliver(msg []byte) {
+	list := oo.snapshot()
+
+	if len(list) == 0 {
+		return
+	}
+
+	msg = append([]byte(nil), msg...)
+
+	for _, o := range list {
+		o.enqueue(msg)
+	}
+}
+
+// drain waits for the outputs to deliver the queued batches (within the timeout)
+func (oo *outputs) drain(timeout time.Duration) {
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+
+	for _, o := range oo.snapshot() {
+		select {
+		case <-o.sync(timeout):
+		case <-o.done:
+		case <-deadline.C:
+			return
+		}
+	}
+}
@@ -0,0 +1,182 @@
+package slogspy
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSpy__AddOutput(t *testing.T) {
+	var (
+		mu     sync.Mutex
+		main   bytes.Buffer
+		extra  bytes.Buffer
+		slowed int
+	)
+
+	release := make(chan struct{})
+
+	spy := NewSpy(slog.NewTextHandler(io.Discard, nil))
+
+	removeExtra := spy.AddOutput(func(msg []byte) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		extra.Write(msg)
+	})
+
+	removeSlow := spy.AddOutput(func(msg []byte) {
+		<-release
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		slowed++
+	}, WithOutputBuffer(1))
+
+	go spy.Run(context.Background(), func(msg []byte) { // nolint: errcheck
+		mu.Lock()
+		defer mu.Unlock()
+
+		main.Write(msg)
+	})
+
+	waitForRunning(t, spy)
+
+	spy.Watch()
+
+	logger := slog.New(spy)
+
+	for i := 0; i < 5; i++ {
+		logger.Info("fan-out", "i", i)
+
+		if err := spy.handler.requestFlush(context.Background(), nil); err != nil {
+			t.Fatal(err)
+		}
+
+		if i == 0 {
+			// Wait for the slow output to pick up the first batch
+			waitFor(t, func() bool { return len(spy.handler.outputs.snapshot()[1].ch) == 0 })
+		}
+	}
+
+	waitFor(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+
+		return bytes.Count(extra.Bytes(), []byte("fan-out")) == 5
+	})
+
+	// The slow output holds one batch and has one more in the queue, the rest are dropped
+	if dropped := spy.Stats().Drops[DropSinkFailure]; dropped != 3 {
+		t.Errorf("expected 3 batches to be dropped by the slow output, got %d", dropped)
+	}
+
+	close(release)
+	removeSlow()
+	removeSlow()
+
+	mu.Lock()
+	if slowed != 2 {
+		t.Errorf("expected the queued batches to be delivered on removal, got %d", slowed)
+	}
+	mu.Unlock()
+
+	removeExtra()
+
+	logger.Info("after removal")
+
+	if err := spy.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := bytes.Count(main.Bytes(), []byte("fan-out")); n != 5 {
+		t.Errorf("expected the main output to receive 5 records, got %d", n)
+	}
+
+	assertBufferContains(t, &main, "after removal")
+	assertBufferContainsNot(t, &extra, "after removal")
+}
+
+func TestSpy__AddOutput__Isolation(t *testing.T) {
+	var (
+		mu   sync.Mutex
+		buf  bytes.Buffer
+		errs []error
+	)
+
+	spy := NewSpy(slog.NewTextHandler(io.Discard, nil), WithErrorHandler(func(err error) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		errs = append(errs, err)
+	}))
+
+	spy.AddOutput(func(msg []byte) {
+		panic("sink is broken")
+	})
+
+	spy.AddOutput(func(msg []byte) {
+		// Slow outputs are waited for on shutdown
+		time.Sleep(20 * time.Millisecond)
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		buf.Write(msg)
+	})
+
+	go spy.Run(context.Background(), nil) // nolint: errcheck
+
+	spy.Watch()
+
+	slog.New(spy).Info("still delivered")
+
+	if err := spy.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	assertBufferContains(t, &buf, "still delivered")
+
+	if len(errs) != 1 || errs[0].Error() != "output panicked: sink is broken" {
+		t.Errorf("expected the panic to be reported, got: %v", errs)
+	}
+}
+
+func TestSpy__AddOutput__RemoveHung(t *testing.T) {
+	spy := NewSpy(slog.NewTextHandler(io.Discard, nil))
+
+	unblock := make(chan struct{})
+	defer close(unblock)
+
+	started := make(chan struct{}, 1)
+
+	remove := spy.AddOutput(func(msg []byte) {
+		started <- struct{}{}
+		<-unblock
+	})
+
+	spy.handler.outputs.deliver([]byte("stuck"))
+	<-started
+
+	spy.handler.outputs.deliver([]byte("queued"))
+
+	begin := time.Now()
+	remove()
+
+	if elapsed := time.Since(begin); elapsed > 2*outputDrainTimeout {
+		t.Errorf("expected removal to be bounded, took %s", elapsed)
+	}
+
+	// The queued batch is dropped once the hung write returns
+	unblock <- struct{}{}
+
+	waitFor(t, func() bool { return spy.Stats().Drops[DropSinkFailure] == 1 })
+}
@@ -0,0 +1,136 @@
+package slogspy
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+const defaultBlockTimeout = 50 * time.Millisecond
+
+// OverflowPolicy defines what to do with a new record when the backlog channel is full.
+type OverflowPolicy int
+
+const (
+	// OverflowDropNewest drops the new record (the default).
+	OverflowDropNewest OverflowPolicy = iota
+	// OverflowDropOldest evicts the oldest queued record to make room for the new one.
+	OverflowDropOldest
+	// OverflowBlock waits for the channel to have room up to the block timeout (or until the record's context is done)
+	// and drops the new record after that.
+	OverflowBlock
+)
+
+// WithOverflowPolicy sets the policy to apply when the backlog channel is full.
+func WithOverflowPolicy(policy OverflowPolicy) SpyHandlerOption {
+	return func(h *SpyHandler) {
+		h.overflowPolicy = policy
+	}
+}
+
+// WithBlockTimeout sets the max time to wait for the backlog channel to have room when using OverflowBlock.
+func WithBlockTimeout(timeout time.Duration) SpyHandlerOption {
+	return func(h *SpyHandler) {
+		h.blockTimeout = timeout
+	}
+}
+
+// WithOnDrop sets a function to be called for every record dropped due to backlog overflow.
+// The function is called synchronously from the logging goroutine (or from the Run loop for evicted records), so it must be fast.
+// Use WithOnDropReason to be notified about all the dropped records.
+func WithOnDrop(fn func(r slog.Record)) SpyHandlerOption {
+	return func(h *SpyHandler) {
+		h.onDrop = func(r slog.Record, reason DropReason) {
+			if reason == DropQueueFull || reason == DropEvicted {
+				fn(r)
+			}
+		}
+	}
+}
+
+// WithCanceledContexts makes the spy capture records logged with canceled contexts. By default, such records
+// are skipped (and reported as DropCanceled), so the spy never extends the latency of requests that are already timing out.
+func WithCanceledContexts() SpyHandlerOption {
+	return func(h *SpyHandler) {
+		h.captureCanceled = true
+	}
+}
+
+// WithMaxBacklogAge makes the Run loop evict (drop) queued records older than the specified age
+// when it falls behind: stale records are worthless for a live view but still cost memory.
+func WithMaxBacklogAge(age time.Duration) SpyHandlerOption {
+	return func(h *SpyHandler) {
+		h.maxBacklogAge = age
+	}
+}
+
+// evict drops the entry if it's been in the backlog for too long
+func (h *SpyHandler) evict(entry *Entry) bool {
+	if h.maxBacklogAge <= 0 {
+		return false
+	}
+
+	age := time.Since(entry.enqueuedAt)
+	h.stats.backlogAge.Store(int64(age))
+
+	if age <= h.maxBacklogAge {
+		return false
+	}
+
+	h.stats.evicted.Add(1)
+	h.drop(entry, DropEvicted)
+
+	return true
+}
+
+func (h *SpyHandler) handleOverflow(ctx context.Context, entry *Entry) {
+	ch := h.queue()
+
+	switch h.overflowPolicy {
+	case OverflowDropOldest:
+		select {
+		case oldest := <-ch:
+			h.drop(oldest, DropQueueFull)
+		default:
+		}
+
+		select {
+		case ch <- entry:
+		default:
+			h.drop(entry, DropQueueFull)
+		}
+	case OverflowBlock:
+		timer := time.NewTimer(h.blockTimeout)
+		defer timer.Stop()
+
+		select {
+		case ch <- entry:
+		case <-timer.C:
+			h.drop(entry, DropQueueFull)
+		case <-ctx.Done():
+			h.drop(entry, DropCanceled)
+		}
+	default:
+		h.drop(entry, DropQueueFull)
+	}
+}
+
+func (h *SpyHandler) drop(entry *Entry, reason DropReason) {
+	if entry.probe {
+		return
+	}
+
+	// Records logged with canceled contexts are skipped rather than dropped due to the overflow
+	// (the same way as the ones canceled before enqueuing, see dropRecord)
+	if reason != DropCanceled {
+		h.trackDropped()
+	}
+
+	h.stats.trackDrop(reason)
+
+	if h.onDrop != nil {
+		h.onDrop(entry.record, reason)
+	}
+
+	releaseEntry(entry)
+}
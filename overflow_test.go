@@ -0,0 +1,242 @@
+package slogspy
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSpy__OverflowPolicy(t *testing.T) {
+	cases := []struct {
+		policy  OverflowPolicy
+		dropped []string
+		queued  []string
+	}{
+		{OverflowDropNewest, []string{"b", "c"}, []string{"a"}},
+		{OverflowDropOldest, []string{"a", "b"}, []string{"c"}},
+		{OverflowBlock, []string{"b", "c"}, []string{"a"}},
+	}
+
+	for _, c := range cases {
+		var (
+			mu      sync.Mutex
+			dropped []string
+		)
+
+		spy := NewSpy(
+			slog.NewTextHandler(&bytes.Buffer{}, nil),
+			WithBacklogSize(1),
+			WithOverflowPolicy(c.policy),
+			WithBlockTimeout(time.Millisecond),
+			WithOnDrop(func(r slog.Record) {
+				mu.Lock()
+				defer mu.Unlock()
+				dropped = append(dropped, r.Message)
+			}),
+		)
+
+		spy.Watch()
+
+		logger := slog.New(spy)
+		logger.Debug("a")
+		logger.Debug("b")
+		logger.Debug("c")
+
+		if !reflect.DeepEqual(dropped, c.dropped) {
+			t.Errorf("policy %d: expected dropped %v, got %v", c.policy, c.dropped, dropped)
+		}
+
+		var queued []string
+
+		for len(spy.handler.queue()) > 0 {
+			queued = append(queued, (<-spy.handler.queue()).record.Message)
+		}
+
+		if !reflect.DeepEqual(queued, c.queued) {
+			t.Errorf("policy %d: expected queued %v, got %v", c.policy, c.queued, queued)
+		}
+	}
+}
+
+func TestSpy__OverflowDropOldest_keepsCommands(t *testing.T) {
+	var dropped []string
+
+	spy := NewSpy(
+		slog.NewTextHandler(&bytes.Buffer{}, nil),
+		WithBacklogSize(1),
+		WithOverflowPolicy(OverflowDropOldest),
+		WithOnDrop(func(r slog.Record) {
+			dropped = append(dropped, r.Message)
+		}),
+	)
+
+	spy.Watch()
+	spy.handler.ctrl <- &Entry{cmd: SpyCommandFlush}
+
+	logger := slog.New(spy)
+	logger.Debug("a")
+	logger.Debug("b")
+
+	// Commands are sent via the control channel, so only records are evicted
+	if !reflect.DeepEqual(dropped, []string{"a"}) {
+		t.Errorf("expected the oldest record to be dropped, got %v", dropped)
+	}
+
+	if entry := <-spy.handler.ctrl; entry.cmd != SpyCommandFlush {
+		t.Errorf("expected flush command to be kept, got %v", entry.cmd)
+	}
+}
+
+func TestSpy__FlushInterval_backpressure(t *testing.T) {
+	var mu sync.Mutex
+	buf := &bytes.Buffer{}
+
+	spy := NewSpy(slog.NewTextHandler(&bytes.Buffer{}, nil), WithBacklogSize(1), WithFlushInterval(10*time.Millisecond))
+
+	spy.Watch()
+
+	logger := slog.New(spy)
+
+	// The backlog is full before the loop starts
+	logger.Debug("first")
+
+	go spy.Run(context.Background(), func(msg []byte) { // nolint: errcheck
+		mu.Lock()
+		defer mu.Unlock()
+
+		buf.Write(msg)
+	})
+	defer spy.Shutdown(context.Background()) // nolint: errcheck
+
+	// Keep the backlog busy; the timer must still flush the records
+	stop := make(chan struct{})
+	defer close(stop)
+
+	go func() {
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				logger.Debug("flood")
+			}
+		}
+	}()
+
+	waitFor(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+
+		return bytes.Contains(buf.Bytes(), []byte("first"))
+	})
+}
+
+func TestSpy__MaxBacklogAge(t *testing.T) {
+	var dropped []string
+
+	buf := &bytes.Buffer{}
+
+	spy := NewSpy(
+		slog.NewTextHandler(&bytes.Buffer{}, nil),
+		WithMaxBacklogAge(20*time.Millisecond),
+		WithOnDrop(func(r slog.Record) { dropped = append(dropped, r.Message) }),
+	)
+
+	spy.Watch()
+
+	logger := slog.New(spy)
+	logger.Debug("stale")
+
+	time.Sleep(40 * time.Millisecond)
+
+	logger.Debug("fresh")
+
+	go spy.Run(context.Background(), func(msg []byte) { buf.Write(msg) }) // nolint: errcheck
+
+	if err := spy.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	assertBufferContains(t, buf, "fresh")
+	assertBufferContainsNot(t, buf, "stale")
+
+	if len(dropped) != 1 || dropped[0] != "stale" {
+		t.Errorf("expected stale record to be dropped, got %v", dropped)
+	}
+
+	stats := spy.Stats()
+
+	if stats.Evicted != 1 || stats.Dropped != 1 {
+		t.Errorf("unexpected stats: %+v", stats)
+	}
+
+	if stats.BacklogAge <= 0 || stats.BacklogAge > 20*time.Millisecond {
+		t.Errorf("expected backlog age of the fresh record, got %v", stats.BacklogAge)
+	}
+}
+
+func TestSpy__CanceledContext(t *testing.T) {
+	var dropped []DropReason
+
+	spy := NewSpy(
+		slog.NewTextHandler(io.Discard, nil),
+		WithBacklogSize(1),
+		WithOverflowPolicy(OverflowBlock),
+		WithBlockTimeout(time.Hour),
+		WithOnDropReason(func(r slog.Record, reason DropReason) { dropped = append(dropped, reason) }),
+	)
+
+	spy.Watch()
+
+	logger := slog.New(spy)
+
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	logger.InfoContext(canceled, "skipped")
+
+	if depth := spy.Stats().QueueDepth; depth != 0 {
+		t.Fatalf("expected the record to be skipped, got queue depth %d", depth)
+	}
+
+	// Fill the backlog, so the next record blocks until its context is done
+	logger.Info("queued")
+
+	ctx, cancelBlocked := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancelBlocked()
+
+	start := time.Now()
+	logger.InfoContext(ctx, "blocked")
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected blocking to be bounded by the context, took %s", elapsed)
+	}
+
+	if len(dropped) != 2 || dropped[0] != DropCanceled || dropped[1] != DropCanceled {
+		t.Errorf("expected 2 canceled drops, got %v", dropped)
+	}
+
+	// Canceled records are accounted the same way whether they were blocked or not
+	if stats := spy.Stats(); stats.Dropped != 0 || stats.Drops[DropCanceled] != 2 {
+		t.Errorf("expected canceled records to only be counted by reason, got %d dropped, %v", stats.Dropped, stats.Drops)
+	}
+}
+
+func TestSpy__WithCanceledContexts(t *testing.T) {
+	spy := NewSpy(slog.NewTextHandler(io.Discard, nil), WithCanceledContexts())
+	spy.Watch()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	slog.New(spy).ErrorContext(ctx, "request timed out")
+
+	if depth := spy.Stats().QueueDepth; depth != 1 {
+		t.Errorf("expected the record to be captured, got queue depth %d", depth)
+	}
+}
@@ -0,0 +1,73 @@
+package slogspy
+
+import (
+	"log/slog"
+	"sync/atomic"
+)
+
+// parentVersion is the root parent handler set via NewSpy or SetParent
+type parentVersion struct {
+	handler slog.Handler
+}
+
+// derivedParent is the parent handler with the spy's attributes and groups applied to the root version
+type derivedParent struct {
+	root    *parentVersion
+	handler slog.Handler
+}
+
+// SetParent atomically replaces the parent handler of the spy and all the spies derived via WithAttrs and WithGroup
+// (the attributes and groups are re-applied to the new handler). Active subscriptions and watchers are kept intact,
+// so it's safe to call when the main handler is rebuilt at runtime (e.g., on config reload).
+func (s *Spy) SetParent(h slog.Handler) {
+	s.root.Store(&parentVersion{handler: h})
+}
+
+// Parent returns the current parent handler (with the spy's attributes and groups applied).
+// It's safe to call concurrently with SetParent.
+func (s *Spy) Parent() slog.Handler {
+	return s.parentHandler()
+}
+
+// parentHandler returns the parent handler for the current root version
+func (s *Spy) parentHandler() slog.Handler {
+	return s.currentParent().handler
+}
+
+func (s *Spy) currentParent() *derivedParent {
+	root := s.root.Load()
+
+	if p := s.parent.Load(); p != nil && p.root == root {
+		return p
+	}
+
+	p := &derivedParent{root: root, handler: s.derive(root.handler)}
+	s.parent.Store(p)
+
+	return p
+}
+
+// derive applies the spy's attributes and groups to the root handler in the original order
+func (s *Spy) derive(h slog.Handler) slog.Handler {
+	groups, frames := s.handler.groups, s.handler.frames
+
+	for depth := 0; depth <= len(groups); depth++ {
+		for len(frames) > 0 && frames[0].depth == depth {
+			h = h.WithAttrs(frames[0].attrs)
+			frames = frames[1:]
+		}
+
+		if depth < len(groups) {
+			h = h.WithGroup(groups[depth])
+		}
+	}
+
+	return h
+}
+
+func newRootParent(h slog.Handler) *atomic.Pointer[parentVersion] {
+	root := &atomic.Pointer[parentVersion]{}
+	root.Store(&parentVersion{handler: h})
+
+	return root
+}
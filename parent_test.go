@@ -0,0 +1,89 @@
+package slogspy
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSpy__SetParent(t *testing.T) {
+	first := &bytes.Buffer{}
+	second := &bytes.Buffer{}
+	spied := &bytes.Buffer{}
+
+	spy := NewSpy(slog.NewTextHandler(first, nil))
+
+	go spy.Run(context.Background(), func(msg []byte) { // nolint: errcheck
+		spied.Write(msg)
+	})
+
+	sub := spy.Subscribe(nil)
+
+	logger := slog.New(spy).With("service", "api").WithGroup("req")
+
+	logger.Info("before", "id", 1)
+
+	spy.SetParent(slog.NewTextHandler(second, nil))
+
+	logger.Info("after", "id", 2)
+
+	if sub.Closed() {
+		t.Errorf("expected subscription to survive the parent swap")
+	}
+
+	if err := spy.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	assertBufferContains(t, first, "msg=before service=api req.id=1")
+	assertBufferContainsNot(t, first, "after")
+
+	// Attributes and groups are re-applied to the new parent
+	assertBufferContains(t, second, "msg=after service=api req.id=2")
+
+	// The spy keeps working across swaps
+	assertBufferContains(t, spied, `"msg":"before"`)
+	assertBufferContains(t, spied, `"msg":"after"`)
+}
+
+func TestSpy__SetParentConcurrently(t *testing.T) {
+	spy := NewSpy(slog.NewTextHandler(io.Discard, nil))
+	logger := slog.New(spy).With("service", "api")
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for j := 0; j < 100; j++ {
+				logger.Info("concurrent", "n", j)
+			}
+		}()
+	}
+
+	latest := &bytes.Buffer{}
+
+	for i := 0; i < 10; i++ {
+		spy.SetParent(slog.NewJSONHandler(io.Discard, nil))
+	}
+
+	spy.SetParent(slog.NewTextHandler(latest, nil))
+
+	wg.Wait()
+
+	// The derived spy applies its attributes to the latest parent
+	derived := logger.Handler().(*Spy)
+
+	if err := derived.Parent().Handle(context.Background(), slog.NewRecord(time.Now(), slog.LevelInfo, "direct", 0)); err != nil {
+		t.Fatal(err)
+	}
+
+	assertBufferContains(t, latest, "msg=direct service=api")
+}
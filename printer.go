@@ -0,0 +1,64 @@
+package slogspy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"time"
+)
+
+// PrinterFallbackMessage is the message of the diagnostic record emitted when the custom printer
+// couldn't be built and the default JSON printer is used instead (see WithPrinter).
+const PrinterFallbackMessage = "slogspy: printer fallback"
+
+var errNilPrinter = errors.New("printer builder returned nil")
+
+func defaultPrinter(w io.Writer) slog.Handler {
+	return slog.NewJSONHandler(w, &slog.HandlerOptions{Level: slog.LevelDebug})
+}
+
+// buildPrinter calls the printer builder; panics and nil handlers are reported as errors
+func buildPrinter(builder func(w io.Writer) slog.Handler, w io.Writer) (printer slog.Handler, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			printer, err = nil, fmt.Errorf("printer builder panicked: %v", r)
+		}
+	}()
+
+	printer = builder(w)
+
+	if printer == nil {
+		return nil, errNilPrinter
+	}
+
+	return printer, nil
+}
+
+// trackPrinterFallback reports the printer construction failure
+func (h *SpyHandler) trackPrinterFallback(err error) {
+	h.stats.printerFallbacks.Add(1)
+
+	if h.errorHandler != nil {
+		h.errorHandler(err)
+	}
+}
+
+// printDiagnostic adds the record describing the printer fallback to the batch; it's only called by the Run loop
+func (h *SpyHandler) printDiagnostic() {
+	err := h.printerErr
+	h.printerErr = nil
+
+	r := slog.NewRecord(time.Now(), slog.LevelError, PrinterFallbackMessage, 0)
+	r.AddAttrs(slog.String("error", err.Error()))
+
+	start := h.buf.Len()
+
+	if err := h.printer.Handle(context.Background(), r); err != nil && h.errorHandler != nil {
+		h.errorHandler(err)
+	}
+
+	h.frameRecord(start, r.Level)
+	h.batchRecords++
+}
@@ -0,0 +1,91 @@
+package slogspy
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestSpy__PrinterFallback(t *testing.T) {
+	for name, builder := range map[string]func(w io.Writer) slog.Handler{
+		"panic": func(w io.Writer) slog.Handler { panic("boom") },
+		"nil":   func(w io.Writer) slog.Handler { return nil },
+	} {
+		t.Run(name, func(t *testing.T) {
+			var (
+				buf    bytes.Buffer
+				errors []error
+			)
+
+			spy := NewSpy(
+				slog.NewTextHandler(io.Discard, nil),
+				WithPrinter(builder),
+				WithErrorHandler(func(err error) { errors = append(errors, err) }),
+			)
+
+			if len(errors) != 1 {
+				t.Fatalf("expected the construction error to be reported, got: %v", errors)
+			}
+
+			if stats := spy.Stats(); stats.PrinterFallbacks != 1 {
+				t.Errorf("expected 1 printer fallback, got %d", stats.PrinterFallbacks)
+			}
+
+			go spy.Run(context.Background(), func(msg []byte) { // nolint: errcheck
+				buf.Write(msg)
+			})
+
+			spy.Watch()
+
+			logger := slog.New(spy)
+			logger.Info("first")
+			logger.Info("second")
+
+			if err := spy.Shutdown(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+
+			lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+
+			if len(lines) != 3 {
+				t.Fatalf("expected the diagnostic record and 2 records, got: %s", buf.String())
+			}
+
+			if !strings.Contains(lines[0], `"level":"ERROR","msg":"`+PrinterFallbackMessage+`"`) {
+				t.Errorf("expected the diagnostic record first, got: %s", lines[0])
+			}
+
+			if !strings.Contains(lines[1], `"msg":"first"`) {
+				t.Errorf("expected the records to be formatted by the default printer, got: %s", lines[1])
+			}
+		})
+	}
+}
+
+func TestSubscription__PrinterFallback(t *testing.T) {
+	var buf bytes.Buffer
+
+	spy := NewSpy(slog.NewTextHandler(io.Discard, nil))
+
+	go spy.Run(context.Background(), nil) // nolint: errcheck
+
+	sub := spy.handler.Subscribe(func(msg []byte) {
+		buf.Write(msg)
+	}, WithSubscriptionPrinter(func(w io.Writer) slog.Handler { panic("boom") }))
+	defer sub.Close()
+
+	slog.New(spy).Info("shared printer")
+
+	if err := spy.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	assertBufferContains(t, &buf, `"msg":"shared printer"`)
+
+	if stats := spy.Stats(); stats.PrinterFallbacks != 1 {
+		t.Errorf("expected 1 printer fallback, got %d", stats.PrinterFallbacks)
+	}
+}
@@ -0,0 +1,543 @@
+package slogspy
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"slices"
+	"sync"
+	"time"
+)
+
+// RecordEncoding defines how the spy's printer encodes records.
+type RecordEncoding int
+
+const (
+	// EncodingJSON formats records via the printer (the JSON one by default).
+	EncodingJSON RecordEncoding = iota
+	// EncodingProtobuf encodes batches as Batch messages (see batch.proto); use DecodeProtobufBatch to decode them.
+	EncodingProtobuf
+)
+
+const (
+	protoVarint = 0
+	protoI64    = 1
+	protoBytes  = 2
+
+	// Value fields
+	protoString   = 1
+	protoInt      = 2
+	protoUint     = 3
+	protoFloat    = 4
+	protoBool     = 5
+	protoDuration = 6
+	protoTime     = 7
+	protoGroup    = 8
+	protoJSON     = 9
+)
+
+// WithEncoding sets the encoding of the flushed records. EncodingProtobuf replaces the printer (see WithPrinter)
+// with the protobuf one, and the framing is not applied (see WithFraming).
+func WithEncoding(e RecordEncoding) SpyHandlerOption {
+	return func(h *SpyHandler) {
+		h.encoding = e
+	}
+}
+
+// applyEncoding configures the printer and the framing according to the encoding
+func (h *SpyHandler) applyEncoding() {
+	if h.encoding != EncodingProtobuf {
+		return
+	}
+
+	h.printerBuilder = NewProtobufPrinter
+	h.framing = FramingRaw
+	h.batchMetadata = false
+}
+
+// NewProtobufPrinter creates a printer writing every record as the Batch message with a single record (see batch.proto),
+// so the written records form a valid batch. It could be used for subscriptions, too (see WithSubscriptionPrinter).
+func NewProtobufPrinter(w io.Writer) slog.Handler {
+	return &protobufPrinter{w: w, mu: &sync.Mutex{}}
+}
+
+type protobufPrinter struct {
+	w  io.Writer
+	mu *sync.Mutex
+	// goas are the attributes and groups added via WithAttrs and WithGroup (in order)
+	goas []groupOrAttrs
+}
+
+type groupOrAttrs struct {
+	group string
+	attrs []slog.Attr
+}
+
+func (p *protobufPrinter) Enabled(context.Context, slog.Level) bool {
+	return true
+}
+
+func (p *protobufPrinter) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return p
+	}
+
+	return p.with(groupOrAttrs{attrs: attrs})
+}
+
+func (p *protobufPrinter) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return p
+	}
+
+	return p.with(groupOrAttrs{group: name})
+}
+
+func (p *protobufPrinter) with(goa groupOrAttrs) *protobufPrinter {
+	return &protobufPrinter{w: p.w, mu: p.mu, goas: append(slices.Clip(p.goas), goa)}
+}
+
+func (p *protobufPrinter) Handle(_ context.Context, r slog.Record) error {
+	attrs := make([]slog.Attr, 0, r.NumAttrs())
+
+	r.Attrs(func(a slog.Attr) bool {
+		attrs = append(attrs, a)
+		return true
+	})
+
+	// Nest the record attributes into the open groups (from the innermost one)
+	for i := len(p.goas) - 1; i >= 0; i-- {
+		goa := p.goas[i]
+
+		if goa.group == "" {
+			attrs = append(slices.Clip(goa.attrs), attrs...)
+			continue
+		}
+
+		if len(attrs) > 0 {
+			attrs = []slog.Attr{{Key: goa.group, Value: slog.GroupValue(attrs...)}}
+		}
+	}
+
+	var rec []byte
+
+	if !r.Time.IsZero() {
+		rec = appendProtoTag(rec, 1, protoVarint)
+		rec = binary.AppendUvarint(rec, uint64(r.Time.UnixNano()))
+	}
+
+	if r.Level != 0 {
+		rec = appendProtoTag(rec, 2, protoVarint)
+		rec = binary.AppendVarint(rec, int64(r.Level))
+	}
+
+	if r.Message != "" {
+		rec = appendProtoTag(rec, 3, protoBytes)
+		rec = appendProtoBytes(rec, []byte(r.Message))
+	}
+
+	rec = appendProtoAttrs(rec, 4, attrs)
+
+	buf := make([]byte, 0, len(rec)+binary.MaxVarintLen64+1)
+	buf = appendProtoTag(buf, 1, protoBytes)
+	buf = appendProtoBytes(buf, rec)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	_, err := p.w.Write(buf)
+
+	return err
+}
+
+// appendProtoAttrs appends the attributes as the repeated field; empty attributes and groups are skipped,
+// and groups with empty keys are inlined (as slog handlers do)
+func appendProtoAttrs(buf []byte, num int, attrs []slog.Attr) []byte {
+	for _, a := range attrs {
+		a.Value = a.Value.Resolve()
+
+		if a.Equal(slog.Attr{}) {
+			continue
+		}
+
+		if a.Value.Kind() == slog.KindGroup {
+			if len(a.Value.Group()) == 0 {
+				continue
+			}
+
+			if a.Key == "" {
+				buf = appendProtoAttrs(buf, num, a.Value.Group())
+				continue
+			}
+		}
+
+		var attr []byte
+
+		if a.Key != "" {
+			attr = appendProtoTag(attr, 1, protoBytes)
+			attr = appendProtoBytes(attr, []byte(a.Key))
+		}
+
+		attr = appendProtoTag(attr, 2, protoBytes)
+		attr = appendProtoBytes(attr, appendProtoValue(nil, a.Value))
+
+		buf = appendProtoTag(buf, num, protoBytes)
+		buf = appendProtoBytes(buf, attr)
+	}
+
+	return buf
+}
+
+func appendProtoValue(buf []byte, v slog.Value) []byte {
+	switch v.Kind() {
+	case slog.KindString:
+		buf = appendProtoTag(buf, protoString, protoBytes)
+		buf = appendProtoBytes(buf, []byte(v.String()))
+	case slog.KindInt64:
+		buf = appendProtoTag(buf, protoInt, protoVarint)
+		buf = binary.AppendVarint(buf, v.Int64())
+	case slog.KindUint64:
+		buf = appendProtoTag(buf, protoUint, protoVarint)
+		buf = binary.AppendUvarint(buf, v.Uint64())
+	case slog.KindFloat64:
+		buf = appendProtoTag(buf, protoFloat, protoI64)
+		buf = binary.LittleEndian.AppendUint64(buf, math.Float64bits(v.Float64()))
+	case slog.KindBool:
+		buf = appendProtoTag(buf, protoBool, protoVarint)
+		buf = binary.AppendUvarint(buf, boolToUint(v.Bool()))
+	case slog.KindDuration:
+		buf = appendProtoTag(buf, protoDuration, protoVarint)
+		buf = binary.AppendUvarint(buf, uint64(v.Duration()))
+	case slog.KindTime:
+		buf = appendProtoTag(buf, protoTime, protoVarint)
+		buf = binary.AppendUvarint(buf, uint64(v.Time().UnixNano()))
+	case slog.KindGroup:
+		buf = appendProtoTag(buf, protoGroup, protoBytes)
+		buf = appendProtoBytes(buf, appendProtoAttrs(nil, 1, v.Group()))
+	default:
+		buf = appendProtoAny(buf, v.Any())
+	}
+
+	return buf
+}
+
+// appendProtoAny encodes errors as strings and other values as JSON (falling back to fmt.Sprint)
+func appendProtoAny(buf []byte, v any) []byte {
+	if err, ok := v.(error); ok {
+		buf = appendProtoTag(buf, protoString, protoBytes)
+		return appendProtoBytes(buf, []byte(err.Error()))
+	}
+
+	data, err := json.Marshal(v)
+
+	if err != nil {
+		buf = appendProtoTag(buf, protoString, protoBytes)
+		return appendProtoBytes(buf, []byte(fmt.Sprint(v)))
+	}
+
+	buf = appendProtoTag(buf, protoJSON, protoBytes)
+
+	return appendProtoBytes(buf, data)
+}
+
+// IsProtobuf returns true if the batch is encoded with EncodingProtobuf. It checks the structure of the whole batch
+// (a sequence of length-delimited records with the known fields), so text batches starting with a newline
+// (the same byte as the records field tag) are not misdetected.
+func IsProtobuf(msg []byte) bool {
+	if len(msg) == 0 {
+		return false
+	}
+
+	for len(msg) > 0 {
+		record, rest, ok := nextProtoField(msg, 1<<3|protoBytes)
+
+		if !ok || !isProtoRecord(record) {
+			return false
+		}
+
+		msg = rest
+	}
+
+	return true
+}
+
+// isProtoRecord checks that the message only contains the Record fields with the expected wire types
+func isProtoRecord(msg []byte) bool {
+	for len(msg) > 0 {
+		tag, n := binary.Uvarint(msg)
+
+		if n <= 0 {
+			return false
+		}
+
+		switch tag {
+		case 1<<3 | protoVarint, 2<<3 | protoVarint:
+			if _, m := binary.Uvarint(msg[n:]); m > 0 {
+				msg = msg[n+m:]
+				continue
+			}
+
+			return false
+		case 3<<3 | protoBytes, 4<<3 | protoBytes:
+			_, rest, ok := nextProtoField(msg, tag)
+
+			if !ok {
+				return false
+			}
+
+			msg = rest
+		default:
+			return false
+		}
+	}
+
+	return true
+}
+
+// nextProtoField reads the length-delimited field with the specified tag from the start of the message
+func nextProtoField(msg []byte, tag uint64) ([]byte, []byte, bool) {
+	actual, n := binary.Uvarint(msg)
+
+	if n <= 0 || actual != tag {
+		return nil, nil, false
+	}
+
+	size, m := binary.Uvarint(msg[n:])
+
+	if m <= 0 || size > uint64(len(msg)-n-m) {
+		return nil, nil, false
+	}
+
+	start := n + m
+
+	return msg[start : start+int(size)], msg[start+int(size):], true
+}
+
+// DecodeProtobufBatch decodes the batch encoded with EncodingProtobuf into records, so they could be
+// passed to any slog.Handler (e.g., to print them in a human-readable form). Times are in the local time zone,
+// and JSON values are decoded as json.RawMessage.
+func DecodeProtobufBatch(data []byte) ([]slog.Record, error) {
+	var records []slog.Record
+
+	err := decodeProtoFields(data, func(num int, _ uint64, value []byte) error {
+		if num != 1 {
+			return nil
+		}
+
+		r, err := decodeProtoRecord(value)
+
+		if err != nil {
+			return err
+		}
+
+		records = append(records, r)
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("malformed protobuf batch: %w", err)
+	}
+
+	return records, nil
+}
+
+func decodeProtoRecord(data []byte) (slog.Record, error) {
+	var (
+		r     slog.Record
+		attrs []slog.Attr
+	)
+
+	err := decodeProtoFields(data, func(num int, value uint64, field []byte) error {
+		switch num {
+		case 1:
+			r.Time = time.Unix(0, int64(value))
+		case 2:
+			r.Level = slog.Level(decodeZigZag(value))
+		case 3:
+			r.Message = string(field)
+		case 4:
+			a, err := decodeProtoAttr(field)
+
+			if err != nil {
+				return err
+			}
+
+			attrs = append(attrs, a)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return r, err
+	}
+
+	record := slog.NewRecord(r.Time, r.Level, r.Message, 0)
+	record.AddAttrs(attrs...)
+
+	return record, nil
+}
+
+func decodeProtoAttr(data []byte) (slog.Attr, error) {
+	var a slog.Attr
+
+	err := decodeProtoFields(data, func(num int, _ uint64, field []byte) error {
+		switch num {
+		case 1:
+			a.Key = string(field)
+		case 2:
+			v, err := decodeProtoValue(field)
+
+			if err != nil {
+				return err
+			}
+
+			a.Value = v
+		}
+
+		return nil
+	})
+
+	return a, err
+}
+
+func decodeProtoValue(data []byte) (slog.Value, error) {
+	var v slog.Value
+
+	err := decodeProtoFields(data, func(num int, value uint64, field []byte) error {
+		switch num {
+		case protoString:
+			v = slog.StringValue(string(field))
+		case protoInt:
+			v = slog.Int64Value(decodeZigZag(value))
+		case protoUint:
+			v = slog.Uint64Value(value)
+		case protoFloat:
+			v = slog.Float64Value(math.Float64frombits(value))
+		case protoBool:
+			v = slog.BoolValue(value != 0)
+		case protoDuration:
+			v = slog.DurationValue(time.Duration(int64(value)))
+		case protoTime:
+			v = slog.TimeValue(time.Unix(0, int64(value)))
+		case protoGroup:
+			var attrs []slog.Attr
+
+			err := decodeProtoFields(field, func(num int, _ uint64, field []byte) error {
+				if num != 1 {
+					return nil
+				}
+
+				a, err := decodeProtoAttr(field)
+
+				if err != nil {
+					return err
+				}
+
+				attrs = append(attrs, a)
+
+				return nil
+			})
+
+			if err != nil {
+				return err
+			}
+
+			v = slog.GroupValue(attrs...)
+		case protoJSON:
+			v = slog.AnyValue(json.RawMessage(field))
+		}
+
+		return nil
+	})
+
+	return v, err
+}
+
+func appendProtoTag(buf []byte, num int, wireType int) []byte {
+	return binary.AppendUvarint(buf, uint64(num)<<3|uint64(wireType))
+}
+
+func appendProtoBytes(buf []byte, data []byte) []byte {
+	buf = binary.AppendUvarint(buf, uint64(len(data)))
+
+	return append(buf, data...)
+}
+
+func boolToUint(b bool) uint64 {
+	if b {
+		return 1
+	}
+
+	return 0
+}
+
+// decodeZigZag decodes the sint64 value
+func decodeZigZag(v uint64) int64 {
+	return int64(v>>1) ^ -int64(v&1)
+}
+
+// decodeProtoFields calls the function for every varint, fixed64 (as value) and length-delimited field
+// (fixed32 fields are skipped)
+func decodeProtoFields(msg []byte, fn func(num int, value uint64, data []byte) error) error {
+	for len(msg) > 0 {
+		tag, n := binary.Uvarint(msg)
+
+		if n <= 0 {
+			return errors.New("malformed field tag")
+		}
+
+		msg = msg[n:]
+		num := int(tag >> 3)
+
+		var err error
+
+		switch tag & 7 {
+		case protoVarint:
+			value, n := binary.Uvarint(msg)
+
+			if n <= 0 {
+				return errors.New("malformed varint")
+			}
+
+			msg = msg[n:]
+			err = fn(num, value, nil)
+		case protoI64:
+			if len(msg) < 8 {
+				return errors.New("malformed fixed64 field")
+			}
+
+			err = fn(num, binary.LittleEndian.Uint64(msg), nil)
+			msg = msg[8:]
+		case protoBytes:
+			size, n := binary.Uvarint(msg)
+
+			if n <= 0 || size > uint64(len(msg)-n) {
+				return errors.New("malformed length-delimited field")
+			}
+
+			err = fn(num, 0, msg[n:n+int(size)])
+			msg = msg[n+int(size):]
+		case 5:
+			if len(msg) < 4 {
+				return errors.New("malformed fixed32 field")
+			}
+
+			msg = msg[4:]
+		default:
+			return fmt.Errorf("unsupported wire type %d", tag&7)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
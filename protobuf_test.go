@@ -0,0 +1,155 @@
+package slogspy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+func TestSpy__EncodingProtobuf(t *testing.T) {
+	spy := NewSpy(slog.NewTextHandler(&bytes.Buffer{}, nil), WithEncoding(EncodingProtobuf), WithFraming(NDJSONEnvelope))
+
+	var batches bytes.Buffer
+
+	go spy.Run(context.Background(), func(msg []byte) { // nolint: errcheck
+		batches.Write(msg)
+	})
+
+	spy.Watch()
+
+	logger := slog.New(spy).With("service", "api").WithGroup("req")
+
+	logger.Warn("slow request", "id", 42, "elapsed", 150*time.Millisecond)
+	logger.Debug("done")
+
+	if err := spy.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	records, err := DecodeProtobufBatch(batches.Bytes())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(records) != 2 {
+		t.Fatalf("expected 2 records, got %d", len(records))
+	}
+
+	var out bytes.Buffer
+
+	printer := slog.NewTextHandler(&out, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey && len(groups) == 0 {
+				return slog.Attr{}
+			}
+			return a
+		},
+	})
+
+	for _, r := range records {
+		printer.Handle(context.Background(), r) // nolint: errcheck
+	}
+
+	expected := "level=WARN msg=\"slow request\" service=api req.id=42 req.elapsed=150ms\nlevel=DEBUG msg=done service=api\n"
+
+	if out.String() != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, out.String())
+	}
+}
+
+func TestProtobufPrinter__Values(t *testing.T) {
+	var buf bytes.Buffer
+
+	now := time.Now()
+	trace := slog.LevelDebug - 4
+
+	r := slog.NewRecord(now, trace, "values", 0)
+	r.AddAttrs(
+		slog.String("str", "s"),
+		slog.Int("int", -7),
+		slog.Uint64("uint", 7),
+		slog.Float64("float", 1.5),
+		slog.Bool("bool", true),
+		slog.Duration("dur", time.Second),
+		slog.Time("at", now),
+		slog.Any("err", errors.New("boom")),
+		slog.Any("tags", []string{"a", "b"}),
+		slog.Group("empty"),
+		slog.Group("", slog.String("inlined", "yes")),
+	)
+
+	NewProtobufPrinter(&buf).Handle(context.Background(), r) // nolint: errcheck
+
+	records, err := DecodeProtobufBatch(buf.Bytes())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(records) != 1 {
+		t.Fatalf("expected 1 record, got %d", len(records))
+	}
+
+	decoded := records[0]
+
+	if !decoded.Time.Equal(now) || decoded.Level != trace || decoded.Message != "values" {
+		t.Errorf("expected the record fields to be decoded, got: %v %v %q", decoded.Time, decoded.Level, decoded.Message)
+	}
+
+	got := map[string]slog.Value{}
+
+	decoded.Attrs(func(a slog.Attr) bool {
+		got[a.Key] = a.Value
+		return true
+	})
+
+	if len(got) != 10 {
+		t.Errorf("expected 10 attributes, got: %v", got)
+	}
+
+	if got["str"].String() != "s" || got["int"].Int64() != -7 || got["uint"].Uint64() != 7 ||
+		got["float"].Float64() != 1.5 || !got["bool"].Bool() || got["dur"].Duration() != time.Second ||
+		!got["at"].Time().Equal(now) || got["err"].String() != "boom" || got["inlined"].String() != "yes" {
+		t.Errorf("expected typed values to be decoded, got: %v", got)
+	}
+
+	if tags, ok := got["tags"].Any().(json.RawMessage); !ok || string(tags) != `["a","b"]` {
+		t.Errorf("expected the JSON value to be decoded, got: %v", got["tags"])
+	}
+}
+
+func TestDecodeProtobufBatch__Malformed(t *testing.T) {
+	if _, err := DecodeProtobufBatch([]byte{0x0a, 0x10, 0x01}); err == nil {
+		t.Error("expected an error for the truncated batch")
+	}
+}
+
+func TestIsProtobuf(t *testing.T) {
+	var batch bytes.Buffer
+
+	printer := NewProtobufPrinter(&batch)
+	printer.Handle(context.Background(), slog.NewRecord(time.Now(), slog.LevelInfo, "first", 0))   // nolint: errcheck
+	printer.Handle(context.Background(), slog.NewRecord(time.Now(), slog.LevelError, "second", 0)) // nolint: errcheck
+
+	if !IsProtobuf(batch.Bytes()) {
+		t.Error("expected the protobuf batch to be detected")
+	}
+
+	for _, msg := range []string{
+		"",
+		"\ntime=2024-05-01T10:20:30Z level=INFO msg=text\n",
+		"\n{\"level\":\"INFO\",\"msg\":\"pretty\"}\n",
+		"\n\n",
+		`{"type":"control","event":"start"}`,
+	} {
+		if IsProtobuf([]byte(msg)) {
+			t.Errorf("expected %q not to be detected as protobuf", msg)
+		}
+	}
+}
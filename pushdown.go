@@ -0,0 +1,150 @@
+package slogspy
+
+import (
+	"strings"
+)
+
+// FilterPushdown describes which kinds of filter conditions a streaming server applies on its side;
+// the rest must be applied by the client.
+type FilterPushdown struct {
+	// Level conditions (level>=warn)
+	Level bool
+	// Msg comparisons (msg=="payment failed")
+	Msg bool
+	// Attrs comparisons and presence checks (attrs.user_id=="42")
+	Attrs bool
+	// Regex matching of any field (msg=~"payment")
+	Regex bool
+}
+
+// FullPushdown makes the server apply all the filter conditions.
+var FullPushdown = FilterPushdown{Level: true, Msg: true, Attrs: true, Regex: true}
+
+// SplitFilterExpr splits the filter expression (see CompileFilter) into the parts applied by the server and
+// by the client according to the pushdown policy. The expression is split by the top-level && operators;
+// a condition is pushed down if all its fields and operators are allowed. Both parts are valid expressions
+// (or empty strings) which could be safely combined with other conditions via &&.
+func SplitFilterExpr(expr string, p FilterPushdown) (server string, client string, err error) {
+	if _, err := CompileFilter(expr); err != nil {
+		return "", "", err
+	}
+
+	tokens, _ := lexExpr(expr)
+
+	var serverParts, clientParts []string
+
+	conjuncts := splitConjuncts(tokens)
+
+	for _, conj := range conjuncts {
+		text := expr[conj[0].pos:conj[len(conj)-1].end]
+
+		// The only conjunct could contain top-level || operators
+		if len(conjuncts) == 1 && hasTopLevelOr(conj) {
+			text = "(" + text + ")"
+		}
+
+		if p.allows(conj) {
+			serverParts = append(serverParts, text)
+		} else {
+			clientParts = append(clientParts, text)
+		}
+	}
+
+	return andExpr(serverParts...), andExpr(clientParts...), nil
+}
+
+// andExpr combines the non-empty expressions via &&
+func andExpr(parts ...string) string {
+	nonEmpty := parts[:0:0]
+
+	for _, part := range parts {
+		if part != "" {
+			nonEmpty = append(nonEmpty, part)
+		}
+	}
+
+	return strings.Join(nonEmpty, " && ")
+}
+
+func hasTopLevelOr(tokens []exprToken) bool {
+	depth := 0
+
+	for _, tok := range tokens {
+		switch tok.kind {
+		case tokLParen:
+			depth++
+		case tokRParen:
+			depth--
+		case tokOr:
+			if depth == 0 {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// splitConjuncts splits the tokens by the top-level && operators (unless there are top-level || ones)
+func splitConjuncts(tokens []exprToken) [][]exprToken {
+	tokens = tokens[:len(tokens)-1] // skip EOF
+
+	var (
+		conjuncts [][]exprToken
+		depth     int
+		start     int
+	)
+
+	for i, tok := range tokens {
+		switch tok.kind {
+		case tokLParen:
+			depth++
+		case tokRParen:
+			depth--
+		case tokOr:
+			if depth == 0 {
+				return [][]exprToken{tokens}
+			}
+		case tokAnd:
+			if depth == 0 {
+				conjuncts = append(conjuncts, tokens[start:i])
+				start = i + 1
+			}
+		}
+	}
+
+	return append(conjuncts, tokens[start:])
+}
+
+func (p FilterPushdown) allows(tokens []exprToken) bool {
+	for i, tok := range tokens {
+		switch tok.kind {
+		case tokOp:
+			if (tok.text == "=~" || tok.text == "!~") && !p.Regex {
+				return false
+			}
+		case tokIdent:
+			// Identifiers following operators are values
+			if i > 0 && tokens[i-1].kind == tokOp {
+				continue
+			}
+
+			switch {
+			case tok.text == "level":
+				if !p.Level {
+					return false
+				}
+			case tok.text == "msg":
+				if !p.Msg {
+					return false
+				}
+			default:
+				if !p.Attrs {
+					return false
+				}
+			}
+		}
+	}
+
+	return true
+}
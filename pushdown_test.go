@@ -0,0 +1,107 @@
+package slogspy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSplitFilterExpr(t *testing.T) {
+	levelOnly := FilterPushdown{Level: true}
+	noRegex := FilterPushdown{Level: true, Msg: true, Attrs: true}
+
+	for _, tc := range []struct {
+		expr     string
+		pushdown FilterPushdown
+		server   string
+		client   string
+	}{
+		{`level>=warn && msg=~"payment"`, levelOnly, `level>=warn`, `msg=~"payment"`},
+		{`level>=warn && msg=~"payment"`, FullPushdown, `level>=warn && msg=~"payment"`, ``},
+		{`level>=warn && attrs.tenant==acme && attrs.path!~"^/health"`, noRegex, `level>=warn && attrs.tenant==acme`, `attrs.path!~"^/health"`},
+		{`(level>=error || attrs.user_id=="42") && msg=="paid"`, levelOnly, ``, `(level>=error || attrs.user_id=="42") && msg=="paid"`},
+		{`level>=error || attrs.user_id=="42"`, noRegex, `(level>=error || attrs.user_id=="42")`, ``},
+		{`level>=error || attrs.user_id=="42"`, levelOnly, ``, `(level>=error || attrs.user_id=="42")`},
+		{`!attrs.debug && level<error`, FilterPushdown{}, ``, `!attrs.debug && level<error`},
+	} {
+		server, client, err := SplitFilterExpr(tc.expr, tc.pushdown)
+
+		if err != nil {
+			t.Fatalf("failed to split %s: %v", tc.expr, err)
+		}
+
+		if server != tc.server || client != tc.client {
+			t.Errorf("expected %s to be split into %q and %q, got %q and %q", tc.expr, tc.server, tc.client, server, client)
+		}
+	}
+
+	if _, _, err := SplitFilterExpr(`level>=`, FullPushdown); err == nil {
+		t.Error("expected invalid expression to fail")
+	}
+}
+
+func TestWebsocketHandler__FilterPushdown(t *testing.T) {
+	spy := NewSpy(slog.NewTextHandler(&bytes.Buffer{}, nil), WithFlushInterval(10*time.Millisecond))
+	ws := spy.WebsocketHandler(WithWebsocketFilterPushdown(FilterPushdown{Level: true}))
+
+	go spy.Run(context.Background(), nil)    // nolint: errcheck
+	defer spy.Shutdown(context.Background()) // nolint: errcheck
+
+	server := httptest.NewServer(ws)
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "?filter=" + url.QueryEscape("level>="))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp.Body.Close() // nolint: errcheck
+
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected 400 for an invalid filter, got %d", resp.StatusCode)
+	}
+
+	conn, rw := dialWebsocket(t, server.URL+"?level=warn&filter="+url.QueryEscape(`msg=~"payment"`))
+	defer conn.Close() // nolint: errcheck
+
+	conn.SetReadDeadline(time.Now().Add(time.Second)) // nolint: errcheck
+	_, payload, err := readWebsocketFrame(rw.Reader)
+
+	if err != nil {
+		t.Fatalf("failed to read frame: %v", err)
+	}
+
+	var announcement map[string]string
+
+	if err := json.Unmarshal(payload, &announcement); err != nil {
+		t.Fatal(err)
+	}
+
+	if announcement["event"] != EventPushdown || announcement["server"] != "level>=WARN" || announcement["client"] != `msg=~"payment"` {
+		t.Errorf("expected pushdown announcement, got: %s", payload)
+	}
+
+	waitFor(t, func() bool { return spy.handler.active.Load() == 1 })
+
+	logger := slog.New(spy)
+	logger.Info("payment info")
+	logger.Warn("payment warning")
+
+	_, payload, err = readWebsocketFrame(rw.Reader)
+
+	if err != nil {
+		t.Fatalf("failed to read frame: %v", err)
+	}
+
+	if strings.Contains(string(payload), "payment info") || !strings.Contains(string(payload), "payment warning") {
+		t.Errorf("expected the server to apply the level filter, got: %s", payload)
+	}
+}
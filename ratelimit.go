@@ -0,0 +1,136 @@
+package slogspy
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const clientLimiterSweepInterval = time.Minute
+
+// ClientLimiter is a token bucket rate limiter tracking buckets per client (by IP or identity).
+// It protects the network transports from clients creating sessions or sending messages in a loop.
+type ClientLimiter struct {
+	rate  float64
+	burst int
+
+	keyFunc func(r *http.Request) string
+
+	mu        sync.Mutex
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+type ClientLimiterOption func(*ClientLimiter)
+
+// WithClientKey sets a function to identify clients (the remote IP is used by default).
+func WithClientKey(fn func(r *http.Request) string) ClientLimiterOption {
+	return func(l *ClientLimiter) {
+		l.keyFunc = fn
+	}
+}
+
+// NewClientLimiter creates a new limiter allowing rate events per second with the specified burst per client.
+func NewClientLimiter(rate float64, burst int, opts ...ClientLimiterOption) *ClientLimiter {
+	l := &ClientLimiter{
+		rate:      rate,
+		burst:     burst,
+		keyFunc:   ClientIP,
+		buckets:   make(map[string]*tokenBucket),
+		lastSweep: time.Now(),
+	}
+
+	for _, opt := range opts {
+		opt(l)
+	}
+
+	return l
+}
+
+// Allow consumes a token for the client and reports whether the event is allowed.
+func (l *ClientLimiter) Allow(key string) bool {
+	ok, _ := l.take(key)
+	return ok
+}
+
+// AllowRequest is like Allow but identifies the client by the request.
+func (l *ClientLimiter) AllowRequest(r *http.Request) bool {
+	return l.Allow(l.keyFunc(r))
+}
+
+// Middleware rejects requests exceeding the limit with 429 Too Many Requests.
+func (l *ClientLimiter) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ok, retryAfter := l.take(l.keyFunc(r)); !ok {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+			http.Error(w, "Too Many Requests", http.StatusTooManyRequests)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// take consumes a token and returns the time to wait for the next one if the bucket is empty
+func (l *ClientLimiter) take(key string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+
+	l.sweep(now)
+
+	b, ok := l.buckets[key]
+
+	if !ok {
+		b = &tokenBucket{tokens: float64(l.burst), last: now}
+		l.buckets[key] = b
+	} else {
+		b.tokens = math.Min(float64(l.burst), b.tokens+now.Sub(b.last).Seconds()*l.rate)
+		b.last = now
+	}
+
+	if b.tokens < 1 {
+		return false, time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+	}
+
+	b.tokens--
+
+	return true, 0
+}
+
+// sweep removes buckets of the clients which have been idle long enough to refill
+func (l *ClientLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < clientLimiterSweepInterval {
+		return
+	}
+
+	l.lastSweep = now
+
+	refill := time.Duration(float64(l.burst) / l.rate * float64(time.Second))
+
+	for key, b := range l.buckets {
+		if now.Sub(b.last) > refill {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// ClientIP returns the remote IP address of the request.
+func ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
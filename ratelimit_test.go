@@ -0,0 +1,60 @@
+package slogspy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestClientLimiter__Allow(t *testing.T) {
+	l := NewClientLimiter(100, 2)
+
+	if !l.Allow("a") || !l.Allow("a") {
+		t.Fatal("expected burst to be allowed")
+	}
+
+	if l.Allow("a") {
+		t.Error("expected client to be limited after burst")
+	}
+
+	if !l.Allow("b") {
+		t.Error("expected other clients not to be affected")
+	}
+
+	time.Sleep(20 * time.Millisecond)
+
+	if !l.Allow("a") {
+		t.Error("expected tokens to be refilled")
+	}
+}
+
+func TestClientLimiter__Middleware(t *testing.T) {
+	l := NewClientLimiter(1, 1, WithClientKey(func(r *http.Request) string {
+		return r.Header.Get("X-Client")
+	}))
+
+	handler := l.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	request := func(client string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest("GET", "/", nil)
+		r.Header.Set("X-Client", client)
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	if w := request("dashboard"); w.Code != http.StatusOK {
+		t.Errorf("expected first request to pass, got %d", w.Code)
+	}
+
+	w := request("dashboard")
+
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "1" {
+		t.Errorf("expected second request to be limited, got %d (Retry-After: %s)", w.Code, w.Header().Get("Retry-After"))
+	}
+
+	if w := request("script"); w.Code != http.StatusOK {
+		t.Errorf("expected other client's request to pass, got %d", w.Code)
+	}
+}
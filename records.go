@@ -0,0 +1,106 @@
+package slogspy
+
+import (
+	"context"
+	"log/slog"
+)
+
+const defaultMaxBatchRecords = 1024
+
+// SpyOutputRecords receives batches of spied records in the structured delivery mode.
+// The slice could be retained by the consumer.
+type SpyOutputRecords func(records []slog.Record)
+
+// WithMaxBatchRecords sets the max number of records in a batch in the structured delivery mode.
+func WithMaxBatchRecords(n int) SpyHandlerOption {
+	return func(h *SpyHandler) {
+		h.maxBatchRecords = n
+	}
+}
+
+// RunRecords works like Run but delivers batches of records instead of formatted bytes.
+// The attributes and groups added via WithAttrs and WithGroup are resolved into the record attributes,
+// the redactor, value formatters and offloading are applied. The printer is not used in this mode,
+// and subscriptions don't receive any data.
+func (h *SpyHandler) RunRecords(ctx context.Context, out SpyOutputRecords) error {
+	return h.run(ctx, nil, out)
+}
+
+// WithSyncRecords enables the synchronous capture mode: spied records are resolved (see RunRecords) and passed
+// to the function right in Handle, so neither the Run loop nor timers are involved (e.g., for unit tests).
+// Sampling and subscriptions are not supported in this mode.
+func WithSyncRecords(fn func(r slog.Record)) SpyHandlerOption {
+	return func(h *SpyHandler) {
+		h.syncRecords = fn
+	}
+}
+
+// handleSync passes the spied record to the synchronous capture function
+func (h *SpyHandler) handleSync(r slog.Record) {
+	entry := &Entry{record: r, groups: h.groups, frames: h.frames}
+
+	if !h.spied(entry) {
+		return
+	}
+
+	h.syncRecords(h.resolveRecord(entry))
+}
+
+func (h *SpyHandler) flushRecords() {
+	if len(h.records) == 0 {
+		return
+	}
+
+	records := h.records
+	h.records = nil
+
+	h.recordsOutput(records)
+	h.trackFlushed(0)
+}
+
+// resolveRecord returns a new record with all the handler attributes and groups resolved
+func (h *SpyHandler) resolveRecord(entry *Entry) slog.Record {
+	r := entry.record
+
+	if len(entry.groups) == 0 && len(entry.frames) == 0 {
+		return h.formatRecord(nil, r)
+	}
+
+	attrs := make([]slog.Attr, 0, r.NumAttrs())
+
+	r.Attrs(func(a slog.Attr) bool {
+		attrs = append(attrs, a)
+		return true
+	})
+
+	resolved := slog.NewRecord(r.Time, r.Level, r.Message, r.PC)
+	resolved.AddAttrs(h.formatAttrs(nil, nestAttrs(entry.groups, entry.frames, attrs))...)
+
+	return resolved
+}
+
+// nestAttrs puts the handler and record attributes into the groups they belong to
+func nestAttrs(groups []string, frames []attrFrame, attrs []slog.Attr) []slog.Attr {
+	// Attributes are collected from the innermost group to the outermost one
+	inner := attrs
+
+	for depth := len(groups); depth >= 0; depth-- {
+		var level []slog.Attr
+
+		for _, frame := range frames {
+			if frame.depth == depth {
+				level = append(level, frame.attrs...)
+			}
+		}
+
+		if depth == len(groups) {
+			level = append(level, inner...)
+		} else if len(inner) > 0 {
+			level = append(level, slog.Attr{Key: groups[depth], Value: slog.GroupValue(inner...)})
+		}
+
+		inner = level
+	}
+
+	return inner
+}
@@ -0,0 +1,89 @@
+package slogspy
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"testing"
+)
+
+func TestSpy__RunRecords(t *testing.T) {
+	var records []slog.Record
+
+	spy := NewSpy(
+		slog.NewTextHandler(&bytes.Buffer{}, nil),
+		WithRedactor(func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == "token" {
+				return slog.String("token", "[FILTERED]")
+			}
+			return a
+		}),
+	)
+
+	go spy.RunRecords(context.Background(), func(batch []slog.Record) { // nolint: errcheck
+		records = append(records, batch...)
+	})
+
+	spy.Watch()
+
+	logger := slog.New(spy).With("service", "api").WithGroup("http").With("token", "secret").WithGroup("request")
+	logger.Info("received", "status", 200)
+	logger.Debug("empty")
+
+	if err := spy.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(records) != 2 {
+		t.Fatalf("expected 2 records, got %d", len(records))
+	}
+
+	buf := &bytes.Buffer{}
+	printer := slog.NewJSONHandler(buf, &slog.HandlerOptions{Level: slog.LevelDebug, ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+		if len(groups) == 0 && a.Key == slog.TimeKey {
+			return slog.Attr{}
+		}
+		return a
+	}})
+
+	for _, r := range records {
+		printer.Handle(context.Background(), r) // nolint: errcheck
+	}
+
+	expected := `{"level":"INFO","msg":"received","service":"api","http":{"token":"[FILTERED]","request":{"status":200}}}
+{"level":"DEBUG","msg":"empty","service":"api","http":{"token":"[FILTERED]"}}
+`
+
+	if buf.String() != expected {
+		t.Errorf("expected records:\n%s\ngot:\n%s", expected, buf.String())
+	}
+}
+
+func TestSpy__SyncRecords(t *testing.T) {
+	var records []slog.Record
+
+	spy := NewSpy(slog.NewTextHandler(&bytes.Buffer{}, nil), WithSyncRecords(func(r slog.Record) {
+		records = append(records, r.Clone())
+	}), WithFilter(func(r *RecordView) bool {
+		_, ok := r.Lookup("api.tenant")
+		return ok
+	}))
+
+	logger := slog.New(spy).WithGroup("api").With("tenant", "acme")
+	logger.Info("not watched")
+
+	spy.Watch()
+
+	logger.Info("handled synchronously", "status", 200)
+	slog.New(spy).Info("filtered out")
+
+	if len(records) != 1 {
+		t.Fatalf("expected 1 record without the Run loop, got %d", len(records))
+	}
+
+	view := &RecordView{Record: records[0]}
+
+	if val, ok := view.Lookup("api.status"); !ok || val.Int64() != 200 {
+		t.Errorf("expected the groups to be resolved, got: %v", records[0])
+	}
+}
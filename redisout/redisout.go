@@ -0,0 +1,374 @@
+// Package redisout provides a spy output publishing flushed batches to a Redis channel.
+// It speaks a minimal subset of RESP (AUTH, PUBLISH and PUBSUB NUMSUB), so no client library is required.
+package redisout
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultChannel = "slogspy"
+
+	defaultTimeout           = time.Second
+	defaultReconnectInterval = time.Second
+	defaultPollInterval      = 5 * time.Second
+)
+
+// ErrDisconnected is reported when a batch is dropped while waiting to reconnect.
+var ErrDisconnected = errors.New("redis: disconnected")
+
+// Watcher is a spy (or spy handler) to keep watching while the channel has subscribers.
+type Watcher interface {
+	Watch()
+	Unwatch()
+}
+
+// Output publishes batches to the Redis channel; use its Write method as a SpyOutput.
+type Output struct {
+	addr              string
+	channel           string
+	password          string
+	timeout           time.Duration
+	reconnectInterval time.Duration
+	errorHandler      func(err error)
+
+	watcher      Watcher
+	pollInterval time.Duration
+	watching     bool
+
+	mu          sync.Mutex
+	conn        net.Conn
+	rw          *bufio.ReadWriter
+	lastAttempt time.Time
+
+	closeOnce sync.Once
+	done      chan struct{}
+	wg        sync.WaitGroup
+}
+
+type Option func(*Output)
+
+// WithChannel sets the channel to publish batches to ("slogspy" by default), e.g., "slogspy:" + hostname
+// to tell app instances apart.
+func WithChannel(name string) Option {
+	return func(o *Output) {
+		o.channel = name
+	}
+}
+
+// WithPassword sets the password to authenticate with.
+func WithPassword(password string) Option {
+	return func(o *Output) {
+		o.password = password
+	}
+}
+
+// WithTimeout sets the dial and I/O timeout (1s by default).
+func WithTimeout(d time.Duration) Option {
+	return func(o *Output) {
+		o.timeout = d
+	}
+}
+
+// WithReconnectInterval sets the min interval between reconnection attempts (1s by default);
+// batches are dropped while disconnected.
+func WithReconnectInterval(d time.Duration) Option {
+	return func(o *Output) {
+		o.reconnectInterval = d
+	}
+}
+
+// WithErrorHandler sets a function to be called on connection and publishing errors.
+func WithErrorHandler(fn func(err error)) Option {
+	return func(o *Output) {
+		o.errorHandler = fn
+	}
+}
+
+// WithWatcher makes the output keep a watcher registered only while the channel has subscribers
+// (checked via PUBSUB NUMSUB every interval, 5s by default), so the spy is only active when someone is viewing.
+func WithWatcher(w Watcher, interval time.Duration) Option {
+	return func(o *Output) {
+		o.watcher = w
+
+		if interval > 0 {
+			o.pollInterval = interval
+		}
+	}
+}
+
+// NewOutput creates an output publishing to the Redis server at addr (host:port).
+// The connection is established lazily and re-established on failures.
+func NewOutput(addr string, opts ...Option) *Output {
+	o := &Output{
+		addr:              addr,
+		channel:           DefaultChannel,
+		timeout:           defaultTimeout,
+		reconnectInterval: defaultReconnectInterval,
+		pollInterval:      defaultPollInterval,
+		done:              make(chan struct{}),
+	}
+
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	if o.watcher != nil {
+		o.wg.Add(1)
+		go o.pollSubscribers()
+	}
+
+	return o
+}
+
+// Write publishes the batch to the channel.
+func (o *Output) Write(msg []byte) {
+	if _, err := o.command([]byte("PUBLISH"), []byte(o.channel), msg); err != nil {
+		o.handleError(err)
+	}
+}
+
+// Subscribers returns the number of the channel subscribers.
+func (o *Output) Subscribers() (int64, error) {
+	reply, err := o.command([]byte("PUBSUB"), []byte("NUMSUB"), []byte(o.channel))
+
+	if err != nil {
+		return 0, err
+	}
+
+	items, ok := reply.([]any)
+
+	if !ok || len(items) != 2 {
+		return 0, fmt.Errorf("redis: unexpected NUMSUB reply: %v", reply)
+	}
+
+	n, ok := items[1].(int64)
+
+	if !ok {
+		return 0, fmt.Errorf("redis: unexpected NUMSUB reply: %v", reply)
+	}
+
+	return n, nil
+}
+
+// Close stops watching subscribers, unregisters the watcher (if any) and closes the connection.
+func (o *Output) Close() error {
+	o.closeOnce.Do(func() { close(o.done) })
+	o.wg.Wait()
+
+	o.setWatching(false)
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.conn == nil {
+		return nil
+	}
+
+	err := o.conn.Close()
+	o.conn = nil
+
+	return err
+}
+
+func (o *Output) pollSubscribers() {
+	defer o.wg.Done()
+
+	ticker := time.NewTicker(o.pollInterval)
+	defer ticker.Stop()
+
+	for {
+		n, err := o.Subscribers()
+
+		if err != nil {
+			o.handleError(err)
+		} else {
+			o.setWatching(n > 0)
+		}
+
+		select {
+		case <-o.done:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (o *Output) setWatching(active bool) {
+	if o.watcher == nil || o.watching == active {
+		return
+	}
+
+	o.watching = active
+
+	if active {
+		o.watcher.Watch()
+	} else {
+		o.watcher.Unwatch()
+	}
+}
+
+// command sends the command and reads the reply; the connection is dropped on I/O errors
+func (o *Output) command(args ...[]byte) (any, error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if err := o.connect(); err != nil {
+		return nil, err
+	}
+
+	reply, err := o.roundtrip(args...)
+
+	if err != nil {
+		var redisErr replyError
+
+		if !errors.As(err, &redisErr) {
+			o.conn.Close() // nolint: errcheck
+			o.conn = nil
+		}
+
+		return nil, err
+	}
+
+	return reply, nil
+}
+
+// connect establishes the connection if needed; must be called with the lock held
+func (o *Output) connect() error {
+	if o.conn != nil {
+		return nil
+	}
+
+	select {
+	case <-o.done:
+		return ErrDisconnected
+	default:
+	}
+
+	if time.Since(o.lastAttempt) < o.reconnectInterval {
+		return ErrDisconnected
+	}
+
+	o.lastAttempt = time.Now()
+
+	conn, err := net.DialTimeout("tcp", o.addr, o.timeout)
+
+	if err != nil {
+		return err
+	}
+
+	o.conn = conn
+	o.rw = bufio.NewReadWriter(bufio.NewReader(conn), bufio.NewWriter(conn))
+
+	if o.password != "" {
+		if _, err := o.roundtrip([]byte("AUTH"), []byte(o.password)); err != nil {
+			conn.Close() // nolint: errcheck
+			o.conn = nil
+
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (o *Output) roundtrip(args ...[]byte) (any, error) {
+	o.conn.SetDeadline(time.Now().Add(o.timeout)) // nolint: errcheck
+
+	if err := writeCommand(o.rw.Writer, args...); err != nil {
+		return nil, err
+	}
+
+	if err := o.rw.Flush(); err != nil {
+		return nil, err
+	}
+
+	return readReply(o.rw.Reader)
+}
+
+func (o *Output) handleError(err error) {
+	if o.errorHandler != nil {
+		o.errorHandler(err)
+	}
+}
+
+// replyError is an error reply from the server (the connection is still usable)
+type replyError string
+
+func (e replyError) Error() string {
+	return "redis: " + string(e)
+}
+
+func writeCommand(w *bufio.Writer, args ...[]byte) error {
+	w.WriteString("*" + strconv.Itoa(len(args)) + "\r\n") // nolint: errcheck
+
+	for _, arg := range args {
+		w.WriteString("$" + strconv.Itoa(len(arg)) + "\r\n") // nolint: errcheck
+		w.Write(arg)                                         // nolint: errcheck
+		w.WriteString("\r\n")                                // nolint: errcheck
+	}
+
+	return nil
+}
+
+func readReply(r *bufio.Reader) (any, error) {
+	line, err := r.ReadString('\n')
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(line) < 3 || line[len(line)-2] != '\r' {
+		return nil, fmt.Errorf("redis: invalid reply %q", line)
+	}
+
+	kind, payload := line[0], line[1:len(line)-2]
+
+	switch kind {
+	case '+':
+		return payload, nil
+	case '-':
+		return nil, replyError(payload)
+	case ':':
+		return strconv.ParseInt(payload, 10, 64)
+	case '$':
+		n, err := strconv.Atoi(payload)
+
+		if err != nil || n < 0 {
+			return nil, err
+		}
+
+		buf := make([]byte, n+2)
+
+		if _, err := io.ReadFull(r, buf); err != nil {
+			return nil, err
+		}
+
+		return string(buf[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(payload)
+
+		if err != nil || n < 0 {
+			return nil, err
+		}
+
+		items := make([]any, n)
+
+		for i := range items {
+			if items[i], err = readReply(r); err != nil {
+				return nil, err
+			}
+		}
+
+		return items, nil
+	}
+
+	return nil, fmt.Errorf("redis: unknown reply type %q", kind)
+}
@@ -0,0 +1,234 @@
+package redisout
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"log/slog"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	slogspy "github.com/palkan/slog-spy"
+)
+
+// fakeServer is a minimal Redis server recording the published messages
+type fakeServer struct {
+	ln net.Listener
+
+	mu          sync.Mutex
+	published   []string
+	commands    []string
+	subscribers atomic.Int64
+	// dropAfter makes the server close the connection after the specified number of commands
+	dropAfter int
+}
+
+func newFakeServer(t *testing.T) *fakeServer {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := &fakeServer{ln: ln}
+
+	go s.serve()
+
+	t.Cleanup(func() { ln.Close() }) // nolint: errcheck
+
+	return s
+}
+
+func (s *fakeServer) serve() {
+	for {
+		conn, err := s.ln.Accept()
+
+		if err != nil {
+			return
+		}
+
+		go s.handle(conn)
+	}
+}
+
+func (s *fakeServer) handle(conn net.Conn) {
+	defer conn.Close() // nolint: errcheck
+
+	r := bufio.NewReader(conn)
+	handled := 0
+
+	for {
+		reply, err := readReply(r)
+
+		if err != nil {
+			return
+		}
+
+		args, _ := reply.([]any)
+		cmd := strings.ToUpper(args[0].(string))
+
+		s.mu.Lock()
+		s.commands = append(s.commands, cmd)
+
+		var resp string
+
+		switch cmd {
+		case "AUTH":
+			resp = "+OK\r\n"
+		case "PUBLISH":
+			s.published = append(s.published, args[1].(string)+" "+args[2].(string))
+			resp = ":" + strconv.FormatInt(s.subscribers.Load(), 10) + "\r\n"
+		case "PUBSUB":
+			channel := args[2].(string)
+			resp = "*2\r\n$" + strconv.Itoa(len(channel)) + "\r\n" + channel + "\r\n:" + strconv.FormatInt(s.subscribers.Load(), 10) + "\r\n"
+		default:
+			resp = "-ERR unknown command\r\n"
+		}
+
+		handled++
+		drop := s.dropAfter > 0 && handled >= s.dropAfter
+		s.mu.Unlock()
+
+		conn.Write([]byte(resp)) // nolint: errcheck
+
+		if drop {
+			return
+		}
+	}
+}
+
+func (s *fakeServer) messages() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]string(nil), s.published...)
+}
+
+type fakeWatcher struct {
+	watchers atomic.Int64
+}
+
+func (w *fakeWatcher) Watch()   { w.watchers.Add(1) }
+func (w *fakeWatcher) Unwatch() { w.watchers.Add(-1) }
+
+func TestOutput__Write(t *testing.T) {
+	server := newFakeServer(t)
+
+	out := NewOutput(server.ln.Addr().String(), WithChannel("slogspy:web-1"), WithPassword("secret"))
+	defer out.Close() // nolint: errcheck
+
+	spy := slogspy.NewSpy(slog.NewTextHandler(io.Discard, nil))
+
+	go spy.Run(context.Background(), out.Write) // nolint: errcheck
+
+	spy.Watch()
+
+	slog.New(spy).Info("hello", "id", 42)
+
+	if err := spy.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	messages := server.messages()
+
+	if len(messages) != 1 {
+		t.Fatalf("expected 1 published message, got %d", len(messages))
+	}
+
+	if !strings.HasPrefix(messages[0], "slogspy:web-1 ") || !strings.Contains(messages[0], `"msg":"hello","id":42`) {
+		t.Errorf("unexpected message: %s", messages[0])
+	}
+
+	server.mu.Lock()
+	defer server.mu.Unlock()
+
+	if server.commands[0] != "AUTH" {
+		t.Errorf("expected to authenticate first, got commands: %v", server.commands)
+	}
+}
+
+func TestOutput__Reconnect(t *testing.T) {
+	server := newFakeServer(t)
+	server.dropAfter = 1
+
+	var errors atomic.Int64
+
+	out := NewOutput(
+		server.ln.Addr().String(),
+		WithReconnectInterval(10*time.Millisecond),
+		WithErrorHandler(func(error) { errors.Add(1) }),
+	)
+	defer out.Close() // nolint: errcheck
+
+	out.Write([]byte("first"))
+	// The connection is closed by the server, the failure is detected on the next write
+	out.Write([]byte("lost"))
+
+	time.Sleep(20 * time.Millisecond)
+
+	out.Write([]byte("second"))
+
+	messages := server.messages()
+
+	if len(messages) != 2 || messages[0] != "slogspy first" || messages[1] != "slogspy second" {
+		t.Errorf("unexpected messages: %v", messages)
+	}
+
+	if errors.Load() == 0 {
+		t.Error("expected the connection error to be reported")
+	}
+}
+
+func TestOutput__WithWatcher(t *testing.T) {
+	server := newFakeServer(t)
+
+	watcher := &fakeWatcher{}
+
+	out := NewOutput(server.ln.Addr().String(), WithWatcher(watcher, 10*time.Millisecond))
+
+	time.Sleep(30 * time.Millisecond)
+
+	if watcher.watchers.Load() != 0 {
+		t.Fatal("expected not to watch without subscribers")
+	}
+
+	server.subscribers.Store(2)
+
+	waitFor(t, func() bool { return watcher.watchers.Load() == 1 })
+
+	server.subscribers.Store(0)
+
+	waitFor(t, func() bool { return watcher.watchers.Load() == 0 })
+
+	server.subscribers.Store(1)
+
+	waitFor(t, func() bool { return watcher.watchers.Load() == 1 })
+
+	if err := out.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if watcher.watchers.Load() != 0 {
+		t.Error("expected to unwatch on close")
+	}
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(time.Second)
+
+	for time.Now().Before(deadline) {
+		if cond() {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	t.Fatal("timed out waiting for condition")
+}
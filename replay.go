@@ -0,0 +1,323 @@
+package slogspy
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"time"
+)
+
+const maxRecordingLineSize = 16 * 1024 * 1024
+
+// Recording contains records restored from a captured session (a stream of JSON records produced by the JSON printer
+// with any framing). It could be used to drive tests of log-processing code with the records captured in production.
+type Recording struct {
+	records []slog.Record
+}
+
+// LoadRecording parses a captured session. Control messages are skipped, batch envelopes are unwrapped,
+// and raw (non-JSON) records are restored with the raw contents as the message.
+// Attributes and groups keep their original order, so replaying a recording is deterministic.
+func LoadRecording(r io.Reader) (*Recording, error) {
+	rec := &Recording{}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxRecordingLineSize)
+
+	line := 0
+
+	for scanner.Scan() {
+		line++
+
+		data := bytes.TrimSpace(scanner.Bytes())
+
+		if len(data) == 0 {
+			continue
+		}
+
+		if err := rec.parseLine(data); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return rec, nil
+}
+
+// Len returns the number of records in the recording.
+func (rec *Recording) Len() int {
+	return len(rec.records)
+}
+
+// Records returns copies of the recorded records.
+func (rec *Recording) Records() []slog.Record {
+	records := make([]slog.Record, len(rec.records))
+
+	for i, r := range rec.records {
+		records[i] = r.Clone()
+	}
+
+	return records
+}
+
+// Replay passes the recorded records to the handler in the original order (skipping the ones the handler is not enabled for).
+// It stops at the first error returned by the handler.
+func (rec *Recording) Replay(ctx context.Context, h slog.Handler) error {
+	for _, r := range rec.records {
+		if !h.Enabled(ctx, r.Level) {
+			continue
+		}
+
+		if err := h.Handle(ctx, r.Clone()); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (rec *Recording) parseLine(data []byte) error {
+	val, err := decodeOrdered(data)
+
+	if err != nil {
+		return err
+	}
+
+	obj, ok := val.(jsonObject)
+
+	if !ok {
+		return errors.New("record must be a JSON object")
+	}
+
+	if typ, ok := obj.get("type"); ok && typ == "control" {
+		return nil
+	}
+
+	if records, ok := obj.get("records"); ok {
+		if _, envelope := obj.get("count"); envelope {
+			items, ok := records.([]any)
+
+			if !ok {
+				return errors.New("envelope records must be an array")
+			}
+
+			for _, item := range items {
+				recordObj, ok := item.(jsonObject)
+
+				if !ok {
+					return errors.New("record must be a JSON object")
+				}
+
+				rec.records = append(rec.records, recordFromObject(recordObj))
+			}
+
+			return nil
+		}
+	}
+
+	if raw, ok := obj.get("raw"); ok && len(obj) == 1 {
+		if msg, ok := raw.(string); ok {
+			rec.records = append(rec.records, slog.NewRecord(time.Time{}, slog.LevelInfo, msg, 0))
+			return nil
+		}
+	}
+
+	rec.records = append(rec.records, recordFromObject(obj))
+
+	return nil
+}
+
+func recordFromObject(obj jsonObject) slog.Record {
+	var (
+		ts    time.Time
+		level slog.Level
+		msg   string
+		attrs []slog.Attr
+	)
+
+	for _, field := range obj {
+		switch field.key {
+		case slog.TimeKey:
+			if s, ok := field.val.(string); ok {
+				if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+					ts = t
+					continue
+				}
+			}
+		case slog.LevelKey:
+			if s, ok := field.val.(string); ok {
+				if err := level.UnmarshalText([]byte(s)); err == nil {
+					continue
+				}
+			}
+		case slog.MessageKey:
+			if s, ok := field.val.(string); ok {
+				msg = s
+				continue
+			}
+		case slog.SourceKey:
+			// Source locations can't be restored
+			continue
+		}
+
+		attrs = append(attrs, attrFromJSON(field.key, field.val))
+	}
+
+	r := slog.NewRecord(ts, level, msg, 0)
+	r.AddAttrs(attrs...)
+
+	return r
+}
+
+func attrFromJSON(key string, val any) slog.Attr {
+	switch v := val.(type) {
+	case jsonObject:
+		attrs := make([]slog.Attr, 0, len(v))
+
+		for _, field := range v {
+			attrs = append(attrs, attrFromJSON(field.key, field.val))
+		}
+
+		return slog.Attr{Key: key, Value: slog.GroupValue(attrs...)}
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return slog.Int64(key, n)
+		}
+
+		f, _ := v.Float64()
+
+		return slog.Float64(key, f)
+	case string:
+		return slog.String(key, v)
+	case bool:
+		return slog.Bool(key, v)
+	default:
+		return slog.Any(key, plainJSON(v))
+	}
+}
+
+// plainJSON converts ordered objects within arrays into maps
+func plainJSON(val any) any {
+	switch v := val.(type) {
+	case jsonObject:
+		m := make(map[string]any, len(v))
+
+		for _, field := range v {
+			m[field.key] = plainJSON(field.val)
+		}
+
+		return m
+	case []any:
+		for i, item := range v {
+			v[i] = plainJSON(item)
+		}
+
+		return v
+	default:
+		return v
+	}
+}
+
+type jsonField struct {
+	key string
+	val any
+}
+
+// jsonObject is a JSON object preserving the order of the keys
+type jsonObject []jsonField
+
+func (obj jsonObject) get(key string) (any, bool) {
+	for _, field := range obj {
+		if field.key == key {
+			return field.val, true
+		}
+	}
+
+	return nil, false
+}
+
+func decodeOrdered(data []byte) (any, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	val, err := decodeValue(dec)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if dec.More() {
+		return nil, errors.New("unexpected data after JSON value")
+	}
+
+	return val, nil
+}
+
+func decodeValue(dec *json.Decoder) (any, error) {
+	tok, err := dec.Token()
+
+	if err != nil {
+		return nil, err
+	}
+
+	delim, ok := tok.(json.Delim)
+
+	if !ok {
+		return tok, nil
+	}
+
+	switch delim {
+	case '{':
+		obj := jsonObject{}
+
+		for dec.More() {
+			keyTok, err := dec.Token()
+
+			if err != nil {
+				return nil, err
+			}
+
+			val, err := decodeValue(dec)
+
+			if err != nil {
+				return nil, err
+			}
+
+			obj = append(obj, jsonField{key: keyTok.(string), val: val})
+		}
+
+		if _, err := dec.Token(); err != nil {
+			return nil, err
+		}
+
+		return obj, nil
+	case '[':
+		arr := []any{}
+
+		for dec.More() {
+			val, err := decodeValue(dec)
+
+			if err != nil {
+				return nil, err
+			}
+
+			arr = append(arr, val)
+		}
+
+		if _, err := dec.Token(); err != nil {
+			return nil, err
+		}
+
+		return arr, nil
+	default:
+		return nil, fmt.Errorf("unexpected delimiter %v", delim)
+	}
+}
@@ -0,0 +1,104 @@
+package slogspy
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRecording__Replay(t *testing.T) {
+	captured := &bytes.Buffer{}
+
+	spy := NewSpy(slog.NewTextHandler(&bytes.Buffer{}, nil), WithFraming(NDJSON))
+
+	go spy.Run(context.Background(), nil) // nolint: errcheck
+
+	waitForRunning(t, spy)
+
+	spy.Subscribe(func(msg []byte) { captured.Write(msg) }, WithSubscriptionEvents())
+
+	logger := slog.New(spy).With("service", "api").WithGroup("http")
+	logger.Info("request", "status", 200, "duration", 1.5, "tags", []string{"a", "b"})
+	logger.Debug("details", slog.Group("user", "id", 42, "admin", false))
+
+	if err := spy.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	rec, err := LoadRecording(bytes.NewReader(captured.Bytes()))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if rec.Len() != 2 {
+		t.Fatalf("expected 2 records, got %d", rec.Len())
+	}
+
+	replayed := &bytes.Buffer{}
+
+	if err := rec.Replay(context.Background(), slog.NewJSONHandler(replayed, &slog.HandlerOptions{Level: slog.LevelDebug})); err != nil {
+		t.Fatal(err)
+	}
+
+	var expected []string
+
+	for _, line := range strings.Split(strings.TrimSpace(captured.String()), "\n") {
+		if !strings.Contains(line, `"type":"control"`) {
+			expected = append(expected, line)
+		}
+	}
+
+	if actual := strings.TrimSpace(replayed.String()); actual != strings.Join(expected, "\n") {
+		t.Errorf("expected replayed records to match the captured ones:\n%s\ngot:\n%s", strings.Join(expected, "\n"), actual)
+	}
+
+	// Replaying is deterministic
+	again := &bytes.Buffer{}
+	rec.Replay(context.Background(), slog.NewJSONHandler(again, &slog.HandlerOptions{Level: slog.LevelDebug})) // nolint: errcheck
+
+	if again.String() != replayed.String() {
+		t.Error("expected replays to produce the same output")
+	}
+
+	// Handlers' levels are respected
+	infoOnly := &bytes.Buffer{}
+	rec.Replay(context.Background(), slog.NewJSONHandler(infoOnly, nil)) // nolint: errcheck
+
+	assertBufferContainsNot(t, infoOnly, "details")
+}
+
+func TestLoadRecording__Envelope(t *testing.T) {
+	input := `{"count":2,"records":[{"time":"2024-05-01T12:00:00Z","level":"WARN","msg":"a","n":1},{"raw":"text record"}],"meta":[]}
+{"raw":"level=INFO msg=text"}
+`
+
+	rec, err := LoadRecording(strings.NewReader(input))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	records := rec.Records()
+
+	if len(records) != 3 {
+		t.Fatalf("expected 3 records, got %d", len(records))
+	}
+
+	if r := records[0]; r.Level != slog.LevelWarn || r.Message != "a" || !r.Time.Equal(time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected record: %v", r)
+	}
+
+	if r := records[2]; r.Message != "level=INFO msg=text" {
+		t.Errorf("expected raw record to become a message, got %v", r)
+	}
+}
+
+func TestLoadRecording__Invalid(t *testing.T) {
+	if _, err := LoadRecording(strings.NewReader("{\"msg\":\"ok\"}\nnot json\n")); err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("expected error for line 2, got %v", err)
+	}
+}
@@ -0,0 +1,76 @@
+package slogspy
+
+import (
+	"log/slog"
+	"slices"
+)
+
+// levelRoute delivers the spied records of the level and above to its own output
+type levelRoute struct {
+	level  slog.Level
+	output SpyOutput
+	// sink is the output used by the Run loop (see WithOutputTimeout)
+	sink SpyOutput
+	// batch is only accessed by the Run loop
+	batch sessionBatch
+}
+
+// WithLevelRoute routes the spied records of the level and above to the output (e.g., errors to an incident channel)
+// instead of the main one. Routes have their own buffers flushed independently; when multiple routes match,
+// the one with the highest level wins. Routed records are not delivered to the extra outputs and subscriptions
+// receiving the main batches. Routes are not used in the structured delivery mode. Nil outputs are ignored.
+func WithLevelRoute(level slog.Level, out SpyOutput) SpyHandlerOption {
+	return func(h *SpyHandler) {
+		if out == nil {
+			return
+		}
+
+		h.routes = append(h.routes, &levelRoute{level: level, output: out})
+
+		slices.SortStableFunc(h.routes, func(a, b *levelRoute) int {
+			return int(b.level) - int(a.level)
+		})
+	}
+}
+
+// routeFor returns the route for the record level (nil means the main output)
+func (h *SpyHandler) routeFor(level slog.Level) *levelRoute {
+	for _, route := range h.routes {
+		if level >= route.level {
+			return route
+		}
+	}
+
+	return nil
+}
+
+// routeRecord moves the last printed record (starting at the offset) from the main buffer to the route's one
+func (h *SpyHandler) routeRecord(route *levelRoute, start int, levels int, level slog.Level) {
+	route.batch.add(h.buf.Bytes()[start:], level)
+
+	h.buf.Truncate(start)
+	h.batchLevels = h.batchLevels[:levels]
+
+	if route.batch.buf.Len() > h.maxBufSize {
+		h.flushRoute(route)
+	}
+}
+
+func (h *SpyHandler) flushRoutes() {
+	for _, route := range h.routes {
+		h.flushRoute(route)
+	}
+}
+
+func (h *SpyHandler) flushRoute(route *levelRoute) {
+	if route.batch.buf.Len() == 0 {
+		return
+	}
+
+	msg := h.compressBatch(h.frameBatch(route.batch.buf.Bytes(), route.batch.levels, &route.batch.seq, false))
+
+	route.sink(msg)
+	h.trackFlushed(len(msg))
+
+	route.batch.reset()
+}
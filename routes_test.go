@@ -0,0 +1,101 @@
+package slogspy
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+)
+
+func TestSpy__WithLevelRoute(t *testing.T) {
+	var main, warnings, errors, subscribed bytes.Buffer
+
+	spy := NewSpy(
+		slog.NewTextHandler(io.Discard, nil),
+		WithLevelRoute(slog.LevelWarn, func(msg []byte) { warnings.Write(msg) }),
+		WithLevelRoute(slog.LevelError, func(msg []byte) { errors.Write(msg) }),
+	)
+
+	go spy.Run(context.Background(), func(msg []byte) { // nolint: errcheck
+		main.Write(msg)
+	})
+
+	sub := spy.Subscribe(func(msg []byte) { subscribed.Write(msg) })
+	defer sub.Close()
+
+	logger := slog.New(spy)
+	logger.Debug("debug details")
+	logger.Info("request served")
+	logger.Warn("slow query")
+	logger.Error("payment failed")
+	logger.Log(context.Background(), slog.LevelError+4, "fatal")
+
+	if err := spy.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	assertBufferContains(t, &main, "debug details")
+	assertBufferContains(t, &main, "request served")
+	assertBufferContainsNot(t, &main, "slow query")
+	assertBufferContainsNot(t, &main, "payment failed")
+
+	assertBufferContains(t, &warnings, "slow query")
+	assertBufferContainsNot(t, &warnings, "payment failed")
+
+	assertBufferContains(t, &errors, "payment failed")
+	assertBufferContains(t, &errors, "fatal")
+	assertBufferContainsNot(t, &errors, "request served")
+
+	// Subscriptions receive the main batches
+	assertBufferContains(t, &subscribed, "request served")
+	assertBufferContainsNot(t, &subscribed, "payment failed")
+}
+
+func TestSpy__WithLevelRoute_FlushBySize(t *testing.T) {
+	var errors [][]byte
+
+	spy := NewSpy(
+		slog.NewTextHandler(io.Discard, nil),
+		WithMaxBufSize(1),
+		WithFraming(NDJSONEnvelope),
+		WithLevelRoute(slog.LevelError, func(msg []byte) { errors = append(errors, msg) }),
+	)
+
+	go spy.Run(context.Background(), nil) // nolint: errcheck
+
+	spy.Watch()
+
+	logger := slog.New(spy)
+	logger.Error("first")
+	logger.Error("second")
+
+	if err := spy.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(errors) != 2 {
+		t.Fatalf("expected the route to be flushed by size, got %d batches", len(errors))
+	}
+
+	if !bytes.HasPrefix(errors[0], []byte(`{"count":1,"records":[{`)) {
+		t.Errorf("expected routed batches to be framed, got: %s", errors[0])
+	}
+}
+
+func TestSpy__WithLevelRoute_NilOutput(t *testing.T) {
+	output := &bytes.Buffer{}
+
+	spy := NewSpy(slog.NewTextHandler(io.Discard, nil), WithLevelRoute(slog.LevelError, nil))
+
+	go spy.Run(context.Background(), func(msg []byte) { output.Write(msg) }) // nolint: errcheck
+
+	spy.Watch()
+	slog.New(spy).Error("not routed")
+
+	if err := spy.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	assertBufferContains(t, output, "not routed")
+}
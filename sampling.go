@@ -0,0 +1,115 @@
+package slogspy
+
+import (
+	"log/slog"
+	"math/rand/v2"
+	"time"
+)
+
+// SampledOutKey is the key of the synthetic attribute added to a spied record with the number of preceding
+// records with the same message elided by sampling or rate limiting.
+const SampledOutKey = "sampled_out"
+
+// WithSampling makes the spy deliver only the specified fraction of the matching records, (0, 1].
+// Static sessions and subscriptions with their own filters are not affected.
+func WithSampling(rate float64) SpyHandlerOption {
+	return func(h *SpyHandler) {
+		h.sampler().rate = rate
+	}
+}
+
+// WithRateLimit makes the spy deliver at most n records with the same message per the specified period.
+// Static sessions and subscriptions with their own filters are not affected.
+func WithRateLimit(n int, per time.Duration) SpyHandlerOption {
+	return func(h *SpyHandler) {
+		s := h.sampler()
+		s.limit = n
+		s.per = per
+	}
+}
+
+func (h *SpyHandler) sampler() *recordSampler {
+	if h.sampling == nil {
+		h.sampling = &recordSampler{keys: make(map[string]*sampleKey)}
+	}
+
+	return h.sampling
+}
+
+// recordSampler tracks the sampled out records per message; it's only accessed by the Run loop
+type recordSampler struct {
+	rate  float64
+	limit int
+	per   time.Duration
+
+	keys      map[string]*sampleKey
+	lastSweep time.Time
+}
+
+type sampleKey struct {
+	// windowStart and count track the rate limit window
+	windowStart time.Time
+	count       int
+	// skipped is the number of records sampled out since the last delivered one
+	skipped int
+}
+
+// allow returns true if the record must be delivered along with the number of the sampled out records
+// with the same message preceding it (or the reason to skip the record)
+func (s *recordSampler) allow(r slog.Record) (bool, int, DropReason) {
+	now := time.Now()
+
+	s.sweep(now)
+
+	key, ok := s.keys[r.Message]
+
+	if !ok {
+		key = &sampleKey{windowStart: now}
+		s.keys[r.Message] = key
+	}
+
+	if s.rate > 0 && s.rate < 1 && rand.Float64() >= s.rate {
+		key.skipped++
+		return false, 0, DropSampled
+	}
+
+	if s.limit > 0 {
+		if now.Sub(key.windowStart) >= s.per {
+			key.windowStart = now
+			key.count = 0
+		}
+
+		if key.count >= s.limit {
+			key.skipped++
+			return false, 0, DropRateLimited
+		}
+
+		key.count++
+	}
+
+	skipped := key.skipped
+	key.skipped = 0
+
+	return true, skipped, ""
+}
+
+// sweep removes the keys with no sampled out records and expired rate limit windows
+func (s *recordSampler) sweep(now time.Time) {
+	interval := s.per
+
+	if interval <= 0 {
+		interval = time.Minute
+	}
+
+	if now.Sub(s.lastSweep) < interval {
+		return
+	}
+
+	s.lastSweep = now
+
+	for msg, key := range s.keys {
+		if key.skipped == 0 && now.Sub(key.windowStart) >= s.per {
+			delete(s.keys, msg)
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package slogspy
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSpy__WithRateLimit(t *testing.T) {
+	buf := &bytes.Buffer{}
+
+	spy := NewSpy(slog.NewTextHandler(&bytes.Buffer{}, nil), WithRateLimit(2, 50*time.Millisecond))
+
+	go spy.Run(context.Background(), func(msg []byte) { // nolint: errcheck
+		buf.Write(msg)
+	})
+
+	spy.Watch()
+
+	logger := slog.New(spy)
+
+	for i := 0; i < 5; i++ {
+		logger.Info("noisy")
+	}
+
+	// The limit is tracked per message
+	logger.Info("quiet")
+
+	// Let the Run loop process the records before the window expires
+	waitFor(t, func() bool { return spy.Stats().SampledOut == 3 })
+
+	time.Sleep(60 * time.Millisecond)
+
+	logger.Info("noisy")
+
+	if err := spy.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := strings.Count(buf.String(), `"msg":"noisy"`); n != 3 {
+		t.Errorf("expected 3 noisy records, got %d: %s", n, buf.String())
+	}
+
+	assertBufferContains(t, buf, "quiet")
+	assertBufferContains(t, buf, `"sampled_out":3`)
+}
+
+func TestRecordSampler__Sampling(t *testing.T) {
+	s := (&SpyHandler{}).sampler()
+	s.rate = 0.5
+
+	delivered, skipped := 0, 0
+
+	for i := 0; i < 1000; i++ {
+		ok, n, _ := s.allow(slog.NewRecord(time.Now(), slog.LevelDebug, "debug", 0))
+
+		if ok {
+			delivered++
+			skipped += n
+		}
+	}
+
+	if delivered < 400 || delivered > 600 {
+		t.Errorf("expected about a half of the records to be delivered, got %d", delivered)
+	}
+
+	// All the skipped records are reported except the ones after the last delivered record
+	if skipped+delivered+s.keys["debug"].skipped != 1000 {
+		t.Errorf("expected sampled out records to be reported, got %d delivered and %d skipped", delivered, skipped)
+	}
+}
@@ -0,0 +1,200 @@
+package slogspy
+
+import (
+	"log/slog"
+	"slices"
+	"sync"
+)
+
+const defaultSchemaDiscoveryLimit = 1024
+
+// Schema describes the records consumers could expect, so dashboards can pre-build filter UIs
+// and column layouts before any records arrive.
+type Schema struct {
+	// Attrs are the dot-separated attribute paths (e.g., "http.status")
+	Attrs []string `json:"attrs"`
+	// Groups are the dot-separated group paths (e.g., "http")
+	Groups []string `json:"groups"`
+	// Levels are the level names (the standard ones are always included)
+	Levels []string `json:"levels"`
+	// Metadata is an arbitrary information about the source (e.g., service name and version)
+	Metadata map[string]any `json:"metadata,omitempty"`
+}
+
+// schemaState contains the declared schema and the attributes discovered from the spied records
+type schemaState struct {
+	declared Schema
+	// limit is the max number of discovered keys (attributes, groups and levels); zero disables discovery
+	limit int
+
+	mu     sync.Mutex
+	attrs  map[string]struct{}
+	groups map[string]struct{}
+	levels map[string]struct{}
+}
+
+func (h *SpyHandler) schemaConfig() *schemaState {
+	if h.schema == nil {
+		h.schema = &schemaState{}
+	}
+
+	return h.schema
+}
+
+// WithSchema declares the schema included into the dictionary frame (see WithSubscriptionSchema).
+func WithSchema(schema Schema) SpyHandlerOption {
+	return func(h *SpyHandler) {
+		h.schemaConfig().declared = schema
+	}
+}
+
+// WithSchemaDiscovery makes the spy add attributes, groups and levels of the spied records to the schema
+// (up to the limit of distinct keys; zero means the default limit of 1024).
+func WithSchemaDiscovery(limit int) SpyHandlerOption {
+	return func(h *SpyHandler) {
+		if limit <= 0 {
+			limit = defaultSchemaDiscoveryLimit
+		}
+
+		h.schemaConfig().limit = limit
+	}
+}
+
+// WithSubscriptionSchema makes the subscription receive the schema dictionary as the first control message:
+//
+//	{"type":"control","event":"schema","attrs":["http.status"],"groups":["http"],"levels":["DEBUG",...],"metadata":{...}}
+func WithSubscriptionSchema() SubscriptionOption {
+	return func(s *Subscription) {
+		s.schema = true
+	}
+}
+
+// Schema returns the declared schema merged with the discovered attributes, groups and levels.
+func (h *SpyHandler) Schema() Schema {
+	levels := []string{
+		slog.LevelDebug.String(),
+		slog.LevelInfo.String(),
+		slog.LevelWarn.String(),
+		slog.LevelError.String(),
+	}
+
+	if h.schema == nil {
+		return Schema{Attrs: []string{}, Groups: []string{}, Levels: levels}
+	}
+
+	s := h.schema
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return Schema{
+		Attrs:    mergeKeys(s.declared.Attrs, s.attrs),
+		Groups:   mergeKeys(s.declared.Groups, s.groups),
+		Levels:   mergeKeys(append(levels, s.declared.Levels...), s.levels),
+		Metadata: s.declared.Metadata,
+	}
+}
+
+// mergeKeys returns the declared keys followed by the sorted discovered ones (without duplicates)
+func mergeKeys(declared []string, discovered map[string]struct{}) []string {
+	keys := make([]string, 0, len(declared)+len(discovered))
+	seen := make(map[string]struct{}, cap(keys))
+
+	for _, key := range declared {
+		if _, ok := seen[key]; !ok {
+			seen[key] = struct{}{}
+			keys = append(keys, key)
+		}
+	}
+
+	extra := make([]string, 0, len(discovered))
+
+	for key := range discovered {
+		if _, ok := seen[key]; !ok {
+			extra = append(extra, key)
+		}
+	}
+
+	slices.Sort(extra)
+
+	return append(keys, extra...)
+}
+
+// observe adds the keys of the (resolved) record to the discovered schema; it's called by the Run loop
+func (s *schemaState) observe(record slog.Record) {
+	if s.limit == 0 {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.attrs == nil {
+		s.attrs = make(map[string]struct{})
+		s.groups = make(map[string]struct{})
+		s.levels = make(map[string]struct{})
+	}
+
+	s.add(s.levels, record.Level.String())
+
+	record.Attrs(func(a slog.Attr) bool {
+		return s.observeAttr("", a)
+	})
+}
+
+func (s *schemaState) observeAttr(prefix string, a slog.Attr) bool {
+	val := a.Value.Resolve()
+
+	path := prefix
+
+	if a.Key != "" {
+		if prefix != "" {
+			path = prefix + "." + a.Key
+		} else {
+			path = a.Key
+		}
+	}
+
+	if val.Kind() != slog.KindGroup {
+		return a.Key == "" || s.add(s.attrs, path)
+	}
+
+	if path != "" && !s.add(s.groups, path) {
+		return false
+	}
+
+	for _, nested := range val.Group() {
+		if !s.observeAttr(path, nested) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// add adds the key to the set; it returns false if the discovery limit is reached
+func (s *schemaState) add(set map[string]struct{}, key string) bool {
+	if _, ok := set[key]; ok {
+		return true
+	}
+
+	if len(s.attrs)+len(s.groups)+len(s.levels) >= s.limit {
+		return false
+	}
+
+	set[key] = struct{}{}
+
+	return true
+}
+
+func (s *Subscription) sendSchema() {
+	schema := s.handler.Schema()
+
+	data := map[string]any{"attrs": schema.Attrs, "groups": schema.Groups, "levels": schema.Levels}
+
+	if schema.Metadata != nil {
+		data["metadata"] = schema.Metadata
+	}
+
+	s.sendControl(EventSchema, data)
+}
@@ -0,0 +1,103 @@
+package slogspy
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"slices"
+	"sync"
+	"testing"
+)
+
+func TestSpy__Schema(t *testing.T) {
+	spy := NewSpy(
+		slog.NewTextHandler(io.Discard, nil),
+		WithSchema(Schema{Attrs: []string{"user_id"}, Levels: []string{"TRACE"}, Metadata: map[string]any{"service": "api"}}),
+		WithSchemaDiscovery(0),
+	)
+
+	go spy.Run(context.Background(), func([]byte) {}) // nolint: errcheck
+
+	spy.Watch()
+
+	logger := slog.New(spy).WithGroup("http")
+	logger.Info("request", "status", 200, slog.Group("req", "path", "/"))
+	slog.New(spy).Log(context.Background(), slog.LevelInfo+2, "custom", "user_id", 42, "tenant", "acme")
+
+	if err := spy.handler.requestFlush(context.Background(), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		mu       sync.Mutex
+		messages [][]byte
+	)
+
+	sub := spy.handler.Subscribe(func(msg []byte) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		messages = append(messages, msg)
+	}, WithSubscriptionSchema(), WithSubscriptionEvents())
+	defer sub.Close()
+
+	spy.Shutdown(context.Background()) // nolint: errcheck
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if len(messages) == 0 {
+		t.Fatal("expected the schema frame")
+	}
+
+	var frame struct {
+		Event    string         `json:"event"`
+		Attrs    []string       `json:"attrs"`
+		Groups   []string       `json:"groups"`
+		Levels   []string       `json:"levels"`
+		Metadata map[string]any `json:"metadata"`
+	}
+
+	if err := json.Unmarshal(messages[0], &frame); err != nil {
+		t.Fatal(err)
+	}
+
+	if frame.Event != EventSchema {
+		t.Fatalf("expected the schema frame first, got: %s", messages[0])
+	}
+
+	if expected := []string{"user_id", "http.req.path", "http.status", "tenant"}; !slices.Equal(frame.Attrs, expected) {
+		t.Errorf("expected attrs %v, got %v", expected, frame.Attrs)
+	}
+
+	if expected := []string{"http", "http.req"}; !slices.Equal(frame.Groups, expected) {
+		t.Errorf("expected groups %v, got %v", expected, frame.Groups)
+	}
+
+	if expected := []string{"DEBUG", "INFO", "WARN", "ERROR", "TRACE", "INFO+2"}; !slices.Equal(frame.Levels, expected) {
+		t.Errorf("expected levels %v, got %v", expected, frame.Levels)
+	}
+
+	if frame.Metadata["service"] != "api" {
+		t.Errorf("expected metadata to be included, got %v", frame.Metadata)
+	}
+}
+
+func TestSpy__SchemaDiscoveryLimit(t *testing.T) {
+	spy := NewSpy(slog.NewTextHandler(io.Discard, nil), WithSchemaDiscovery(3))
+
+	go spy.Run(context.Background(), func([]byte) {}) // nolint: errcheck
+
+	spy.Watch()
+	slog.New(spy).Info("test", "a", 1, "b", 2, "c", 3, "d", 4)
+
+	if err := spy.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// The level counts towards the limit
+	if schema := spy.Schema(); !slices.Equal(schema.Attrs, []string{"a", "b"}) {
+		t.Errorf("expected discovery to stop at the limit, got %v", schema.Attrs)
+	}
+}
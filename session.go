@@ -0,0 +1,254 @@
+package slogspy
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+)
+
+// SessionConfig describes an always-on session started automatically when the spy starts
+// (e.g., "forward WARN+ records from the payments component to the audit file").
+type SessionConfig struct {
+	Name string
+	// Level is the minimum level of the session records (all levels by default)
+	Level slog.Leveler
+	// Filter selects the session records; sessions are not affected by the spy's filter
+	Filter Filter
+	// Sampling is the fraction of matching records to deliver, (0, 1]; zero means all records
+	Sampling float64
+	// Printer builds a handler to format the session records (the spy's printer is used by default)
+	Printer func(w io.Writer) slog.Handler
+	Output  SpyOutput
+	// Subscription options (quotas, groups, etc.)
+	Options []SubscriptionOption
+}
+
+// WithStaticSession declares a session which is started every time the Run loop starts.
+// Static sessions don't activate the spy's output (as keyed watchers with their own outputs).
+func WithStaticSession(cfg SessionConfig) SpyHandlerOption {
+	return func(h *SpyHandler) {
+		h.staticSessions = append(h.staticSessions, cfg)
+	}
+}
+
+// WithSubscriptionFilter makes the subscription receive only the records matching the filter.
+// Such subscriptions get their own batches and are not affected by the spy's filter.
+func WithSubscriptionFilter(f Filter) SubscriptionOption {
+	return func(s *Subscription) {
+		s.filter = f
+	}
+}
+
+// WithSubscriptionSampling makes the subscription receive only the specified fraction of records, (0, 1].
+// Unless the subscription has its own filter, the spy's filter is applied first.
+func WithSubscriptionSampling(rate float64) SubscriptionOption {
+	return func(s *Subscription) {
+		s.sampling = rate
+	}
+}
+
+// WithSubscriptionPrinter makes the subscription use its own handler to format records
+// (e.g., a text handler for a terminal and JSON for a web UI).
+func WithSubscriptionPrinter(printerBuilder func(w io.Writer) slog.Handler) SubscriptionOption {
+	return func(s *Subscription) {
+		s.printer = printerBuilder
+	}
+}
+
+// StaticSession returns the running static session subscription by its name.
+func (h *SpyHandler) StaticSession(name string) (*Subscription, bool) {
+	h.state.mu.Lock()
+	defer h.state.mu.Unlock()
+
+	sub, ok := h.state.sessions[name]
+
+	return sub, ok
+}
+
+// startSessions subscribes the static sessions which are not running yet;
+// must be called with the state lock held
+func (h *SpyHandler) startSessions() {
+	if len(h.staticSessions) == 0 {
+		return
+	}
+
+	if h.state.sessions == nil {
+		h.state.sessions = make(map[string]*Subscription, len(h.staticSessions))
+	}
+
+	for _, cfg := range h.staticSessions {
+		if sub, ok := h.state.sessions[cfg.Name]; ok && !sub.Closed() {
+			continue
+		}
+
+		filter := cfg.Filter
+
+		if cfg.Level != nil {
+			filter = levelFilter(cfg.Level, filter)
+		}
+
+		// Static sessions are independent of the spy's filter
+		if filter == nil {
+			filter = func(*RecordView) bool { return true }
+		}
+
+		opts := []SubscriptionOption{WithSubscriptionFilter(filter), WithSubscriptionSampling(cfg.Sampling)}
+
+		if cfg.Printer != nil {
+			opts = append(opts, WithSubscriptionPrinter(cfg.Printer))
+		}
+
+		opts = append(opts, cfg.Options...)
+		opts = append(opts, withStatic())
+
+		h.state.sessions[cfg.Name] = h.Subscribe(cfg.Output, opts...)
+	}
+}
+
+// withStatic marks the subscription as a static session, so it isn't counted as a watcher of the spy's output
+func withStatic() SubscriptionOption {
+	return func(s *Subscription) {
+		s.static = true
+	}
+}
+
+func levelFilter(level slog.Leveler, f Filter) Filter {
+	return func(r *RecordView) bool {
+		if r.Record.Level < level.Level() {
+			return false
+		}
+
+		return f == nil || f(r)
+	}
+}
+
+// sessionBatch accumulates formatted records for a subscription with its own filter or printer;
+// it's only accessed by the Run loop
+type sessionBatch struct {
+	buf     bytes.Buffer
+	levels  []slog.Level
+	records int
+	printer slog.Handler
+	// seq is the sequence number of the previous batch (see WithBatchMetadata)
+	seq int64
+}
+
+func (b *sessionBatch) add(record []byte, level slog.Level) {
+	b.buf.Write(record)
+	b.levels = append(b.levels, level)
+	b.records++
+}
+
+func (b *sessionBatch) reset() {
+	b.buf.Reset()
+	b.levels = b.levels[:0]
+	b.records = 0
+}
+
+// matchSessions returns the subscriptions with their own batches matching the entry;
+// subscriptions without their own filter follow the spy's one
+func (h *SpyHandler) matchSessions(entry *Entry, spied bool) []*Subscription {
+	sessions := h.subs.sessionsList()
+
+	if len(sessions) == 0 {
+		return nil
+	}
+
+	matched := h.matchedSessions[:0]
+
+	var view *RecordView
+
+	for _, sub := range sessions {
+		if sub.requestScoped {
+			if entry.capture == sub {
+				matched = append(matched, sub)
+			}
+			continue
+		}
+
+		if entry.annotation {
+			matched = append(matched, sub)
+			continue
+		}
+
+		if sub.filter == nil && !spied {
+			continue
+		}
+
+		if sub.filter != nil {
+			if view == nil {
+				view = &RecordView{Record: entry.record, groups: entry.groups, frames: entry.frames}
+			}
+
+			if !sub.filter(view) {
+				continue
+			}
+		}
+
+		if sub.sampling > 0 && sub.sampling < 1 && rand.Float64() >= sub.sampling {
+			continue
+		}
+
+		matched = append(matched, sub)
+	}
+
+	h.matchedSessions = matched
+
+	return matched
+}
+
+// sharesPrinter returns true if any of the subscriptions uses the spy's printer
+func sharesPrinter(sessions []*Subscription) bool {
+	for _, sub := range sessions {
+		if sub.batch.printer == nil {
+			return true
+		}
+	}
+
+	return false
+}
+
+// printSessions adds the record to the subscriptions batches; the shared record is the one formatted by the spy's printer
+func (h *SpyHandler) printSessions(sessions []*Subscription, record slog.Record, shared []byte) {
+	for _, sub := range sessions {
+		if sub.batch.printer == nil {
+			sub.batch.add(shared, record.Level)
+		} else {
+			start := sub.batch.buf.Len()
+
+			err := sub.batch.printer.Handle(context.Background(), record)
+
+			if err != nil && h.errorHandler != nil {
+				h.errorHandler(err)
+			}
+
+			h.frameBuffer(&sub.batch.buf, start)
+			sub.batch.levels = append(sub.batch.levels, record.Level)
+			sub.batch.records++
+		}
+
+		if sub.batch.buf.Len() > h.maxBufSize {
+			h.flushSession(sub)
+		}
+	}
+}
+
+func (h *SpyHandler) flushSessions() {
+	for _, sub := range h.subs.sessionsList() {
+		h.flushSession(sub)
+	}
+}
+
+func (h *SpyHandler) flushSession(sub *Subscription) {
+	if sub.batch.buf.Len() == 0 {
+		return
+	}
+
+	msg := h.compressBatch(h.frameBatch(sub.batch.buf.Bytes(), sub.batch.levels, &sub.batch.seq, false))
+
+	sub.deliver(msg, sub.batch.records)
+
+	sub.batch.reset()
+}
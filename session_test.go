@@ -0,0 +1,144 @@
+package slogspy
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+)
+
+func TestSpy__StaticSession(t *testing.T) {
+	audit := &bytes.Buffer{}
+	output := &bytes.Buffer{}
+
+	spy := NewSpy(
+		slog.NewTextHandler(&bytes.Buffer{}, nil),
+		WithFilter(WhereAttr("component", "==", "api")),
+		WithStaticSession(SessionConfig{
+			Name:   "audit",
+			Level:  slog.LevelWarn,
+			Filter: WhereAttr("component", "==", "payments"),
+			Output: func(msg []byte) { audit.Write(msg) },
+		}),
+	)
+
+	go spy.Run(context.Background(), func(msg []byte) { output.Write(msg) }) // nolint: errcheck
+
+	waitForRunning(t, spy)
+
+	sub, ok := spy.StaticSession("audit")
+
+	if !ok {
+		t.Fatal("expected static session to be started")
+	}
+
+	payments := slog.New(spy).With("component", "payments")
+	payments.Info("charge")
+	payments.Warn("declined")
+
+	slog.New(spy).With("component", "api").Warn("slow")
+	spy.handler.requestFlush(context.Background(), nil) // nolint: errcheck
+
+	spy.Watch()
+	slog.New(spy).With("component", "api").Warn("watched")
+
+	if err := spy.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	assertBufferContains(t, audit, "declined")
+	assertBufferContainsNot(t, audit, "charge")
+	assertBufferContainsNot(t, audit, "slow")
+
+	// Static sessions don't activate the spy's output
+	assertBufferContainsNot(t, output, "slow")
+	assertBufferContainsNot(t, output, "declined")
+	assertBufferContains(t, output, "watched")
+
+	if n := sub.records.Load(); n != 1 {
+		t.Errorf("expected 1 audit record, got %d", n)
+	}
+
+	if !sub.Closed() {
+		t.Error("expected static session to be closed on shutdown")
+	}
+}
+
+func TestSpy__StaticSession_Restart(t *testing.T) {
+	spy := NewSpy(
+		slog.NewTextHandler(&bytes.Buffer{}, nil),
+		WithStaticSession(SessionConfig{Name: "all", Output: func(msg []byte) {}}),
+	)
+
+	for i := 0; i < 2; i++ {
+		go spy.Run(context.Background(), nil) // nolint: errcheck
+
+		waitForRunning(t, spy)
+
+		if sub, ok := spy.StaticSession("all"); !ok || sub.Closed() {
+			t.Fatalf("expected static session to be running (run %d)", i)
+		}
+
+		if err := spy.Shutdown(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestSubscription__Sampling(t *testing.T) {
+	spy := NewSpy(slog.NewTextHandler(&bytes.Buffer{}, nil))
+
+	go spy.Run(context.Background(), nil) // nolint: errcheck
+
+	waitForRunning(t, spy)
+
+	sampled := spy.Subscribe(func(msg []byte) {}, WithSubscriptionSampling(0.5))
+	all := spy.Subscribe(func(msg []byte) {}, WithSubscriptionFilter(func(r *RecordView) bool { return true }))
+
+	logger := slog.New(spy)
+
+	for i := 0; i < 1000; i++ {
+		logger.Info("sample")
+	}
+
+	if err := spy.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := all.records.Load(); n != 1000 {
+		t.Errorf("expected 1000 records, got %d", n)
+	}
+
+	if n := sampled.records.Load(); n < 300 || n > 700 {
+		t.Errorf("expected about 500 sampled records, got %d", n)
+	}
+}
+
+func TestSubscription__Printer(t *testing.T) {
+	json := &bytes.Buffer{}
+	text := &bytes.Buffer{}
+
+	spy := NewSpy(slog.NewTextHandler(&bytes.Buffer{}, nil), WithFilter(WhereAttr("http.visible", "==", true)))
+
+	go spy.Run(context.Background(), nil) // nolint: errcheck
+
+	waitForRunning(t, spy)
+
+	spy.Subscribe(func(msg []byte) { json.Write(msg) })
+	spy.Subscribe(func(msg []byte) { text.Write(msg) }, WithSubscriptionPrinter(func(w io.Writer) slog.Handler {
+		return slog.NewTextHandler(w, &slog.HandlerOptions{Level: slog.LevelDebug})
+	}))
+
+	logger := slog.New(spy).With("service", "api").WithGroup("http")
+	logger.Info("request", "status", 200, "visible", true)
+	logger.Info("hidden", "visible", false)
+
+	if err := spy.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	assertBufferContains(t, json, `"service":"api","http":{"status":200`)
+	assertBufferContains(t, text, "service=api http.status=200")
+	assertBufferContainsNot(t, text, "hidden")
+}
@@ -0,0 +1,86 @@
+package slogspy
+
+import (
+	"io"
+	"log/slog"
+	"runtime"
+	"sync"
+)
+
+// WithSource makes the spy's printer include the source location (file, line and function) of the records
+// regardless of the parent handler settings. Custom printers receive it as the "source" attribute,
+// so they must not set AddSource themselves. Records logged with the IgnorePC flag have no source.
+func WithSource() SpyHandlerOption {
+	return func(h *SpyHandler) {
+		h.addSource = true
+	}
+}
+
+// withSource returns a copy of the record with the source attribute resolved from the record's PC
+func withSource(r slog.Record) slog.Record {
+	if r.PC == 0 {
+		return r
+	}
+
+	frame, _ := runtime.CallersFrames([]uintptr{r.PC}).Next()
+
+	r = r.Clone()
+	r.AddAttrs(slog.Any(slog.SourceKey, &slog.Source{Function: frame.Function, File: frame.File, Line: frame.Line}))
+
+	return r
+}
+
+// WithSourceToggle sets a function to enable or disable the source location (PC) capture at runtime.
+// It's called with false when the spy is created (true if WithSource is set, so the capture stays enabled)
+// and then every time the first subscription requesting source info (see WithSubscriptionSource) starts
+// or the last one ends. Use it to switch the IgnorePC flag, so the callers' stack is only inspected while someone needs it.
+func WithSourceToggle(fn func(enabled bool)) SpyHandlerOption {
+	return func(h *SpyHandler) {
+		h.source = &sourceToggle{fn: fn}
+	}
+}
+
+// WithSubscriptionSource makes the subscription receive records with source locations
+// (the JSON printer with AddSource is used unless a custom printer is provided).
+func WithSubscriptionSource() SubscriptionOption {
+	return func(s *Subscription) {
+		s.source = true
+
+		if s.printer == nil {
+			s.printer = sourcePrinter
+		}
+	}
+}
+
+func sourcePrinter(w io.Writer) slog.Handler {
+	return slog.NewJSONHandler(w, &slog.HandlerOptions{Level: slog.LevelDebug, AddSource: true})
+}
+
+// sourceToggle tracks the number of subscriptions requesting source info
+type sourceToggle struct {
+	mu sync.Mutex
+	n  int
+	fn func(enabled bool)
+}
+
+func (t *sourceToggle) acquire() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.n++
+
+	if t.n == 1 {
+		t.fn(true)
+	}
+}
+
+func (t *sourceToggle) release() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.n--
+
+	if t.n == 0 {
+		t.fn(false)
+	}
+}
@@ -0,0 +1,103 @@
+package slogspy
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"sync"
+	"testing"
+)
+
+func TestSpy__SourceToggle(t *testing.T) {
+	defer func(v bool) { IgnorePC = v }(IgnorePC)
+
+	var toggles []bool
+
+	spy := NewSpy(slog.NewTextHandler(&bytes.Buffer{}, nil), WithSourceToggle(func(enabled bool) {
+		toggles = append(toggles, enabled)
+		IgnorePC = !enabled
+	}))
+
+	go spy.Run(context.Background(), nil) // nolint: errcheck
+
+	var mu sync.Mutex
+	buf := &bytes.Buffer{}
+
+	sub := spy.Subscribe(func(msg []byte) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		buf.Write(msg)
+	}, WithSubscriptionSource())
+
+	other := spy.Subscribe(nil, WithSubscriptionSource())
+
+	slog.New(spy).Info("with-source")
+
+	if err := sub.Flush(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	sub.Close()
+	other.Close()
+
+	if err := spy.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(toggles) != 3 || toggles[0] || !toggles[1] || toggles[2] {
+		t.Errorf("expected source capture to be disabled, enabled and disabled again, got %v", toggles)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	assertBufferContains(t, buf, `"source":{`)
+	assertBufferContains(t, buf, "source_test.go")
+}
+
+func TestSpy__WithSource(t *testing.T) {
+	defer func(v bool) { IgnorePC = v }(IgnorePC)
+
+	var (
+		mu      sync.Mutex
+		buf     bytes.Buffer
+		toggles []bool
+	)
+
+	main := &bytes.Buffer{}
+
+	spy := NewSpy(slog.NewTextHandler(main, nil), WithSource(), WithSourceToggle(func(enabled bool) {
+		toggles = append(toggles, enabled)
+		IgnorePC = !enabled
+	}))
+
+	go spy.Run(context.Background(), func(msg []byte) { // nolint: errcheck
+		mu.Lock()
+		defer mu.Unlock()
+
+		buf.Write(msg)
+	})
+
+	spy.Watch()
+
+	sub := spy.Subscribe(nil, WithSubscriptionSource())
+	sub.Close()
+
+	slog.New(spy).Info("where am I")
+
+	if err := spy.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(toggles) != 1 || !toggles[0] {
+		t.Errorf("expected source capture to stay enabled, got %v", toggles)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	assertBufferContains(t, &buf, `"source":{"function":"github.com/palkan/slog-spy.TestSpy__WithSource"`)
+	assertBufferContains(t, &buf, "source_test.go")
+	assertBufferContainsNot(t, main, "source")
+}
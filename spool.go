@@ -0,0 +1,299 @@
+package slogspy
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// defaultSpoolBuffer is the number of batches queued in memory before spilling to disk
+const defaultSpoolBuffer = 16
+
+type spoolConfig struct {
+	dir      string
+	maxBytes int64
+}
+
+// WithSpool makes the spy deliver batches to the output asynchronously and spill them to a bounded disk spool
+// when the output can't keep up (instead of blocking the Run loop and dropping records). Spooled batches are replayed
+// to the output in order once it catches up. The spool is kept in a temporary directory within dir (the system
+// temporary directory if empty) which is removed when the Run loop exits. When the spool exceeds maxBytes,
+// the oldest segments are dropped. Subscriptions are not affected.
+func WithSpool(dir string, maxBytes int64) SpyHandlerOption {
+	return func(h *SpyHandler) {
+		h.spoolConfig = &spoolConfig{dir: dir, maxBytes: maxBytes}
+	}
+}
+
+type spoolSegment struct {
+	path    string
+	size    int64
+	batches int64
+}
+
+type spool struct {
+	dir         string
+	maxBytes    int64
+	segmentSize int64
+
+	out          SpyOutput
+	errorHandler func(err error)
+	stats        *spyStats
+
+	ch     chan []byte
+	notify chan struct{}
+	stop   chan struct{}
+	done   chan struct{}
+
+	mu sync.Mutex
+	// spooling is set while there are batches on disk; all the new batches are spooled to keep the order
+	spooling bool
+	segments []*spoolSegment
+	active   *os.File
+	seq      int
+	size     int64
+}
+
+func newSpool(cfg *spoolConfig, out SpyOutput, h *SpyHandler) (*spool, error) {
+	dir, err := os.MkdirTemp(cfg.dir, "slogspy-spool-")
+
+	if err != nil {
+		return nil, err
+	}
+
+	s := &spool{
+		dir:          dir,
+		maxBytes:     cfg.maxBytes,
+		segmentSize:  max(cfg.maxBytes/4, 1),
+		out:          out,
+		errorHandler: h.errorHandler,
+		stats:        h.stats,
+		ch:           make(chan []byte, defaultSpoolBuffer),
+		notify:       make(chan struct{}, 1),
+		stop:         make(chan struct{}),
+		done:         make(chan struct{}),
+	}
+
+	go s.deliver()
+
+	return s, nil
+}
+
+// push queues the batch for delivery; it's called by the Run loop, and the message is reused after the call
+func (s *spool) push(msg []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.spooling {
+		select {
+		case s.ch <- bytes.Clone(msg):
+			return
+		default:
+			s.spooling = true
+		}
+	}
+
+	if err := s.write(msg); err != nil {
+		s.dropBatches(1)
+		s.handleError(err)
+	}
+
+	select {
+	case s.notify <- struct{}{}:
+	default:
+	}
+}
+
+// write appends the batch to the active segment (rotating segments and dropping the oldest ones if needed);
+// must be called with the lock held
+func (s *spool) write(msg []byte) error {
+	if s.active == nil {
+		s.seq++
+
+		f, err := os.Create(filepath.Join(s.dir, fmt.Sprintf("%08d.spool", s.seq)))
+
+		if err != nil {
+			return err
+		}
+
+		s.active = f
+		s.segments = append(s.segments, &spoolSegment{path: f.Name()})
+	}
+
+	seg := s.segments[len(s.segments)-1]
+
+	var header [4]byte
+	binary.BigEndian.PutUint32(header[:], uint32(len(msg)))
+
+	if _, err := s.active.Write(append(header[:], msg...)); err != nil {
+		return err
+	}
+
+	n := int64(len(msg) + len(header))
+
+	seg.size += n
+	seg.batches++
+	s.size += n
+	s.stats.spooled.Add(n)
+
+	if seg.size >= s.segmentSize {
+		s.rotate()
+	}
+
+	for s.size > s.maxBytes && len(s.segments) > 1 {
+		oldest := s.segments[0]
+		s.segments = s.segments[1:]
+		s.size -= oldest.size
+		s.stats.spooled.Add(-oldest.size)
+		s.dropBatches(oldest.batches)
+
+		os.Remove(oldest.path) // nolint: errcheck
+	}
+
+	return nil
+}
+
+// dropBatches accounts the batches lost by the spool (they're counted as sink failures, too)
+func (s *spool) dropBatches(n int64) {
+	s.stats.spoolDropped.Add(n)
+	s.stats.trackDrops(DropSinkFailure, n)
+}
+
+// rotate closes the active segment; must be called with the lock held
+func (s *spool) rotate() {
+	if s.active == nil {
+		return
+	}
+
+	if err := s.active.Close(); err != nil {
+		s.handleError(err)
+	}
+
+	s.active = nil
+}
+
+// next takes the oldest segment to replay; it returns nil (and stops spooling) if there are no segments left.
+// The in-memory queue is empty while spooling, so the spooled batches are always replayed in order.
+func (s *spool) next() *spoolSegment {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.segments) == 0 {
+		s.spooling = false
+		return nil
+	}
+
+	if len(s.segments) == 1 {
+		s.rotate()
+	}
+
+	seg := s.segments[0]
+	s.segments = s.segments[1:]
+	s.size -= seg.size
+	s.stats.spooled.Add(-seg.size)
+
+	return seg
+}
+
+func (s *spool) deliver() {
+	defer close(s.done)
+
+	for {
+		s.deliverQueued()
+
+		if seg := s.next(); seg != nil {
+			s.replay(seg)
+			continue
+		}
+
+		select {
+		case msg := <-s.ch:
+			s.out(msg)
+		case <-s.notify:
+		case <-s.stop:
+			s.flush()
+			return
+		}
+	}
+}
+
+func (s *spool) deliverQueued() {
+	for {
+		select {
+		case msg := <-s.ch:
+			s.out(msg)
+		default:
+			return
+		}
+	}
+}
+
+// flush delivers all the queued and spooled batches
+func (s *spool) flush() {
+	for {
+		s.deliverQueued()
+
+		seg := s.next()
+
+		if seg == nil {
+			return
+		}
+
+		s.replay(seg)
+	}
+}
+
+func (s *spool) replay(seg *spoolSegment) {
+	defer os.Remove(seg.path) // nolint: errcheck
+
+	f, err := os.Open(seg.path)
+
+	if err != nil {
+		s.dropBatches(seg.batches)
+		s.handleError(err)
+		return
+	}
+
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+
+	var header [4]byte
+
+	for {
+		if _, err := io.ReadFull(r, header[:]); err != nil {
+			if err != io.EOF {
+				s.handleError(err)
+			}
+			return
+		}
+
+		msg := make([]byte, binary.BigEndian.Uint32(header[:]))
+
+		if _, err := io.ReadFull(r, msg); err != nil {
+			s.handleError(err)
+			return
+		}
+
+		s.out(msg)
+	}
+}
+
+// close delivers the remaining batches and removes the spool directory
+func (s *spool) close() {
+	close(s.stop)
+	<-s.done
+
+	os.RemoveAll(s.dir) // nolint: errcheck
+}
+
+func (s *spool) handleError(err error) {
+	if s.errorHandler != nil {
+		s.errorHandler(err)
+	}
+}
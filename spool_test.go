@@ -0,0 +1,125 @@
+package slogspy
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"testing"
+)
+
+func TestSpy__WithSpool(t *testing.T) {
+	dir := t.TempDir()
+
+	var (
+		mu   sync.Mutex
+		msgs []string
+	)
+
+	release := make(chan struct{})
+
+	spy := NewSpy(slog.NewTextHandler(&bytes.Buffer{}, nil), WithSpool(dir, 1024*1024), WithMaxBufSize(1))
+
+	go spy.Run(context.Background(), func(msg []byte) { // nolint: errcheck
+		<-release
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		msgs = append(msgs, string(msg))
+	})
+
+	spy.Watch()
+
+	logger := slog.New(spy)
+
+	// Every record is flushed right away (the buffer size limit is exceeded), the output is blocked,
+	// so the batches beyond the in-memory queue are spooled
+	for i := 0; i < 100; i++ {
+		logger.Info(fmt.Sprintf("record-%03d", i))
+	}
+
+	waitFor(t, func() bool { return spy.Stats().Spooled > 0 })
+
+	close(release)
+
+	if err := spy.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if len(msgs) != 100 {
+		t.Fatalf("expected all batches to be delivered, got %d", len(msgs))
+	}
+
+	for i, msg := range msgs {
+		if !bytes.Contains([]byte(msg), []byte(fmt.Sprintf("record-%03d", i))) {
+			t.Fatalf("expected batches to be delivered in order, got %s at %d", msg, i)
+		}
+	}
+
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("expected spool directory to be removed, got %d entries", len(entries))
+	}
+
+	if spy.Stats().Spooled != 0 {
+		t.Errorf("expected spool to be empty, got %d bytes", spy.Stats().Spooled)
+	}
+}
+
+func TestSpool__MaxBytes(t *testing.T) {
+	var delivered []string
+
+	block := make(chan struct{})
+
+	s, err := newSpool(&spoolConfig{dir: t.TempDir(), maxBytes: 400}, func(msg []byte) {
+		<-block
+		delivered = append(delivered, string(msg))
+	}, NewSpyHandler())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The first batch is taken by the delivery goroutine and the next ones fill the queue
+	s.push([]byte("queued"))
+
+	waitFor(t, func() bool { return len(s.ch) == 0 })
+
+	for i := 0; i < defaultSpoolBuffer; i++ {
+		s.push([]byte("queued"))
+	}
+
+	for i := 0; i < 20; i++ {
+		s.push(bytes.Repeat([]byte{byte('a' + i)}, 96))
+	}
+
+	if s.stats.spoolDropped.Load() == 0 {
+		t.Errorf("expected the oldest segments to be dropped")
+	}
+
+	if drops := s.stats.drops[DropSinkFailure.index()].Load(); drops != s.stats.spoolDropped.Load() {
+		t.Errorf("expected spool drops to be counted as sink failures, got %d", drops)
+	}
+
+	if s.stats.spooled.Load() > 400 {
+		t.Errorf("expected spool to be bounded, got %d bytes", s.stats.spooled.Load())
+	}
+
+	close(block)
+	s.close()
+
+	last := delivered[len(delivered)-1]
+
+	if last != string(bytes.Repeat([]byte{'t'}, 96)) {
+		t.Errorf("expected the newest batches to be kept, got %s", last)
+	}
+
+	if int64(len(delivered))+s.stats.spoolDropped.Load() != int64(defaultSpoolBuffer+1+20) {
+		t.Errorf("expected all batches to be either delivered or dropped, got %d delivered", len(delivered))
+	}
+}
@@ -0,0 +1,228 @@
+package slogspy
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// Spy is a slog.Handler wrapping the parent (main) handler and streaming records to the spy handler when it's active.
+type Spy struct {
+	// root is the parent handler shared by all the derived spies (see SetParent)
+	root    *atomic.Pointer[parentVersion]
+	parent  atomic.Pointer[derivedParent]
+	handler *SpyHandler
+}
+
+var _ slog.Handler = (*Spy)(nil)
+
+// NewSpy creates a new Spy wrapping the parent handler; options configure the underlying SpyHandler.
+func NewSpy(parent slog.Handler, opts ...SpyHandlerOption) *Spy {
+	handler := NewSpyHandler(opts...)
+
+	return &Spy{
+		root:    newRootParent(parent),
+		handler: handler,
+	}
+}
+
+func (s *Spy) Enabled(ctx context.Context, level slog.Level) bool {
+	if !s.handler.Enabled(ctx, level) {
+		return s.parentHandler().Enabled(ctx, level)
+	}
+
+	return true
+}
+
+func (s *Spy) Handle(ctx context.Context, r slog.Record) (err error) {
+	if s.handler.Enabled(ctx, r.Level) {
+		s.handler.Handle(ctx, r) // nolint: errcheck
+	}
+
+	if parent := s.parentHandler(); parent.Enabled(ctx, r.Level) {
+		err = parent.Handle(ctx, r)
+	}
+
+	return
+}
+
+func (s *Spy) WithAttrs(attrs []slog.Attr) slog.Handler {
+	p := s.currentParent()
+
+	return s.derived(&derivedParent{root: p.root, handler: p.handler.WithAttrs(attrs)}, (s.handler.WithAttrs(attrs)).(*SpyHandler))
+}
+
+func (s *Spy) WithGroup(name string) slog.Handler {
+	p := s.currentParent()
+
+	return s.derived(&derivedParent{root: p.root, handler: p.handler.WithGroup(name)}, (s.handler.WithGroup(name)).(*SpyHandler))
+}
+
+func (s *Spy) derived(parent *derivedParent, handler *SpyHandler) *Spy {
+	spy := &Spy{root: s.root, handler: handler}
+	spy.parent.Store(parent)
+
+	return spy
+}
+
+// Handler returns the parent handler.
+//
+// Deprecated: use Parent.
+func (s *Spy) Handler() slog.Handler {
+	return s.Parent()
+}
+
+// Run starts the spy loop delivering batches to the output (see SpyHandler.Run).
+func (s *Spy) Run(ctx context.Context, out SpyOutput) error {
+	return s.handler.Run(ctx, out)
+}
+
+// RunRecords starts the spy loop in the structured delivery mode (see SpyHandler.RunRecords).
+func (s *Spy) RunRecords(ctx context.Context, out SpyOutputRecords) error {
+	return s.handler.RunRecords(ctx, out)
+}
+
+// Shutdown stops the spy loop gracefully (see SpyHandler.Shutdown).
+func (s *Spy) Shutdown(ctx context.Context) error {
+	return s.handler.Shutdown(ctx)
+}
+
+// Subscribe registers a new subscription (see SpyHandler.Subscribe).
+func (s *Spy) Subscribe(out SpyOutput, opts ...SubscriptionOption) *Subscription {
+	return s.handler.Subscribe(out, opts...)
+}
+
+// StopAll closes the spy's subscriptions tagged with the label (see SpyHandler.StopAll).
+func (s *Spy) StopAll(label string) int {
+	return s.handler.StopAll(label)
+}
+
+// SetFilter replaces the filter for spied records; nil removes the filter.
+func (s *Spy) SetFilter(f Filter) {
+	s.handler.SetFilter(f)
+}
+
+// SetFilterExpr replaces the filter with the compiled expression (see SpyHandler.SetFilterExpr).
+func (s *Spy) SetFilterExpr(expr string) error {
+	return s.handler.SetFilterExpr(expr)
+}
+
+// SetLevel changes the minimum level of spied records.
+func (s *Spy) SetLevel(level slog.Level) {
+	s.handler.SetLevel(level)
+}
+
+// ResetLevel removes the minimum level of spied records.
+func (s *Spy) ResetLevel() {
+	s.handler.ResetLevel()
+}
+
+// Schema returns the known record keys and metadata (see SpyHandler.Schema).
+func (s *Spy) Schema() Schema {
+	return s.handler.Schema()
+}
+
+// SetFlushInterval changes the max flush interval (see SpyHandler.SetFlushInterval).
+func (s *Spy) SetFlushInterval(interval time.Duration) error {
+	return s.handler.SetFlushInterval(interval)
+}
+
+// SetMaxBufSize changes the maximum output buffer size (see SpyHandler.SetMaxBufSize).
+func (s *Spy) SetMaxBufSize(size int) error {
+	return s.handler.SetMaxBufSize(size)
+}
+
+// Resize replaces the backlog with a new one of the specified size (see SpyHandler.Resize).
+func (s *Spy) Resize(backlog int) error {
+	return s.handler.Resize(backlog)
+}
+
+// Fetch returns the offloaded value by its reference.
+func (s *Spy) Fetch(id string) ([]byte, bool) {
+	return s.handler.Fetch(id)
+}
+
+// FetchHandler returns an HTTP handler serving offloaded values.
+func (s *Spy) FetchHandler() http.Handler {
+	return s.handler.FetchHandler()
+}
+
+// Stats returns the spy stats snapshot.
+func (s *Spy) Stats() Stats {
+	return s.handler.Stats()
+}
+
+// WatchContext registers a watcher until the returned context is canceled (see SpyHandler.WatchContext).
+func (s *Spy) WatchContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	return s.handler.WatchContext(ctx)
+}
+
+// WatchWithTTL registers a watcher which is unregistered automatically after the TTL.
+func (s *Spy) WatchWithTTL(ttl time.Duration) *WatchToken {
+	return s.handler.WatchWithTTL(ttl)
+}
+
+// StaticSession returns the running static session subscription by its name.
+func (s *Spy) StaticSession(name string) (*Subscription, bool) {
+	return s.handler.StaticSession(name)
+}
+
+// CaptureFor streams records to the output for the specified duration (see SpyHandler.CaptureFor).
+func (s *Spy) CaptureFor(ctx context.Context, d time.Duration, out SpyOutput) CaptureStats {
+	return s.handler.CaptureFor(ctx, d, out)
+}
+
+// CaptureUntil streams records to the output until the context is canceled (see SpyHandler.CaptureUntil).
+func (s *Spy) CaptureUntil(ctx context.Context, out SpyOutput) CaptureStats {
+	return s.handler.CaptureUntil(ctx, out)
+}
+
+// CaptureContext returns a context and the request-scoped subscription receiving records logged with it (see SpyHandler.CaptureContext).
+func (s *Spy) CaptureContext(ctx context.Context, out SpyOutput, opts ...SubscriptionOption) (context.Context, *Subscription) {
+	return s.handler.CaptureContext(ctx, out, opts...)
+}
+
+// WatchKey activates the spy only for the records carrying the attribute with the value (see SpyHandler.WatchKey).
+func (s *Spy) WatchKey(path string, value any) *Subscription {
+	return s.handler.WatchKey(path, value)
+}
+
+// SubscribeKey is like WatchKey but delivers the matching records to the provided output.
+func (s *Spy) SubscribeKey(path string, value any, out SpyOutput, opts ...SubscriptionOption) *Subscription {
+	return s.handler.SubscribeKey(path, value, out, opts...)
+}
+
+// DumpOnPanic logs the recovered panic and re-panics; it must be deferred directly (see SpyHandler.DumpOnPanic).
+func (s *Spy) DumpOnPanic() {
+	if v := recover(); v != nil {
+		s.handler.dumpPanic(v)
+		panic(v)
+	}
+}
+
+// Annotate attaches a context marker to the stream (see SpyHandler.Annotate).
+func (s *Spy) Annotate(attrs ...slog.Attr) {
+	s.handler.Annotate(attrs...)
+}
+
+// CaptureGoroutine captures the records of the current goroutine and its children (see SpyHandler.CaptureGoroutine).
+func (s *Spy) CaptureGoroutine(parent context.Context, out SpyOutput, opts ...SubscriptionOption) (context.Context, *Subscription) {
+	return s.handler.CaptureGoroutine(parent, out, opts...)
+}
+
+// AddOutput registers an extra output with its own queue (see SpyHandler.AddOutput).
+func (s *Spy) AddOutput(out SpyOutput, opts ...OutputOption) (remove func()) {
+	return s.handler.AddOutput(out, opts...)
+}
+
+// Watch registers a watcher activating the spy.
+func (s *Spy) Watch() {
+	s.handler.Watch()
+}
+
+// Unwatch unregisters a watcher.
+func (s *Spy) Unwatch() {
+	s.handler.Unwatch()
+}
@@ -0,0 +1,274 @@
+package slogspy
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+func TestSpy__Handle(t *testing.T) {
+	mainBuf := &bytes.Buffer{}
+	buf := &bytes.Buffer{}
+
+	done := make(chan struct{})
+
+	output := func(msg []byte) {
+		buf.Write(msg)
+
+		if bytes.Contains(msg, []byte("done")) {
+			close(done)
+		}
+	}
+
+	handler := slog.NewTextHandler(mainBuf, &slog.HandlerOptions{Level: slog.LevelInfo})
+	spy := NewSpy(handler)
+
+	logger := slog.New(spy)
+
+	go spy.Run(context.Background(), output)
+	defer spy.Shutdown(context.Background())
+
+	logger.Debug("never")
+	logger.Info("only-main")
+
+	spy.Watch()
+	logger.Debug("only-spy")
+
+	spy.Watch()
+	logger.Info("both")
+
+	spy.Unwatch()
+	logger.Debug("still-spying")
+
+	spy.Unwatch()
+	logger.Debug("never-again")
+
+	spy.Watch()
+	logger.Debug("done")
+
+	select {
+	case <-done:
+	case <-time.After(1 * time.Second):
+		t.Fatal("timed out to receive done message")
+	}
+
+	assertBufferContains(t, mainBuf, "only-main")
+	assertBufferContains(t, buf, "only-spy")
+	assertBufferContains(t, mainBuf, "both")
+	assertBufferContains(t, buf, "both")
+	assertBufferContains(t, buf, "still-spying")
+	assertBufferContainsNot(t, buf, "never")
+}
+
+func TestSpy__Shutdown(t *testing.T) {
+	buf := &bytes.Buffer{}
+
+	spy := NewSpy(slog.NewTextHandler(&bytes.Buffer{}, nil), WithFlushInterval(time.Hour))
+	logger := slog.New(spy)
+
+	go spy.Run(context.Background(), func(msg []byte) {
+		buf.Write(msg)
+	})
+
+	spy.Watch()
+	logger.Debug("queued")
+
+	if err := spy.Shutdown(context.Background()); err != nil {
+		t.Fatalf("unexpected shutdown error: %v", err)
+	}
+
+	assertBufferContains(t, buf, "queued")
+
+	// Repeated shutdowns and logging after shutdown are no-ops
+	if err := spy.Shutdown(context.Background()); err != nil {
+		t.Fatalf("unexpected shutdown error: %v", err)
+	}
+
+	logger.Debug("after-shutdown")
+
+	assertBufferContainsNot(t, buf, "after-shutdown")
+}
+
+func TestSpy__Shutdown_timeout(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+
+	spy := NewSpy(slog.NewTextHandler(&bytes.Buffer{}, nil), WithFlushInterval(time.Millisecond))
+	logger := slog.New(spy)
+
+	go spy.Run(context.Background(), func(msg []byte) {
+		<-release
+	})
+
+	spy.Watch()
+	logger.Debug("stuck")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	if err := spy.Shutdown(ctx); err != context.DeadlineExceeded {
+		t.Errorf("expected deadline exceeded error, got: %v", err)
+	}
+}
+
+func TestSpy__Run_restart(t *testing.T) {
+	buf := &bytes.Buffer{}
+	output := func(msg []byte) {
+		buf.Write(msg)
+	}
+
+	spy := NewSpy(slog.NewTextHandler(&bytes.Buffer{}, nil))
+	logger := slog.New(spy)
+	spy.Watch()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error, 1)
+
+	go func() { errCh <- spy.Run(ctx, output) }()
+
+	waitForRunning(t, spy)
+
+	if err := spy.Run(ctx, output); err != ErrAlreadyRunning {
+		t.Fatalf("expected already running error, got: %v", err)
+	}
+
+	cancel()
+
+	if err := <-errCh; err != context.Canceled {
+		t.Fatalf("expected context canceled error, got: %v", err)
+	}
+
+	go spy.Run(context.Background(), output) // nolint: errcheck
+	waitForRunning(t, spy)
+
+	logger.Debug("restarted")
+
+	if err := spy.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	assertBufferContains(t, buf, "restarted")
+
+	go spy.Run(context.Background(), output) // nolint: errcheck
+	waitForRunning(t, spy)
+
+	logger.Debug("restarted-after-shutdown")
+
+	if err := spy.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	assertBufferContains(t, buf, "restarted-after-shutdown")
+}
+
+type failingPrinter struct {
+	slog.Handler
+}
+
+func (failingPrinter) Handle(context.Context, slog.Record) error {
+	return errors.New("printer failed")
+}
+
+func TestSpy__WithErrorHandler(t *testing.T) {
+	var errs []error
+
+	spy := NewSpy(
+		slog.NewTextHandler(&bytes.Buffer{}, nil),
+		WithPrinter(func(w io.Writer) slog.Handler {
+			return failingPrinter{slog.NewJSONHandler(w, nil)}
+		}),
+		WithErrorHandler(func(err error) {
+			errs = append(errs, err)
+		}),
+	)
+
+	go spy.Run(context.Background(), func(msg []byte) {}) // nolint: errcheck
+
+	spy.Watch()
+	slog.New(spy).Debug("failed")
+
+	if err := spy.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(errs) != 1 || errs[0].Error() != "printer failed" {
+		t.Errorf("expected printer error to be reported, got: %v", errs)
+	}
+}
+
+func waitForRunning(t *testing.T, spy *Spy) {
+	t.Helper()
+
+	waitFor(t, func() bool {
+		spy.handler.state.mu.Lock()
+		defer spy.handler.state.mu.Unlock()
+		return spy.handler.state.running
+	})
+}
+
+func assertBufferContains(t *testing.T, buf *bytes.Buffer, expected string) {
+	t.Helper()
+
+	if !bytes.Contains(buf.Bytes(), []byte(expected)) {
+		t.Errorf("expected buffer to contain %s, got %s", expected, buf.String())
+	}
+}
+
+func assertBufferContainsNot(t *testing.T, buf *bytes.Buffer, expected string) {
+	t.Helper()
+
+	if bytes.Contains(buf.Bytes(), []byte(expected)) {
+		t.Errorf("expected buffer to not contain %s, got %s", expected, buf.String())
+	}
+}
+
+func TestSpy__WatchWithTTL(t *testing.T) {
+	spy := NewSpy(slog.NewTextHandler(&bytes.Buffer{}, nil))
+
+	token := spy.WatchWithTTL(20 * time.Millisecond)
+
+	if spy.handler.active.Load() != 1 {
+		t.Fatal("expected spy to be active")
+	}
+
+	waitFor(t, func() bool { return spy.handler.active.Load() == 0 })
+
+	// Releasing an expired token is a no-op
+	token.Release()
+
+	spy.Watch()
+	token.Release()
+
+	if n := spy.handler.active.Load(); n != 1 {
+		t.Errorf("expected 1 watcher, got %d", n)
+	}
+
+	released := spy.WatchWithTTL(time.Hour)
+	released.Release()
+	released.Release()
+
+	if n := spy.handler.active.Load(); n != 1 {
+		t.Errorf("expected 1 watcher, got %d", n)
+	}
+}
+
+func TestSpy__UnwatchNeverNegative(t *testing.T) {
+	spy := NewSpy(slog.NewTextHandler(&bytes.Buffer{}, nil))
+
+	spy.Unwatch()
+	spy.Unwatch()
+
+	if n := spy.handler.active.Load(); n != 0 {
+		t.Errorf("expected 0 watchers, got %d", n)
+	}
+
+	spy.Watch()
+
+	if !spy.handler.Enabled(context.Background(), slog.LevelDebug) {
+		t.Error("expected spy to be active after a single Watch call")
+	}
+}
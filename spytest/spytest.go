@@ -0,0 +1,235 @@
+// Package spytest provides a recorder to verify logs in unit tests: records are captured synchronously
+// (without the spy's Run loop, goroutines or timers), so assertions could be made right after logging.
+package spytest
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	slogspy "github.com/palkan/slog-spy"
+)
+
+// Recorder is a spy collecting records in memory.
+type Recorder struct {
+	t   testing.TB
+	spy *slogspy.Spy
+
+	mu      sync.Mutex
+	records []slog.Record
+}
+
+type config struct {
+	parent     slog.Handler
+	setDefault bool
+	spyOptions []slogspy.SpyHandlerOption
+}
+
+type Option func(*config)
+
+// WithParent sets the handler the spy is installed around (records are discarded by default).
+func WithParent(h slog.Handler) Option {
+	return func(c *config) {
+		c.parent = h
+	}
+}
+
+// WithDefault makes the recorder's logger the default one for the duration of the test.
+func WithDefault() Option {
+	return func(c *config) {
+		c.setDefault = true
+	}
+}
+
+// WithSpyOptions passes the options to the underlying spy handler (e.g., slogspy.WithRedactor).
+func WithSpyOptions(opts ...slogspy.SpyHandlerOption) Option {
+	return func(c *config) {
+		c.spyOptions = append(c.spyOptions, opts...)
+	}
+}
+
+// New creates a recorder and starts capturing records right away.
+func New(t testing.TB, opts ...Option) *Recorder {
+	t.Helper()
+
+	c := &config{parent: slog.NewTextHandler(io.Discard, nil)}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	rec := &Recorder{t: t}
+	rec.spy = slogspy.NewSpy(c.parent, append(c.spyOptions, slogspy.WithSyncRecords(rec.collect))...)
+	rec.spy.Watch()
+
+	if c.setDefault {
+		prev := slog.Default()
+		slog.SetDefault(rec.Logger())
+		t.Cleanup(func() { slog.SetDefault(prev) })
+	}
+
+	return rec
+}
+
+// Handler returns the handler to install into the code under test.
+func (r *Recorder) Handler() slog.Handler {
+	return r.spy
+}
+
+// Logger returns a new logger using the recorder's handler.
+func (r *Recorder) Logger() *slog.Logger {
+	return slog.New(r.spy)
+}
+
+// Records returns the captured records in order; the logger attributes and groups are resolved,
+// so they could be queried via RecordView.Lookup.
+func (r *Recorder) Records() []*slogspy.RecordView {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	views := make([]*slogspy.RecordView, len(r.records))
+
+	for i, record := range r.records {
+		views[i] = &slogspy.RecordView{Record: record}
+	}
+
+	return views
+}
+
+// Count returns the number of captured records.
+func (r *Recorder) Count() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return len(r.records)
+}
+
+// Reset discards the captured records.
+func (r *Recorder) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.records = nil
+}
+
+// HasLog returns true if a record with the level and message has been captured; the arguments are
+// the expected attributes in the slog key-value form (keys could be dot-separated paths, e.g., "http.status").
+// Numbers (including durations) are compared by value regardless of their types.
+func (r *Recorder) HasLog(level slog.Level, msg string, args ...any) bool {
+	return len(r.find(level, msg, args)) > 0
+}
+
+// AssertLog fails the test unless the log has been captured (see HasLog).
+func (r *Recorder) AssertLog(level slog.Level, msg string, args ...any) {
+	r.t.Helper()
+
+	if !r.HasLog(level, msg, args...) {
+		r.t.Errorf("expected %s %q %v to be logged, got:\n%s", level, msg, args, r)
+	}
+}
+
+// AssertNoLog fails the test if the log has been captured (see HasLog).
+func (r *Recorder) AssertNoLog(level slog.Level, msg string, args ...any) {
+	r.t.Helper()
+
+	if r.HasLog(level, msg, args...) {
+		r.t.Errorf("expected %s %q %v not to be logged, got:\n%s", level, msg, args, r)
+	}
+}
+
+// String returns the captured records in the text format (e.g., to include into failure messages).
+func (r *Recorder) String() string {
+	var buf strings.Builder
+
+	printer := slog.NewTextHandler(&buf, &slog.HandlerOptions{
+		// The time only makes the output noisy
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if len(groups) == 0 && a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return a
+		},
+	})
+
+	for _, view := range r.Records() {
+		printer.Handle(context.Background(), view.Record) // nolint: errcheck
+	}
+
+	return buf.String()
+}
+
+func (r *Recorder) find(level slog.Level, msg string, args []any) []*slogspy.RecordView {
+	filters := attrFilters(args)
+
+	var found []*slogspy.RecordView
+
+	for _, view := range r.Records() {
+		if view.Record.Level != level || view.Record.Message != msg {
+			continue
+		}
+
+		matched := true
+
+		for _, f := range filters {
+			if !f(view) {
+				matched = false
+				break
+			}
+		}
+
+		if matched {
+			found = append(found, view)
+		}
+	}
+
+	return found
+}
+
+// attrFilters converts the key-value arguments into attribute filters (groups are flattened into paths)
+func attrFilters(args []any) []slogspy.Filter {
+	expected := slog.NewRecord(time.Time{}, 0, "", 0)
+	expected.Add(args...)
+
+	var filters []slogspy.Filter
+
+	var add func(prefix string, a slog.Attr)
+
+	add = func(prefix string, a slog.Attr) {
+		path := a.Key
+
+		switch {
+		case a.Key == "":
+			path = prefix
+		case prefix != "":
+			path = fmt.Sprintf("%s.%s", prefix, a.Key)
+		}
+
+		if val := a.Value.Resolve(); val.Kind() == slog.KindGroup {
+			for _, nested := range val.Group() {
+				add(path, nested)
+			}
+			return
+		}
+
+		filters = append(filters, slogspy.WhereAttr(path, "==", a.Value.Resolve().Any()))
+	}
+
+	expected.Attrs(func(a slog.Attr) bool {
+		add("", a)
+		return true
+	})
+
+	return filters
+}
+
+func (r *Recorder) collect(record slog.Record) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.records = append(r.records, record.Clone())
+}
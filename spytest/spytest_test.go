@@ -0,0 +1,120 @@
+package spytest
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	slogspy "github.com/palkan/slog-spy"
+)
+
+func TestRecorder__HasLog(t *testing.T) {
+	rec := New(t)
+
+	logger := rec.Logger().With("tenant", "acme").WithGroup("http")
+	logger.Info("request served", "status", 200, "took", 15*time.Millisecond)
+	rec.Logger().Debug("debug details", slog.Group("db", slog.String("query", "SELECT 1")))
+
+	if rec.Count() != 2 {
+		t.Fatalf("expected 2 records, got %d", rec.Count())
+	}
+
+	for _, args := range [][]any{
+		nil,
+		{"tenant", "acme"},
+		{"http.status", 200},
+		{"http.status", int64(200), "http.took", 15 * time.Millisecond},
+		{slog.Group("http", slog.Int("status", 200))},
+	} {
+		if !rec.HasLog(slog.LevelInfo, "request served", args...) {
+			t.Errorf("expected log with %v to be captured", args)
+		}
+	}
+
+	for _, args := range [][]any{
+		{"tenant", "globex"},
+		{"status", 200},
+		{"http.status", 500},
+	} {
+		if rec.HasLog(slog.LevelInfo, "request served", args...) {
+			t.Errorf("expected log with %v not to be captured", args)
+		}
+	}
+
+	if rec.HasLog(slog.LevelWarn, "request served") {
+		t.Error("expected the level to be matched")
+	}
+
+	rec.AssertLog(slog.LevelDebug, "debug details", "db.query", "SELECT 1")
+
+	rec.Reset()
+
+	if rec.Count() != 0 {
+		t.Errorf("expected no records after reset, got %d", rec.Count())
+	}
+
+	rec.AssertNoLog(slog.LevelInfo, "request served")
+}
+
+func TestRecorder__Parent(t *testing.T) {
+	var buf bytes.Buffer
+
+	rec := New(t, WithParent(slog.NewTextHandler(&buf, nil)), WithSpyOptions(slogspy.WithLevel(slog.LevelInfo)))
+
+	rec.Logger().Info("visible")
+	rec.Logger().Debug("hidden")
+
+	if !strings.Contains(buf.String(), "visible") {
+		t.Errorf("expected the parent handler to receive the record, got: %s", buf.String())
+	}
+
+	if rec.Count() != 1 {
+		t.Errorf("expected the spy level to be respected, got %d records", rec.Count())
+	}
+}
+
+func TestRecorder__Default(t *testing.T) {
+	prev := slog.Default()
+
+	t.Run("default", func(t *testing.T) {
+		rec := New(t, WithDefault())
+
+		slog.Warn("via default")
+
+		rec.AssertLog(slog.LevelWarn, "via default")
+	})
+
+	if slog.Default() != prev {
+		t.Error("expected the default logger to be restored")
+	}
+}
+
+type failingT struct {
+	testing.TB
+	errors []string
+}
+
+func (f *failingT) Errorf(format string, args ...any) {
+	f.errors = append(f.errors, fmt.Sprintf(format, args...))
+}
+
+func TestRecorder__AssertLog(t *testing.T) {
+	ft := &failingT{TB: t}
+	rec := New(ft)
+
+	rec.Logger().Info("hello", "user_id", 42)
+
+	rec.AssertLog(slog.LevelInfo, "hello", "user_id", 43)
+	rec.AssertNoLog(slog.LevelInfo, "hello", "user_id", 42)
+
+	if len(ft.errors) != 2 {
+		t.Fatalf("expected 2 failures, got: %v", ft.errors)
+	}
+
+	if !strings.Contains(ft.errors[0], `msg=hello user_id=42`) {
+		t.Errorf("expected the failure to list the captured records, got: %s", ft.errors[0])
+	}
+}
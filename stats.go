@@ -0,0 +1,133 @@
+package slogspy
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Stats contains the spy runtime statistics.
+type Stats struct {
+	// Dropped is the number of records dropped due to the backlog overflow (including the evicted ones);
+	// records logged with canceled contexts are only counted by reason (see Drops)
+	Dropped int64 `json:"dropped"`
+	// Evicted is the number of queued records evicted due to their age (see WithMaxBacklogAge)
+	Evicted int64 `json:"evicted"`
+	// SampledOut is the number of matching records skipped due to sampling or rate limiting
+	SampledOut int64 `json:"sampled_out"`
+	// BacklogAge is the time the last processed record spent in the backlog (only tracked with WithMaxBacklogAge)
+	BacklogAge time.Duration `json:"backlog_age"`
+	// Spooled is the current size of the disk spool in bytes (see WithSpool)
+	Spooled int64 `json:"spooled"`
+	// SpoolDropped is the number of batches dropped due to the spool size limit or disk errors
+	// (they're also counted as DropSinkFailure drops)
+	SpoolDropped int64 `json:"spool_dropped"`
+	// Drops is the number of drops by reason (only non-zero counters are included)
+	Drops map[DropReason]int64 `json:"drops,omitempty"`
+	// QueueDepth is the current number of entries in the backlog channel
+	QueueDepth int `json:"queue_depth"`
+	// BytesFlushed is the total number of bytes flushed
+	BytesFlushed int64 `json:"bytes_flushed"`
+	// Flushes is the total number of flushes
+	Flushes int64 `json:"flushes"`
+	// Watchers is the current number of watchers (including subscriptions)
+	Watchers int64 `json:"watchers"`
+	// Latency is the delivery latency measured by probes (see WithLatencyProbes)
+	Latency LatencyStats `json:"latency"`
+	// Collapsed is the number of duplicate records collapsed into the preceding ones (see WithDedup)
+	Collapsed int64 `json:"collapsed"`
+	// Dumps is the number of the recent records dumps delivered by the error trigger (see WithErrorTrigger)
+	Dumps int64 `json:"dumps"`
+	// SlowOutputs is the number of output writes not completed within the output timeout (see WithOutputTimeout)
+	SlowOutputs int64 `json:"slow_outputs"`
+	// PrinterFallbacks is the number of printers which couldn't be built and were replaced by the default ones
+	PrinterFallbacks int64 `json:"printer_fallbacks"`
+}
+
+// MetricsCollector receives the spy metrics updates (e.g., to export them to Prometheus).
+// Methods are called synchronously, so they must be fast.
+type MetricsCollector interface {
+	// IncDropped is called for every dropped record
+	IncDropped()
+	// AddFlushed is called on every flush with the number of bytes flushed
+	AddFlushed(bytes int)
+	// SetQueueDepth is called on every flush with the current backlog size
+	SetQueueDepth(n int)
+	// SetWatchers is called every time the number of watchers changes
+	SetWatchers(n int64)
+}
+
+// WithMetricsCollector sets the metrics collector for the spy.
+func WithMetricsCollector(mc MetricsCollector) SpyHandlerOption {
+	return func(h *SpyHandler) {
+		h.metrics = mc
+	}
+}
+
+type spyStats struct {
+	dropped      atomic.Int64
+	evicted      atomic.Int64
+	sampledOut   atomic.Int64
+	spooled      atomic.Int64
+	spoolDropped atomic.Int64
+	drops        [len(dropReasons)]atomic.Int64
+	backlogAge   atomic.Int64
+	bytesFlushed atomic.Int64
+	flushes      atomic.Int64
+
+	printerFallbacks atomic.Int64
+	collapsed        atomic.Int64
+	dumps            atomic.Int64
+	slowOutputs      atomic.Int64
+	latency          latencyStats
+}
+
+// Stats returns the current runtime statistics.
+func (h *SpyHandler) Stats() Stats {
+	return Stats{
+		Dropped:      h.stats.dropped.Load(),
+		Evicted:      h.stats.evicted.Load(),
+		SampledOut:   h.stats.sampledOut.Load(),
+		BacklogAge:   time.Duration(h.stats.backlogAge.Load()),
+		Spooled:      h.stats.spooled.Load(),
+		SpoolDropped: h.stats.spoolDropped.Load(),
+		Drops:        h.stats.dropsByReason(),
+		QueueDepth:   len(h.queue()),
+		BytesFlushed: h.stats.bytesFlushed.Load(),
+		Flushes:      h.stats.flushes.Load(),
+		Watchers:     h.active.Load(),
+
+		PrinterFallbacks: h.stats.printerFallbacks.Load(),
+		Collapsed:        h.stats.collapsed.Load(),
+		Dumps:            h.stats.dumps.Load(),
+		SlowOutputs:      h.stats.slowOutputs.Load(),
+		Latency:          h.stats.latency.snapshot(),
+	}
+}
+
+func (h *SpyHandler) trackDropped() {
+	h.stats.dropped.Add(1)
+
+	if h.metrics != nil {
+		h.metrics.IncDropped()
+	}
+}
+
+func (h *SpyHandler) trackFlushed(n int) {
+	h.stats.bytesFlushed.Add(int64(n))
+	h.stats.flushes.Add(1)
+
+	if h.metrics != nil {
+		h.metrics.AddFlushed(n)
+		h.metrics.SetQueueDepth(len(h.queue()))
+	}
+}
+
+func (h *SpyHandler) trackWatchers(n int64) {
+	if h.metrics != nil {
+		h.metrics.SetWatchers(n)
+	}
+
+	if h.onWatchers != nil {
+		h.onWatchers()
+	}
+}
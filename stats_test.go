@@ -0,0 +1,76 @@
+package slogspy
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"sync"
+	"testing"
+)
+
+type testMetrics struct {
+	mu       sync.Mutex
+	dropped  int
+	flushed  int
+	depth    int
+	watchers int64
+}
+
+func (m *testMetrics) IncDropped() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.dropped++
+}
+
+func (m *testMetrics) AddFlushed(bytes int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.flushed += bytes
+}
+
+func (m *testMetrics) SetQueueDepth(n int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.depth = n
+}
+
+func (m *testMetrics) SetWatchers(n int64) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.watchers = n
+}
+
+func TestSpy__Stats(t *testing.T) {
+	metrics := &testMetrics{}
+	buf := &bytes.Buffer{}
+
+	spy := NewSpy(slog.NewTextHandler(&bytes.Buffer{}, nil), WithBacklogSize(2), WithMetricsCollector(metrics))
+	logger := slog.New(spy)
+
+	spy.Watch()
+	logger.Debug("a")
+	logger.Debug("b")
+	logger.Debug("c")
+
+	stats := spy.Stats()
+
+	if stats.Dropped != 1 || stats.QueueDepth != 2 || stats.Watchers != 1 {
+		t.Errorf("unexpected stats before run: %+v", stats)
+	}
+
+	go spy.Run(context.Background(), func(msg []byte) { buf.Write(msg) }) // nolint: errcheck
+
+	if err := spy.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	stats = spy.Stats()
+
+	if stats.Flushes != 1 || stats.BytesFlushed != int64(buf.Len()) || stats.QueueDepth != 0 {
+		t.Errorf("unexpected stats after run: %+v", stats)
+	}
+
+	if metrics.dropped != 1 || metrics.flushed != buf.Len() || metrics.watchers != 1 {
+		t.Errorf("unexpected metrics: %+v", metrics)
+	}
+}
@@ -0,0 +1,611 @@
+package slogspy
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Subscription context cancellation causes (see context.Cause)
+var (
+	ErrSubscriptionClosed  = errors.New("subscription closed")
+	ErrSubscriptionExpired = errors.New("subscription expired")
+	ErrQuotaExhausted      = errors.New("subscription quota exhausted")
+	ErrSpyShutdown         = errors.New("spy shut down")
+)
+
+// Quota limits the number of bytes delivered to a subscription (or a group of subscriptions).
+// If Window is set, the limit is applied per window and batches exceeding it are dropped;
+// otherwise, the limit is applied to the whole lifetime, and subscriptions are closed once it's exhausted.
+type Quota struct {
+	Bytes  int64
+	Window time.Duration
+}
+
+type quotaState struct {
+	mu          sync.Mutex
+	quota       Quota
+	used        int64
+	windowStart time.Time
+}
+
+func newQuotaState(q Quota) *quotaState {
+	return &quotaState{quota: q, windowStart: time.Now()}
+}
+
+// allow consumes n bytes from the quota; it reports whether the bytes fit
+// and whether the (lifetime) quota is exhausted
+func (q *quotaState) allow(n int) (bool, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.quota.Window > 0 {
+		if now := time.Now(); now.Sub(q.windowStart) >= q.quota.Window {
+			q.windowStart = now
+			q.used = 0
+		}
+	}
+
+	if q.used+int64(n) > q.quota.Bytes {
+		return false, q.quota.Window == 0
+	}
+
+	q.used += int64(n)
+
+	return true, false
+}
+
+// refund returns the bytes consumed by allow (e.g., when the batch is rejected by another quota)
+func (q *quotaState) refund(n int) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.used = max(q.used-int64(n), 0)
+}
+
+// SubscriptionGroup combines subscriptions sharing the same quota and drop accounting
+// (e.g., all sessions opened by the same dashboard).
+type SubscriptionGroup struct {
+	name    string
+	quota   *quotaState
+	dropped atomic.Int64
+
+	mu   sync.Mutex
+	subs map[*Subscription]struct{}
+}
+
+// NewSubscriptionGroup creates a new subscription group with the shared quota.
+// Zero quota means no limits.
+func NewSubscriptionGroup(name string, quota Quota) *SubscriptionGroup {
+	g := &SubscriptionGroup{
+		name: name,
+		subs: make(map[*Subscription]struct{}),
+	}
+
+	if quota.Bytes > 0 {
+		g.quota = newQuotaState(quota)
+	}
+
+	return g
+}
+
+// Name returns the group name.
+func (g *SubscriptionGroup) Name() string {
+	return g.name
+}
+
+// Dropped returns the number of batches dropped for the group subscriptions due to quotas.
+func (g *SubscriptionGroup) Dropped() int64 {
+	return g.dropped.Load()
+}
+
+// Size returns the number of active subscriptions in the group.
+func (g *SubscriptionGroup) Size() int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	return len(g.subs)
+}
+
+// Close closes all the group subscriptions.
+func (g *SubscriptionGroup) Close() {
+	g.mu.Lock()
+	subs := make([]*Subscription, 0, len(g.subs))
+	for sub := range g.subs {
+		subs = append(subs, sub)
+	}
+	g.mu.Unlock()
+
+	for _, sub := range subs {
+		sub.Close()
+	}
+}
+
+func (g *SubscriptionGroup) add(sub *Subscription) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	g.subs[sub] = struct{}{}
+}
+
+func (g *SubscriptionGroup) remove(sub *Subscription) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	delete(g.subs, sub)
+}
+
+// Subscription is a consumer of spied logs with its own output.
+// Every subscription is registered as a watcher until closed.
+type Subscription struct {
+	handler *SpyHandler
+	output  SpyOutput
+	control SpyOutput
+
+	group   *SubscriptionGroup
+	quota   *quotaState
+	dropped atomic.Int64
+
+	adaptive *adaptiveState
+	throttle *throttleState
+
+	// Delivered batches accounting
+	records atomic.Int64
+	bytes   atomic.Int64
+
+	// Subscriptions with their own filter (or sampling) get their own batches (see session.go)
+	filter   Filter
+	sampling float64
+	printer  func(w io.Writer) slog.Handler
+	batch    *sessionBatch
+	// source is set if the subscription needs source locations (see WithSubscriptionSource)
+	source bool
+	// key is set for keyed watchers (see WatchKey)
+	key Filter
+	// requestScoped subscriptions only receive records logged with their context (see CaptureContext)
+	requestScoped bool
+	// static subscriptions are started by the spy itself and don't activate its output (see WithStaticSession)
+	static bool
+
+	ttl     time.Duration
+	timerMu sync.Mutex
+	timer   *time.Timer
+	// stopParent unregisters the callback closing the subscription with the parent context (see CaptureContext)
+	stopParent func() bool
+
+	ctx    context.Context
+	cancel context.CancelCauseFunc
+
+	closeOnce sync.Once
+	closed    atomic.Bool
+
+	events bool
+	// labels are used to stop the related subscriptions at once (see StopAll)
+	labels map[string]string
+	// schema is set if the subscription receives the schema dictionary first (see WithSubscriptionSchema)
+	schema bool
+	paused atomic.Bool
+	// outMu serializes output calls (lifecycle events could be emitted concurrently with deliveries)
+	outMu sync.Mutex
+	// timed calls the outputs with the output timeout (see WithOutputTimeout)
+	timed *timedOutput
+}
+
+type SubscriptionOption func(*Subscription)
+
+// WithSubscriptionGroup adds the subscription to the group.
+func WithSubscriptionGroup(g *SubscriptionGroup) SubscriptionOption {
+	return func(s *Subscription) {
+		s.group = g
+	}
+}
+
+// WithSubscriptionTTL makes the subscription expire (close) after the specified duration.
+func WithSubscriptionTTL(ttl time.Duration) SubscriptionOption {
+	return func(s *Subscription) {
+		s.ttl = ttl
+	}
+}
+
+// WithSubscriptionQuota sets the subscription's own quota.
+func WithSubscriptionQuota(q Quota) SubscriptionOption {
+	return func(s *Subscription) {
+		if q.Bytes > 0 {
+			s.quota = newQuotaState(q)
+		}
+	}
+}
+
+// Subscribe registers a new subscription delivering flushed logs to the output.
+// The output is called from the Run loop; the message must not be retained after the call returns.
+func (h *SpyHandler) Subscribe(out SpyOutput, opts ...SubscriptionOption) *Subscription {
+	sub := h.newSubscription(out, opts...)
+	h.register(sub)
+
+	return sub
+}
+
+// subscriber is a source of subscriptions (a spy or a bus) used by transports
+type subscriber interface {
+	newSubscription(out SpyOutput, opts ...SubscriptionOption) *Subscription
+	register(sub *Subscription)
+}
+
+var _ subscriber = (*SpyHandler)(nil)
+
+func (h *SpyHandler) newSubscription(out SpyOutput, opts ...SubscriptionOption) *Subscription {
+	sub := &Subscription{
+		handler: h,
+		output:  out,
+	}
+
+	sub.ctx, sub.cancel = context.WithCancelCause(context.Background())
+
+	for _, opt := range opts {
+		opt(sub)
+	}
+
+	if sub.output != nil {
+		sub.timed = h.newTimedOutput(func() { sub.closeWithCause(ErrSlowConsumer) })
+	}
+
+	if sub.timed != nil {
+		sub.output = sub.timed.wrap(sub.output)
+
+		if sub.control != nil {
+			sub.control = sub.timed.wrap(sub.control)
+		}
+	}
+
+	if sub.filter != nil || sub.sampling > 0 || sub.printer != nil || sub.requestScoped {
+		sub.batch = &sessionBatch{}
+
+		if sub.printer != nil {
+			printer, err := buildPrinter(sub.printer, &sub.batch.buf)
+
+			// Fall back to the spy's printer
+			if err != nil {
+				h.trackPrinterFallback(err)
+			}
+
+			sub.batch.printer = printer
+		}
+	}
+
+	return sub
+}
+
+func (h *SpyHandler) register(sub *Subscription) {
+	if sub.group != nil {
+		sub.group.add(sub)
+	}
+
+	h.subs.add(sub)
+	h.Watch()
+
+	if len(sub.labels) > 0 {
+		labeledSubscriptions.add(sub)
+	}
+
+	if sub.source && h.source != nil {
+		h.source.acquire()
+	}
+
+	if sub.schema {
+		sub.sendSchema()
+	}
+
+	start := map[string]any{}
+
+	if sub.group != nil {
+		start["group"] = sub.group.Name()
+	}
+
+	if len(sub.labels) > 0 {
+		start["labels"] = sub.Labels()
+	}
+
+	sub.emit(EventStart, start)
+
+	if sub.ttl > 0 {
+		sub.timerMu.Lock()
+		sub.timer = time.AfterFunc(sub.ttl, func() { sub.closeWithCause(ErrSubscriptionExpired) })
+		sub.timerMu.Unlock()
+	}
+
+	// The check must go after adding the subscription, so it's either closed here or by the shutdown
+	if h.subs.sealed.Load() {
+		sub.closeWithCause(ErrSpyShutdown)
+	}
+}
+
+// WatchContext registers a watcher until the returned context is canceled:
+// either via the cancel function, by the parent context or when the spy is shut down.
+func (h *SpyHandler) WatchContext(parent context.Context) (context.Context, context.CancelFunc) {
+	sub := h.Subscribe(nil)
+
+	stop := context.AfterFunc(parent, func() { sub.closeWithCause(context.Cause(parent)) })
+
+	return sub.Context(), func() {
+		stop()
+		sub.Close()
+	}
+}
+
+// Close unregisters the subscription. It's safe to call Close multiple times.
+func (s *Subscription) Close() {
+	s.closeWithCause(ErrSubscriptionClosed)
+}
+
+// Context returns a context which is canceled when the subscription ends; use context.Cause to find out why.
+func (s *Subscription) Context() context.Context {
+	return s.ctx
+}
+
+func (s *Subscription) closeWithCause(cause error) {
+	s.closeOnce.Do(func() {
+		s.closed.Store(true)
+
+		s.timerMu.Lock()
+		if s.timer != nil {
+			s.timer.Stop()
+		}
+		if s.stopParent != nil {
+			s.stopParent()
+		}
+		s.timerMu.Unlock()
+
+		// The end event must be sent before the context is canceled (and the transport is closed)
+		s.emit(EventEnd, map[string]any{"reason": cause.Error(), "records": s.records.Load(), "bytes": s.bytes.Load()})
+
+		s.cancel(cause)
+		s.handler.subs.remove(s)
+
+		if s.timed != nil {
+			s.timed.close()
+		}
+
+		if s.group != nil {
+			s.group.remove(s)
+		}
+
+		if len(s.labels) > 0 {
+			labeledSubscriptions.remove(s)
+		}
+
+		s.handler.Unwatch()
+
+		if s.source && s.handler.source != nil {
+			s.handler.source.release()
+		}
+
+		// Scoped watchers are untracked after unwatching, so the remaining watchers count never exceeds the scoped one
+		if s.scoped() {
+			s.handler.subs.scoped.Add(-1)
+		}
+	})
+}
+
+// Flush makes sure all the records logged before the call are delivered to the subscription
+// (only the subscription's own batch is flushed if it has one). It blocks until the Run loop
+// performs the flush or the context is done.
+func (s *Subscription) Flush(ctx context.Context) error {
+	if s.Closed() {
+		return ErrSubscriptionClosed
+	}
+
+	return s.handler.requestFlush(ctx, s)
+}
+
+// scoped returns true if the subscription only activates the spy for the specific records
+// (see WatchKey, CaptureContext and WithStaticSession)
+func (s *Subscription) scoped() bool {
+	return s.key != nil || s.requestScoped || s.static
+}
+
+// Closed returns true if the subscription has been closed (explicitly or due to the exhausted quota).
+func (s *Subscription) Closed() bool {
+	return s.closed.Load()
+}
+
+// Dropped returns the number of batches dropped for the subscription due to quotas.
+func (s *Subscription) Dropped() int64 {
+	return s.dropped.Load()
+}
+
+func (s *Subscription) deliver(msg []byte, records int) {
+	// Bare watchers have no output
+	if s.output == nil || s.paused.Load() {
+		return
+	}
+
+	if s.group != nil && s.group.quota != nil {
+		if ok, exhausted := s.group.quota.allow(len(msg)); !ok {
+			s.group.dropped.Add(1)
+			s.handler.stats.trackDrop(DropQuota)
+			s.emit(EventDrop, map[string]any{"reason": string(DropQuota), "dropped": s.dropped.Add(1)})
+
+			if exhausted {
+				s.closeWithCause(ErrQuotaExhausted)
+			}
+			return
+		}
+	}
+
+	if s.quota != nil {
+		if ok, exhausted := s.quota.allow(len(msg)); !ok {
+			if s.group != nil {
+				// The batch isn't delivered, so it must not consume the shared quota
+				if s.group.quota != nil {
+					s.group.quota.refund(len(msg))
+				}
+
+				s.group.dropped.Add(1)
+			}
+
+			s.handler.stats.trackDrop(DropQuota)
+			s.emit(EventDrop, map[string]any{"reason": string(DropQuota), "dropped": s.dropped.Add(1)})
+
+			if exhausted {
+				s.closeWithCause(ErrQuotaExhausted)
+			}
+			return
+		}
+	}
+
+	if s.throttle != nil {
+		ok, elidedRecords, elidedBytes := s.throttle.allow(len(msg), records)
+
+		if !ok {
+			return
+		}
+
+		if elidedRecords > 0 {
+			s.sendControl(EventThrottle, map[string]any{"records": elidedRecords, "bytes": elidedBytes})
+		}
+	}
+
+	s.records.Add(int64(records))
+	s.bytes.Add(int64(len(msg)))
+
+	if s.adaptive != nil {
+		msg = s.encode(msg)
+	}
+
+	s.write(s.output, msg)
+}
+
+func (s *Subscription) write(out SpyOutput, msg []byte) {
+	s.outMu.Lock()
+	defer s.outMu.Unlock()
+
+	out(msg)
+}
+
+type subscriptions struct {
+	mu   sync.RWMutex
+	subs map[*Subscription]struct{}
+	// all is the snapshot of all the subscriptions (copied on write, read without locking on flushes)
+	all atomic.Pointer[[]*Subscription]
+	// sessions is the snapshot of subscriptions with their own batches (copied on write)
+	sessions []*Subscription
+	// keyWatchers is the snapshot of keyed watchers delivering to the spy's output (copied on write)
+	keyWatchers []*Subscription
+	// scoped is the number of keyed (including the ones with their own outputs) and request-scoped watchers
+	scoped atomic.Int64
+	// sealed is set when the spy stops accepting new subscriptions (see Teardown)
+	sealed atomic.Bool
+	// goroutines contains the goroutine captures (see CaptureGoroutine)
+	goroutines goroutineCaptures
+}
+
+func newSubscriptions() *subscriptions {
+	return &subscriptions{subs: make(map[*Subscription]struct{})}
+}
+
+func (ss *subscriptions) add(sub *Subscription) {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	if _, ok := ss.subs[sub]; ok {
+		return
+	}
+
+	ss.subs[sub] = struct{}{}
+
+	all := ss.list()
+	all = append(all[:len(all):len(all)], sub)
+	ss.all.Store(&all)
+
+	if sub.batch != nil {
+		ss.sessions = append(ss.sessions[:len(ss.sessions):len(ss.sessions)], sub)
+	}
+
+	if sub.scoped() {
+		ss.scoped.Add(1)
+	}
+
+	if sub.key != nil && sub.batch == nil {
+		ss.keyWatchers = append(ss.keyWatchers[:len(ss.keyWatchers):len(ss.keyWatchers)], sub)
+	}
+}
+
+func (ss *subscriptions) remove(sub *Subscription) {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	if _, ok := ss.subs[sub]; !ok {
+		return
+	}
+
+	delete(ss.subs, sub)
+
+	all := make([]*Subscription, 0, len(ss.subs))
+	for _, s := range ss.list() {
+		if s != sub {
+			all = append(all, s)
+		}
+	}
+	ss.all.Store(&all)
+
+	if sub.batch != nil {
+		sessions := make([]*Subscription, 0, len(ss.sessions))
+		for _, s := range ss.sessions {
+			if s != sub {
+				sessions = append(sessions, s)
+			}
+		}
+		ss.sessions = sessions
+	}
+
+	if sub.key != nil && sub.batch == nil {
+		watchers := make([]*Subscription, 0, len(ss.keyWatchers))
+		for _, s := range ss.keyWatchers {
+			if s != sub {
+				watchers = append(watchers, s)
+			}
+		}
+		ss.keyWatchers = watchers
+	}
+}
+
+// deliver sends the batch to all the subscriptions except for the ones with their own batches
+func (ss *subscriptions) deliver(msg []byte, records int) {
+	for _, sub := range ss.list() {
+		if sub.batch == nil {
+			sub.deliver(msg, records)
+		}
+	}
+}
+
+func (ss *subscriptions) sessionsList() []*Subscription {
+	ss.mu.RLock()
+	defer ss.mu.RUnlock()
+
+	return ss.sessions
+}
+
+func (ss *subscriptions) keyWatchersList() []*Subscription {
+	ss.mu.RLock()
+	defer ss.mu.RUnlock()
+
+	return ss.keyWatchers
+}
+
+func (ss *subscriptions) closeAll(cause error) {
+	for _, sub := range ss.list() {
+		sub.closeWithCause(cause)
+	}
+}
+
+func (ss *subscriptions) list() []*Subscription {
+	if all := ss.all.Load(); all != nil {
+		return *all
+	}
+
+	return nil
+}
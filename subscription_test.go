@@ -0,0 +1,203 @@
+package slogspy
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+func TestSpy__Subscribe(t *testing.T) {
+	spy := NewSpy(slog.NewTextHandler(&bytes.Buffer{}, nil))
+
+	buf := &bytes.Buffer{}
+	sub := spy.Subscribe(func(msg []byte) {
+		buf.Write(msg)
+	})
+
+	if spy.handler.active.Load() != 1 {
+		t.Fatalf("expected subscription to be registered as a watcher")
+	}
+
+	go spy.Run(context.Background(), nil) // nolint: errcheck
+
+	slog.New(spy).Debug("subscribed")
+
+	if err := spy.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	assertBufferContains(t, buf, "subscribed")
+
+	sub.Close()
+	sub.Close()
+
+	if spy.handler.active.Load() != 0 {
+		t.Errorf("expected subscription to be unregistered, got %d watchers", spy.handler.active.Load())
+	}
+}
+
+func TestSubscriptionGroup__SharedQuota(t *testing.T) {
+	spy := NewSpy(slog.NewTextHandler(&bytes.Buffer{}, nil))
+	group := NewSubscriptionGroup("dashboard", Quota{Bytes: 10, Window: time.Hour})
+
+	var delivered int
+
+	for i := 0; i < 3; i++ {
+		spy.Subscribe(func(msg []byte) { delivered++ }, WithSubscriptionGroup(group))
+	}
+
+	spy.handler.subs.deliver([]byte("1234"), 1)
+
+	if delivered != 2 {
+		t.Errorf("expected 2 deliveries within the shared quota, got %d", delivered)
+	}
+
+	if group.Dropped() != 1 {
+		t.Errorf("expected 1 drop in group, got %d", group.Dropped())
+	}
+
+	group.Close()
+
+	if group.Size() != 0 || spy.handler.active.Load() != 0 {
+		t.Errorf("expected all group subscriptions to be closed")
+	}
+}
+
+func TestSubscriptionGroup__SharedQuota_OwnQuotaRejects(t *testing.T) {
+	spy := NewSpy(slog.NewTextHandler(&bytes.Buffer{}, nil))
+	group := NewSubscriptionGroup("dashboard", Quota{Bytes: 8, Window: time.Hour})
+
+	var limited, sibling int
+
+	spy.Subscribe(func(msg []byte) { limited++ }, WithSubscriptionGroup(group), WithSubscriptionQuota(Quota{Bytes: 2, Window: time.Hour}))
+	spy.Subscribe(func(msg []byte) { sibling++ }, WithSubscriptionGroup(group))
+
+	spy.handler.subs.deliver([]byte("1234"), 1)
+	spy.handler.subs.deliver([]byte("1234"), 1)
+
+	// Batches rejected by the own quota don't consume the group quota
+	if limited != 0 || sibling != 2 {
+		t.Errorf("expected the sibling to receive both batches, got %d and %d", limited, sibling)
+	}
+}
+
+func TestSubscription__LifetimeQuota(t *testing.T) {
+	spy := NewSpy(slog.NewTextHandler(&bytes.Buffer{}, nil))
+
+	var delivered int
+
+	sub := spy.Subscribe(func(msg []byte) { delivered++ }, WithSubscriptionQuota(Quota{Bytes: 6}))
+
+	spy.handler.subs.deliver([]byte("1234"), 1)
+	spy.handler.subs.deliver([]byte("1234"), 1)
+
+	if delivered != 1 || sub.Dropped() != 1 {
+		t.Errorf("expected 1 delivery and 1 drop, got %d and %d", delivered, sub.Dropped())
+	}
+
+	if !sub.Closed() {
+		t.Error("expected subscription to be closed when the lifetime quota is exhausted")
+	}
+}
+
+func TestSubscription__Context(t *testing.T) {
+	spy := NewSpy(slog.NewTextHandler(&bytes.Buffer{}, nil))
+
+	sub := spy.Subscribe(func(msg []byte) {}, WithSubscriptionQuota(Quota{Bytes: 1}))
+	spy.handler.subs.deliver([]byte("exceeds"), 1)
+
+	assertContextCause(t, sub.Context(), ErrQuotaExhausted)
+
+	expiring := spy.Subscribe(func(msg []byte) {}, WithSubscriptionTTL(10*time.Millisecond))
+
+	assertContextCause(t, expiring.Context(), ErrSubscriptionExpired)
+
+	go spy.Run(context.Background(), nil) // nolint: errcheck
+
+	active := spy.Subscribe(func(msg []byte) {})
+	watchCtx, cancel := spy.WatchContext(context.Background())
+	defer cancel()
+
+	if spy.handler.active.Load() != 2 {
+		t.Errorf("expected 2 watchers, got %d", spy.handler.active.Load())
+	}
+
+	if err := spy.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	assertContextCause(t, active.Context(), ErrSpyShutdown)
+	assertContextCause(t, watchCtx, ErrSpyShutdown)
+
+	if spy.handler.active.Load() != 0 {
+		t.Errorf("expected no watchers after shutdown, got %d", spy.handler.active.Load())
+	}
+}
+
+func TestSpy__WatchContext(t *testing.T) {
+	spy := NewSpy(slog.NewTextHandler(&bytes.Buffer{}, nil))
+
+	parent, cancelParent := context.WithCancel(context.Background())
+
+	ctx, cancel := spy.WatchContext(parent)
+	defer cancel()
+
+	if !spy.handler.Enabled(context.Background(), slog.LevelDebug) {
+		t.Fatal("expected spy to be active")
+	}
+
+	cancelParent()
+
+	assertContextCause(t, ctx, context.Canceled)
+	waitFor(t, func() bool { return spy.handler.active.Load() == 0 })
+
+	// Canceling multiple times is safe
+	cancel()
+
+	if spy.handler.active.Load() != 0 {
+		t.Errorf("expected watchers counter not to go negative, got %d", spy.handler.active.Load())
+	}
+}
+
+func assertContextCause(t *testing.T, ctx context.Context, expected error) {
+	t.Helper()
+
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Second):
+		t.Fatalf("timed out waiting for context to be canceled with %v", expected)
+	}
+
+	if cause := context.Cause(ctx); cause != expected {
+		t.Errorf("expected context cause %v, got %v", expected, cause)
+	}
+}
+
+func TestSubscriptions__List(t *testing.T) {
+	spy := NewSpy(slog.NewTextHandler(&bytes.Buffer{}, nil))
+
+	first := spy.Subscribe(func([]byte) {})
+	second := spy.Subscribe(func([]byte) {})
+
+	if n := len(spy.handler.subs.list()); n != 2 {
+		t.Fatalf("expected 2 subscriptions, got %d", n)
+	}
+
+	if allocs := testing.AllocsPerRun(100, func() { spy.handler.subs.list() }); allocs != 0 {
+		t.Errorf("expected no allocations, got %v", allocs)
+	}
+
+	first.Close()
+
+	if subs := spy.handler.subs.list(); len(subs) != 1 || subs[0] != second {
+		t.Fatalf("expected only the second subscription, got %v", subs)
+	}
+
+	second.Close()
+
+	if n := len(spy.handler.subs.list()); n != 0 {
+		t.Errorf("expected no subscriptions, got %d", n)
+	}
+}
@@ -0,0 +1,144 @@
+package slogspy
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+const defaultTeardownTimeout = 10 * time.Second
+
+// TeardownStage is a stage of the shutdown sequence performed by Teardown.
+type TeardownStage string
+
+const (
+	// TeardownSeal stops accepting new subscriptions (new ones are closed right away with ErrSpyShutdown)
+	TeardownSeal TeardownStage = "seal"
+	// TeardownDrain shuts the spy down: the queued records are processed and the final batch is flushed
+	TeardownDrain TeardownStage = "drain"
+	// TeardownSinks closes the sinks (outputs)
+	TeardownSinks TeardownStage = "sinks"
+	// TeardownTransports stops the transports (WebSocket handlers, HTTP servers, etc.)
+	TeardownTransports TeardownStage = "transports"
+)
+
+// Teardown coordinates the spy shutdown with its sinks and transports in the correct order:
+// stop accepting new subscriptions, drain the queue and flush, close sinks, then stop transports
+// (so the final batch still reaches the connected clients).
+type Teardown struct {
+	spy        *Spy
+	sinks      []func(context.Context) error
+	transports []func(context.Context) error
+	onStage    func(TeardownStage)
+	timeout    time.Duration
+
+	once sync.Once
+	err  error
+}
+
+type TeardownOption func(*Teardown)
+
+// WithTeardownSink adds a function to close a sink (e.g., an output connection or a file).
+// Sinks are closed in the reverse order.
+func WithTeardownSink(fn func(context.Context) error) TeardownOption {
+	return func(t *Teardown) {
+		t.sinks = append(t.sinks, fn)
+	}
+}
+
+// WithTeardownTransport adds a function to stop a transport (e.g., http.Server.Shutdown).
+// Transports are stopped in the reverse order.
+func WithTeardownTransport(fn func(context.Context) error) TeardownOption {
+	return func(t *Teardown) {
+		t.transports = append(t.transports, fn)
+	}
+}
+
+// WithTeardownHook sets a function to be called before every stage (e.g., for logging).
+func WithTeardownHook(fn func(TeardownStage)) TeardownOption {
+	return func(t *Teardown) {
+		t.onStage = fn
+	}
+}
+
+// WithTeardownTimeout sets the timeout for the whole sequence when it's triggered by Run (10s by default).
+func WithTeardownTimeout(d time.Duration) TeardownOption {
+	return func(t *Teardown) {
+		t.timeout = d
+	}
+}
+
+// NewTeardown creates a shutdown sequence for the spy.
+func NewTeardown(spy *Spy, opts ...TeardownOption) *Teardown {
+	t := &Teardown{spy: spy, timeout: defaultTeardownTimeout}
+
+	for _, opt := range opts {
+		opt(t)
+	}
+
+	return t
+}
+
+// Shutdown performs the shutdown sequence. Failed stages don't stop the sequence; all the errors are joined.
+// Only the first call performs the sequence, the subsequent ones return the same error.
+func (t *Teardown) Shutdown(ctx context.Context) error {
+	t.once.Do(func() {
+		t.err = t.shutdown(ctx)
+	})
+
+	return t.err
+}
+
+// Run waits for the context to be done and performs the shutdown sequence (limited by the teardown timeout).
+// It's meant to be used with errgroup:
+//
+//	g, ctx := errgroup.WithContext(ctx)
+//	g.Go(func() error { return spy.Run(ctx, out) })
+//	g.Go(func() error { return teardown.Run(ctx) })
+func (t *Teardown) Run(ctx context.Context) error {
+	<-ctx.Done()
+
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), t.timeout)
+	defer cancel()
+
+	return t.Shutdown(ctx)
+}
+
+func (t *Teardown) shutdown(ctx context.Context) error {
+	var errs []error
+
+	t.stage(TeardownSeal)
+	t.spy.handler.subs.sealed.Store(true)
+
+	t.stage(TeardownDrain)
+	if err := t.spy.Shutdown(ctx); err != nil {
+		errs = append(errs, err)
+	}
+
+	t.stage(TeardownSinks)
+	errs = append(errs, closeAllReverse(ctx, t.sinks)...)
+
+	t.stage(TeardownTransports)
+	errs = append(errs, closeAllReverse(ctx, t.transports)...)
+
+	return errors.Join(errs...)
+}
+
+func (t *Teardown) stage(stage TeardownStage) {
+	if t.onStage != nil {
+		t.onStage(stage)
+	}
+}
+
+func closeAllReverse(ctx context.Context, fns []func(context.Context) error) []error {
+	var errs []error
+
+	for i := len(fns) - 1; i >= 0; i-- {
+		if err := fns[i](ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errs
+}
@@ -0,0 +1,120 @@
+package slogspy
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestTeardown__Shutdown(t *testing.T) {
+	var (
+		mu     sync.Mutex
+		events []string
+	)
+
+	track := func(event string) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		events = append(events, event)
+	}
+
+	spy := NewSpy(slog.NewTextHandler(io.Discard, nil))
+
+	go spy.Run(context.Background(), func(msg []byte) { // nolint: errcheck
+		track("flush")
+	})
+
+	waitForRunning(t, spy)
+
+	spy.Watch()
+
+	slog.New(spy).Info("last words")
+
+	sinkErr := errors.New("sink failed")
+
+	teardown := NewTeardown(
+		spy,
+		WithTeardownHook(func(stage TeardownStage) { track(string(stage)) }),
+		WithTeardownSink(func(context.Context) error { track("sink 1"); return nil }),
+		WithTeardownSink(func(context.Context) error { track("sink 2"); return sinkErr }),
+		WithTeardownTransport(func(context.Context) error { track("transport"); return nil }),
+	)
+
+	err := teardown.Shutdown(context.Background())
+
+	if !errors.Is(err, sinkErr) {
+		t.Errorf("expected sink error to be returned, got: %v", err)
+	}
+
+	expected := "seal,drain,flush,sinks,sink 2,sink 1,transports,transport"
+
+	if actual := strings.Join(events, ","); actual != expected {
+		t.Errorf("expected stages order: %s, got: %s", expected, actual)
+	}
+
+	if err := teardown.Shutdown(context.Background()); !errors.Is(err, sinkErr) {
+		t.Errorf("expected the same error on the repeated call, got: %v", err)
+	}
+
+	if len(events) != 8 {
+		t.Errorf("expected the sequence to be performed once, got: %v", events)
+	}
+}
+
+func TestTeardown__Seal(t *testing.T) {
+	spy := NewSpy(slog.NewTextHandler(io.Discard, nil))
+
+	go spy.Run(context.Background(), nil) // nolint: errcheck
+
+	waitForRunning(t, spy)
+
+	if err := NewTeardown(spy).Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	sub := spy.Subscribe(func([]byte) {})
+
+	if !sub.Closed() || !errors.Is(context.Cause(sub.Context()), ErrSpyShutdown) {
+		t.Error("expected new subscriptions to be closed after shutdown")
+	}
+
+	if stats := spy.Stats(); stats.Watchers != 0 {
+		t.Errorf("expected no watchers, got %d", stats.Watchers)
+	}
+}
+
+func TestTeardown__Run(t *testing.T) {
+	spy := NewSpy(slog.NewTextHandler(io.Discard, nil))
+
+	go spy.Run(context.Background(), nil) // nolint: errcheck
+
+	waitForRunning(t, spy)
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	done := make(chan error)
+
+	var closed bool
+
+	go func() {
+		done <- NewTeardown(spy, WithTeardownSink(func(ctx context.Context) error {
+			closed = ctx.Err() == nil
+			return nil
+		})).Run(ctx)
+	}()
+
+	cancel()
+
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	if !closed {
+		t.Error("expected sinks to be closed with a live context")
+	}
+}
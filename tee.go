@@ -0,0 +1,113 @@
+package slogspy
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"sync"
+)
+
+// Tee installs a spy over the application's handler and collects all the records in memory
+// (e.g., to verify the logs in integration tests). Records are collected safely from any number of goroutines.
+type Tee struct {
+	spy  *Spy
+	done chan struct{}
+
+	mu      sync.Mutex
+	records []slog.Record
+}
+
+// NewTee creates a Tee over the parent handler and starts collecting records right away.
+// The backlog overflow policy is OverflowBlock by default, and records logged with canceled contexts are captured,
+// so no records are dropped.
+func NewTee(parent slog.Handler, opts ...SpyHandlerOption) *Tee {
+	t := &Tee{
+		spy:  NewSpy(parent, append([]SpyHandlerOption{WithOverflowPolicy(OverflowBlock), WithCanceledContexts()}, opts...)...),
+		done: make(chan struct{}),
+	}
+
+	t.spy.Watch()
+
+	go func() {
+		defer close(t.done)
+		t.spy.RunRecords(context.Background(), t.collect) // nolint: errcheck
+	}()
+
+	return t
+}
+
+// Handler returns the handler to install into the application's logger.
+func (t *Tee) Handler() slog.Handler {
+	return t.spy
+}
+
+// Logger returns a new logger using the Tee handler.
+func (t *Tee) Logger() *slog.Logger {
+	return slog.New(t.spy)
+}
+
+// Close stops collecting records: the records logged before the call are flushed.
+func (t *Tee) Close(ctx context.Context) error {
+	if err := t.spy.Shutdown(ctx); err != nil {
+		return err
+	}
+
+	select {
+	case <-t.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Records returns the collected records ordered by time (the logger attributes and groups are resolved),
+// so they could be queried via RecordView.Lookup.
+func (t *Tee) Records() []*RecordView {
+	t.mu.Lock()
+	records := slices.Clone(t.records)
+	t.mu.Unlock()
+
+	slices.SortStableFunc(records, func(a, b slog.Record) int {
+		return a.Time.Compare(b.Time)
+	})
+
+	views := make([]*RecordView, len(records))
+
+	for i, r := range records {
+		views[i] = &RecordView{Record: r}
+	}
+
+	return views
+}
+
+// Find returns the collected records matching the filter (see Records).
+func (t *Tee) Find(f Filter) []*RecordView {
+	var found []*RecordView
+
+	for _, r := range t.Records() {
+		if f(r) {
+			found = append(found, r)
+		}
+	}
+
+	return found
+}
+
+// Messages returns the messages of the collected records in order.
+func (t *Tee) Messages() []string {
+	records := t.Records()
+	msgs := make([]string, len(records))
+
+	for i, r := range records {
+		msgs[i] = r.Record.Message
+	}
+
+	return msgs
+}
+
+func (t *Tee) collect(records []slog.Record) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.records = append(t.records, records...)
+}
@@ -0,0 +1,66 @@
+package slogspy
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"slices"
+	"sync"
+	"testing"
+)
+
+func TestTee(t *testing.T) {
+	main := &bytes.Buffer{}
+
+	tee := NewTee(slog.NewTextHandler(main, nil))
+	logger := tee.Logger().With("component", "worker")
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+
+		go func(i int) {
+			defer wg.Done()
+
+			for j := 0; j < 100; j++ {
+				logger.Debug("job", "worker", i, "job", j)
+			}
+		}(i)
+	}
+
+	wg.Wait()
+
+	logger.Info("done")
+
+	if err := tee.Close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	records := tee.Records()
+
+	if len(records) != 1001 {
+		t.Fatalf("expected all records to be collected, got %d", len(records))
+	}
+
+	if msgs := tee.Messages(); msgs[len(msgs)-1] != "done" {
+		t.Errorf("expected records to be ordered, got the last one: %s", msgs[len(msgs)-1])
+	}
+
+	jobs := tee.Find(WhereAttr("worker", "==", 3))
+
+	if len(jobs) != 100 {
+		t.Errorf("expected 100 records of worker 3, got %d", len(jobs))
+	}
+
+	if val, ok := jobs[0].Lookup("component"); !ok || val.String() != "worker" {
+		t.Errorf("expected logger attributes to be resolved, got %v", val)
+	}
+
+	if !slices.IsSortedFunc(records, func(a, b *RecordView) int { return a.Record.Time.Compare(b.Record.Time) }) {
+		t.Errorf("expected records to be sorted by time")
+	}
+
+	// The main handler still receives the records
+	assertBufferContains(t, main, "msg=done component=worker")
+}
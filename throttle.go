@@ -0,0 +1,58 @@
+package slogspy
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// WithSubscriptionThrottle limits the subscription delivery rate to the specified number of bytes per second
+// allowing bursts of up to burst bytes (at least the rate). Batches exceeding the budget
+// are dropped; once the delivery resumes, the subscription receives a notice with the number of elided
+// records and bytes before the next batch:
+//
+//	{"type":"control","event":"throttle","records":120,"bytes":48213}
+func WithSubscriptionThrottle(rate int, burst int) SubscriptionOption {
+	return func(s *Subscription) {
+		if rate > 0 {
+			s.throttle = &throttleState{rate: float64(rate), burst: float64(max(rate, burst))}
+			s.throttle.bucket = tokenBucket{tokens: s.throttle.burst, last: time.Now()}
+		}
+	}
+}
+
+type throttleState struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	bucket tokenBucket
+
+	// records and bytes elided since the last delivered batch
+	records int64
+	bytes   int64
+}
+
+// allow consumes n bytes from the budget; it returns the number of records and bytes
+// elided before this batch (if it's allowed)
+func (t *throttleState) allow(n int, records int) (bool, int64, int64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+
+	t.bucket.tokens = math.Min(t.burst, t.bucket.tokens+now.Sub(t.bucket.last).Seconds()*t.rate)
+	t.bucket.last = now
+
+	if t.bucket.tokens < float64(n) {
+		t.records += int64(records)
+		t.bytes += int64(n)
+		return false, 0, 0
+	}
+
+	t.bucket.tokens -= float64(n)
+
+	elidedRecords, elidedBytes := t.records, t.bytes
+	t.records, t.bytes = 0, 0
+
+	return true, elidedRecords, elidedBytes
+}
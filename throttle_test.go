@@ -0,0 +1,46 @@
+package slogspy
+
+import (
+	"bytes"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+func TestSubscription__Throttle(t *testing.T) {
+	spy := NewSpy(slog.NewTextHandler(&bytes.Buffer{}, nil))
+
+	var msgs []string
+
+	sub := spy.Subscribe(func(msg []byte) { msgs = append(msgs, string(msg)) }, WithSubscriptionThrottle(100, 10))
+	defer sub.Close()
+
+	// The burst allowance is raised to the rate
+	sub.deliver(bytes.Repeat([]byte("a"), 80), 2)
+	sub.deliver(bytes.Repeat([]byte("b"), 40), 3)
+	sub.deliver(bytes.Repeat([]byte("c"), 30), 1)
+
+	if len(msgs) != 1 {
+		t.Fatalf("expected batches exceeding the budget to be dropped, got %d messages", len(msgs))
+	}
+
+	time.Sleep(300 * time.Millisecond)
+
+	sub.deliver([]byte("d"), 1)
+
+	if len(msgs) != 3 {
+		t.Fatalf("expected throttle notice and the batch, got %v", msgs)
+	}
+
+	if msgs[1] != "{\"bytes\":70,\"event\":\"throttle\",\"records\":4,\"type\":\"control\"}\n" {
+		t.Errorf("unexpected throttle notice: %s", msgs[1])
+	}
+
+	if msgs[2] != "d" {
+		t.Errorf("expected the batch after the notice, got %s", msgs[2])
+	}
+
+	if sub.records.Load() != 3 {
+		t.Errorf("expected only delivered records to be counted, got %d", sub.records.Load())
+	}
+}
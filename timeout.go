@@ -0,0 +1,264 @@
+package slogspy
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultSlowOutputBuffer = 16
+
+	// DroppedBatchMessage is the message of the records passed to the drop callback (see WithOnDropReason)
+	// for the batches dropped by stalled outputs; the "bytes" attribute contains the batch size
+	DroppedBatchMessage = "slogspy: batch dropped"
+)
+
+var (
+	// ErrOutputTimeout is reported to the error handler when an output doesn't complete a write within the output timeout
+	ErrOutputTimeout = errors.New("output timed out")
+	// ErrSlowConsumer is the cause of subscriptions closed due to stalled outputs (see SlowOutputEvict)
+	ErrSlowConsumer = errors.New("subscription output is too slow")
+)
+
+// SlowOutputPolicy defines what to do with the batches when an output doesn't complete a write within the output timeout.
+type SlowOutputPolicy int
+
+const (
+	// SlowOutputDrop drops the batches until the stalled output completes the write (the default).
+	SlowOutputDrop SlowOutputPolicy = iota
+	// SlowOutputBuffer queues the batches for the stalled output up to the buffer size (see WithSlowOutputBuffer)
+	// and drops the rest.
+	SlowOutputBuffer
+	// SlowOutputEvict closes the subscriptions with stalled outputs (the cause is ErrSlowConsumer);
+	// the batches for other outputs are dropped.
+	SlowOutputEvict
+)
+
+// WithOutputTimeout makes the Run loop wait for the output (as well as subscriptions, level routes and error trigger
+// outputs) to complete a write at most for the specified duration, so a hung consumer doesn't stall spying.
+// Outputs are called from dedicated goroutines in this mode; the slow output policy decides what to do once an output
+// is stalled. Dropped batches are counted as DropSlowOutput drops, timeouts are counted in Stats().SlowOutputs
+// and reported to the error handler (ErrOutputTimeout).
+func WithOutputTimeout(timeout time.Duration) SpyHandlerOption {
+	return func(h *SpyHandler) {
+		h.slowOutputConfig().timeout = timeout
+	}
+}
+
+// WithSlowOutputPolicy sets the policy to apply when an output is stalled (see WithOutputTimeout).
+func WithSlowOutputPolicy(policy SlowOutputPolicy) SpyHandlerOption {
+	return func(h *SpyHandler) {
+		h.slowOutputConfig().policy = policy
+	}
+}
+
+// WithSlowOutputBuffer sets the number of batches queued for a stalled output (in addition to the one being written)
+// when using SlowOutputBuffer (16 by default).
+func WithSlowOutputBuffer(size int) SpyHandlerOption {
+	return func(h *SpyHandler) {
+		h.slowOutputConfig().buffer = size
+	}
+}
+
+type slowOutputConfig struct {
+	timeout time.Duration
+	policy  SlowOutputPolicy
+	buffer  int
+}
+
+func (h *SpyHandler) slowOutputConfig() *slowOutputConfig {
+	if h.slowOutput == nil {
+		h.slowOutput = &slowOutputConfig{buffer: defaultSlowOutputBuffer}
+	}
+
+	return h.slowOutput
+}
+
+// withOutputTimeout wraps the output used by the Run loop into the timed one if the output timeout is configured;
+// the returned function stops the timed output
+func (h *SpyHandler) withOutputTimeout(out SpyOutput) (SpyOutput, func()) {
+	if out == nil {
+		return nil, func() {}
+	}
+
+	timed := h.newTimedOutput(nil)
+
+	if timed == nil {
+		return out, func() {}
+	}
+
+	return timed.wrap(out), timed.close
+}
+
+// newTimedOutput returns the timed output if the output timeout is configured (nil otherwise);
+// the evict function is called (from a separate goroutine) when the output is stalled with SlowOutputEvict
+func (h *SpyHandler) newTimedOutput(evict func()) *timedOutput {
+	if h.slowOutput == nil || h.slowOutput.timeout <= 0 {
+		return nil
+	}
+
+	size := 1
+
+	if h.slowOutput.policy == SlowOutputBuffer && h.slowOutput.buffer > 1 {
+		size = h.slowOutput.buffer
+	}
+
+	t := &timedOutput{
+		timeout:      h.slowOutput.timeout,
+		policy:       h.slowOutput.policy,
+		evict:        evict,
+		ch:           make(chan timedBatch, size),
+		errorHandler: h.errorHandler,
+		onDrop:       h.onDrop,
+		stats:        h.stats,
+	}
+
+	go t.run()
+
+	return t
+}
+
+type timedBatch struct {
+	out     SpyOutput
+	msg     []byte
+	written chan struct{}
+}
+
+// timedOutput calls outputs from its own goroutine and waits for the writes up to the timeout
+type timedOutput struct {
+	timeout time.Duration
+	policy  SlowOutputPolicy
+	evict   func()
+
+	ch chan timedBatch
+	// stalled is set when a write times out and reset once it completes
+	stalled atomic.Bool
+
+	mu     sync.Mutex
+	closed bool
+
+	errorHandler func(err error)
+	onDrop       func(r slog.Record, reason DropReason)
+	stats        *spyStats
+}
+
+// wrap returns the output writing via the timed output
+func (t *timedOutput) wrap(out SpyOutput) SpyOutput {
+	return func(msg []byte) {
+		t.write(out, msg)
+	}
+}
+
+func (t *timedOutput) write(out SpyOutput, msg []byte) {
+	t.mu.Lock()
+
+	if t.closed {
+		t.mu.Unlock()
+		return
+	}
+
+	stalled := t.stalled.Load()
+
+	if stalled && t.policy != SlowOutputBuffer {
+		t.mu.Unlock()
+		t.drop(msg)
+		return
+	}
+
+	// The message buffer is reused after the output returns
+	batch := timedBatch{out: out, msg: bytes.Clone(msg), written: make(chan struct{})}
+
+	select {
+	case t.ch <- batch:
+	default:
+		t.mu.Unlock()
+		t.drop(msg)
+		return
+	}
+
+	t.mu.Unlock()
+
+	// Don't wait for the stalled output
+	if stalled {
+		return
+	}
+
+	timer := time.NewTimer(t.timeout)
+	defer timer.Stop()
+
+	select {
+	case <-batch.written:
+		return
+	case <-timer.C:
+	}
+
+	t.stalled.Store(true)
+
+	// The write might have been completed right after the timeout
+	select {
+	case <-batch.written:
+		t.stalled.Store(false)
+	default:
+	}
+
+	t.stats.slowOutputs.Add(1)
+
+	if t.errorHandler != nil {
+		t.errorHandler(ErrOutputTimeout)
+	}
+
+	if t.policy == SlowOutputEvict && t.evict != nil {
+		go t.evict()
+	}
+}
+
+// drop accounts the batch dropped due to the stalled output; the drop callback receives the batch
+// as a record with the DroppedBatchMessage message
+func (t *timedOutput) drop(msg []byte) {
+	t.stats.trackDrop(DropSlowOutput)
+
+	if t.onDrop != nil {
+		r := slog.NewRecord(time.Now(), slog.LevelWarn, DroppedBatchMessage, 0)
+		r.AddAttrs(slog.Int("bytes", len(msg)))
+
+		t.onDrop(r, DropSlowOutput)
+	}
+}
+
+func (t *timedOutput) run() {
+	for batch := range t.ch {
+		t.deliver(batch)
+		close(batch.written)
+		t.stalled.Store(false)
+	}
+}
+
+func (t *timedOutput) deliver(batch timedBatch) {
+	defer func() {
+		if err := recover(); err != nil {
+			t.stats.trackDrop(DropSinkFailure)
+
+			if t.errorHandler != nil {
+				t.errorHandler(fmt.Errorf("output panicked: %v", err))
+			}
+		}
+	}()
+
+	batch.out(batch.msg)
+}
+
+// close stops accepting batches; the queued ones are still delivered (the stalled output is never waited for)
+func (t *timedOutput) close() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if !t.closed {
+		t.closed = true
+		close(t.ch)
+	}
+}
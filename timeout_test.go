@@ -0,0 +1,220 @@
+package slogspy
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSpy__OutputTimeout(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		errs    []error
+		dropped []slog.Record
+	)
+
+	spy := NewSpy(
+		slog.NewTextHandler(&bytes.Buffer{}, nil),
+		WithOutputTimeout(10*time.Millisecond),
+		WithErrorHandler(func(err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			errs = append(errs, err)
+		}),
+		WithOnDropReason(func(r slog.Record, reason DropReason) {
+			mu.Lock()
+			defer mu.Unlock()
+
+			if reason == DropSlowOutput {
+				dropped = append(dropped, r)
+			}
+		}),
+	)
+
+	unblock := make(chan struct{})
+	written := make(chan []byte, 10)
+
+	go spy.Run(context.Background(), func(msg []byte) { // nolint: errcheck
+		<-unblock
+		written <- bytes.Clone(msg)
+	})
+
+	waitForRunning(t, spy)
+	spy.Watch()
+
+	logger := slog.New(spy)
+
+	logger.Info("stalled")
+	spy.handler.requestFlush(context.Background(), nil) // nolint: errcheck
+
+	// The output is stalled, so the batch is dropped without waiting
+	start := time.Now()
+
+	logger.Info("dropped")
+	spy.handler.requestFlush(context.Background(), nil) // nolint: errcheck
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected the Run loop not to wait for the stalled output, took %s", elapsed)
+	}
+
+	stats := spy.Stats()
+
+	if stats.SlowOutputs != 1 {
+		t.Errorf("expected 1 slow output, got %d", stats.SlowOutputs)
+	}
+
+	if stats.Drops[DropSlowOutput] != 1 {
+		t.Errorf("expected 1 slow output drop, got %v", stats.Drops)
+	}
+
+	mu.Lock()
+	if len(errs) != 1 || !errors.Is(errs[0], ErrOutputTimeout) {
+		t.Errorf("expected the timeout to be reported, got: %v", errs)
+	}
+
+	if len(dropped) != 1 || dropped[0].Message != DroppedBatchMessage {
+		t.Errorf("expected the drop callback to be called for the dropped batch, got: %v", dropped)
+	}
+	mu.Unlock()
+
+	close(unblock)
+
+	if msg := <-written; !bytes.Contains(msg, []byte("stalled")) {
+		t.Errorf("expected the stalled batch to be written eventually, got: %s", msg)
+	}
+
+	// The output is marked as recovered right after the write completes
+	waitFor(t, func() bool {
+		logger.Info("recovered")
+		spy.handler.requestFlush(context.Background(), nil) // nolint: errcheck
+
+		select {
+		case msg := <-written:
+			return bytes.Contains(msg, []byte("recovered"))
+		default:
+			return false
+		}
+	})
+
+	spy.Shutdown(context.Background()) // nolint: errcheck
+}
+
+func TestSubscription__SlowOutputEvict(t *testing.T) {
+	spy := NewSpy(
+		slog.NewTextHandler(&bytes.Buffer{}, nil),
+		WithOutputTimeout(10*time.Millisecond),
+		WithSlowOutputPolicy(SlowOutputEvict),
+	)
+
+	unblock := make(chan struct{})
+	defer close(unblock)
+
+	sub := spy.Subscribe(func(msg []byte) { <-unblock })
+
+	go spy.Run(context.Background(), nil) // nolint: errcheck
+
+	slog.New(spy).Info("stalled")
+	spy.handler.requestFlush(context.Background(), nil) // nolint: errcheck
+
+	select {
+	case <-sub.Context().Done():
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected the subscription to be evicted")
+	}
+
+	if cause := context.Cause(sub.Context()); cause != ErrSlowConsumer {
+		t.Errorf("expected the slow consumer cause, got: %v", cause)
+	}
+
+	spy.Shutdown(context.Background()) // nolint: errcheck
+}
+
+func TestSubscription__SlowOutputBuffer(t *testing.T) {
+	spy := NewSpy(
+		slog.NewTextHandler(&bytes.Buffer{}, nil),
+		WithOutputTimeout(10*time.Millisecond),
+		WithSlowOutputPolicy(SlowOutputBuffer),
+		WithSlowOutputBuffer(2),
+	)
+
+	unblock := make(chan struct{})
+
+	var (
+		mu  sync.Mutex
+		buf bytes.Buffer
+	)
+
+	spy.Subscribe(func(msg []byte) {
+		<-unblock
+
+		mu.Lock()
+		defer mu.Unlock()
+		buf.Write(msg)
+	})
+
+	go spy.Run(context.Background(), nil) // nolint: errcheck
+
+	logger := slog.New(spy)
+
+	// The first batch is being written, the next two are queued
+	for _, msg := range []string{"first", "second", "third", "fourth"} {
+		logger.Info(msg)
+		spy.handler.requestFlush(context.Background(), nil) // nolint: errcheck
+	}
+
+	if drops := spy.Stats().Drops[DropSlowOutput]; drops != 1 {
+		t.Errorf("expected 1 batch to be dropped once the buffer is full, got %d", drops)
+	}
+
+	close(unblock)
+
+	waitFor(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return bytes.Contains(buf.Bytes(), []byte("third"))
+	})
+
+	mu.Lock()
+	assertBufferContains(t, &buf, "first")
+	assertBufferContains(t, &buf, "second")
+	assertBufferContainsNot(t, &buf, "fourth")
+	mu.Unlock()
+
+	spy.Shutdown(context.Background()) // nolint: errcheck
+}
+
+func TestSpy__OutputTimeout_LevelRoute(t *testing.T) {
+	unblock := make(chan struct{})
+	defer close(unblock)
+
+	output := &bytes.Buffer{}
+
+	spy := NewSpy(
+		slog.NewTextHandler(&bytes.Buffer{}, nil),
+		WithOutputTimeout(10*time.Millisecond),
+		WithLevelRoute(slog.LevelError, func(msg []byte) { <-unblock }),
+	)
+
+	go spy.Run(context.Background(), func(msg []byte) { output.Write(msg) }) // nolint: errcheck
+
+	waitForRunning(t, spy)
+	spy.Watch()
+
+	logger := slog.New(spy)
+	logger.Error("stalled")
+	logger.Info("delivered")
+
+	if err := spy.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	assertBufferContains(t, output, "delivered")
+
+	if n := spy.Stats().SlowOutputs; n != 1 {
+		t.Errorf("expected 1 slow output, got %d", n)
+	}
+}
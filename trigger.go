@@ -0,0 +1,124 @@
+package slogspy
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log/slog"
+	"runtime/debug"
+	"time"
+)
+
+const (
+	defaultTriggerHistory = 256
+	panicDumpTimeout      = time.Second
+)
+
+// PanicMessage is the message of the record logged by DumpOnPanic.
+const PanicMessage = "panic"
+
+// WithErrorTrigger makes the spy record into the history ring even with no watchers and deliver the recent records
+// to the output every time a record of the level (or above) is logged. This way, you get the debug logs
+// leading up to every error without keeping debug logging on globally.
+// The delivered batch contains the printed records (the last one is the trigger record); the history is cleared after that.
+// The trigger is not used in the structured delivery mode.
+func WithErrorTrigger(level slog.Level, out SpyOutput) SpyHandlerOption {
+	return func(h *SpyHandler) {
+		h.triggerConfig().level = level
+		h.trigger.output = out
+	}
+}
+
+// WithTriggerHistory sets the number of recent records kept for the error trigger (256 by default).
+func WithTriggerHistory(size int) SpyHandlerOption {
+	return func(h *SpyHandler) {
+		if size > 0 {
+			h.triggerConfig().history = make([][]byte, size)
+		}
+	}
+}
+
+func (h *SpyHandler) triggerConfig() *errorTrigger {
+	if h.trigger == nil {
+		h.trigger = &errorTrigger{history: make([][]byte, defaultTriggerHistory)}
+	}
+
+	return h.trigger
+}
+
+// errorTrigger keeps the recently printed records; it's only accessed by the Run loop
+type errorTrigger struct {
+	level  slog.Level
+	output SpyOutput
+	// sink is the output used by the Run loop (see WithOutputTimeout)
+	sink SpyOutput
+
+	history [][]byte
+	pos     int
+	n       int
+	buf     bytes.Buffer
+}
+
+// remember puts the printed record into the history ring (reusing the buffers of the evicted records)
+func (t *errorTrigger) remember(printed []byte) {
+	t.history[t.pos] = append(t.history[t.pos][:0], printed...)
+	t.pos = (t.pos + 1) % len(t.history)
+
+	if t.n < len(t.history) {
+		t.n++
+	}
+}
+
+// triggerDump delivers the recent records to the trigger output and clears the history
+func (h *SpyHandler) triggerDump() {
+	t := h.trigger
+
+	if t.n == 0 || t.sink == nil {
+		return
+	}
+
+	t.buf.Reset()
+
+	for i := t.n; i > 0; i-- {
+		t.buf.Write(t.history[(t.pos-i+len(t.history))%len(t.history)])
+	}
+
+	t.n = 0
+
+	t.sink(t.buf.Bytes())
+	h.stats.dumps.Add(1)
+}
+
+// DumpOnPanic logs the recovered panic (with the stack trace) at the error trigger level, so the recent records
+// are delivered to the trigger output (see WithErrorTrigger), waits for the spy to flush and re-panics.
+// It must be deferred directly:
+//
+//	defer spy.DumpOnPanic()
+func (h *SpyHandler) DumpOnPanic() {
+	if v := recover(); v != nil {
+		h.dumpPanic(v)
+		panic(v)
+	}
+}
+
+func (h *SpyHandler) dumpPanic(v any) {
+	if h.closed.Load() {
+		return
+	}
+
+	level := slog.LevelError
+
+	if h.trigger != nil {
+		level = h.trigger.level
+	}
+
+	r := slog.NewRecord(time.Now(), level, PanicMessage, 0)
+	r.AddAttrs(slog.String("panic", fmt.Sprint(v)), slog.String("stack", string(debug.Stack())))
+
+	h.enqueueRecord(context.Background(), r, nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), panicDumpTimeout)
+	defer cancel()
+
+	h.requestFlush(ctx, nil) // nolint: errcheck
+}
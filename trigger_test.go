@@ -0,0 +1,97 @@
+package slogspy
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestSpy__WithErrorTrigger(t *testing.T) {
+	var main bytes.Buffer
+	var dumps []string
+
+	spy := NewSpy(
+		slog.NewTextHandler(io.Discard, &slog.HandlerOptions{Level: slog.LevelInfo}),
+		WithErrorTrigger(slog.LevelError, func(msg []byte) { dumps = append(dumps, string(msg)) }),
+		WithTriggerHistory(3),
+	)
+
+	go spy.Run(context.Background(), func(msg []byte) { // nolint: errcheck
+		main.Write(msg)
+	})
+
+	logger := slog.New(spy)
+
+	if !logger.Enabled(context.Background(), slog.LevelDebug) {
+		t.Fatal("expected the spy to be enabled with no watchers")
+	}
+
+	for _, msg := range []string{"d1", "d2", "d3"} {
+		logger.Debug(msg)
+	}
+
+	logger.Error("e1")
+	logger.Debug("d4")
+	logger.Error("e2")
+
+	if err := spy.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(dumps) != 2 {
+		t.Fatalf("expected 2 dumps, got %d: %v", len(dumps), dumps)
+	}
+
+	for i, expected := range [][]string{{"d2", "d3", "e1"}, {"d4", "e2"}} {
+		if n := strings.Count(dumps[i], "\n"); n != len(expected) {
+			t.Errorf("expected %d records in dump #%d, got: %s", len(expected), i, dumps[i])
+		}
+
+		for _, msg := range expected {
+			if !strings.Contains(dumps[i], `"msg":"`+msg+`"`) {
+				t.Errorf("expected dump #%d to contain %s, got: %s", i, msg, dumps[i])
+			}
+		}
+	}
+
+	// Records are not delivered to the main output without watchers
+	if main.Len() != 0 {
+		t.Errorf("expected no batches, got: %s", main.String())
+	}
+
+	if n := spy.Stats().Dumps; n != 2 {
+		t.Errorf("expected 2 dumps to be reported, got %d", n)
+	}
+}
+
+func TestSpy__DumpOnPanic(t *testing.T) {
+	var dump bytes.Buffer
+
+	spy := NewSpy(
+		slog.NewTextHandler(io.Discard, nil),
+		WithErrorTrigger(slog.LevelError, func(msg []byte) { dump.Write(msg) }),
+	)
+
+	go spy.Run(context.Background(), nil)                    // nolint: errcheck
+	t.Cleanup(func() { spy.Shutdown(context.Background()) }) // nolint: errcheck
+
+	logger := slog.New(spy)
+
+	recovered := func() (v any) {
+		defer func() { v = recover() }()
+		defer spy.DumpOnPanic()
+
+		logger.Debug("charging card", "amount", 42)
+		panic("boom")
+	}()
+
+	if recovered != "boom" {
+		t.Fatalf("expected the panic to be propagated, got: %v", recovered)
+	}
+
+	assertBufferContains(t, &dump, `"msg":"charging card"`)
+	assertBufferContains(t, &dump, `"msg":"panic","panic":"boom","stack":"goroutine`)
+}
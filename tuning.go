@@ -0,0 +1,190 @@
+package slogspy
+
+import (
+	"fmt"
+	"time"
+)
+
+// SetFlushInterval changes the max flush interval; the change is applied by the Run loop
+// (or when it starts), so it's safe to call it while the process is live.
+func (h *SpyHandler) SetFlushInterval(interval time.Duration) error {
+	apply, err := flushIntervalChange(interval)
+
+	if err != nil {
+		return err
+	}
+
+	h.configure(apply)
+
+	return nil
+}
+
+func flushIntervalChange(interval time.Duration) (func(h *SpyHandler), error) {
+	if interval <= 0 {
+		return nil, fmt.Errorf("invalid flush interval: %s", interval)
+	}
+
+	return func(h *SpyHandler) {
+		h.flushInterval = interval
+
+		// Make sure the buffered records don't wait for the previous interval
+		if h.timerC != nil {
+			h.stopTimer()
+			h.armTimer()
+		}
+	}, nil
+}
+
+// SetMaxBufSize changes the maximum output buffer size; the change is applied by the Run loop.
+func (h *SpyHandler) SetMaxBufSize(size int) error {
+	apply, err := maxBufSizeChange(size)
+
+	if err != nil {
+		return err
+	}
+
+	h.configure(apply)
+
+	return nil
+}
+
+func maxBufSizeChange(size int) (func(h *SpyHandler), error) {
+	if size <= 0 {
+		return nil, fmt.Errorf("invalid buffer size: %d", size)
+	}
+
+	return func(h *SpyHandler) {
+		h.maxBufSize = size
+
+		if h.buf.Len() > h.maxBufSize {
+			h.flush()
+		}
+	}, nil
+}
+
+// Resize replaces the backlog channel with a new one of the specified size; the change is applied by the Run loop.
+// Records queued so far are processed before the new backlog is used, so they're never dropped.
+func (h *SpyHandler) Resize(backlog int) error {
+	apply, err := resizeChange(backlog)
+
+	if err != nil {
+		return err
+	}
+
+	h.configure(apply)
+
+	return nil
+}
+
+func resizeChange(backlog int) (func(h *SpyHandler), error) {
+	// Non-blocking sends to an unbuffered channel would drop almost every record
+	if backlog < 1 {
+		return nil, fmt.Errorf("invalid backlog size: %d", backlog)
+	}
+
+	return func(h *SpyHandler) {
+		// Records left in the previously retired backlogs go first
+		h.processRetired()
+
+		old := h.queue()
+		ch := make(chan *Entry, backlog)
+		h.ch.Store(&ch)
+
+		// Producers which loaded the old backlog before the swap could still send records to it
+		h.retire(old)
+
+		for n := len(old); n > 0; n-- {
+			h.processAndFlush(<-old)
+		}
+	}, nil
+}
+
+// retiredSweepInterval is how often the Run loop checks the retired backlogs for late records
+const retiredSweepInterval = 10 * time.Millisecond
+
+// retiredBacklog is the backlog replaced by Resize; it's kept until no producer could send to it anymore
+type retiredBacklog struct {
+	ch chan *Entry
+	at time.Time
+}
+
+// retire adds the backlog to the list of the retired ones swept by the Run loop
+func (h *SpyHandler) retire(ch chan *Entry) {
+	h.retired = append(h.retired, retiredBacklog{ch: ch, at: time.Now()})
+
+	if h.retiredTicker == nil {
+		h.retiredTicker = time.NewTicker(retiredSweepInterval)
+		h.retiredC = h.retiredTicker.C
+	}
+}
+
+// processRetired processes the records sent to the retired backlogs; it returns true if there were any
+func (h *SpyHandler) processRetired() bool {
+	processed := false
+
+	for _, r := range h.retired {
+		for n := len(r.ch); n > 0; n-- {
+			h.processAndFlush(<-r.ch)
+			processed = true
+		}
+	}
+
+	return processed
+}
+
+// sweepRetired processes the late records and forgets the retired backlogs once producers can't hold them anymore
+// (sending to a backlog takes at most the block timeout)
+func (h *SpyHandler) sweepRetired() {
+	h.processRetired()
+
+	grace := h.blockTimeout + time.Second
+	kept := h.retired[:0]
+
+	for _, r := range h.retired {
+		if len(r.ch) > 0 || time.Since(r.at) < grace {
+			kept = append(kept, r)
+		}
+	}
+
+	clear(h.retired[len(kept):])
+	h.retired = kept
+
+	if len(h.retired) == 0 {
+		h.retiredTicker.Stop()
+		h.retiredTicker = nil
+		h.retiredC = nil
+	}
+}
+
+// configure sends the configuration change to the Run loop or postpones it until the loop starts
+func (h *SpyHandler) configure(apply func(h *SpyHandler)) {
+	h.state.mu.Lock()
+
+	if !h.state.running {
+		h.state.pending = append(h.state.pending, apply)
+		h.state.mu.Unlock()
+		return
+	}
+
+	done := h.state.done
+	h.state.mu.Unlock()
+
+	select {
+	case h.ctrl <- &Entry{cmd: SpyCommandConfigure, apply: apply}:
+	case <-done:
+		// The loop has exited; try again to postpone the change
+		h.configure(apply)
+	}
+}
+
+// applyPending applies the configuration changes made while the Run loop wasn't running
+func (h *SpyHandler) applyPending() {
+	h.state.mu.Lock()
+	pending := h.state.pending
+	h.state.pending = nil
+	h.state.mu.Unlock()
+
+	for _, apply := range pending {
+		apply(h)
+	}
+}
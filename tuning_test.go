@@ -0,0 +1,188 @@
+package slogspy
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSpy__SetFlushInterval(t *testing.T) {
+	flushed := make(chan []byte, 1)
+
+	spy := NewSpy(slog.NewTextHandler(io.Discard, nil), WithFlushInterval(time.Hour))
+
+	go spy.Run(context.Background(), func(msg []byte) { // nolint: errcheck
+		flushed <- msg
+	})
+
+	waitForRunning(t, spy)
+
+	spy.Watch()
+	slog.New(spy).Info("waiting for flush")
+
+	if err := spy.SetFlushInterval(10 * time.Millisecond); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-flushed:
+	case <-time.After(time.Second):
+		t.Fatal("expected the armed timer to use the new interval")
+	}
+
+	if err := spy.SetFlushInterval(0); err == nil {
+		t.Error("expected zero interval to be rejected")
+	}
+
+	spy.Shutdown(context.Background()) // nolint: errcheck
+}
+
+func TestSpy__SetMaxBufSize(t *testing.T) {
+	flushed := make(chan []byte, 10)
+
+	spy := NewSpy(slog.NewTextHandler(io.Discard, nil), WithFlushInterval(time.Hour))
+
+	// Changes made before the loop starts are applied on start
+	if err := spy.SetMaxBufSize(1); err != nil {
+		t.Fatal(err)
+	}
+
+	go spy.Run(context.Background(), func(msg []byte) { // nolint: errcheck
+		flushed <- msg
+	})
+
+	spy.Watch()
+	slog.New(spy).Info("flushed immediately")
+
+	select {
+	case msg := <-flushed:
+		if !bytes.Contains(msg, []byte("flushed immediately")) {
+			t.Errorf("unexpected batch: %s", msg)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected the record to be flushed right away")
+	}
+
+	if err := spy.SetMaxBufSize(-1); err == nil {
+		t.Error("expected negative buffer size to be rejected")
+	}
+
+	spy.Shutdown(context.Background()) // nolint: errcheck
+}
+
+func TestSpy__Resize(t *testing.T) {
+	var (
+		mu      sync.Mutex
+		records []string
+	)
+
+	release := make(chan struct{})
+
+	spy := NewSpy(slog.NewTextHandler(io.Discard, nil), WithBacklogSize(2), WithMaxBufSize(1))
+
+	go spy.Run(context.Background(), func(msg []byte) { // nolint: errcheck
+		<-release
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		records = append(records, string(msg))
+	})
+
+	waitForRunning(t, spy)
+
+	spy.Watch()
+	logger := slog.New(spy)
+
+	// The first record blocks the loop in the output, the next ones are queued
+	logger.Info("record 0")
+	waitFor(t, func() bool { return spy.Stats().QueueDepth == 0 })
+
+	logger.Info("record 1")
+	logger.Info("record 2")
+
+	if err := spy.Resize(10); err != nil {
+		t.Fatal(err)
+	}
+
+	close(release)
+
+	if err := spy.handler.requestFlush(context.Background(), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if actual := cap(spy.handler.queue()); actual != 10 {
+		t.Errorf("expected backlog of 10, got %d", actual)
+	}
+
+	for i := 3; i < 10; i++ {
+		logger.Info(fmt.Sprintf("record %d", i))
+	}
+
+	if err := spy.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if stats := spy.Stats(); stats.Dropped != 0 {
+		t.Errorf("expected no drops, got %d", stats.Dropped)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	output := strings.Join(records, "")
+	pos := 0
+
+	for i := 0; i < 10; i++ {
+		idx := strings.Index(output[pos:], fmt.Sprintf("record %d", i))
+
+		if idx < 0 {
+			t.Fatalf("expected record %d to be delivered in order, got: %s", i, output)
+		}
+
+		pos += idx
+	}
+}
+
+func TestSpy__Resize_LateProducers(t *testing.T) {
+	buf := &bytes.Buffer{}
+
+	spy := NewSpy(slog.NewTextHandler(io.Discard, nil))
+
+	go spy.Run(context.Background(), func(msg []byte) { buf.Write(msg) }) // nolint: errcheck
+
+	waitForRunning(t, spy)
+	spy.Watch()
+
+	// A producer loaded the first backlog before two quick resizes
+	first := spy.handler.queue()
+
+	for _, size := range []int{8, 16} {
+		if err := spy.Resize(size); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := spy.handler.requestFlush(context.Background(), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	r := slog.NewRecord(time.Now(), slog.LevelInfo, "late", 0)
+	first <- &Entry{record: r}
+
+	if err := spy.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	assertBufferContains(t, buf, "late")
+
+	if err := spy.Resize(0); err == nil {
+		t.Error("expected the empty backlog to be rejected")
+	}
+}
@@ -0,0 +1,754 @@
+package slogspy
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1" // nolint: gosec
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultWebsocketSendBuffer   = 64
+	defaultWebsocketPingInterval = 30 * time.Second
+	defaultWebsocketWriteTimeout = 10 * time.Second
+	defaultWebsocketMaxFrameSize = 1024 * 1024
+
+	websocketGUID          = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+	websocketMaxControlLen = 125
+	websocketMaxReadLen    = 64 * 1024
+)
+
+const (
+	wsOpContinuation byte = 0x0
+	wsOpText         byte = 0x1
+	wsOpBinary       byte = 0x2
+	wsOpClose        byte = 0x8
+	wsOpPing         byte = 0x9
+	wsOpPong         byte = 0xA
+)
+
+const wsClosePolicyViolation = 1008
+
+var errWebsocketFrameTooLarge = errors.New("websocket frame is too large")
+
+// WebsocketHandler is an http.Handler which upgrades connections to WebSocket
+// and streams spied logs to them. Every connected client is registered as a subscription.
+type WebsocketHandler struct {
+	source subscriber
+
+	mu    sync.Mutex
+	conns map[*wsConn]struct{}
+
+	sendBuffer   int
+	pingInterval time.Duration
+	writeTimeout time.Duration
+	maxFrameSize int
+	opcode       byte
+	checkOrigin  func(r *http.Request) bool
+	groupFor     func(r *http.Request) *SubscriptionGroup
+	labelsFor    func(r *http.Request) map[string]string
+	adaptive     *AdaptiveEncoding
+	limiter      *ClientLimiter
+	events       bool
+	schema       bool
+	pushdown     FilterPushdown
+}
+
+var _ http.Handler = (*WebsocketHandler)(nil)
+
+type WebsocketOption func(*WebsocketHandler)
+
+// WithWebsocketSendBuffer sets the number of batches queued per connection.
+// Clients which fall behind are disconnected.
+func WithWebsocketSendBuffer(size int) WebsocketOption {
+	return func(h *WebsocketHandler) {
+		h.sendBuffer = size
+	}
+}
+
+// WithWebsocketPingInterval sets the keepalive ping interval.
+// Clients not responding within two intervals are disconnected.
+func WithWebsocketPingInterval(interval time.Duration) WebsocketOption {
+	return func(h *WebsocketHandler) {
+		h.pingInterval = interval
+	}
+}
+
+// WithWebsocketWriteTimeout sets the max time to write a single frame to a client.
+func WithWebsocketWriteTimeout(timeout time.Duration) WebsocketOption {
+	return func(h *WebsocketHandler) {
+		h.writeTimeout = timeout
+	}
+}
+
+// WithWebsocketMaxFrameSize sets the max size of a frame combined from the pending batches
+// when a client falls behind (batches larger than that are sent as is).
+func WithWebsocketMaxFrameSize(size int) WebsocketOption {
+	return func(h *WebsocketHandler) {
+		h.maxFrameSize = size
+	}
+}
+
+// WithWebsocketBinaryFrames makes the handler send logs as binary frames (text frames are used by default).
+func WithWebsocketBinaryFrames() WebsocketOption {
+	return func(h *WebsocketHandler) {
+		h.opcode = wsOpBinary
+	}
+}
+
+// WithWebsocketCheckOrigin sets a function to validate the Origin header of incoming connections.
+// All origins are allowed by default.
+func WithWebsocketCheckOrigin(fn func(r *http.Request) bool) WebsocketOption {
+	return func(h *WebsocketHandler) {
+		h.checkOrigin = fn
+	}
+}
+
+// WithWebsocketGroup sets a function to resolve a subscription group for the incoming connection
+// (e.g., by the dashboard identifier). A nil group means no grouping.
+func WithWebsocketGroup(fn func(r *http.Request) *SubscriptionGroup) WebsocketOption {
+	return func(h *WebsocketHandler) {
+		h.groupFor = fn
+	}
+}
+
+// WithWebsocketLabels sets a function to resolve the labels of the incoming connection's subscription
+// (e.g., the incident identifier from the query), so the connections could be stopped via StopAll.
+func WithWebsocketLabels(fn func(r *http.Request) map[string]string) WebsocketOption {
+	return func(h *WebsocketHandler) {
+		h.labelsFor = fn
+	}
+}
+
+// WithWebsocketAdaptiveEncoding makes connections switch to a compact encoding under load (see WithAdaptiveEncoding).
+// Encoded batches are sent as binary frames, control messages are sent as text frames.
+func WithWebsocketAdaptiveEncoding(config AdaptiveEncoding) WebsocketOption {
+	return func(h *WebsocketHandler) {
+		h.adaptive = &config
+	}
+}
+
+// WithWebsocketEvents makes connections receive lifecycle events (see WithSubscriptionEvents).
+func WithWebsocketEvents() WebsocketOption {
+	return func(h *WebsocketHandler) {
+		h.events = true
+	}
+}
+
+// WithWebsocketSchema makes connections receive the schema dictionary first (see WithSubscriptionSchema).
+func WithWebsocketSchema() WebsocketOption {
+	return func(h *WebsocketHandler) {
+		h.schema = true
+	}
+}
+
+// WithWebsocketRateLimit limits connection attempts and messages sent by clients.
+// Connections are rejected with 429 Too Many Requests, clients sending too many messages are disconnected.
+func WithWebsocketRateLimit(l *ClientLimiter) WebsocketOption {
+	return func(h *WebsocketHandler) {
+		h.limiter = l
+	}
+}
+
+// WithWebsocketFilterPushdown sets which kinds of the client filter conditions are applied by the server
+// (all by default). Clients pass filters via the "filter" (an expression, see CompileFilter) and "level"
+// query parameters; the split is announced via the "pushdown" control message, so clients know which
+// conditions they must apply themselves:
+//
+//	{"type":"control","event":"pushdown","server":"level>=warn","client":"msg=~\"payment\""}
+func WithWebsocketFilterPushdown(p FilterPushdown) WebsocketOption {
+	return func(h *WebsocketHandler) {
+		h.pushdown = p
+	}
+}
+
+// WebsocketHandler creates a new WebsocketHandler for the spy:
+//
+//	go spy.Run(ctx, nil)
+//	http.Handle("/logs", spy.WebsocketHandler())
+func (s *Spy) WebsocketHandler(opts ...WebsocketOption) *WebsocketHandler {
+	return newWebsocketHandler(s.handler, opts...)
+}
+
+func newWebsocketHandler(source subscriber, opts ...WebsocketOption) *WebsocketHandler {
+	h := &WebsocketHandler{
+		source:       source,
+		conns:        make(map[*wsConn]struct{}),
+		sendBuffer:   defaultWebsocketSendBuffer,
+		pingInterval: defaultWebsocketPingInterval,
+		writeTimeout: defaultWebsocketWriteTimeout,
+		maxFrameSize: defaultWebsocketMaxFrameSize,
+		opcode:       wsOpText,
+		pushdown:     FullPushdown,
+	}
+
+	for _, opt := range opts {
+		opt(h)
+	}
+
+	return h
+}
+
+func (h *WebsocketHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h.checkOrigin != nil && !h.checkOrigin(r) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	var clientKey string
+
+	if h.limiter != nil {
+		clientKey = h.limiter.keyFunc(r)
+
+		if ok, _ := h.limiter.take(clientKey); !ok {
+			http.Error(w, "Too Many Requests", http.StatusTooManyRequests)
+			return
+		}
+	}
+
+	serverFilter, clientFilter, err := h.clientFilter(r)
+
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	netConn, rw, err := websocketUpgrade(w, r)
+
+	if err != nil {
+		return
+	}
+
+	c := &wsConn{
+		conn:         netConn,
+		rw:           rw,
+		send:         make(chan wsMessage, h.sendBuffer),
+		done:         make(chan struct{}),
+		writeTimeout: h.writeTimeout,
+		maxFrameSize: h.maxFrameSize,
+		opcode:       h.opcode,
+		limiter:      h.limiter,
+		clientKey:    clientKey,
+	}
+
+	subOpts := []SubscriptionOption{WithSubscriptionControl(c.enqueueControl)}
+
+	if h.adaptive != nil {
+		subOpts = append(subOpts, WithAdaptiveEncoding(*h.adaptive))
+	}
+
+	if h.events {
+		subOpts = append(subOpts, WithSubscriptionEvents())
+	}
+
+	if h.schema {
+		subOpts = append(subOpts, WithSubscriptionSchema())
+	}
+
+	if h.groupFor != nil {
+		if group := h.groupFor(r); group != nil {
+			subOpts = append(subOpts, WithSubscriptionGroup(group))
+		}
+	}
+
+	if h.labelsFor != nil {
+		for key, value := range h.labelsFor(r) {
+			subOpts = append(subOpts, WithSubscriptionLabel(key, value))
+		}
+	}
+
+	if serverFilter != "" {
+		f, _ := CompileFilter(serverFilter)
+		subOpts = append(subOpts, WithSubscriptionFilter(f))
+	}
+
+	h.add(c)
+	defer h.remove(c)
+
+	// The subscription must be assigned before it starts receiving logs
+	c.sub = h.source.newSubscription(c.enqueue, subOpts...)
+
+	if serverFilter != "" || clientFilter != "" {
+		c.sub.sendControl(EventPushdown, map[string]any{"server": serverFilter, "client": clientFilter})
+	}
+
+	h.source.register(c.sub)
+	defer c.sub.Close()
+
+	// Disconnect the client when the subscription ends (e.g., the quota is exhausted)
+	stop := context.AfterFunc(c.sub.Context(), c.Close)
+	defer stop()
+
+	go c.writeLoop(h.pingInterval)
+
+	c.readLoop(2 * h.pingInterval)
+}
+
+// clientFilter returns the parts of the client filter applied by the server and by the client
+func (h *WebsocketHandler) clientFilter(r *http.Request) (string, string, error) {
+	return splitQueryFilter(r.URL.Query(), h.pushdown)
+}
+
+// splitQueryFilter splits the conditions of the level and filter query parameters into the parts applied
+// by the server and by the client according to the pushdown policy
+func splitQueryFilter(query url.Values, p FilterPushdown) (string, string, error) {
+	var server, client string
+
+	if expr := strings.TrimSpace(query.Get("filter")); expr != "" {
+		var err error
+
+		if server, client, err = SplitFilterExpr(expr, p); err != nil {
+			return "", "", err
+		}
+	}
+
+	if level := query.Get("level"); level != "" {
+		var l slog.Level
+
+		if err := l.UnmarshalText([]byte(level)); err != nil {
+			return "", "", err
+		}
+
+		cond := "level>=" + l.String()
+
+		// Level names can't contain minus signs (e.g., DEBUG-4)
+		if strings.Contains(cond, "-") {
+			cond = "level>=" + strconv.Itoa(int(l))
+		}
+
+		if p.Level {
+			server = andExpr(cond, server)
+		} else {
+			client = andExpr(cond, client)
+		}
+	}
+
+	return server, client, nil
+}
+
+// Close disconnects all clients.
+func (h *WebsocketHandler) Close() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for c := range h.conns {
+		c.Close()
+	}
+}
+
+func (h *WebsocketHandler) add(c *wsConn) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.conns[c] = struct{}{}
+}
+
+func (h *WebsocketHandler) remove(c *wsConn) {
+	c.Close()
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	delete(h.conns, c)
+}
+
+type wsConn struct {
+	conn net.Conn
+	rw   *bufio.ReadWriter
+	sub  *Subscription
+
+	send   chan wsMessage
+	done   chan struct{}
+	opcode byte
+
+	limiter   *ClientLimiter
+	clientKey string
+
+	writeMu      sync.Mutex
+	writeTimeout time.Duration
+	closeOnce    sync.Once
+
+	maxFrameSize int
+	// pending is the message taken from the send buffer but not merged into the previous frame
+	pending *wsMessage
+}
+
+func (c *wsConn) Close() {
+	c.closeOnce.Do(func() {
+		close(c.done)
+		c.conn.Close()
+	})
+}
+
+type wsMessage struct {
+	opcode  byte
+	payload []byte
+	// mergeable is set for plain (not encoded) batches which could be concatenated
+	mergeable bool
+}
+
+// enqueue queues the batch for sending; slow clients with a full send buffer are disconnected
+func (c *wsConn) enqueue(msg []byte) {
+	if c.sub.Encoding() != "" || IsCompressed(msg) {
+		c.enqueueFrame(wsOpBinary, msg, false)
+		return
+	}
+
+	// Protobuf batches could be concatenated, too
+	if IsProtobuf(msg) {
+		c.enqueueFrame(wsOpBinary, msg, true)
+		return
+	}
+
+	c.enqueueFrame(c.opcode, msg, true)
+}
+
+func (c *wsConn) enqueueControl(msg []byte) {
+	c.enqueueFrame(wsOpText, msg, false)
+}
+
+func (c *wsConn) enqueueFrame(opcode byte, msg []byte, mergeable bool) {
+	// The message buffer is reused by the spy after the output returns
+	payload := acquirePayload(msg)
+
+	select {
+	case c.send <- wsMessage{opcode, payload, mergeable}:
+	default:
+		// We're called from the subscription output, and closing the subscription emits the end event
+		// via the same output, so it must be done from another goroutine
+		go c.sub.Close()
+		c.Close()
+	}
+}
+
+func (c *wsConn) writeLoop(pingInterval time.Duration) {
+	ticker := time.NewTicker(pingInterval)
+	defer ticker.Stop()
+	defer c.Close()
+
+	for {
+		if c.pending != nil {
+			msg := c.merge(*c.pending)
+
+			if err := c.writeMessage(msg); err != nil {
+				return
+			}
+			continue
+		}
+
+		select {
+		case <-c.done:
+			return
+		case msg := <-c.send:
+			if err := c.writeMessage(c.merge(msg)); err != nil {
+				return
+			}
+		case <-ticker.C:
+			if err := c.write(wsOpPing, nil); err != nil {
+				return
+			}
+		}
+	}
+}
+
+// merge combines the batches queued after the message into a single frame (up to the max frame size),
+// so clients catching up after a stall receive fewer larger frames
+func (c *wsConn) merge(msg wsMessage) wsMessage {
+	c.pending = nil
+
+	if !msg.mergeable {
+		return msg
+	}
+
+	for {
+		select {
+		case next := <-c.send:
+			if !next.mergeable || next.opcode != msg.opcode || len(msg.payload)+len(next.payload) > c.maxFrameSize {
+				c.pending = &next
+				return msg
+			}
+
+			msg.payload = append(msg.payload, next.payload...)
+			releasePayload(next.payload)
+		default:
+			return msg
+		}
+	}
+}
+
+func (c *wsConn) readLoop(pongWait time.Duration) {
+	for {
+		c.conn.SetReadDeadline(time.Now().Add(pongWait)) // nolint: errcheck
+
+		opcode, payload, err := readWebsocketFrame(c.rw.Reader)
+
+		if err != nil {
+			return
+		}
+
+		// Pongs are responses to our pings, all the other messages are rate-limited
+		if opcode != wsOpPong && c.limiter != nil && !c.limiter.Allow(c.clientKey) {
+			c.write(wsOpClose, wsClosePayload(wsClosePolicyViolation, "rate limit exceeded")) // nolint: errcheck
+			return
+		}
+
+		switch opcode {
+		case wsOpPing:
+			if err := c.write(wsOpPong, payload); err != nil {
+				return
+			}
+		case wsOpClose:
+			c.write(wsOpClose, payload) // nolint: errcheck
+			return
+		}
+	}
+}
+
+func (c *wsConn) writeMessage(msg wsMessage) error {
+	defer releasePayload(msg.payload)
+
+	return c.write(msg.opcode, msg.payload)
+}
+
+func (c *wsConn) write(opcode byte, payload []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+
+	c.conn.SetWriteDeadline(time.Now().Add(c.writeTimeout)) // nolint: errcheck
+
+	if err := writeWebsocketFrame(c.rw.Writer, opcode, payload, nil); err != nil {
+		return err
+	}
+
+	return c.rw.Flush()
+}
+
+// wsMaxPooledPayload is the max capacity of a payload buffer returned to the pool
+const wsMaxPooledPayload = 256 * 1024
+
+var wsPayloadPool = sync.Pool{New: func() any { return new([]byte) }}
+
+// acquirePayload returns a pooled copy of the message
+func acquirePayload(msg []byte) []byte {
+	buf := wsPayloadPool.Get().(*[]byte)
+
+	return append((*buf)[:0], msg...)
+}
+
+func releasePayload(payload []byte) {
+	if cap(payload) > wsMaxPooledPayload {
+		return
+	}
+
+	payload = payload[:0]
+	wsPayloadPool.Put(&payload)
+}
+
+func websocketUpgrade(w http.ResponseWriter, r *http.Request) (net.Conn, *bufio.ReadWriter, error) {
+	if r.Method != http.MethodGet ||
+		!headerContainsToken(r.Header, "Connection", "upgrade") ||
+		!headerContainsToken(r.Header, "Upgrade", "websocket") {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return nil, nil, errors.New("not a websocket handshake")
+	}
+
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		http.Error(w, "Upgrade Required", http.StatusUpgradeRequired)
+		return nil, nil, errors.New("unsupported websocket version")
+	}
+
+	key := r.Header.Get("Sec-WebSocket-Key")
+
+	if key == "" {
+		http.Error(w, "Bad Request", http.StatusBadRequest)
+		return nil, nil, errors.New("missing websocket key")
+	}
+
+	hijacker, ok := w.(http.Hijacker)
+
+	if !ok {
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return nil, nil, errors.New("response writer doesn't support hijacking")
+	}
+
+	conn, rw, err := hijacker.Hijack()
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rw.WriteString("HTTP/1.1 101 Switching Protocols\r\n")                   // nolint: errcheck
+	rw.WriteString("Upgrade: websocket\r\nConnection: Upgrade\r\n")          // nolint: errcheck
+	rw.WriteString("Sec-WebSocket-Accept: " + websocketAccept(key) + "\r\n") // nolint: errcheck
+	rw.WriteString("\r\n")                                                   // nolint: errcheck
+
+	if err := rw.Flush(); err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+
+	return conn, rw, nil
+}
+
+func wsClosePayload(code uint16, reason string) []byte {
+	return append(binary.BigEndian.AppendUint16(nil, code), reason...)
+}
+
+func websocketAccept(key string) string {
+	h := sha1.New() // nolint: gosec
+	h.Write([]byte(key + websocketGUID))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+func headerContainsToken(header http.Header, name string, token string) bool {
+	for _, value := range header.Values(name) {
+		for _, part := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(part), token) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// writeWebsocketFrame writes a single final frame; the payload is masked if the mask is provided (client-to-server frames).
+func writeWebsocketFrame(w io.Writer, opcode byte, payload []byte, mask []byte) error {
+	header := make([]byte, 2, 14)
+	header[0] = 0x80 | opcode
+
+	var maskBit byte
+
+	if mask != nil {
+		maskBit = 0x80
+	}
+
+	switch n := len(payload); {
+	case n <= websocketMaxControlLen:
+		header[1] = maskBit | byte(n)
+	case n <= 0xFFFF:
+		header[1] = maskBit | 126
+		header = binary.BigEndian.AppendUint16(header, uint16(n))
+	default:
+		header[1] = maskBit | 127
+		header = binary.BigEndian.AppendUint64(header, uint64(n))
+	}
+
+	if mask != nil {
+		header = append(header, mask[:4]...)
+
+		masked := make([]byte, len(payload))
+		for i, b := range payload {
+			masked[i] = b ^ mask[i%4]
+		}
+		payload = masked
+	}
+
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+
+	_, err := w.Write(payload)
+	return err
+}
+
+// readWebsocketFrame reads a complete (possibly fragmented) message or a control frame.
+func readWebsocketFrame(r *bufio.Reader) (byte, []byte, error) {
+	var (
+		opcode  byte
+		message []byte
+	)
+
+	for {
+		fin, op, payload, err := readWebsocketFragment(r)
+
+		if err != nil {
+			return 0, nil, err
+		}
+
+		// Control frames may be interleaved with fragments
+		if op >= wsOpClose {
+			return op, payload, nil
+		}
+
+		if op != wsOpContinuation {
+			opcode = op
+		}
+
+		if len(message)+len(payload) > websocketMaxReadLen {
+			return 0, nil, errWebsocketFrameTooLarge
+		}
+
+		message = append(message, payload...)
+
+		if fin {
+			return opcode, message, nil
+		}
+	}
+}
+
+func readWebsocketFragment(r *bufio.Reader) (bool, byte, []byte, error) {
+	var header [2]byte
+
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return false, 0, nil, err
+	}
+
+	fin := header[0]&0x80 != 0
+	opcode := header[0] & 0x0F
+	masked := header[1]&0x80 != 0
+	length := uint64(header[1] & 0x7F)
+
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(r, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(r, ext[:]); err != nil {
+			return false, 0, nil, err
+		}
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+
+	if length > websocketMaxReadLen {
+		return false, 0, nil, errWebsocketFrameTooLarge
+	}
+
+	var mask [4]byte
+
+	if masked {
+		if _, err := io.ReadFull(r, mask[:]); err != nil {
+			return false, 0, nil, err
+		}
+	}
+
+	payload := make([]byte, length)
+
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return false, 0, nil, err
+	}
+
+	if masked {
+		for i := range payload {
+			payload[i] ^= mask[i%4]
+		}
+	}
+
+	return fin, opcode, payload, nil
+}
@@ -0,0 +1,309 @@
+package slogspy
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWebsocketHandler__Broadcast(t *testing.T) {
+	spy := NewSpy(slog.NewTextHandler(&bytes.Buffer{}, nil), WithFlushInterval(10*time.Millisecond))
+	ws := spy.WebsocketHandler()
+
+	go spy.Run(context.Background(), nil)
+	defer spy.Shutdown(context.Background())
+
+	server := httptest.NewServer(ws)
+	defer server.Close()
+
+	conn, rw := dialWebsocket(t, server.URL)
+	defer conn.Close()
+
+	waitFor(t, func() bool { return spy.handler.active.Load() == 1 })
+
+	logger := slog.New(spy)
+	logger.Debug("websocket-test")
+
+	conn.SetReadDeadline(time.Now().Add(time.Second)) // nolint: errcheck
+	opcode, payload, err := readWebsocketFrame(rw.Reader)
+
+	if err != nil {
+		t.Fatalf("failed to read frame: %v", err)
+	}
+
+	if opcode != wsOpText {
+		t.Errorf("expected text frame, got opcode %d", opcode)
+	}
+
+	if !strings.Contains(string(payload), "websocket-test") {
+		t.Errorf("expected payload to contain websocket-test, got %s", payload)
+	}
+
+	writeWebsocketFrame(rw.Writer, wsOpClose, nil, []byte{1, 2, 3, 4}) // nolint: errcheck
+	rw.Flush()                                                         // nolint: errcheck
+
+	waitFor(t, func() bool { return spy.handler.active.Load() == 0 })
+}
+
+func TestWebsocketHandler__SlowClient(t *testing.T) {
+	spy := NewSpy(slog.NewTextHandler(&bytes.Buffer{}, nil))
+	ws := spy.WebsocketHandler(WithWebsocketSendBuffer(1))
+
+	server := httptest.NewServer(ws)
+	defer server.Close()
+
+	conn, _ := dialWebsocket(t, server.URL)
+	defer conn.Close()
+
+	waitFor(t, func() bool { return spy.handler.active.Load() == 1 })
+
+	// We never read from the connection, so the send buffer eventually overflows
+	payload := bytes.Repeat([]byte("x"), 1024*1024)
+	for i := 0; i < 50; i++ {
+		spy.handler.subs.deliver(payload, 1)
+	}
+
+	waitFor(t, func() bool { return spy.handler.active.Load() == 0 })
+}
+
+func TestWebsocketHandler__SlowClient_Events(t *testing.T) {
+	spy := NewSpy(slog.NewTextHandler(&bytes.Buffer{}, nil))
+	ws := spy.WebsocketHandler(WithWebsocketSendBuffer(1), WithWebsocketEvents())
+
+	server := httptest.NewServer(ws)
+	defer server.Close()
+
+	conn, _ := dialWebsocket(t, server.URL)
+	defer conn.Close()
+
+	waitFor(t, func() bool { return spy.handler.active.Load() == 1 })
+
+	delivered := make(chan struct{})
+
+	// Closing the slow client emits the end event, which must not deadlock the delivery
+	go func() {
+		defer close(delivered)
+
+		payload := bytes.Repeat([]byte("x"), 1024*1024)
+		for i := 0; i < 50; i++ {
+			spy.handler.subs.deliver(payload, 1)
+		}
+	}()
+
+	select {
+	case <-delivered:
+	case <-time.After(5 * time.Second):
+		t.Fatal("delivery to the slow client is stuck")
+	}
+
+	waitFor(t, func() bool { return spy.handler.active.Load() == 0 })
+}
+
+func TestWebsocketHandler__Group(t *testing.T) {
+	spy := NewSpy(slog.NewTextHandler(&bytes.Buffer{}, nil))
+	group := NewSubscriptionGroup("dashboard", Quota{})
+
+	ws := spy.WebsocketHandler(WithWebsocketGroup(func(r *http.Request) *SubscriptionGroup {
+		if r.URL.Query().Get("dashboard") == "1" {
+			return group
+		}
+		return nil
+	}))
+
+	server := httptest.NewServer(ws)
+	defer server.Close()
+
+	conn, _ := dialWebsocket(t, server.URL+"/?dashboard=1")
+	defer conn.Close()
+
+	conn2, _ := dialWebsocket(t, server.URL)
+	defer conn2.Close()
+
+	waitFor(t, func() bool { return spy.handler.active.Load() == 2 })
+
+	if group.Size() != 1 {
+		t.Errorf("expected group to have 1 subscription, got %d", group.Size())
+	}
+}
+
+func TestWebsocketHandler__Labels(t *testing.T) {
+	spy := NewSpy(slog.NewTextHandler(&bytes.Buffer{}, nil))
+
+	ws := spy.WebsocketHandler(WithWebsocketLabels(func(r *http.Request) map[string]string {
+		if incident := r.URL.Query().Get("incident"); incident != "" {
+			return map[string]string{"incident": incident}
+		}
+		return nil
+	}))
+
+	server := httptest.NewServer(ws)
+	defer server.Close()
+
+	conn, _ := dialWebsocket(t, server.URL+"/?incident=INC-1")
+	defer conn.Close()
+
+	conn2, _ := dialWebsocket(t, server.URL)
+	defer conn2.Close()
+
+	waitFor(t, func() bool { return spy.handler.active.Load() == 2 })
+
+	if stopped := StopAll("incident=INC-1"); stopped != 1 {
+		t.Errorf("expected 1 connection to be stopped, got %d", stopped)
+	}
+
+	waitFor(t, func() bool { return spy.handler.active.Load() == 1 })
+}
+
+func TestWebsocketHandler__NotUpgrade(t *testing.T) {
+	spy := NewSpy(slog.NewTextHandler(&bytes.Buffer{}, nil))
+
+	server := httptest.NewServer(spy.WebsocketHandler())
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected 400, got %d", resp.StatusCode)
+	}
+}
+
+func dialWebsocket(t *testing.T, url string) (net.Conn, *bufio.ReadWriter) {
+	t.Helper()
+
+	req, _ := http.NewRequest("GET", url, nil)
+
+	conn, err := net.Dial("tcp", req.URL.Host)
+
+	if err != nil {
+		t.Fatalf("failed to dial: %v", err)
+	}
+
+	key := "dGhlIHNhbXBsZSBub25jZQ=="
+
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Key", key)
+
+	if err := req.Write(conn); err != nil {
+		t.Fatalf("failed to write handshake: %v", err)
+	}
+
+	rw := bufio.NewReadWriter(bufio.NewReader(conn), bufio.NewWriter(conn))
+
+	resp, err := http.ReadResponse(rw.Reader, req)
+
+	if err != nil {
+		t.Fatalf("failed to read handshake: %v", err)
+	}
+
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("expected 101, got %d", resp.StatusCode)
+	}
+
+	if resp.Header.Get("Sec-WebSocket-Accept") != websocketAccept(key) {
+		t.Fatalf("invalid accept header: %s", resp.Header.Get("Sec-WebSocket-Accept"))
+	}
+
+	return conn, rw
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+
+	deadline := time.Now().Add(time.Second)
+
+	for time.Now().Before(deadline) {
+		if cond() {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	t.Fatal("timed out waiting for condition")
+}
+
+func TestWebsocketHandler__RateLimit(t *testing.T) {
+	spy := NewSpy(slog.NewTextHandler(&bytes.Buffer{}, nil))
+	ws := spy.WebsocketHandler(WithWebsocketRateLimit(NewClientLimiter(0.001, 2)))
+
+	server := httptest.NewServer(ws)
+	defer server.Close()
+
+	conn, rw := dialWebsocket(t, server.URL)
+	defer conn.Close()
+
+	// The second message exceeds the limit
+	for i := 0; i < 2; i++ {
+		writeWebsocketFrame(rw.Writer, wsOpText, []byte("hello"), []byte{1, 2, 3, 4}) // nolint: errcheck
+	}
+	rw.Flush() // nolint: errcheck
+
+	conn.SetReadDeadline(time.Now().Add(time.Second)) // nolint: errcheck
+	opcode, payload, err := readWebsocketFrame(rw.Reader)
+
+	if err != nil || opcode != wsOpClose || binary.BigEndian.Uint16(payload) != wsClosePolicyViolation {
+		t.Errorf("expected policy violation close frame, got opcode %d (%v)", opcode, err)
+	}
+
+	resp, err := http.Get(server.URL)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("expected new connection to be rejected with 429, got %d", resp.StatusCode)
+	}
+}
+
+func TestWebsocketConn__Merge(t *testing.T) {
+	c := &wsConn{send: make(chan wsMessage, 8), maxFrameSize: 10}
+
+	c.send <- wsMessage{wsOpText, []byte("b\n"), true}
+	c.send <- wsMessage{wsOpText, []byte("c\n"), true}
+	c.send <- wsMessage{wsOpText, []byte(`{"type":"control"}`), false}
+	c.send <- wsMessage{wsOpText, []byte("d\n"), true}
+	c.send <- wsMessage{wsOpText, []byte("too-large\n"), true}
+
+	msg := c.merge(wsMessage{wsOpText, []byte("a\n"), true})
+
+	if string(msg.payload) != "a\nb\nc\n" {
+		t.Errorf("expected pending batches to be merged, got %q", msg.payload)
+	}
+
+	// Control messages are never merged
+	msg = c.merge(*c.pending)
+
+	if string(msg.payload) != `{"type":"control"}` || c.pending != nil {
+		t.Errorf("expected control message, got %q", msg.payload)
+	}
+
+	msg = c.merge(<-c.send)
+
+	if string(msg.payload) != "d\n" {
+		t.Errorf("expected merged frame to respect the max frame size, got %q", msg.payload)
+	}
+
+	msg = c.merge(*c.pending)
+
+	if string(msg.payload) != "too-large\n" || c.pending != nil {
+		t.Errorf("expected the last batch, got %q", msg.payload)
+	}
+}